@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -14,8 +15,10 @@ import (
 
 func main() {
 	command := newCommand()
-	code := cli.Run(command)
-	os.Exit(code)
+	if err := cli.RunNoErrOutput(command); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(cmds.ExitCode(err))
+	}
 }
 
 func newCommand() *cobra.Command {
@@ -38,5 +41,16 @@ func newCommand() *cobra.Command {
 	cmd.AddCommand(cmds.NewRunMicroshiftCommand())
 	cmd.AddCommand(cmds.NewVersionCommand(ioStreams))
 	cmd.AddCommand(cmds.NewShowConfigCommand(ioStreams))
+	cmd.AddCommand(cmds.NewConfigCommand(ioStreams))
+	cmd.AddCommand(cmds.NewInspectCommand(ioStreams))
+	cmd.AddCommand(cmds.NewTopologyCommand(ioStreams))
+	cmd.AddCommand(cmds.NewWaitForReadyCommand(ioStreams))
+	cmd.AddCommand(cmds.NewListServicesCommand(ioStreams))
+	cmd.AddCommand(cmds.NewKubeconfigsCommand(ioStreams))
+	cmd.AddCommand(cmds.NewKubeconfigCommand(ioStreams))
+	cmd.AddCommand(cmds.NewDiagnosticsCommand(ioStreams))
+	cmd.AddCommand(cmds.NewLogsCommand(ioStreams))
+	cmd.AddCommand(cmds.NewUpgradeCommand(ioStreams))
+	cmd.AddCommand(cmds.NewGenerateSystemdCommand(ioStreams))
 	return cmd
 }
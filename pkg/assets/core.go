@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	embedded "github.com/openshift/microshift/assets"
+	"github.com/openshift/microshift/pkg/config"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -29,8 +30,9 @@ func init() {
 }
 
 type nsApplier struct {
-	Client *coreclientv1.CoreV1Client
-	ns     *corev1.Namespace
+	Client   coreclientv1.NamespacesGetter
+	ns       *corev1.Namespace
+	metadata config.NamespacesConfig
 }
 
 func coreClient(kubeconfigPath string) *coreclientv1.CoreV1Client {
@@ -55,6 +57,24 @@ func (ns *nsApplier) Reader(objBytes []byte, render RenderFunc, params RenderPar
 		panic(err)
 	}
 	ns.ns = obj.(*corev1.Namespace)
+	ns.ns.Labels = mergeMetadata(ns.metadata.Labels, ns.ns.Labels)
+	ns.ns.Annotations = mergeMetadata(ns.metadata.Annotations, ns.ns.Annotations)
+}
+
+// mergeMetadata returns the configured labels or annotations overridden by the ones
+// of the asset
+func mergeMetadata(configured, asset map[string]string) map[string]string {
+	if len(configured) == 0 {
+		return asset
+	}
+	merged := map[string]string{}
+	for k, v := range configured {
+		merged[k] = v
+	}
+	for k, v := range asset {
+		merged[k] = v
+	}
+	return merged
 }
 
 func (ns *nsApplier) Applier() error {
@@ -182,8 +202,8 @@ func applyCore(cores []string, applier readerApplier, render RenderFunc, params
 	return nil
 }
 
-func ApplyNamespaces(cores []string, kubeconfigPath string) error {
-	ns := &nsApplier{}
+func ApplyNamespaces(cores []string, metadata config.NamespacesConfig, kubeconfigPath string) error {
+	ns := &nsApplier{metadata: metadata}
 	ns.Client = coreClient(kubeconfigPath)
 	return applyCore(cores, ns, nil, nil)
 }
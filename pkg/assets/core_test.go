@@ -0,0 +1,49 @@
+package assets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyNamespacesMetadata(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ns := &nsApplier{
+		Client: client.CoreV1(),
+		metadata: config.NamespacesConfig{
+			Labels: map[string]string{
+				"site":                               "edge-1",
+				"pod-security.kubernetes.io/enforce": "restricted",
+			},
+			Annotations: map[string]string{"example.com/owner": "platform"},
+		},
+	}
+	if err := applyCore([]string{"components/ovn/namespace.yaml", "components/service-ca/ns.yaml"}, ns, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"openshift-ovn-kubernetes", "openshift-service-ca"} {
+		created, err := client.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created.Labels["site"] != "edge-1" || created.Annotations["example.com/owner"] != "platform" {
+			t.Errorf("expected namespace %s to carry the configured metadata, got labels %v and annotations %v", name, created.Labels, created.Annotations)
+		}
+	}
+
+	// the namespaces' own labels take precedence
+	ovn, err := client.CoreV1().Namespaces().Get(context.Background(), "openshift-ovn-kubernetes", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ovn.Labels["pod-security.kubernetes.io/enforce"]; got != "privileged" {
+		t.Errorf("expected the OVN namespace to keep its pod security level, got %q", got)
+	}
+	if got := ovn.Annotations["openshift.io/description"]; got != "OVN Kubernetes components" {
+		t.Errorf("expected the OVN namespace to keep its annotations, got %v", ovn.Annotations)
+	}
+}
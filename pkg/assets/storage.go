@@ -7,6 +7,7 @@ import (
 	embedded "github.com/openshift/microshift/assets"
 
 	scv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	scclientv1 "k8s.io/client-go/kubernetes/typed/storage/v1"
@@ -22,6 +23,15 @@ var (
 	scCodecs = serializer.NewCodecFactory(scScheme)
 )
 
+const (
+	// isDefaultClassAnnotation marks the storage class of claims without a storageClassName.
+	isDefaultClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+	// managedClassLabel marks the storage classes MicroShift creates.
+	managedClassLabel = "microshift.openshift.io/managed-storage-class"
+	// legacyClassName is the class created by the versions that didn't label it.
+	legacyClassName = "topolvm-provisioner"
+)
+
 func init() {
 	if err := scv1.AddToScheme(scScheme); err != nil {
 		panic(err)
@@ -38,7 +48,7 @@ func scClient(kubeconfigPath string) *scclientv1.StorageV1Client {
 }
 
 type scApplier struct {
-	Client *scclientv1.StorageV1Client
+	Client scclientv1.StorageV1Interface
 	sc     *scv1.StorageClass
 }
 
@@ -57,8 +67,39 @@ func (s *scApplier) Reader(objBytes []byte, render RenderFunc, params RenderPara
 	s.sc = obj.(*scv1.StorageClass)
 }
 func (s *scApplier) Applier() error {
-	_, _, err := resourceapply.ApplyStorageClass(context.TODO(), s.Client, assetsEventRecorder, s.sc)
-	return err
+	if _, _, err := resourceapply.ApplyStorageClass(context.TODO(), s.Client, assetsEventRecorder, s.sc); err != nil {
+		return err
+	}
+	return s.removePreviousDefault()
+}
+
+// removePreviousDefault removes the default annotation from the classes MicroShift created under a previous
+// name when the applied class is the default, so that the cluster doesn't end up with several default classes.
+// Classes created by others are left alone.
+func (s *scApplier) removePreviousDefault() error {
+	if s.sc.Labels[managedClassLabel] != "true" || s.sc.Annotations[isDefaultClassAnnotation] != "true" {
+		return nil
+	}
+	classes, err := s.Client.StorageClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range classes.Items {
+		class := &classes.Items[i]
+		managed := class.Labels[managedClassLabel] == "true" || (class.Name == legacyClassName && class.Provisioner == s.sc.Provisioner)
+		if class.Name == s.sc.Name || !managed {
+			continue
+		}
+		if _, ok := class.Annotations[isDefaultClassAnnotation]; !ok {
+			continue
+		}
+		klog.Infof("Removing the default annotation from the previous storage class %s", class.Name)
+		delete(class.Annotations, isDefaultClassAnnotation)
+		if _, err := s.Client.StorageClasses().Update(context.TODO(), class, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to remove the default annotation from storage class %s: %v", class.Name, err)
+		}
+	}
+	return nil
 }
 
 func applySCs(scs []string, applier readerApplier, render RenderFunc, params RenderParams) error {
@@ -0,0 +1,76 @@
+package assets
+
+import (
+	"context"
+	"testing"
+
+	scv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestClass(name, provisioner string, labels, annotations map[string]string) *scv1.StorageClass {
+	return &scv1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Labels: labels, Annotations: annotations},
+		Provisioner: provisioner,
+	}
+}
+
+func TestApplyStorageClassRemovesPreviousDefault(t *testing.T) {
+	managed := map[string]string{managedClassLabel: "true"}
+	setup := func(defaultClass string) (*fake.Clientset, *scApplier) {
+		client := fake.NewSimpleClientset(
+			newTestClass("previous-lvm", "topolvm.cybozu.com", managed, map[string]string{isDefaultClassAnnotation: "true", "example.com/owner": "platform"}),
+			newTestClass(legacyClassName, "topolvm.cybozu.com", nil, map[string]string{isDefaultClassAnnotation: "true"}),
+			newTestClass("user-lvm", "topolvm.cybozu.com", nil, map[string]string{isDefaultClassAnnotation: "true"}),
+		)
+		sc := &scApplier{
+			Client: client.StorageV1(),
+			sc:     newTestClass("local-lvm", "topolvm.cybozu.com", managed, map[string]string{isDefaultClassAnnotation: defaultClass}),
+		}
+		return client, sc
+	}
+	isDefaultClass := func(t *testing.T, client *fake.Clientset, name string) bool {
+		c, err := client.StorageV1().StorageClasses().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ok := c.Annotations[isDefaultClassAnnotation]
+		return ok
+	}
+
+	client, sc := setup("true")
+	if err := sc.Applier(); err != nil {
+		t.Fatal(err)
+	}
+	if !isDefaultClass(t, client, "local-lvm") {
+		t.Errorf("expected the renamed class to be the default")
+	}
+	for _, name := range []string{"previous-lvm", legacyClassName} {
+		if isDefaultClass(t, client, name) {
+			t.Errorf("expected the default annotation to be removed from the previous class %s", name)
+		}
+	}
+	previous, err := client.StorageV1().StorageClasses().Get(context.Background(), "previous-lvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.Annotations["example.com/owner"] != "platform" {
+		t.Errorf("expected the previous class to keep its other annotations, got %v", previous.Annotations)
+	}
+	// classes MicroShift didn't create are left alone, even of the same provisioner
+	if !isDefaultClass(t, client, "user-lvm") {
+		t.Errorf("expected the class created by the user to keep its default annotation")
+	}
+
+	// other classes stay the default while MicroShift's class isn't
+	client, sc = setup("false")
+	if err := sc.Applier(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"previous-lvm", legacyClassName, "user-lvm"} {
+		if !isDefaultClass(t, client, name) {
+			t.Errorf("expected %s to keep its default annotation while the applied class isn't the default", name)
+		}
+	}
+}
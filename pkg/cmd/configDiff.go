@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+type configDiffOptions struct {
+	Output string
+	genericclioptions.IOStreams
+}
+
+// fieldDiff is a config field whose resolved value differs from the default
+type fieldDiff struct {
+	Field   string      `json:"field"`
+	Default interface{} `json:"default"`
+	Value   interface{} `json:"value"`
+}
+
+func NewConfigCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect MicroShift's configuration",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewConfigDiffCommand(ioStreams))
+	return cmd
+}
+
+func NewConfigDiffCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := configDiffOptions{
+		Output:    "yaml",
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Print the fields of the resolved configuration that differ from the defaults",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.Output, "output", "o", opts.Output, "One of 'yaml' or 'json'.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *configDiffOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if opts.Output != "yaml" && opts.Output != "json" {
+		return fmt.Errorf("unknown output format %q", opts.Output)
+	}
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+
+	diffs, err := configDiff(config.NewMicroshiftConfig(), cfg)
+	if err != nil {
+		return err
+	}
+
+	var marshalled []byte
+	if opts.Output == "json" {
+		marshalled, err = json.MarshalIndent(diffs, "", "  ")
+	} else {
+		marshalled, err = yaml.Marshal(diffs)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.Out, "%s\n", string(marshalled))
+	return nil
+}
+
+// configDiff returns the fields of cfg that differ from defaults, sorted by field path.
+func configDiff(defaults, cfg *config.MicroshiftConfig) ([]fieldDiff, error) {
+	defaultFields, err := toFieldMap(defaults)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := toFieldMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := []fieldDiff{}
+	diffFields("", defaultFields, fields, &diffs)
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs, nil
+}
+
+func toFieldMap(cfg *config.MicroshiftConfig) (map[string]interface{}, error) {
+	marshalled, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(marshalled, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffFields recurses into nested objects, anything else, e.g. lists, is compared as a whole.
+func diffFields(prefix string, defaults, values map[string]interface{}, diffs *[]fieldDiff) {
+	keys := map[string]struct{}{}
+	for k := range defaults {
+		keys[k] = struct{}{}
+	}
+	for k := range values {
+		keys[k] = struct{}{}
+	}
+
+	for k := range keys {
+		field := k
+		if prefix != "" {
+			field = prefix + "." + k
+		}
+		d, v := defaults[k], values[k]
+		dMap, dIsMap := d.(map[string]interface{})
+		vMap, vIsMap := v.(map[string]interface{})
+		if dIsMap && vIsMap {
+			diffFields(field, dMap, vMap, diffs)
+			continue
+		}
+		if !reflect.DeepEqual(d, v) {
+			*diffs = append(*diffs, fieldDiff{Field: field, Default: d, Value: v})
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestConfigDiff(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(`
+apiVersion: microshift.openshift.io/v1beta1
+kind: MicroShiftConfig
+cluster:
+  mtu: "1300"
+  domain: cluster.local
+roles:
+- controlplane
+apiServer:
+  auditLogFormat: legacy
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	cmd := NewConfigDiffCommand(genericclioptions.IOStreams{Out: out, ErrOut: out})
+	cmd.SetArgs([]string{"--config", configFile, "--output", "json", "--cluster-cidr", "10.50.0.0/16"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config diff failed: %v", err)
+	}
+
+	var diffs []fieldDiff
+	if err := json.Unmarshal(out.Bytes(), &diffs); err != nil {
+		t.Fatalf("failed to parse output %q: %v", out.String(), err)
+	}
+
+	// the domain matches the default and is not reported
+	expected := []fieldDiff{
+		{Field: "apiServer.auditLogFormat", Default: "json", Value: "legacy"},
+		{Field: "cluster.clusterCIDR", Default: "10.42.0.0/16", Value: "10.50.0.0/16"},
+		{Field: "cluster.mtu", Default: "1400", Value: "1300"},
+		{Field: "roles", Default: []interface{}{"controlplane", "node"}, Value: []interface{}{"controlplane"}},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected diff %+v, got %+v", expected, diffs)
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/openshift/microshift/pkg/util/sigchannel"
+	"k8s.io/klog/v2"
+)
+
+const (
+	topologyPath    = "/topology"
+	kubeconfigsPath = "/kubeconfigs"
+	readyzPath      = "/readyz"
+)
+
+// readiness is the aggregate readiness of MicroShift, which is ready once all services
+// are ready, or degraded for optional ones, and it notified systemd of its readiness.
+type readiness struct {
+	Ready bool `json:"ready"`
+	// Waiting are the services that are not ready yet
+	Waiting []string `json:"waiting"`
+	// Failed are the critical services that failed or stopped before MicroShift was ready
+	Failed []string `json:"failed"`
+}
+
+// readinessOf aggregates the states of the services. MicroShift is only ready once it
+// passed the same checks as the systemd readiness notification, e.g. --ready-delay and
+// --ready-dns-timeout, which notified reports. Until then, critical services that
+// stopped count as failed, while after it they are services that completed.
+func readinessOf(statuses []servicemanager.ServiceStatus, notified bool) readiness {
+	waiting, failed := []string{}, []string{}
+	for _, s := range statuses {
+		switch {
+		case s.State == servicemanager.StatePending || s.State == servicemanager.StateStarting:
+			waiting = append(waiting, s.Name)
+		case s.Optional:
+		case s.State == servicemanager.StateFailed, s.State == servicemanager.StateStopped && !notified:
+			failed = append(failed, s.Name)
+		}
+	}
+	return readiness{Ready: notified && len(waiting) == 0 && len(failed) == 0, Waiting: waiting, Failed: failed}
+}
+
+// serveControlSocket reports the state of the manager's services and the kubeconfigs
+// generated during init on a unix socket until ctx is done. notified is closed once
+// MicroShift notified systemd of its readiness.
+func serveControlSocket(ctx context.Context, path string, m *servicemanager.ServiceManager, kubeconfigs []kubeconfigInfo, notified <-chan struct{}) error {
+	// a socket left behind by a previous instance prevents listening
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	if kubeconfigs == nil {
+		kubeconfigs = []kubeconfigInfo{}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(topologyPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.Status()); err != nil {
+			klog.Warningf("failed to write service status: %v", err)
+		}
+	})
+	mux.HandleFunc(kubeconfigsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(kubeconfigs); err != nil {
+			klog.Warningf("failed to write kubeconfigs: %v", err)
+		}
+	})
+	mux.HandleFunc(readyzPath, func(w http.ResponseWriter, r *http.Request) {
+		readiness := readinessOf(m.Status(), sigchannel.IsClosed(notified))
+		w.Header().Set("Content-Type", "application/json")
+		if !readiness.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(readiness); err != nil {
+			klog.Warningf("failed to write readiness: %v", err)
+		}
+	})
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("control socket %s failed: %v", path, err)
+		}
+	}()
+	return nil
+}
+
+// getTopology retrieves the state of the services from the instance listening on the
+// control socket.
+func getTopology(path string) ([]servicemanager.ServiceStatus, error) {
+	statuses := []servicemanager.ServiceStatus{}
+	if err := getFromControlSocket(path, topologyPath, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// getKubeconfigs retrieves the kubeconfigs generated by the instance listening on the
+// control socket.
+func getKubeconfigs(path string) ([]kubeconfigInfo, error) {
+	kubeconfigs := []kubeconfigInfo{}
+	if err := getFromControlSocket(path, kubeconfigsPath, &kubeconfigs); err != nil {
+		return nil, err
+	}
+	return kubeconfigs, nil
+}
+
+// getReadiness retrieves the readiness of the instance listening on the control socket.
+func getReadiness(path string) (readiness, error) {
+	r := readiness{}
+	resp, err := getControlSocketResponse(path, readyzPath)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return r, fmt.Errorf("unexpected response from %s: %s", path, resp.Status)
+	}
+	return r, json.NewDecoder(resp.Body).Decode(&r)
+}
+
+// getFromControlSocket decodes the JSON response to a GET of endpoint into v.
+func getFromControlSocket(path, endpoint string, v interface{}) error {
+	resp, err := getControlSocketResponse(path, endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func getControlSocketResponse(path, endpoint string) (*http.Response, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	resp, err := client.Get("http://microshift" + endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MicroShift on %s, is it running? %w", path, err)
+	}
+	return resp, nil
+}
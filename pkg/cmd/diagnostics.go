@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/util/cert"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"github.com/openshift/microshift/pkg/version"
+)
+
+const redacted = "REDACTED"
+
+// secretArgs are substrings of argument names whose values are redacted from the bundle
+var secretArgs = []string{"token", "password", "secret"}
+
+type diagnosticsOptions struct {
+	Output string
+	genericclioptions.IOStreams
+}
+
+func NewDiagnosticsCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := diagnosticsOptions{
+		Output:    "microshift-diagnostics.tar.gz",
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "diagnostics",
+		Short: "Collect logs, configuration, certificate metadata and service status into a bundle for bug reports",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Output, "output", opts.Output, "Path of the gzipped tarball to write.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *diagnosticsOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(opts.Output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := writeDiagnosticsBundle(f, cfg); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.Out, "Wrote diagnostics bundle to %s\n", opts.Output)
+	return nil
+}
+
+// writeDiagnosticsBundle writes a gzipped tarball describing the instance configured by
+// cfg. Private keys and kubeconfigs are never included, only metadata of certificates.
+func writeDiagnosticsBundle(w io.Writer, cfg *config.MicroshiftConfig) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+
+	add := func(name string, contents []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents)), ModTime: now}); err != nil {
+			return err
+		}
+		_, err := tw.Write(contents)
+		return err
+	}
+	addJSON := func(name string, v interface{}) error {
+		marshalled, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, append(marshalled, '\n'))
+	}
+
+	if err := addJSON("version.json", version.Get()); err != nil {
+		return err
+	}
+
+	// values substituted from references may be secrets, e.g. read from files
+	marshalled, err := yaml.Marshal(redactConfig(cfg.Unsubstituted()))
+	if err != nil {
+		return err
+	}
+	if err := add("config.yaml", marshalled); err != nil {
+		return err
+	}
+
+	// the instance may not be running, which is worth knowing in itself
+	if statuses, err := getTopology(cfg.ControlSocketPath()); err != nil {
+		if err := add("topology-error.txt", []byte(err.Error()+"\n")); err != nil {
+			return err
+		}
+	} else if err := addJSON("topology.json", statuses); err != nil {
+		return err
+	}
+
+	certs, err := certificateMetadata(cryptomaterial.CertsDirectory(cfg.DataDir))
+	if err != nil {
+		return err
+	}
+	if err := addJSON("certificates.json", certs); err != nil {
+		return err
+	}
+
+	if cfg.Logging.File != "" {
+		// rotated files are named after the log file, with a timestamp before the extension
+		ext := filepath.Ext(cfg.Logging.File)
+		logs, err := filepath.Glob(strings.TrimSuffix(cfg.Logging.File, ext) + "*" + ext)
+		if err != nil {
+			return err
+		}
+		for _, path := range logs {
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := add(filepath.Join("logs", filepath.Base(path)), contents); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// redactConfig returns a copy of cfg without the ingress serving key and with the values
+// of arguments that look like credentials replaced.
+func redactConfig(cfg *config.MicroshiftConfig) *config.MicroshiftConfig {
+	c := *cfg
+	c.Ingress.ServingCertificate = nil
+	c.Ingress.ServingKey = nil
+
+	redactArgs := func(args map[string][]string) map[string][]string {
+		if args == nil {
+			return nil
+		}
+		out := make(map[string][]string, len(args))
+		for name, values := range args {
+			out[name] = values
+			for _, s := range secretArgs {
+				if strings.Contains(strings.ToLower(name), s) {
+					out[name] = []string{redacted}
+					break
+				}
+			}
+		}
+		return out
+	}
+	c.APIServer.ExtraArgs = redactArgs(cfg.APIServer.ExtraArgs)
+	c.ControllerManager.ExtraArgs = redactArgs(cfg.ControllerManager.ExtraArgs)
+	c.Scheduler.ExtraArgs = redactArgs(cfg.Scheduler.ExtraArgs)
+	c.Node.ExtraArgs = redactArgs(cfg.Node.ExtraArgs)
+	return &c
+}
+
+type certificateInfo struct {
+	Path        string    `json:"path"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	Serial      string    `json:"serial"`
+	DNSNames    []string  `json:"dnsNames,omitempty"`
+	IPAddresses []string  `json:"ipAddresses,omitempty"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+}
+
+// certificateMetadata describes every certificate found in certsDir, with paths relative
+// to it. Missing directories, e.g. before the first start, yield no certificates.
+func certificateMetadata(certsDir string) ([]certificateInfo, error) {
+	infos := []certificateInfo{}
+	if _, err := os.Stat(certsDir); os.IsNotExist(err) {
+		return infos, nil
+	}
+	err := filepath.WalkDir(certsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".crt" {
+			return err
+		}
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		certs, err := cert.ParseCertsPEM(pemBytes)
+		if err != nil {
+			return fmt.Errorf("invalid certificate %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(certsDir, path)
+		if err != nil {
+			return err
+		}
+		for _, c := range certs {
+			info := certificateInfo{
+				Path:      rel,
+				Subject:   c.Subject.String(),
+				Issuer:    c.Issuer.String(),
+				Serial:    c.SerialNumber.String(),
+				DNSNames:  c.DNSNames,
+				NotBefore: c.NotBefore,
+				NotAfter:  c.NotAfter,
+			}
+			for _, ip := range c.IPAddresses {
+				info.IPAddresses = append(info.IPAddresses, ip.String())
+			}
+			infos = append(infos, info)
+		}
+		return nil
+	})
+	return infos, err
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+const (
+	clusterDNSCheckInterval = 2 * time.Second
+	// clusterDNSLookupTimeout bounds each lookup, so that an unresponsive server
+	// doesn't delay the next attempt
+	clusterDNSLookupTimeout = 2 * time.Second
+)
+
+// hostResolver resolves host names, e.g. a net.Resolver
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// clusterDNSResolver returns a resolver querying the cluster DNS service directly,
+// as the host's resolv.conf doesn't point to it.
+func clusterDNSResolver(cfg *config.MicroshiftConfig) hostResolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(cfg.Cluster.DNS, "53"))
+		},
+	}
+}
+
+// clusterDNSCheckName is the fully qualified name of the kubernetes service, which
+// is resolvable as soon as the cluster DNS serves the cluster domain.
+func clusterDNSCheckName(cfg *config.MicroshiftConfig) string {
+	return "kubernetes.default.svc." + cfg.Cluster.Domain + "."
+}
+
+// waitForClusterDNS resolves name every interval until it succeeds and returns true,
+// or returns an error once timeout passed. It returns false without an error if
+// MicroShift is interrupted in the meantime.
+func waitForClusterDNS(resolver hostResolver, name string, timeout, interval time.Duration, interrupted <-chan os.Signal) (bool, error) {
+	klog.Infof("waiting up to %s for the cluster DNS to resolve %s before sending the sd_notify readiness message", timeout, name)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	var lastErr error
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterDNSLookupTimeout)
+		addrs, err := resolver.LookupHost(ctx, name)
+		cancel()
+		if err == nil && len(addrs) > 0 {
+			klog.Infof("the cluster DNS resolved %s to %v", name, addrs)
+			return true, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no addresses for %s", name)
+		}
+		lastErr = err
+		klog.V(2).Infof("the cluster DNS failed to resolve %s: %v", name, err)
+
+		select {
+		case <-deadline.C:
+			return false, fmt.Errorf("the cluster DNS didn't resolve %s within %s: %w", name, timeout, lastErr)
+		case <-interrupted:
+			return false, nil
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// flakyResolver fails the first lookups, then resolves all names to addrs
+type flakyResolver struct {
+	failures int
+	addrs    []string
+	lookups  []string
+}
+
+func (r *flakyResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.lookups = append(r.lookups, host)
+	if len(r.lookups) <= r.failures {
+		return nil, errors.New("server misbehaving")
+	}
+	return r.addrs, nil
+}
+
+func TestWaitForClusterDNS(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	name := clusterDNSCheckName(cfg)
+	if name != "kubernetes.default.svc.cluster.local." {
+		t.Errorf("expected the kubernetes service to be looked up by its fully qualified name, got %q", name)
+	}
+
+	resolver := &flakyResolver{failures: 3, addrs: []string{"10.43.0.1"}}
+	ready, err := waitForClusterDNS(resolver, name, 5*time.Second, time.Millisecond, nil)
+	if !ready || err != nil {
+		t.Errorf("expected the check to pass once the cluster DNS resolves, got %v, %v", ready, err)
+	}
+	if len(resolver.lookups) != 4 || resolver.lookups[3] != name {
+		t.Errorf("expected %s to be looked up until it resolved, got %v", name, resolver.lookups)
+	}
+}
+
+func TestWaitForClusterDNSTimeout(t *testing.T) {
+	for _, resolver := range []*flakyResolver{{failures: 1000}, {addrs: []string{}}} {
+		ready, err := waitForClusterDNS(resolver, "kubernetes.default.svc.cluster.local.", 20*time.Millisecond, time.Millisecond, nil)
+		if ready || err == nil || !strings.Contains(err.Error(), "didn't resolve kubernetes.default.svc.cluster.local. within 20ms") {
+			t.Errorf("expected the check to time out, got %v, %v", ready, err)
+		}
+	}
+}
+
+func TestWaitForClusterDNSInterrupted(t *testing.T) {
+	interrupted := make(chan os.Signal, 1)
+	interrupted <- os.Interrupt
+	ready, err := waitForClusterDNS(&flakyResolver{failures: 1000}, "kubernetes.default.svc.cluster.local.", time.Minute, time.Minute, interrupted)
+	if ready || err != nil {
+		t.Errorf("expected an interrupted check to stop without an error, got %v, %v", ready, err)
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+)
+
+// Exit codes of the MicroShift process, so that supervisors can react to the
+// kind of failure.
+const (
+	// ExitOK is returned after a clean shutdown
+	ExitOK = 0
+	// ExitConfigError is returned for invalid configuration or command line
+	// arguments, and an unsuitable host environment
+	ExitConfigError = 1
+	// ExitCertError is returned if certificates can't be generated or loaded
+	ExitCertError = 2
+	// ExitServiceError is returned if a service failed to start or exited with an error
+	ExitServiceError = 3
+	// ExitShutdownTimeout is returned if services didn't stop within the graceful shutdown timeout
+	ExitShutdownTimeout = 4
+	// ExitHookError is returned if a pre-start hook failed
+	ExitHookError = 5
+)
+
+// ExitError is an error that determines the exit code of the process.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
+func exitError(code int, err error) error {
+	return &ExitError{Code: code, Err: err}
+}
+
+// ExitCode returns the process exit code for an error returned by a command.
+// Errors that don't carry an exit code, e.g. flag parsing errors, are
+// configuration errors.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitConfigError
+}
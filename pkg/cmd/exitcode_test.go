@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func TestExitCode(t *testing.T) {
+	var tests = []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "no error", err: nil, want: ExitOK},
+		{name: "config error", err: exitError(ExitConfigError, errors.New("invalid role")), want: ExitConfigError},
+		{name: "cert error", err: exitError(ExitCertError, errors.New("bad cert")), want: ExitCertError},
+		{name: "service error", err: exitError(ExitServiceError, errors.New("etcd failed")), want: ExitServiceError},
+		{name: "shutdown timeout", err: exitError(ExitShutdownTimeout, errors.New("timed out")), want: ExitShutdownTimeout},
+		{name: "hook error", err: exitError(ExitHookError, errors.New("hook failed")), want: ExitHookError},
+		{name: "wrapped exit error", err: fmt.Errorf("run: %w", exitError(ExitCertError, errors.New("bad cert"))), want: ExitCertError},
+		{name: "plain error", err: errors.New("unknown flag"), want: ExitConfigError},
+	}
+
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("%s: ExitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunMicroshiftInvalidConfig(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cmd := &cobra.Command{}
+	addRunFlags(cmd, cfg)
+	if err := cmd.Flags().Set("roles", "worker"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RunMicroshift(cfg, cmd.Flags())
+	if got := ExitCode(err); got != ExitConfigError {
+		t.Errorf("expected exit code %d for an invalid role, got %d (%v)", ExitConfigError, got, err)
+	}
+}
+
+func TestRunMicroshiftSkipPrivilegeCheck(t *testing.T) {
+	defer func() {
+		geteuid = os.Geteuid
+	}()
+	geteuid = func() int { return 1000 }
+
+	var tests = []struct {
+		skip bool
+		want int
+	}{
+		{skip: false, want: ExitConfigError},
+		// the failing pre-start hook runs right after the check
+		{skip: true, want: ExitHookError},
+	}
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	contents := fmt.Sprintf("apiVersion: microshift.openshift.io/v1beta1\ndataDir: %s\nhooks:\n  preStart:\n  - command: /bin/false\n", t.TempDir())
+	if err := os.WriteFile(configFile, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		cmd := NewRunMicroshiftCommand()
+		for flag, value := range map[string]string{"config": configFile, "roles": "node", "skip-privilege-check": strconv.FormatBool(tt.skip)} {
+			if err := cmd.Flags().Set(flag, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := RunMicroshift(config.NewMicroshiftConfig(), cmd.Flags())
+		if got := ExitCode(err); got != tt.want {
+			t.Errorf("--skip-privilege-check=%t: expected exit code %d for an unprivileged start, got %d (%v)", tt.skip, tt.want, got, err)
+		}
+	}
+}
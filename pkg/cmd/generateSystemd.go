@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+const (
+	systemdUnitName       = "microshift.service"
+	systemdEnvDropInName  = "10-environment.conf"
+	generatedUnitExecPath = "/usr/bin/microshift"
+)
+
+type generateSystemdOptions struct {
+	OutputDir   string
+	DataDir     string
+	ConfigFile  string
+	WatchdogSec time.Duration
+	Env         []string
+	genericclioptions.IOStreams
+}
+
+func NewGenerateSystemdCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := generateSystemdOptions{
+		DataDir:   config.NewMicroshiftConfig().DataDir,
+		IOStreams: ioStreams,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "generate-systemd",
+		Short: "Write a systemd unit and environment drop-in running MicroShift with the given settings",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.OutputDir, "output-dir", opts.OutputDir, "Directory to write the unit and its drop-in directory to, e.g. /etc/systemd/system.")
+	flags.StringVar(&opts.DataDir, "data-dir", opts.DataDir, "Directory for storing runtime data of the generated service.")
+	flags.StringVar(&opts.ConfigFile, "config", opts.ConfigFile, "Config file for the generated service to read, defaults to the config file MicroShift would use.")
+	flags.DurationVar(&opts.WatchdogSec, "watchdog-sec", opts.WatchdogSec, "Restart MicroShift if it doesn't ping systemd's watchdog within this duration, 0 disables the watchdog.")
+	flags.StringArrayVar(&opts.Env, "env", opts.Env, "Environment variable KEY=VALUE to set for the generated service in the drop-in, may be repeated.")
+
+	return cmd
+}
+
+func (opts *generateSystemdOptions) Run() error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	dropInDir := filepath.Join(opts.OutputDir, systemdUnitName+".d")
+	if err := os.MkdirAll(dropInDir, 0755); err != nil {
+		return err
+	}
+	files := []struct{ path, contents string }{
+		{filepath.Join(opts.OutputDir, systemdUnitName), renderSystemdUnit(opts)},
+		{filepath.Join(dropInDir, systemdEnvDropInName), renderSystemdEnvDropIn(opts)},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.path, []byte(f.contents), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Out, "Wrote %s\n", f.path)
+	}
+	return nil
+}
+
+func (opts *generateSystemdOptions) validate() error {
+	if opts.OutputDir == "" {
+		return fmt.Errorf("the directory to write the unit to must be specified with --output-dir")
+	}
+	if !filepath.IsAbs(opts.DataDir) {
+		return fmt.Errorf("data directory %q must be an absolute path", opts.DataDir)
+	}
+	if opts.ConfigFile != "" && !filepath.IsAbs(opts.ConfigFile) {
+		return fmt.Errorf("config file %q must be an absolute path", opts.ConfigFile)
+	}
+	// systemd splits ExecStart at whitespace and only supports whole seconds without a unit
+	if strings.ContainsAny(opts.DataDir+opts.ConfigFile, " \t\n\"'") {
+		return fmt.Errorf("the data directory and config file paths must not contain whitespace or quotes")
+	}
+	if opts.WatchdogSec < 0 {
+		return fmt.Errorf("watchdog duration must not be negative")
+	}
+	if opts.WatchdogSec%time.Second != 0 {
+		return fmt.Errorf("watchdog duration %s must be a whole number of seconds", opts.WatchdogSec)
+	}
+	for _, env := range opts.Env {
+		if key, _, ok := strings.Cut(env, "="); !ok || key == "" || strings.ContainsAny(key, " \t\n\"'") {
+			return fmt.Errorf("invalid environment variable %q, must be KEY=VALUE", env)
+		}
+	}
+	return nil
+}
+
+// renderSystemdUnit returns the unit running MicroShift, matching the packaged unit
+// except for the chosen flags and watchdog.
+func renderSystemdUnit(opts *generateSystemdOptions) string {
+	execStart := []string{generatedUnitExecPath, "run", "--data-dir=" + opts.DataDir}
+	if opts.ConfigFile != "" {
+		execStart = append(execStart, "--config="+opts.ConfigFile)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by microshift generate-systemd\n")
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=MicroShift\n")
+	b.WriteString("Wants=network-online.target crio.service openvswitch.service microshift-ovs-init.service\n")
+	b.WriteString("After=network-online.target crio.service openvswitch.service microshift-ovs-init.service\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("WorkingDirectory=/usr/bin/\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(execStart, " "))
+	b.WriteString("Restart=always\n")
+	b.WriteString("User=root\n")
+	b.WriteString("Type=notify\n")
+	if opts.WatchdogSec > 0 {
+		fmt.Fprintf(&b, "WatchdogSec=%d\n", int64(opts.WatchdogSec/time.Second))
+		// MicroShift pings the watchdog itself, pings of its child processes are ignored
+		b.WriteString("NotifyAccess=main\n")
+	}
+	b.WriteString("Delegate=yes\n")
+	b.WriteString("CPUAccounting=yes\n")
+	b.WriteString("BlockIOAccounting=yes\n")
+	b.WriteString("MemoryAccounting=yes\n")
+	b.WriteString("LimitNOFILE=1048576\n")
+	b.WriteString("TimeoutStartSec=2m\n")
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// renderSystemdEnvDropIn returns the drop-in setting the environment of the unit,
+// which can be edited without regenerating the unit.
+func renderSystemdEnvDropIn(opts *generateSystemdOptions) string {
+	var b strings.Builder
+	b.WriteString("# Generated by microshift generate-systemd\n")
+	b.WriteString("[Service]\n")
+	if len(opts.Env) == 0 {
+		b.WriteString("#Environment=MICROSHIFT_LOGVLEVEL=2\n")
+	}
+	for _, env := range opts.Env {
+		fmt.Fprintf(&b, "Environment=%q\n", env)
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestRenderSystemdUnit(t *testing.T) {
+	opts := &generateSystemdOptions{
+		DataDir:     "/srv/microshift",
+		ConfigFile:  "/etc/microshift/edge.yaml",
+		WatchdogSec: 2 * time.Minute,
+	}
+	unit := renderSystemdUnit(opts)
+
+	for _, want := range []string{
+		"ExecStart=/usr/bin/microshift run --data-dir=/srv/microshift --config=/etc/microshift/edge.yaml\n",
+		"Type=notify\n",
+		"WatchdogSec=120\n",
+		"NotifyAccess=main\n",
+		"Restart=always\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("expected the unit to contain %q, got:\n%s", want, unit)
+		}
+	}
+}
+
+func TestRenderSystemdUnitWithoutWatchdog(t *testing.T) {
+	opts := &generateSystemdOptions{DataDir: "/var/lib/microshift"}
+	unit := renderSystemdUnit(opts)
+
+	if !strings.Contains(unit, "ExecStart=/usr/bin/microshift run --data-dir=/var/lib/microshift\n") {
+		t.Errorf("expected the unit to run MicroShift without a config flag, got:\n%s", unit)
+	}
+	if strings.Contains(unit, "WatchdogSec=") {
+		t.Errorf("expected no watchdog when it is disabled, got:\n%s", unit)
+	}
+}
+
+func TestRenderSystemdEnvDropIn(t *testing.T) {
+	opts := &generateSystemdOptions{Env: []string{"MICROSHIFT_LOGVLEVEL=4", "HTTPS_PROXY=http://proxy:3128"}}
+	dropIn := renderSystemdEnvDropIn(opts)
+
+	want := "[Service]\nEnvironment=\"MICROSHIFT_LOGVLEVEL=4\"\nEnvironment=\"HTTPS_PROXY=http://proxy:3128\"\n"
+	if !strings.HasSuffix(dropIn, want) {
+		t.Errorf("expected the drop-in to end with %q, got:\n%s", want, dropIn)
+	}
+}
+
+func TestGenerateSystemdWritesFiles(t *testing.T) {
+	out := &bytes.Buffer{}
+	opts := &generateSystemdOptions{
+		OutputDir:   t.TempDir(),
+		DataDir:     "/var/lib/microshift",
+		WatchdogSec: time.Minute,
+		IOStreams:   genericclioptions.IOStreams{Out: out, ErrOut: out},
+	}
+	if err := opts.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	unit, err := os.ReadFile(filepath.Join(opts.OutputDir, "microshift.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(unit) != renderSystemdUnit(opts) {
+		t.Errorf("expected the rendered unit to be written, got:\n%s", unit)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "microshift.service.d", "10-environment.conf")); err != nil {
+		t.Errorf("expected the environment drop-in to be written: %v", err)
+	}
+}
+
+func TestGenerateSystemdValidate(t *testing.T) {
+	var tests = []struct {
+		name    string
+		opts    generateSystemdOptions
+		wantErr bool
+	}{
+		{"defaults", generateSystemdOptions{OutputDir: "/tmp/out", DataDir: "/var/lib/microshift"}, false},
+		{"missing output dir", generateSystemdOptions{DataDir: "/var/lib/microshift"}, true},
+		{"relative data dir", generateSystemdOptions{OutputDir: "/tmp/out", DataDir: "data"}, true},
+		{"relative config", generateSystemdOptions{OutputDir: "/tmp/out", DataDir: "/var/lib/microshift", ConfigFile: "config.yaml"}, true},
+		{"whitespace in path", generateSystemdOptions{OutputDir: "/tmp/out", DataDir: "/var/lib/micro shift"}, true},
+		{"fractional watchdog", generateSystemdOptions{OutputDir: "/tmp/out", DataDir: "/var/lib/microshift", WatchdogSec: 1500 * time.Millisecond}, true},
+		{"invalid env", generateSystemdOptions{OutputDir: "/tmp/out", DataDir: "/var/lib/microshift", Env: []string{"NOVALUE"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
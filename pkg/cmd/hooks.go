@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+const defaultHookTimeout = time.Minute
+
+// runHooks runs the hooks of a lifecycle phase in order, stopping at the first one
+// that fails.
+func runHooks(phase string, hooks []config.Hook) error {
+	for _, hook := range hooks {
+		if err := runHook(phase, hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHook runs the hook's command and logs its output.
+func runHook(phase string, hook config.Hook) error {
+	timeout := defaultHookTimeout
+	if hook.Timeout != "" {
+		// the timeout has been validated with the config
+		timeout, _ = time.ParseDuration(hook.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	klog.Infof("Running %s hook %q", phase, hook.Command)
+	output, err := exec.CommandContext(ctx, hook.Command, hook.Args...).CombinedOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		klog.Infof("%s hook %q: %s", phase, hook.Command, scanner.Text())
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s hook %q timed out after %v", phase, hook.Command, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook %q failed: %w", phase, hook.Command, err)
+	}
+	return nil
+}
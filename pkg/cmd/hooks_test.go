@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// sentinelHook appends the name to the sentinel file and exits with the given code
+func sentinelHook(t *testing.T, sentinel, name, exitCode string) config.Hook {
+	script := filepath.Join(t.TempDir(), name+".sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$1\" >> \"$2\"\nexit "+exitCode+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return config.Hook{Command: script, Args: []string{name, sentinel}}
+}
+
+func TestRunHooks(t *testing.T) {
+	var ttests = []struct {
+		name     string
+		hooks    []string
+		failing  string
+		wantErr  bool
+		expected string
+	}{
+		{name: "no hooks", expected: ""},
+		{name: "hooks run in order", hooks: []string{"mount", "prepare"}, expected: "mount\nprepare\n"},
+		{name: "failing hook aborts", hooks: []string{"mount", "prepare", "notify"}, failing: "prepare", wantErr: true, expected: "mount\nprepare\n"},
+	}
+
+	for _, tt := range ttests {
+		sentinel := filepath.Join(t.TempDir(), "sentinel")
+		hooks := []config.Hook{}
+		for _, name := range tt.hooks {
+			exitCode := "0"
+			if name == tt.failing {
+				exitCode = "1"
+			}
+			hooks = append(hooks, sentinelHook(t, sentinel, name, exitCode))
+		}
+
+		if err := runHooks("pre-start", hooks); (err != nil) != tt.wantErr {
+			t.Errorf("%s: runHooks() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		contents, err := os.ReadFile(sentinel)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if string(contents) != tt.expected {
+			t.Errorf("%s: expected hooks to write %q, got %q", tt.name, tt.expected, string(contents))
+		}
+	}
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	hook := config.Hook{Command: "/bin/sleep", Args: []string{"10"}, Timeout: "100ms"}
+	if err := runHook("pre-start", hook); err == nil {
+		t.Errorf("expected hook exceeding its timeout to fail")
+	}
+}
@@ -16,12 +16,19 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
 	ctrl "k8s.io/kubernetes/pkg/controlplane"
 
 	"github.com/openshift/microshift/pkg/config"
@@ -29,24 +36,129 @@ import (
 	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 )
 
-var microshiftDataDir = config.GetDataDir()
+// readFile is a variable so that tests can simulate a slow filesystem
+var readFile = os.ReadFile
 
-func initAll(cfg *config.MicroshiftConfig) error {
+// initAll creates or loads the certificates and kubeconfigs, returning the kubeconfigs it
+// generated. It gives up between the steps once ctx is done, so that a slow disk fails the
+// start instead of delaying it for long.
+func initAll(ctx context.Context, cfg *config.MicroshiftConfig) ([]kubeconfigInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	kubeconfigs, err := initAllSteps(ctx, cfg)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("certificates and kubeconfigs were not initialized in time: %w", ctx.Err())
+	}
+	return kubeconfigs, err
+}
+
+// initAllSteps creates or loads the certificates and kubeconfigs. A new certs directory is
+// generated in a staging directory next to it and only renamed into place once every step
+// succeeded, so that a failed init doesn't leave a half-initialized certs directory behind
+// that later starts would load.
+func initAllSteps(ctx context.Context, cfg *config.MicroshiftConfig) ([]kubeconfigInfo, error) {
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	if _, err := os.Stat(certsDir); !os.IsNotExist(err) {
+		return initAllStepsInDir(ctx, cfg, certsDir)
+	}
+
+	stagingDir := certsDir + ".staging"
+	// a staging directory is left over from an interrupted init
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return nil, err
+	}
+	kubeconfigs, err := initAllStepsInDir(ctx, cfg, stagingDir)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(stagingDir, certsDir)
+	}
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		return nil, err
+	}
+	return kubeconfigs, nil
+}
+
+func initAllStepsInDir(ctx context.Context, cfg *config.MicroshiftConfig, certsDir string) ([]kubeconfigInfo, error) {
 	// create CA and keys
-	certChains, err := initCerts(cfg)
+	certChains, err := initCertsInDir(cfg, certsDir)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 	// create kubeconfig for kube-scheduler, kubelet,controller-manager
-	if err := initKubeconfig(cfg, certChains); err != nil {
+	kubeconfigs, err := initKubeconfig(cfg, certsDir, certChains)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cfg.AdditionalTrustBundle != "" {
+		if err := initAdditionalTrustBundle(cfg, certsDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return kubeconfigs, nil
+}
+
+// initAdditionalTrustBundle copies the user provided CA bundle into the certs directory
+// so that components can trust it when connecting to TLS endpoints.
+func initAdditionalTrustBundle(cfg *config.MicroshiftConfig, certsDir string) error {
+	bundlePEM, err := readFile(cfg.AdditionalTrustBundle)
+	if err != nil {
+		return fmt.Errorf("failed to load the additional trust bundle: %v", err)
+	}
+
+	bundlePath := cryptomaterial.AdditionalTrustBundlePath(certsDir)
+	if err := os.MkdirAll(filepath.Dir(bundlePath), 0700); err != nil {
 		return err
 	}
+	return os.WriteFile(bundlePath, bundlePEM, 0644)
+}
+
+// systemCertDirs are the directories Go reads CA certificates from on Linux
+// when SSL_CERT_DIR is not set
+var systemCertDirs = []string{
+	"/etc/ssl/certs",
+	"/etc/pki/tls/certs",
+}
+
+// addToSSLCertDirEnv adds directories to SSL_CERT_DIR so that certificates in them
+// are trusted by the system cert pool in addition to the system defaults.
+// It needs to be called before the system cert pool is first loaded.
+func addToSSLCertDirEnv(dirs ...string) error {
+	existing := systemCertDirs
+	if env := os.Getenv("SSL_CERT_DIR"); env != "" {
+		existing = strings.Split(env, ":")
+	}
+
+	certDirs := []string{}
+	for _, dir := range append(existing, dirs...) {
+		if dir != "" && !config.StringInList(dir, certDirs) {
+			certDirs = append(certDirs, dir)
+		}
+	}
 
+	if err := os.Setenv("SSL_CERT_DIR", strings.Join(certDirs, ":")); err != nil {
+		return fmt.Errorf("error updating SSL_CERT_DIR: %v", err)
+	}
 	return nil
 }
 
 func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains, error) {
-	_, svcNet, err := net.ParseCIDR(cfg.Cluster.ServiceCIDR)
+	return initCertsInDir(cfg, cryptomaterial.CertsDirectory(cfg.DataDir))
+}
+
+func initCertsInDir(cfg *config.MicroshiftConfig, certsDir string) (*cryptomaterial.CertificateChains, error) {
+	// the kubernetes service gets its IP from the primary service CIDR
+	_, svcNet, err := net.ParseCIDR(cfg.ServiceCIDRs()[0])
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +168,11 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 		return nil, err
 	}
 
-	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	externalHostnames := []string{cfg.KubeletNodeName()}
+	if host := cfg.APIServer.ExternalHostname(); host != "" {
+		externalHostnames = append(externalHostnames, host)
+	}
+	warnRouterDomainChanged(cfg, certsDir)
 
 	certChains, err := cryptomaterial.NewCertificateChains(
 		// ------------------------------
@@ -129,7 +245,7 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 						ValidityDays: cryptomaterial.ClientCertValidityDays,
 					},
 					// userinfo per https://kubernetes.io/docs/reference/access-authn-authz/node/#overview
-					UserInfo: &user.DefaultInfo{Name: "system:node:" + cfg.NodeName, Groups: []string{"system:nodes"}},
+					UserInfo: &user.DefaultInfo{Name: "system:node:" + cfg.KubeletNodeName(), Groups: []string{"system:nodes"}},
 				},
 			).WithServingCertificates(
 				&cryptomaterial.ServingCertificateSigningRequestInfo{
@@ -137,7 +253,7 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 						Name:         "kubelet-server",
 						ValidityDays: cryptomaterial.ServingCertValidityDays,
 					},
-					Hostnames: []string{cfg.NodeName, cfg.NodeIP},
+					Hostnames: append([]string{cfg.KubeletNodeName(), cfg.NodeIP}, cfg.Node.ServingCertSANs...),
 				},
 			),
 		),
@@ -151,7 +267,7 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 					Name:         "aggregator-client",
 					ValidityDays: cryptomaterial.ClientCertValidityDays,
 				},
-				UserInfo: &user.DefaultInfo{Name: "system:openshift-aggregator"},
+				UserInfo: &user.DefaultInfo{Name: cryptomaterial.AggregatorClientUserName},
 			},
 		),
 
@@ -186,7 +302,8 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 					ValidityDays: cryptomaterial.IngressServingCertValidityDays,
 				},
 				Hostnames: []string{
-					"router-default.apps." + cfg.Cluster.Domain,
+					"router-default." + cfg.IngressDomain(),
+					"*." + cfg.IngressDomain(),
 				},
 			},
 		),
@@ -203,9 +320,7 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 					Name:         "kube-external-serving",
 					ValidityDays: cryptomaterial.KubeAPIServerServingCertValidityDays,
 				},
-				Hostnames: []string{
-					cfg.NodeName,
-				},
+				Hostnames: externalHostnames,
 			},
 		),
 
@@ -272,7 +387,7 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 					ValidityDays: 3 * 365,
 				},
 				UserInfo:  &user.DefaultInfo{Name: "system:etcd-peer:etcd-client", Groups: []string{"system:etcd-peers"}},
-				Hostnames: []string{"localhost", cfg.NodeIP, "127.0.0.1", cfg.NodeName},
+				Hostnames: []string{"localhost", cfg.NodeIP, "127.0.0.1", cfg.KubeletNodeName()},
 			},
 			&cryptomaterial.PeerCertificateSigningRequestInfo{
 				CertificateSigningRequestInfo: cryptomaterial.CertificateSigningRequestInfo{
@@ -280,7 +395,7 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 					ValidityDays: 3 * 365,
 				},
 				UserInfo:  &user.DefaultInfo{Name: "system:etcd-server:etcd-client", Groups: []string{"system:etcd-servers"}},
-				Hostnames: []string{"localhost", "127.0.0.1", cfg.NodeIP, cfg.NodeName},
+				Hostnames: []string{"localhost", "127.0.0.1", cfg.NodeIP, cfg.KubeletNodeName()},
 			},
 		),
 	).WithCABundle(
@@ -321,12 +436,19 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 		return nil, err
 	}
 
-	if err := util.GenKeys(filepath.Join(microshiftDataDir, "/resources/kube-apiserver/secrets/service-account-key"),
-		"service-account.crt", "service-account.key"); err != nil {
+	if err := verifyFrontProxyCertificate(certChains); err != nil {
 		return nil, err
 	}
 
-	cfg.Ingress.ServingCertificate, cfg.Ingress.ServingKey, err = certChains.GetCertKey("ingress-ca", "router-default-serving")
+	if err := initServiceAccountKey(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Ingress.CertFile != "" {
+		cfg.Ingress.ServingCertificate, cfg.Ingress.ServingKey, err = util.LoadCertKeyPair(cfg.Ingress.CertFile, cfg.Ingress.KeyFile)
+	} else {
+		cfg.Ingress.ServingCertificate, cfg.Ingress.ServingKey, err = certChains.GetCertKey("ingress-ca", "router-default-serving")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -334,67 +456,151 @@ func initCerts(cfg *config.MicroshiftConfig) (*cryptomaterial.CertificateChains,
 	return certChains, nil
 }
 
-func initKubeconfig(
-	cfg *config.MicroshiftConfig,
-	certChains *cryptomaterial.CertificateChains,
-) error {
-	inClusterTrustBundlePEM, err := os.ReadFile(cryptomaterial.ServiceAccountTokenCABundlePath(cryptomaterial.CertsDirectory(microshiftDataDir)))
+// warnRouterDomainChanged logs that the existing router serving certificate is issued
+// again because it doesn't cover the ingress domain, e.g. after the domain was changed
+// or the default of an earlier version was replaced, as routes created before keep
+// their hosts under the previous domain.
+func warnRouterDomainChanged(cfg *config.MicroshiftConfig, certsDir string) {
+	certs, err := cert.CertsFromFile(cryptomaterial.ServingCertPath(filepath.Join(cryptomaterial.IngressCADir(certsDir), "router-default-serving")))
 	if err != nil {
-		return fmt.Errorf("failed to load the in-cluster trust bundle: %v", err)
+		return
 	}
+	if err := certs[0].VerifyHostname("router-default." + cfg.IngressDomain()); err != nil {
+		klog.Warningf("The router serving certificate for %v doesn't cover the ingress domain %s and is issued again, routes under the previous domain are no longer covered by it", certs[0].DNSNames, cfg.IngressDomain())
+	}
+}
 
-	adminKubeconfigCertPEM, adminKubeconfigKeyPEM, err := certChains.GetCertKey("admin-kubeconfig-signer", "admin-kubeconfig-client")
+// verifyFrontProxyCertificate checks that the client certificate the apiserver proxies
+// requests to aggregated apiservers with is signed by the requestheader CA for client
+// authentication, and is issued to the allowed user.
+func verifyFrontProxyCertificate(certChains *cryptomaterial.CertificateChains) error {
+	clientCert, err := parseCert(certChains, "aggregator-signer", "aggregator-client")
 	if err != nil {
 		return err
 	}
-	if err := util.KubeConfigWithClientCerts(
-		cfg.KubeConfigPath(config.KubeAdmin),
-		cfg.Cluster.URL,
-		inClusterTrustBundlePEM,
-		adminKubeconfigCertPEM,
-		adminKubeconfigKeyPEM,
-	); err != nil {
+	caPEM, err := certChains.GetSigner("aggregator-signer").GetSignerCertPEM()
+	if err != nil {
 		return err
 	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	if _, err := clientCert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		return fmt.Errorf("front-proxy client certificate isn't valid for the requestheader CA: %w", err)
+	}
+	if clientCert.Subject.CommonName != cryptomaterial.AggregatorClientUserName {
+		return fmt.Errorf("front-proxy client certificate is issued to %q, expected %q", clientCert.Subject.CommonName, cryptomaterial.AggregatorClientUserName)
+	}
+	return nil
+}
 
-	kcmCertPEM, kcmKeyPEM, err := certChains.GetCertKey("kube-control-plane-signer", "kube-controller-manager")
-	if err != nil {
-		return err
+// initServiceAccountKey generates the key pair for service account tokens unless a
+// signing key is configured or was generated before, and keeps the issuer and public
+// key in use before they changed.
+func initServiceAccountKey(cfg *config.MicroshiftConfig) error {
+	if cfg.APIServer.ServiceAccountSigningKeyFile == "" {
+		if _, err := os.Stat(cfg.ServiceAccountSigningKeyPath()); os.IsNotExist(err) {
+			if err := util.GenKeys(cfg.ServiceAccountKeyDir(), "service-account.crt", "service-account.key"); err != nil {
+				return err
+			}
+		}
 	}
-	if err := util.KubeConfigWithClientCerts(
-		cfg.KubeConfigPath(config.KubeControllerManager),
-		cfg.Cluster.URL,
-		inClusterTrustBundlePEM,
-		kcmCertPEM,
-		kcmKeyPEM,
-	); err != nil {
+	return keepPreviousServiceAccountIssuer(cfg)
+}
+
+// keepPreviousServiceAccountIssuer records the issuer and public key of service
+// account tokens, moving the recorded ones aside as the previous ones when they
+// changed, so that kube-apiserver keeps accepting the tokens issued before until they
+// are replaced.
+func keepPreviousServiceAccountIssuer(cfg *config.MicroshiftConfig) error {
+	if err := os.MkdirAll(cfg.ServiceAccountKeyDir(), 0700); err != nil {
 		return err
 	}
-
-	schedulerCertPEM, schedulerKeyPEM, err := certChains.GetCertKey("kube-control-plane-signer", "kube-scheduler")
+	keys, err := keyutil.PublicKeysFromFile(cfg.ServiceAccountSigningKeyPath())
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to read the service account signing key: %w", err)
 	}
-	if err := util.KubeConfigWithClientCerts(
-		cfg.KubeConfigPath(config.KubeScheduler),
-		cfg.Cluster.URL,
-		inClusterTrustBundlePEM,
-		schedulerCertPEM, schedulerKeyPEM,
-	); err != nil {
+	der, err := x509.MarshalPKIXPublicKey(keys[0])
+	if err != nil {
 		return err
 	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	for _, record := range []struct{ path, previousPath, value string }{
+		{filepath.Join(cfg.ServiceAccountKeyDir(), "issuer"), cfg.ServiceAccountPreviousIssuerPath(), cfg.APIServer.ServiceAccountIssuer},
+		{filepath.Join(cfg.ServiceAccountKeyDir(), "service-account-active.pub"), cfg.ServiceAccountPreviousPublicKeyPath(), string(publicKeyPEM)},
+	} {
+		recorded, err := os.ReadFile(record.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil && string(recorded) == record.value {
+			continue
+		}
+		if err == nil {
+			if err := os.WriteFile(record.previousPath, recorded, 0600); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(record.path, []byte(record.value), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	kubeletCertPEM, kubeletKeyPEM, err := certChains.GetCertKey("kubelet-signer", "kube-csr-signer", "kubelet-client")
+// kubeconfigInfo describes a kubeconfig generated during init and the server it targets.
+type kubeconfigInfo struct {
+	Name   config.KubeConfigID `json:"name"`
+	Path   string              `json:"path"`
+	Server string              `json:"server"`
+}
+
+func initKubeconfig(
+	cfg *config.MicroshiftConfig,
+	certsDir string,
+	certChains *cryptomaterial.CertificateChains,
+) ([]kubeconfigInfo, error) {
+	inClusterTrustBundlePEM, err := readFile(cryptomaterial.ServiceAccountTokenCABundlePath(certsDir))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to load the in-cluster trust bundle: %v", err)
 	}
-	if err := util.KubeConfigWithClientCerts(
-		cfg.KubeConfigPath(config.Kubelet),
-		cfg.Cluster.URL,
-		inClusterTrustBundlePEM,
-		kubeletCertPEM, kubeletKeyPEM,
-	); err != nil {
-		return err
+
+	type kubeconfigSpec struct {
+		id     config.KubeConfigID
+		server string
+		// path of the client certificate in the certificate chains
+		cert []string
 	}
-	return nil
+	kubeconfigs := []kubeconfigSpec{
+		{id: config.KubeAdmin, server: cfg.Cluster.URL, cert: []string{"admin-kubeconfig-signer", "admin-kubeconfig-client"}},
+		{id: config.KubeControllerManager, server: cfg.Cluster.URL, cert: []string{"kube-control-plane-signer", "kube-controller-manager"}},
+		{id: config.KubeScheduler, server: cfg.Cluster.URL, cert: []string{"kube-control-plane-signer", "kube-scheduler"}},
+		{id: config.Kubelet, server: cfg.Cluster.URL, cert: []string{"kubelet-signer", "kube-csr-signer", "kubelet-client"}},
+	}
+	// clients outside the host reach the apiserver through the external URL
+	if cfg.APIServer.ExternalURL != "" {
+		kubeconfigs = append(kubeconfigs, kubeconfigSpec{
+			id:     config.KubeAdminExternal,
+			server: cfg.APIServer.ExternalURL,
+			cert:   []string{"admin-kubeconfig-signer", "admin-kubeconfig-client"},
+		})
+	}
+
+	generated := make([]kubeconfigInfo, 0, len(kubeconfigs))
+	for _, k := range kubeconfigs {
+		certPEM, keyPEM, err := certChains.GetCertKey(k.cert...)
+		if err != nil {
+			return nil, err
+		}
+		if err := util.KubeConfigWithClientCerts(
+			cfg.KubeConfigPath(k.id),
+			k.server,
+			inClusterTrustBundlePEM,
+			certPEM, keyPEM,
+		); err != nil {
+			return nil, err
+		}
+		generated = append(generated, kubeconfigInfo{Name: k.id, Path: cfg.KubeConfigPath(k.id), Server: k.server})
+	}
+	return generated, nil
 }
@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestInitCertsHostnameOverride(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.HostnameOverride = "edge-node-1"
+
+	if _, err := initCerts(cfg); err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	var tests = []struct {
+		path      string
+		wantCN    string
+		wantSANIn bool
+	}{
+		{path: cryptomaterial.ClientCertPath(cryptomaterial.KubeletClientCertDir(certsDir)), wantCN: "system:node:edge-node-1"},
+		{path: cryptomaterial.ServingCertPath(cryptomaterial.KubeletServingCertDir(certsDir)), wantSANIn: true},
+		{path: cryptomaterial.ServingCertPath(cryptomaterial.KubeAPIServerExternalServingCertDir(certsDir)), wantSANIn: true},
+		{path: cryptomaterial.PeerCertPath(cryptomaterial.EtcdPeerCertDir(certsDir)), wantSANIn: true},
+	}
+	for _, tt := range tests {
+		pemBytes, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs, err := cert.ParseCertsPEM(pemBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.wantCN != "" && certs[0].Subject.CommonName != tt.wantCN {
+			t.Errorf("%s: expected common name %q, got %q", tt.path, tt.wantCN, certs[0].Subject.CommonName)
+		}
+		if tt.wantSANIn {
+			found := false
+			for _, name := range certs[0].DNSNames {
+				found = found || name == "edge-node-1"
+			}
+			if !found {
+				t.Errorf("%s: expected %q in the SANs, got %v", tt.path, "edge-node-1", certs[0].DNSNames)
+			}
+		}
+	}
+}
+
+func TestInitCertsKubeletServingSANs(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.ServingCertSANs = []string{"edge-node-1.example.com", "192.168.1.10"}
+
+	if _, err := initCerts(cfg); err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+
+	pemBytes, err := os.ReadFile(cryptomaterial.ServingCertPath(cryptomaterial.KubeletServingCertDir(cryptomaterial.CertsDirectory(cfg.DataDir))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := cert.ParseCertsPEM(pemBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range append([]string{cfg.KubeletNodeName(), cfg.NodeIP}, cfg.Node.ServingCertSANs...) {
+		if err := certs[0].VerifyHostname(host); err != nil {
+			t.Errorf("expected the kubelet serving certificate to be valid for %q: %v", host, err)
+		}
+	}
+}
+
+func TestInitCertsIngressDomain(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Cluster.IngressDomain = "apps.example.com"
+
+	certChains, err := initCerts(cfg)
+	if err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+	certPEM, _, err := certChains.GetCertKey("ingress-ca", "router-default-serving")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := cert.ParseCertsPEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"router-default.apps.example.com", "hello.apps.example.com"} {
+		if err := certs[0].VerifyHostname(host); err != nil {
+			t.Errorf("expected the router serving certificate to be valid for %q: %v", host, err)
+		}
+	}
+}
+
+func TestInitCertsIngressDomainChanged(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	// the router serving certificate of an earlier default domain
+	cfg.Cluster.IngressDomain = "apps." + cfg.Cluster.Domain
+	if _, err := initCerts(cfg); err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+
+	cfg.Cluster.IngressDomain = ""
+	certChains, err := initCerts(cfg)
+	if err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+	certPEM, _, err := certChains.GetCertKey("ingress-ca", "router-default-serving")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := cert.ParseCertsPEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := certs[0].VerifyHostname("router-default." + cfg.IngressDomain()); err != nil {
+		t.Errorf("expected the router serving certificate to be issued again for the new domain: %v", err)
+	}
+	if !bytes.Equal(cfg.Ingress.ServingCertificate, certPEM) {
+		t.Errorf("expected the router to be configured with the new certificate")
+	}
+}
+
+func TestInitCertsFrontProxy(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	if _, err := initCerts(cfg); err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+
+	// replace the front-proxy client certificate with one of another CA
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	frontProxyDir := cryptomaterial.AggregatorClientCertDir(certsDir)
+	schedulerDir := filepath.Join(cryptomaterial.KubeControlPlaneSignerCertDir(certsDir), "kube-scheduler")
+	for _, path := range []func(string) string{cryptomaterial.ClientCertPath, cryptomaterial.ClientKeyPath} {
+		contents, err := os.ReadFile(path(schedulerDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path(frontProxyDir), contents, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := initCerts(cfg); err == nil || !strings.Contains(err.Error(), "front-proxy client certificate") {
+		t.Errorf("expected the front-proxy client certificate of another CA to be rejected, got %v", err)
+	}
+}
+
+func TestInitExternalURL(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.APIServer.ExternalURL = "https://api.example.com:8443"
+
+	certChains, err := initCerts(cfg)
+	if err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+	if _, err := initKubeconfig(cfg, cryptomaterial.CertsDirectory(cfg.DataDir), certChains); err != nil {
+		t.Fatalf("failed to initialize kubeconfigs: %v", err)
+	}
+
+	pemBytes, err := os.ReadFile(cryptomaterial.ServingCertPath(cryptomaterial.KubeAPIServerExternalServingCertDir(cryptomaterial.CertsDirectory(cfg.DataDir))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := cert.ParseCertsPEM(pemBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := certs[0].VerifyHostname("api.example.com"); err != nil {
+		t.Errorf("expected the external serving certificate to be valid for the external host: %v", err)
+	}
+
+	kubeconfig, err := clientcmd.LoadFromFile(cfg.KubeConfigPath(config.KubeAdminExternal))
+	if err != nil {
+		t.Fatalf("failed to load external kubeconfig: %v", err)
+	}
+	for name, cluster := range kubeconfig.Clusters {
+		if cluster.Server != cfg.APIServer.ExternalURL {
+			t.Errorf("cluster %q: expected server %q, got %q", name, cfg.APIServer.ExternalURL, cluster.Server)
+		}
+	}
+	if err := verifyKubeconfig(cfg.KubeConfigPath(config.KubeAdminExternal)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAddToSSLCertDirEnv(t *testing.T) {
+	defer os.Unsetenv("SSL_CERT_DIR")
+	var tests = []struct {
+		env      string
+		expected string
+	}{
+		{env: "", expected: "/etc/ssl/certs:/etc/pki/tls/certs:/var/lib/microshift/certs/additional-trust-bundle"},
+		{env: "/my/certs:/var/lib/microshift/certs/additional-trust-bundle", expected: "/my/certs:/var/lib/microshift/certs/additional-trust-bundle"},
+	}
+	for _, tt := range tests {
+		os.Setenv("SSL_CERT_DIR", tt.env)
+		if err := addToSSLCertDirEnv("/var/lib/microshift/certs/additional-trust-bundle"); err != nil {
+			t.Fatal(err)
+		}
+		if got := os.Getenv("SSL_CERT_DIR"); got != tt.expected {
+			t.Errorf("expected SSL_CERT_DIR %q with %q, got %q", tt.expected, tt.env, got)
+		}
+	}
+}
+
+func TestInitAllDeadline(t *testing.T) {
+	defer func() {
+		readFile = os.ReadFile
+	}()
+
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+
+	// simulate a slow disk, returning from the read only once the deadline passed
+	timeout := time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	readFile = func(name string) ([]byte, error) {
+		<-ctx.Done()
+		return os.ReadFile(name)
+	}
+
+	_, err := initAll(ctx, cfg)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected initialization to fail with the deadline, got %v", err)
+	}
+	// nothing is left writing once initAll returned
+	for _, dir := range []string{certsDir, certsDir + ".staging"} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected no %s after the timed out initialization, got %v", dir, err)
+		}
+	}
+}
+
+func TestInitAllCanceled(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := initAll(ctx, cfg); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected initialization to fail with the canceled context, got %v", err)
+	}
+}
+
+func TestInitAllPartialFailure(t *testing.T) {
+	defer func() {
+		readFile = os.ReadFile
+	}()
+
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	// a staging directory left over from an interrupted init
+	if err := os.MkdirAll(certsDir+".staging", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// fail once the certificates are generated, while creating the kubeconfigs
+	readFile = func(name string) ([]byte, error) {
+		return nil, errors.New("disk failure")
+	}
+	if _, err := initAll(context.Background(), cfg); err == nil {
+		t.Fatal("expected initialization to fail")
+	}
+	for _, dir := range []string{certsDir, certsDir + ".staging"} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected no %s after the failed initialization, got %v", dir, err)
+		}
+	}
+
+	readFile = os.ReadFile
+	if _, err := initAll(context.Background(), cfg); err != nil {
+		t.Fatalf("failed to initialize certificates: %v", err)
+	}
+	if err := verifyCertificates(certsDir); err != nil {
+		t.Errorf("expected the certificates to be initialized in place: %v", err)
+	}
+	if _, err := os.Stat(certsDir + ".staging"); !os.IsNotExist(err) {
+		t.Errorf("expected the staging directory to be renamed, got %v", err)
+	}
+}
+
+func TestInitServiceAccountKey(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	if err := os.MkdirAll(cfg.ServiceAccountKeyDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := initServiceAccountKey(cfg); err != nil {
+		t.Fatalf("initServiceAccountKey() error = %v", err)
+	}
+	if _, err := keyutil.PrivateKeyFromFile(cfg.ServiceAccountSigningKeyPath()); err != nil {
+		t.Errorf("expected a generated signing key: %v", err)
+	}
+	if _, err := keyutil.PublicKeysFromFile(cfg.ServiceAccountPublicKeyPath()); err != nil {
+		t.Errorf("expected a generated public key: %v", err)
+	}
+}
+
+func TestInitServiceAccountKeyConfigured(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	keyDir := t.TempDir()
+	if err := util.GenKeys(keyDir, "sa.pub", "sa.key"); err != nil {
+		t.Fatal(err)
+	}
+	cfg.APIServer.ServiceAccountSigningKeyFile = filepath.Join(keyDir, "sa.key")
+
+	if err := initServiceAccountKey(cfg); err != nil {
+		t.Fatalf("initServiceAccountKey() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.ServiceAccountKeyDir(), "service-account.key")); !os.IsNotExist(err) {
+		t.Errorf("expected no key to be generated when one is configured, got %v", err)
+	}
+}
+
+func TestInitServiceAccountKeyTransition(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	if err := initServiceAccountKey(cfg); err != nil {
+		t.Fatalf("initServiceAccountKey() error = %v", err)
+	}
+	generated, err := os.ReadFile(cfg.ServiceAccountSigningKeyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// restarting keeps the generated key
+	if err := initServiceAccountKey(cfg); err != nil {
+		t.Fatalf("initServiceAccountKey() error = %v", err)
+	}
+	if key, err := os.ReadFile(cfg.ServiceAccountSigningKeyPath()); err != nil || !bytes.Equal(key, generated) {
+		t.Errorf("expected the generated signing key to be kept, got %v", err)
+	}
+	for _, path := range []string{cfg.ServiceAccountPreviousIssuerPath(), cfg.ServiceAccountPreviousPublicKeyPath()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected no %s before the issuer or key changed, got %v", path, err)
+		}
+	}
+
+	// changing the issuer and key keeps the previous ones
+	keyDir := t.TempDir()
+	if err := util.GenKeys(keyDir, "sa.pub", "sa.key"); err != nil {
+		t.Fatal(err)
+	}
+	cfg.APIServer.ServiceAccountIssuer = "https://oidc.example.com/microshift"
+	cfg.APIServer.ServiceAccountSigningKeyFile = filepath.Join(keyDir, "sa.key")
+	if err := initServiceAccountKey(cfg); err != nil {
+		t.Fatalf("initServiceAccountKey() error = %v", err)
+	}
+	if previous, err := os.ReadFile(cfg.ServiceAccountPreviousIssuerPath()); err != nil || string(previous) != "https://kubernetes.default.svc" {
+		t.Errorf("expected the previous issuer to be kept, got %q, %v", previous, err)
+	}
+	previousKeys, err := keyutil.PublicKeysFromFile(cfg.ServiceAccountPreviousPublicKeyPath())
+	if err != nil {
+		t.Fatalf("expected the previous public key to be kept: %v", err)
+	}
+	generatedKeys, err := keyutil.PublicKeysFromFile(filepath.Join(cfg.ServiceAccountKeyDir(), "service-account.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(previousKeys, generatedKeys) {
+		t.Errorf("expected the previous public key to be the generated one")
+	}
+}
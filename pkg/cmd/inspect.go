@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+type inspectCertsOptions struct {
+	Rotate string
+	genericclioptions.IOStreams
+}
+
+// certInfo is a leaf certificate of the certificate chains, named by the path of its
+// signers and its own name, e.g. kube-control-plane-signer/kube-scheduler
+type certInfo struct {
+	Name     string
+	NotAfter time.Time
+	// Missing is set if the certificate wasn't issued yet
+	Missing bool
+}
+
+func NewInspectCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Inspect the state MicroShift keeps in its data directory",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewInspectCertsCommand(ioStreams))
+	return cmd
+}
+
+func NewInspectCertsCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := inspectCertsOptions{
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "List the certificates MicroShift issued and when they expire, or rotate one of them",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Rotate, "rotate", opts.Rotate, "Name of a certificate to issue again, e.g. kube-control-plane-signer/kube-scheduler. CAs can't be rotated.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *inspectCertsOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+	// rotating issues the missing certificates too, which is up to MicroShift
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	if _, err := os.Stat(certsDir); err != nil {
+		return fmt.Errorf("no certificates found in %s, MicroShift needs to start once first: %w", certsDir, err)
+	}
+
+	if opts.Rotate != "" {
+		if err := rotateCert(cfg, opts.Rotate); err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Out, "Rotated certificate %s\n", opts.Rotate)
+		// the services load their certificates on start
+		if _, err := getReadiness(cfg.ControlSocketPath()); err == nil {
+			fmt.Fprintln(opts.ErrOut, "MicroShift is running, restart it with 'systemctl restart microshift' for its services to use the new certificate")
+		}
+		return nil
+	}
+
+	certs, err := listCerts(cfg)
+	if err != nil {
+		return err
+	}
+	return renderCertsText(opts.Out, certs)
+}
+
+// listCerts returns the leaf certificates MicroShift issued, ordered by signer, without
+// issuing the missing ones. The names and locations of the certificates are taken from
+// chains issued in a temporary directory.
+func listCerts(cfg *config.MicroshiftConfig) ([]certInfo, error) {
+	dataDir, err := mkdirTemp("", "microshift-inspect-certs-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary data directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dataDir); err != nil {
+			klog.Warningf("failed to remove temporary data directory %s: %v", dataDir, err)
+		}
+	}()
+	layoutCfg := *cfg
+	layoutCfg.DataDir = dataDir
+	layout, err := initCerts(&layoutCfg)
+	if err != nil {
+		return nil, err
+	}
+	layoutDir, certsDir := cryptomaterial.CertsDirectory(dataDir), cryptomaterial.CertsDirectory(cfg.DataDir)
+
+	certs := []certInfo{}
+	var walk func(path []string, signer *cryptomaterial.CertificateSigner) error
+	walk = func(path []string, signer *cryptomaterial.CertificateSigner) error {
+		for _, name := range signer.GetCertNames() {
+			certPath := append(append([]string{}, path...), name)
+			info := certInfo{Name: strings.Join(certPath, "/")}
+			layoutCertDir, err := signer.GetCertDir(name)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(layoutDir, layoutCertDir)
+			if err != nil {
+				return err
+			}
+			parsed, err := readIssuedCert(filepath.Join(certsDir, rel))
+			if err != nil {
+				return fmt.Errorf("failed to read certificate %s: %w", info.Name, err)
+			}
+			if parsed == nil {
+				info.Missing = true
+			} else {
+				info.NotAfter = parsed.NotAfter
+			}
+			certs = append(certs, info)
+		}
+		for _, name := range signer.GetSubCANames() {
+			if err := walk(append(append([]string{}, path...), name), signer.GetSubCA(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range layout.GetSignerNames() {
+		if err := walk([]string{name}, layout.GetSigner(name)); err != nil {
+			return nil, err
+		}
+	}
+	return certs, nil
+}
+
+// readIssuedCert returns the client, serving or peer certificate in certDir, or nil if
+// none was issued.
+func readIssuedCert(certDir string) (*x509.Certificate, error) {
+	for _, path := range []string{cryptomaterial.ClientCertPath(certDir), cryptomaterial.ServingCertPath(certDir), cryptomaterial.PeerCertPath(certDir)} {
+		certs, err := cert.CertsFromFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return certs[0], nil
+	}
+	return nil, nil
+}
+
+func parseCert(certChains *cryptomaterial.CertificateChains, certPath ...string) (*x509.Certificate, error) {
+	certPEM, _, err := certChains.GetCertKey(certPath...)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := cert.ParseCertsPEM(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", strings.Join(certPath, "/"), err)
+	}
+	return certs[0], nil
+}
+
+// rotateCert removes the named leaf certificate and its key, so that they are issued
+// again along with the kubeconfigs embedding them, and checks that the new certificate
+// is signed by its CA. Rotating a CA invalidates all certificates it signed, so CAs are
+// refused.
+func rotateCert(cfg *config.MicroshiftConfig, name string) error {
+	certPath := strings.Split(name, "/")
+	certChains, err := initCerts(cfg)
+	if err != nil {
+		return err
+	}
+	if certChains.GetSigner(certPath...) != nil {
+		return fmt.Errorf("%s is a CA, rotating it requires issuing all certificates it signed again and is not supported by this command", name)
+	}
+	signer := certChains.GetSigner(certPath[:len(certPath)-1]...)
+	if len(certPath) < 2 || signer == nil {
+		return fmt.Errorf("unknown certificate %s, see 'microshift inspect certs' for the certificate names", name)
+	}
+	certDir, err := signer.GetCertDir(certPath[len(certPath)-1])
+	if err != nil {
+		return fmt.Errorf("unknown certificate %s, see 'microshift inspect certs' for the certificate names", name)
+	}
+	old, err := parseCert(certChains, certPath...)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{
+		cryptomaterial.ClientCertPath(certDir), cryptomaterial.ClientKeyPath(certDir),
+		cryptomaterial.ServingCertPath(certDir), cryptomaterial.ServingKeyPath(certDir),
+		cryptomaterial.PeerCertPath(certDir), cryptomaterial.PeerKeyPath(certDir),
+	} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	if _, err := initAll(context.Background(), cfg); err != nil {
+		return fmt.Errorf("failed to issue certificate %s again: %w", name, err)
+	}
+
+	certChains, err = initCerts(cfg)
+	if err != nil {
+		return err
+	}
+	rotated, err := parseCert(certChains, certPath...)
+	if err != nil {
+		return err
+	}
+	if rotated.Equal(old) {
+		return fmt.Errorf("certificate %s was not issued again", name)
+	}
+	caPEM, err := certChains.GetSigner(certPath[:len(certPath)-1]...).GetSignerCertPEM()
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	if _, err := rotated.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		return fmt.Errorf("rotated certificate %s doesn't chain to its CA: %w", name, err)
+	}
+	return nil
+}
+
+func renderCertsText(w io.Writer, certs []certInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tEXPIRES")
+	for _, c := range certs {
+		expires := "missing"
+		if !c.Missing {
+			expires = c.NotAfter.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", c.Name, expires)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+func newTestCertsConfig(t *testing.T) *config.MicroshiftConfig {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	if _, err := initAll(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestRotateCert(t *testing.T) {
+	cfg := newTestCertsConfig(t)
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	schedulerCert := cryptomaterial.ClientCertPath(filepath.Join(cryptomaterial.KubeControlPlaneSignerCertDir(certsDir), "kube-scheduler"))
+	controllerManagerCert := cryptomaterial.ClientCertPath(filepath.Join(cryptomaterial.KubeControlPlaneSignerCertDir(certsDir), "kube-controller-manager"))
+	readFile := func(path string) []byte {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return contents
+	}
+	before, untouched, kubeconfig := readFile(schedulerCert), readFile(controllerManagerCert), readFile(cfg.KubeConfigPath(config.KubeScheduler))
+
+	if err := rotateCert(cfg, "kube-control-plane-signer/kube-scheduler"); err != nil {
+		t.Fatalf("rotateCert() error = %v", err)
+	}
+
+	if bytes.Equal(readFile(schedulerCert), before) {
+		t.Errorf("expected the kube-scheduler certificate to be issued again")
+	}
+	if bytes.Equal(readFile(cfg.KubeConfigPath(config.KubeScheduler)), kubeconfig) {
+		t.Errorf("expected the kube-scheduler kubeconfig to embed the new certificate")
+	}
+	if !bytes.Equal(readFile(controllerManagerCert), untouched) {
+		t.Errorf("expected the kube-controller-manager certificate to be left untouched")
+	}
+}
+
+func TestRotateCertRefused(t *testing.T) {
+	cfg := newTestCertsConfig(t)
+
+	var tests = []struct {
+		name      string
+		errSubstr string
+	}{
+		{name: "kube-control-plane-signer", errSubstr: "is a CA"},
+		{name: "kubelet-signer/kube-csr-signer", errSubstr: "is a CA"},
+		{name: "kube-control-plane-signer/kube-proxy", errSubstr: "unknown certificate"},
+		{name: "kube-scheduler", errSubstr: "unknown certificate"},
+	}
+	for _, tt := range tests {
+		if err := rotateCert(cfg, tt.name); err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+			t.Errorf("expected rotating %s to fail with %q, got %v", tt.name, tt.errSubstr, err)
+		}
+	}
+}
+
+func TestListCerts(t *testing.T) {
+	cfg := newTestCertsConfig(t)
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	// listing doesn't issue missing certificates
+	schedulerCertDir := filepath.Join(cryptomaterial.KubeControlPlaneSignerCertDir(certsDir), "kube-scheduler")
+	if err := os.RemoveAll(schedulerCertDir); err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := listCerts(cfg)
+	if err != nil {
+		t.Fatalf("listCerts() error = %v", err)
+	}
+	names := map[string]bool{}
+	for _, c := range certs {
+		names[c.Name] = true
+		if c.Name == "kube-control-plane-signer/kube-scheduler" {
+			if !c.Missing {
+				t.Errorf("expected the removed certificate %s to be missing", c.Name)
+			}
+			continue
+		}
+		if !c.NotAfter.After(time.Now()) {
+			t.Errorf("expected certificate %s to be valid, expires %v", c.Name, c.NotAfter)
+		}
+	}
+	for _, expected := range []string{"kube-control-plane-signer/kube-scheduler", "kubelet-signer/kube-csr-signer/kubelet-client"} {
+		if !names[expected] {
+			t.Errorf("expected certificate %s to be listed, got %v", expected, names)
+		}
+	}
+	if names["kube-control-plane-signer"] {
+		t.Errorf("expected CAs not to be listed")
+	}
+	if _, err := os.Stat(schedulerCertDir); !os.IsNotExist(err) {
+		t.Errorf("expected the missing certificate not to be issued, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/util/cert"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+const adminKubeconfigCert = "admin-kubeconfig-signer/admin-kubeconfig-client"
+
+type kubeconfigRotateOptions struct {
+	genericclioptions.IOStreams
+}
+
+func NewKubeconfigCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Manage the kubeconfigs MicroShift generates",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewKubeconfigRotateCommand(ioStreams))
+	return cmd
+}
+
+func NewKubeconfigRotateCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := kubeconfigRotateOptions{
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Issue the admin client certificate again with the existing CA and rewrite the admin kubeconfigs",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *kubeconfigRotateOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+	if err := rotateAdminKubeconfig(cfg); err != nil {
+		return err
+	}
+	for _, id := range adminKubeconfigs(cfg) {
+		fmt.Fprintf(opts.Out, "Rewrote kubeconfig %s\n", cfg.KubeConfigPath(id))
+	}
+	fmt.Fprintln(opts.ErrOut, "The previous admin client certificate remains valid until it expires, as Kubernetes doesn't revoke certificates")
+	return nil
+}
+
+// adminKubeconfigs returns the kubeconfigs embedding the admin client certificate
+func adminKubeconfigs(cfg *config.MicroshiftConfig) []config.KubeConfigID {
+	ids := []config.KubeConfigID{config.KubeAdmin}
+	if cfg.APIServer.ExternalURL != "" {
+		ids = append(ids, config.KubeAdminExternal)
+	}
+	return ids
+}
+
+// rotateAdminKubeconfig issues the admin client certificate again and rewrites the
+// kubeconfigs embedding it. The certificate must be signed by the existing CA, so a
+// missing or invalid CA is refused instead of generating a new one, which would
+// invalidate all admin kubeconfigs handed out.
+func rotateAdminKubeconfig(cfg *config.MicroshiftConfig) error {
+	caDir := cryptomaterial.AdminKubeconfigSignerDir(cryptomaterial.CertsDirectory(cfg.DataDir))
+	if err := verifyCA(caDir); err != nil {
+		return fmt.Errorf("refusing to rotate the admin kubeconfig: %w", err)
+	}
+	caPEM, err := os.ReadFile(cryptomaterial.CACertPath(caDir))
+	if err != nil {
+		return err
+	}
+
+	if err := rotateCert(cfg, adminKubeconfigCert); err != nil {
+		return err
+	}
+
+	rotatedPEM, err := os.ReadFile(cryptomaterial.CACertPath(caDir))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(caPEM, rotatedPEM) {
+		return fmt.Errorf("the admin kubeconfig CA in %s changed while rotating", caDir)
+	}
+	for _, id := range adminKubeconfigs(cfg) {
+		if err := verifyKubeconfig(cfg.KubeConfigPath(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyCA checks that the CA in dir exists, is currently valid and matches its key.
+func verifyCA(dir string) error {
+	certPath, keyPath := cryptomaterial.CACertPath(dir), cryptomaterial.CAKeyPath(dir)
+	pemBytes, err := os.ReadFile(certPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the CA: %w", err)
+	}
+	certs, err := cert.ParseCertsPEM(pemBytes)
+	if err != nil {
+		return fmt.Errorf("invalid CA %s: %w", certPath, err)
+	}
+	now := time.Now()
+	if !certs[0].IsCA || now.Before(certs[0].NotBefore) || now.After(certs[0].NotAfter) {
+		return fmt.Errorf("%s is not a CA valid at %v", certPath, now)
+	}
+	if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		return fmt.Errorf("CA %s does not match its key: %w", certPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+func TestRotateAdminKubeconfig(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.APIServer.ExternalURL = "https://api.example.com:6443"
+	if _, err := initAll(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	caDir := cryptomaterial.AdminKubeconfigSignerDir(cryptomaterial.CertsDirectory(cfg.DataDir))
+	readFile := func(path string) []byte {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return contents
+	}
+	clientCert := cryptomaterial.ClientCertPath(cryptomaterial.AdminKubeconfigClientCertDir(cryptomaterial.CertsDirectory(cfg.DataDir)))
+	schedulerCert := cryptomaterial.ClientCertPath(filepath.Join(cryptomaterial.KubeControlPlaneSignerCertDir(cryptomaterial.CertsDirectory(cfg.DataDir)), "kube-scheduler"))
+	ca, before, untouched := readFile(cryptomaterial.CACertPath(caDir)), readFile(clientCert), readFile(schedulerCert)
+	kubeconfigs := map[config.KubeConfigID][]byte{}
+	for _, id := range []config.KubeConfigID{config.KubeAdmin, config.KubeAdminExternal} {
+		kubeconfigs[id] = readFile(cfg.KubeConfigPath(id))
+	}
+
+	if err := rotateAdminKubeconfig(cfg); err != nil {
+		t.Fatalf("rotateAdminKubeconfig() error = %v", err)
+	}
+
+	if bytes.Equal(readFile(clientCert), before) {
+		t.Errorf("expected the admin client certificate to be issued again")
+	}
+	if !bytes.Equal(readFile(cryptomaterial.CACertPath(caDir)), ca) {
+		t.Errorf("expected the admin kubeconfig CA to be preserved")
+	}
+	for _, id := range []config.KubeConfigID{config.KubeAdmin, config.KubeAdminExternal} {
+		if bytes.Equal(readFile(cfg.KubeConfigPath(id)), kubeconfigs[id]) {
+			t.Errorf("expected the %s kubeconfig to embed the new certificate", id)
+		}
+	}
+	if !bytes.Equal(readFile(schedulerCert), untouched) {
+		t.Errorf("expected the kube-scheduler certificate to be left untouched")
+	}
+}
+
+func TestRotateAdminKubeconfigInvalidCA(t *testing.T) {
+	var tests = []struct {
+		name      string
+		breakCA   func(certsDir string) error
+		errSubstr string
+	}{
+		{name: "missing", breakCA: func(certsDir string) error {
+			return os.RemoveAll(cryptomaterial.AdminKubeconfigSignerDir(certsDir))
+		}, errSubstr: "failed to read the CA"},
+		{name: "mismatched key", breakCA: func(certsDir string) error {
+			// the key of another CA
+			contents, err := os.ReadFile(cryptomaterial.CAKeyPath(cryptomaterial.KubeControlPlaneSignerCertDir(certsDir)))
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(cryptomaterial.CAKeyPath(cryptomaterial.AdminKubeconfigSignerDir(certsDir)), contents, 0600)
+		}, errSubstr: "does not match its key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestCertsConfig(t)
+			certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+			caDir := cryptomaterial.AdminKubeconfigSignerDir(certsDir)
+			if err := tt.breakCA(certsDir); err != nil {
+				t.Fatal(err)
+			}
+			kubeconfig, err := os.ReadFile(cfg.KubeConfigPath(config.KubeAdmin))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := rotateAdminKubeconfig(cfg); err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+				t.Errorf("expected the rotation to be refused with %q, got %v", tt.errSubstr, err)
+			}
+			if _, err := os.Stat(cryptomaterial.CACertPath(caDir)); tt.name == "missing" && !os.IsNotExist(err) {
+				t.Errorf("expected no CA to be generated, got %v", err)
+			}
+			if after, _ := os.ReadFile(cfg.KubeConfigPath(config.KubeAdmin)); !bytes.Equal(after, kubeconfig) {
+				t.Errorf("expected the admin kubeconfig to be left untouched")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+type kubeconfigsOptions struct {
+	Output string
+	genericclioptions.IOStreams
+}
+
+func NewKubeconfigsCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := kubeconfigsOptions{
+		Output:    "text",
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "kubeconfigs",
+		Short: "Print the kubeconfigs generated by the running MicroShift instance and the server URL each targets",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.Output, "output", "o", opts.Output, "One of 'text' or 'json'.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *kubeconfigsOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if opts.Output != "text" && opts.Output != "json" {
+		return fmt.Errorf("unknown output format %q", opts.Output)
+	}
+	// the data directory holding the control socket may be configured
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+
+	kubeconfigs, err := getKubeconfigs(cfg.ControlSocketPath())
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		marshalled, err := json.MarshalIndent(kubeconfigs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Out, "%s\n", string(marshalled))
+		return nil
+	}
+	return renderKubeconfigsText(opts.Out, kubeconfigs)
+}
+
+func renderKubeconfigsText(w io.Writer, kubeconfigs []kubeconfigInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSERVER\tPATH")
+	for _, k := range kubeconfigs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", k.Name, k.Server, k.Path)
+	}
+	return tw.Flush()
+}
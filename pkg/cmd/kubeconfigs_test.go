@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+)
+
+func TestControlSocketKubeconfigs(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.APIServer.ExternalURL = "https://api.example.com:6443"
+
+	kubeconfigs, err := initAll(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "microshift.sock")
+	if err := serveControlSocket(ctx, path, servicemanager.NewServiceManager(), kubeconfigs, nil); err != nil {
+		t.Fatalf("failed to serve control socket: %v", err)
+	}
+
+	got, err := getKubeconfigs(path)
+	if err != nil {
+		t.Fatalf("failed to get kubeconfigs: %v", err)
+	}
+	expected := []kubeconfigInfo{
+		{Name: config.KubeAdmin, Path: cfg.KubeConfigPath(config.KubeAdmin), Server: cfg.Cluster.URL},
+		{Name: config.KubeControllerManager, Path: cfg.KubeConfigPath(config.KubeControllerManager), Server: cfg.Cluster.URL},
+		{Name: config.KubeScheduler, Path: cfg.KubeConfigPath(config.KubeScheduler), Server: cfg.Cluster.URL},
+		{Name: config.Kubelet, Path: cfg.KubeConfigPath(config.Kubelet), Server: cfg.Cluster.URL},
+		{Name: config.KubeAdminExternal, Path: cfg.KubeConfigPath(config.KubeAdminExternal), Server: cfg.APIServer.ExternalURL},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected kubeconfigs %v, got %v", expected, got)
+	}
+	for _, k := range got {
+		if err := verifyKubeconfig(k.Path); err != nil {
+			t.Errorf("%s: %v", k.Name, err)
+		}
+	}
+}
+
+func TestControlSocketNoKubeconfigs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "microshift.sock")
+	if err := serveControlSocket(ctx, path, servicemanager.NewServiceManager(), nil, nil); err != nil {
+		t.Fatalf("failed to serve control socket: %v", err)
+	}
+
+	kubeconfigs, err := getKubeconfigs(path)
+	if err != nil {
+		t.Fatalf("failed to get kubeconfigs: %v", err)
+	}
+	if len(kubeconfigs) != 0 {
+		t.Errorf("expected no kubeconfigs, got %v", kubeconfigs)
+	}
+}
+
+func TestRenderKubeconfigsText(t *testing.T) {
+	expected := `NAME       SERVER                  PATH
+kubeadmin  https://127.0.0.1:6443  /var/lib/microshift/resources/kubeadmin/kubeconfig
+`
+	var out bytes.Buffer
+	kubeconfigs := []kubeconfigInfo{
+		{Name: config.KubeAdmin, Path: "/var/lib/microshift/resources/kubeadmin/kubeconfig", Server: "https://127.0.0.1:6443"},
+	}
+	if err := renderKubeconfigsText(&out, kubeconfigs); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("expected text output\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/v2"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+type listServicesOptions struct {
+	Output string
+	genericclioptions.IOStreams
+}
+
+// plannedService is a service that runs with the resolved configuration
+type plannedService struct {
+	Name         string   `json:"name"`
+	Dependencies []string `json:"dependencies"`
+	Optional     bool     `json:"optional"`
+}
+
+func NewListServicesCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := listServicesOptions{
+		Output:    "text",
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "list-services",
+		Short: "Print the services MicroShift runs with the configured roles and features, without starting them",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.Output, "output", "o", opts.Output, "One of 'text' or 'json'.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *listServicesOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if opts.Output != "text" && opts.Output != "json" {
+		return fmt.Errorf("unknown output format %q", opts.Output)
+	}
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+
+	services, err := listServices(cfg)
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		marshalled, err := json.MarshalIndent(services, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Out, "%s\n", string(marshalled))
+		return nil
+	}
+	return renderServicesText(opts.Out, services)
+}
+
+// listServices returns the services MicroShift runs with cfg, in the order they are
+// added. The services write their configuration when they are created, so they are
+// created against a temporary data directory.
+func listServices(cfg *config.MicroshiftConfig) ([]plannedService, error) {
+	dataDir, err := os.MkdirTemp("", "microshift-list-services-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary data directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dataDir); err != nil {
+			klog.Warningf("failed to remove temporary data directory %s: %v", dataDir, err)
+		}
+	}()
+
+	listCfg := *cfg
+	listCfg.DataDir = dataDir
+	services := []plannedService{}
+	for _, status := range newServiceManager(&listCfg).Status() {
+		services = append(services, plannedService{
+			Name:         status.Name,
+			Dependencies: status.Dependencies,
+			Optional:     status.Optional,
+		})
+	}
+	return services, nil
+}
+
+func renderServicesText(w io.Writer, services []plannedService) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tCLASS\tDEPENDENCIES")
+	for _, s := range services {
+		class := "critical"
+		if s.Optional {
+			class = "optional"
+		}
+		deps := strings.Join(s.Dependencies, ",")
+		if deps == "" {
+			deps = "<none>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, class, deps)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func serviceNames(services []plannedService) []string {
+	names := []string{}
+	for _, s := range services {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestListServices(t *testing.T) {
+	controlPlane := []string{
+		"etcd",
+		"kube-apiserver",
+		"audit-log-sweeper",
+		"kube-scheduler",
+		"kube-controller-manager",
+		"openshift-crd-manager",
+		"route-controller-manager",
+		"cluster-policy-controller",
+		"openshift-default-scc-manager",
+		"microshift-mdns-controller",
+		"infrastructure-services-manager",
+		"version-manager",
+		"kustomizer",
+	}
+	common := []string{"sysconfwatch-controller", "clock-monitor"}
+
+	var tests = []struct {
+		roles    []string
+		expected []string
+	}{
+		{roles: []string{config.ControlPlaneRole}, expected: append(append([]string{}, common...), controlPlane...)},
+		{roles: []string{config.NodeRole}, expected: append(append([]string{}, common...), "kubelet")},
+		{roles: []string{config.ControlPlaneRole, config.NodeRole}, expected: append(append(append([]string{}, common...), controlPlane...), "kubelet")},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Roles = tt.roles
+
+		services, err := listServices(cfg)
+		if err != nil {
+			t.Fatalf("listServices() with roles %v error = %v", tt.roles, err)
+		}
+		if got := serviceNames(services); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("expected services %v for roles %v, got %v", tt.expected, tt.roles, got)
+		}
+	}
+}
+
+// dependsOn returns whether the service named name waits for dependency, directly or
+// through its own dependencies.
+func dependsOn(services []plannedService, name, dependency string) bool {
+	for _, s := range services {
+		if s.Name != name {
+			continue
+		}
+		for _, d := range s.Dependencies {
+			if d == dependency || dependsOn(services, d, dependency) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestListServicesNodeWaitsForAPIServer(t *testing.T) {
+	var tests = []struct {
+		roles    []string
+		expected bool
+	}{
+		{roles: []string{config.NodeRole}, expected: false},
+		{roles: []string{config.ControlPlaneRole, config.NodeRole}, expected: true},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Roles = tt.roles
+		cfg.Node.PrePullImages = []string{"registry.k8s.io/busybox"}
+
+		services, err := listServices(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"kubelet", "image-prepuller"} {
+			if got := dependsOn(services, name, "kube-apiserver"); got != tt.expected {
+				t.Errorf("expected %s to wait for kube-apiserver %v with roles %v, got %v", name, tt.expected, tt.roles, got)
+			}
+		}
+	}
+}
+
+func TestListServicesOptional(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Services.Optional = []string{"kustomizer"}
+
+	services, err := listServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range services {
+		if expected := cfg.ServiceOptional(s.Name); s.Optional != expected {
+			t.Errorf("expected %s to be optional=%v, got %v", s.Name, expected, s.Optional)
+		}
+		if s.Name == "kustomizer" && !s.Optional {
+			t.Errorf("expected the kustomizer to be optional")
+		}
+	}
+}
+
+func TestListServicesAPIServerSocket(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Roles = []string{config.ControlPlaneRole}
+	cfg.APIServer.Socket = "/run/microshift/apiserver.sock"
+
+	services, err := listServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range services {
+		if s.Name == "kube-apiserver-socket" {
+			if !reflect.DeepEqual(s.Dependencies, []string{"kube-apiserver"}) {
+				t.Errorf("expected the socket to depend on kube-apiserver, got %v", s.Dependencies)
+			}
+			return
+		}
+	}
+	t.Errorf("expected the apiserver socket to run, got %v", serviceNames(services))
+}
+
+func TestListServicesDefaultNetworkPolicy(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Roles = []string{config.ControlPlaneRole}
+	cfg.Cluster.DefaultNetworkPolicy = config.DefaultNetworkPolicyDenyAllIngress
+
+	services, err := listServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range services {
+		if s.Name == "default-network-policy-controller" {
+			return
+		}
+	}
+	t.Errorf("expected the default network policy controller to run, got %v", serviceNames(services))
+}
+
+func TestListServicesPriorityClasses(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Roles = []string{config.ControlPlaneRole}
+	cfg.Scheduler.PriorityClasses = []config.PriorityClass{{Name: "workload-default", Value: 1000}}
+
+	services, err := listServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range services {
+		if s.Name == "priority-class-manager" {
+			return
+		}
+	}
+	t.Errorf("expected the priority class manager to run, got %v", serviceNames(services))
+}
+
+func TestListServicesOpenShiftComponents(t *testing.T) {
+	var tests = []struct {
+		disabled []string
+		mdns     bool
+		omitted  []string
+	}{
+		{
+			disabled: []string{config.ComponentOpenShiftSCC},
+			mdns:     true,
+			omitted:  []string{"openshift-default-scc-manager"},
+		},
+		{
+			disabled: []string{config.ComponentIngress, config.ComponentOpenShiftControllerManager},
+			mdns:     true,
+			omitted:  []string{"route-controller-manager"},
+		},
+		{
+			disabled: []string{config.ComponentIngress, config.ComponentStorage, config.ComponentOpenShiftCRDs, config.ComponentOpenShiftControllerManager, config.ComponentOpenShiftSCC},
+			mdns:     false,
+			omitted:  []string{"openshift-crd-manager", "route-controller-manager", "openshift-default-scc-manager"},
+		},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Roles = []string{config.ControlPlaneRole}
+		cfg.Components.Disabled = tt.disabled
+		cfg.MDNS.Enabled = tt.mdns
+
+		// the remaining services must not depend on the omitted ones
+		services, err := listServices(cfg)
+		if err != nil {
+			t.Fatalf("listServices() with disabled components %v error = %v", tt.disabled, err)
+		}
+		names := serviceNames(services)
+		for _, name := range tt.omitted {
+			if config.StringInList(name, names) {
+				t.Errorf("expected %s not to run with disabled components %v, got %v", name, tt.disabled, names)
+			}
+		}
+		if !config.StringInList("infrastructure-services-manager", names) || tt.mdns != config.StringInList("microshift-mdns-controller", names) {
+			t.Errorf("expected the other services to run with disabled components %v, got %v", tt.disabled, names)
+		}
+	}
+}
+
+func TestRenderServicesText(t *testing.T) {
+	expected := `SERVICE         CLASS     DEPENDENCIES
+etcd            critical  <none>
+kube-apiserver  critical  etcd
+kustomizer      optional  kube-apiserver
+`
+	var out bytes.Buffer
+	err := renderServicesText(&out, []plannedService{
+		{Name: "etcd", Dependencies: []string{}},
+		{Name: "kube-apiserver", Dependencies: []string{"etcd"}},
+		{Name: "kustomizer", Dependencies: []string{"kube-apiserver"}, Optional: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("expected text output\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// newLogWriter returns a writer to the log file that rotates it once it reached the
+// configured size.
+func newLogWriter(logging config.LoggingConfig) (*lumberjack.Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(logging.File), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	return &lumberjack.Logger{
+		Filename:   logging.File,
+		MaxSize:    logging.MaxSizeMB,
+		MaxBackups: logging.MaxBackups,
+		MaxAge:     logging.MaxAgeDays,
+	}, nil
+}
+
+// logToFile routes klog's output to the rotating log file. Errors are still written
+// to stderr, so that they show up in the journal.
+func logToFile(logging config.LoggingConfig) (*lumberjack.Logger, error) {
+	w, err := newLogWriter(logging)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	// klog writes messages once per severity at or below theirs unless one_output is set,
+	// which would duplicate warnings and errors in the single file
+	for name, value := range map[string]string{"logtostderr": "false", "one_output": "true"} {
+		if err := fs.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	klog.SetOutput(w)
+	return w, nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+func TestLogRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "log")
+	logging := config.LoggingConfig{
+		File:       filepath.Join(dir, "microshift.log"),
+		MaxSizeMB:  1,
+		MaxBackups: 2,
+	}
+	w, err := newLogWriter(logging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	line := append(bytes.Repeat([]byte("x"), 1023), '\n')
+	countFiles := func() int {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+
+	// a full megabyte fits into the log file
+	for i := 0; i < 1024; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := countFiles(); n != 1 {
+		t.Fatalf("expected no rotation below %dMB, found %d files", logging.MaxSizeMB, n)
+	}
+
+	if _, err := w.Write(line); err != nil {
+		t.Fatal(err)
+	}
+	if n := countFiles(); n != 2 {
+		t.Errorf("expected the log file to be rotated at %dMB, found %d files", logging.MaxSizeMB, n)
+	}
+	info, err := os.Stat(logging.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(line)) {
+		t.Errorf("expected the new log file to contain only the last line, got %d bytes", info.Size())
+	}
+}
+
+func TestLogToFile(t *testing.T) {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	defer func() {
+		fs.Set("logtostderr", "true")
+		fs.Set("one_output", "false")
+		klog.SetOutput(os.Stderr)
+	}()
+
+	logging := config.NewMicroshiftConfig().Logging
+	logging.File = filepath.Join(t.TempDir(), "microshift.log")
+	w, err := logToFile(logging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	klog.Warning("test message")
+	klog.Flush()
+
+	contents, err := os.ReadFile(logging.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(contents), "test message"); n != 1 {
+		t.Errorf("expected the message to be logged to the file once, found it %d times in %q", n, string(contents))
+	}
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+const logsFollowInterval = 500 * time.Millisecond
+
+// logEntryHeader matches the header klog starts each entry with: the service that
+// logged the entry, or ??? outside of the services, followed by the severity and the
+// timestamp, e.g. "etcd I1014 09:21:07.123456" or "etcd I2022-10-14T09:21:07Z".
+var logEntryHeader = regexp.MustCompile(`^(\S+) [IWEF]\d`)
+
+type logsOptions struct {
+	Components []string
+	Tail       int
+	Follow     bool
+	// followInterval is how often a followed log file is checked for new lines
+	followInterval time.Duration
+	genericclioptions.IOStreams
+}
+
+func NewLogsCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := logsOptions{
+		Tail:           -1,
+		followInterval: logsFollowInterval,
+		IOStreams:      ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Print the log of MicroShift from its log file, optionally only the entries of some services",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVar(&opts.Components, "component", opts.Components, "Only print the entries of these services, e.g. etcd,kube-apiserver. Entries logged outside of the services are labeled ???.")
+	flags.IntVar(&opts.Tail, "tail", opts.Tail, "Number of recent lines to print, -1 prints all lines of the log file.")
+	flags.BoolVarP(&opts.Follow, "follow", "f", opts.Follow, "Keep printing new lines as they are logged.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *logsOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+	if cfg.Logging.File == "" {
+		return errors.New("logging.file isn't set, MicroShift logs to stderr, e.g. read it with journalctl -u microshift")
+	}
+
+	filter := newLogFilter(opts.Components)
+	offset, err := printLogs(cfg.Logging.File, filter, opts.Tail, opts.Out)
+	if err != nil || !opts.Follow {
+		return err
+	}
+	return followLogs(cmd.Context(), cfg.Logging.File, offset, filter, opts.followInterval, opts.Out)
+}
+
+// logFilter selects the entries of the components, or all entries if there are none.
+// Lines without a header continue the entry before them.
+type logFilter struct {
+	components sets.String
+	selected   bool
+}
+
+func newLogFilter(components []string) *logFilter {
+	return &logFilter{components: sets.NewString(components...)}
+}
+
+func (f *logFilter) match(line string) bool {
+	if f.components.Len() == 0 {
+		return true
+	}
+	if m := logEntryHeader.FindStringSubmatch(line); m != nil {
+		f.selected = f.components.Has(m[1])
+	}
+	return f.selected
+}
+
+// printLogs writes the last tail lines of the log file the filter matches to out, or
+// all of them if tail is negative. It returns the offset up to which the file was read.
+func printLogs(path string, filter *logFilter, tail int, out io.Writer) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	offset, err := readLogLines(f, filter, func(line string) {
+		if tail < 0 {
+			fmt.Fprint(out, line)
+			return
+		}
+		lines = append(lines, line)
+		if len(lines) > tail {
+			lines = lines[1:]
+		}
+	})
+	for _, line := range lines {
+		fmt.Fprint(out, line)
+	}
+	return offset, err
+}
+
+// followLogs writes the lines of the log file the filter matches as they are appended
+// after offset until ctx is done. Once the file is rotated, the rest of the rotated
+// file is read before the new file is read from its start.
+func followLogs(ctx context.Context, path string, offset int64, filter *logFilter, interval time.Duration, out io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+	for {
+		if offset, err = printLogsFrom(f, offset, filter, out); err != nil {
+			return err
+		}
+
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		// the new file may not exist yet right after the rotated one was renamed
+		if info, err := os.Stat(path); err == nil && !os.SameFile(current, info) {
+			next, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			f.Close()
+			f, offset = next, 0
+			continue
+		}
+		if current.Size() < offset {
+			// truncated
+			offset = 0
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// printLogsFrom writes the complete lines of f after offset the filter matches to out
+// and returns the offset after the last complete line.
+func printLogsFrom(f *os.File, offset int64, filter *logFilter, out io.Writer) (int64, error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	read, err := readLogLines(f, filter, func(line string) { fmt.Fprint(out, line) })
+	return offset + read, err
+}
+
+// readLogLines calls print with each complete line of r the filter matches and returns
+// the number of bytes read up to the last complete line, so that a line klog is still
+// writing is read again once it is complete.
+func readLogLines(r io.Reader, filter *logFilter, print func(line string)) (int64, error) {
+	br := bufio.NewReader(r)
+	var read int64
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			return read, nil
+		}
+		if err != nil {
+			return read, err
+		}
+		read += int64(len(line))
+		if filter.match(line) {
+			print(line)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testLog = `??? I1014 09:21:07.000001   12345 run.go:180] Starting MicroShift
+etcd I1014 09:21:07.100000   12345 etcd.go:140] etcd is ready
+kube-apiserver W1014 09:21:08.000000   12345 apiserver.go:10] slow request:
+  GET /api/v1/namespaces
+kube-apiserver I2022-10-14T09:21:09.123456789Z   12345 kube-apiserver.go:300] kube-apiserver is ready
+etcd E1014 09:21:10.000000   12345 etcd.go:160] etcd lost its lease
+??? I1014 09:21:11.000000   12345 run.go:310] MicroShift is ready
+`
+
+func TestPrintLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "microshift.log")
+	if err := os.WriteFile(path, []byte(testLog), 0600); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(testLog, "\n")
+
+	var tests = []struct {
+		name       string
+		components []string
+		tail       int
+		expected   []string
+	}{
+		{name: "all", tail: -1, expected: lines[:7]},
+		{name: "tail", tail: 2, expected: lines[5:7]},
+		{name: "component", components: []string{"kube-apiserver"}, tail: -1, expected: lines[2:5]},
+		{name: "components", components: []string{"etcd", "???"}, tail: -1, expected: []string{lines[0], lines[1], lines[5], lines[6]}},
+		{name: "component tail", components: []string{"etcd"}, tail: 1, expected: lines[5:6]},
+		{name: "unknown component", components: []string{"kubelet"}, tail: -1, expected: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			offset, err := printLogs(path, newLogFilter(tt.components), tt.tail, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offset != int64(len(testLog)) {
+				t.Errorf("expected the whole file to be read, got offset %d of %d", offset, len(testLog))
+			}
+			if expected := strings.Join(tt.expected, ""); out.String() != expected {
+				t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+			}
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollowLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "microshift.log")
+	if err := os.WriteFile(path, []byte(testLog), 0600); err != nil {
+		t.Fatal(err)
+	}
+	appendLog := func(s string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out syncBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- followLogs(ctx, path, int64(len(testLog)), newLogFilter([]string{"etcd"}), time.Millisecond, &out)
+	}()
+
+	// a partially written line is only printed once it is complete
+	appendLog("kube-apiserver I1014 09:22:00.000000   12345 apiserver.go:10] ignored\netcd I1014 09:22:01.000000   12345 etcd.go:1] compacted")
+	time.Sleep(20 * time.Millisecond)
+	appendLog(" revision 10\n")
+	// the log file is rotated
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("etcd I1014 09:23:00.000000   12345 etcd.go:2] defragmented\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "etcd I1014 09:22:01.000000   12345 etcd.go:1] compacted revision 10\netcd I1014 09:23:00.000000   12345 etcd.go:2] defragmented\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for out.String() != expected && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("expected the new etcd entries\n%s\ngot\n%s", expected, out.String())
+	}
+}
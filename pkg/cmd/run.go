@@ -2,8 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,19 +21,57 @@ import (
 	"github.com/openshift/microshift/pkg/servicemanager"
 	"github.com/openshift/microshift/pkg/sysconfwatch"
 	"github.com/openshift/microshift/pkg/util"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/sys/unix"
 
 	"k8s.io/klog/v2"
 )
 
 const (
 	gracefulShutdownTimeout = 60
+	defaultBindTimeout      = 10 * time.Second
+	defaultInitTimeout      = 2 * time.Minute
+	// time the apiserver needs after its shutdown delay to drain requests and the
+	// services depending on it need to stop
+	shutdownDrainMargin = 10 * time.Second
+	// how often the systemd status is updated with the state of the services
+	statusUpdatePeriod = 2 * time.Second
+	// the backoff between retried boots doubles up to this limit
+	defaultBootBackoff = 10 * time.Second
+	maxBootBackoff     = 5 * time.Minute
 )
 
+// mkdirAll, access, chmod and isMountPoint are variables so that tests can simulate
+// filesystem failures
+var (
+	mkdirAll     = os.MkdirAll
+	access       = unix.Access
+	chmod        = os.Chmod
+	isMountPoint = mountPoint
+)
+
+// geteuid is a variable so that tests can simulate running unprivileged
+var geteuid = os.Geteuid
+
+// sdNotify is a variable so that tests can observe the readiness notification
+var sdNotify = daemon.SdNotify
+
+type componentPort struct {
+	component string
+	port      int
+}
+
 func addRunFlags(cmd *cobra.Command, cfg *config.MicroshiftConfig) {
 	flags := cmd.Flags()
+	flags.String("config", "", "Config file to read, defaults to ~/.microshift/config.yaml or /etc/microshift/config.yaml.")
 	// All other flags will be read after reading both config file and env vars.
+	flags.String("profile", cfg.Profile, "Profile selecting the roles, components and defaults of this MicroShift instance ("+strings.Join(config.ProfileNames(), ", ")+").")
+	flags.StringSlice("roles", cfg.Roles, "Roles of this MicroShift instance.")
+	flags.String("data-dir", cfg.DataDir, "Directory for storing runtime data.")
+	flags.String("audit-log-dir", cfg.AuditLogDir, "Directory for storing audit logs.")
+	flags.StringSlice("disabled-components", cfg.Components.Disabled, "Infrastructure components not to deploy and OpenShift-specific services not to run (service-ca, storage, ingress, dns, network, openshift-crds, openshift-controller-manager, openshift-scc).")
 	flags.String("node-name", cfg.NodeName, "The hostname of the node.")
 	flags.String("node-ip", cfg.NodeIP, "The IP address of the node.")
 	flags.String("url", cfg.Cluster.URL, "The URL of the API server.")
@@ -38,6 +81,18 @@ func addRunFlags(cmd *cobra.Command, cfg *config.MicroshiftConfig) {
 	flags.String("cluster-dns", cfg.Cluster.DNS, "Comma-separated list of DNS server IP address. This value is used for containers DNS server in case of Pods with \"dnsPolicy=ClusterFirst\".")
 	flags.String("cluster-domain", cfg.Cluster.Domain, "Domain for this cluster.")
 	flags.String("cluster-mtu", cfg.Cluster.MTU, "Network MTU for pods in the cluster.")
+	flags.Bool("disable-mdns", !cfg.MDNS.Enabled, "Don't announce the node and routes via mDNS.")
+	flags.Bool("disable-manifests", !cfg.Manifests.Enabled, "Don't apply the manifests from the manifests directories.")
+	flags.Bool("disable-metrics", !cfg.Metrics.Enabled, "Don't serve etcd's metrics.")
+	flags.Bool("no-sysctl", !cfg.Node.ManageSysctls, "Don't set the kernel parameters the node requires and node.sysctls, e.g. if the host's configuration manages them.")
+}
+
+// configFileFlag returns the config file passed with --config, empty for the default
+// one, so that the subcommands read the same configuration as the service they
+// talk to.
+func configFileFlag(flags *pflag.FlagSet) string {
+	configFile, _ := flags.GetString("config")
+	return configFile
 }
 
 func NewRunMicroshiftCommand() *cobra.Command {
@@ -52,65 +107,180 @@ func NewRunMicroshiftCommand() *cobra.Command {
 	}
 
 	addRunFlags(cmd, cfg)
+	cmd.Flags().Duration("bind-timeout", defaultBindTimeout, "How long to wait for ports required by MicroShift to become available before giving up.")
+	cmd.Flags().Duration("init-timeout", defaultInitTimeout, "How long to wait for the certificates and kubeconfigs to be generated or loaded before giving up.")
+	cmd.Flags().Duration("ready-delay", 0, "How long to wait after MicroShift became ready before notifying systemd, so that dependent units don't start while it settles.")
+	cmd.Flags().Duration("ready-dns-timeout", 0, "How long to wait for the cluster DNS to resolve the kubernetes service before notifying systemd that MicroShift is ready, stopping MicroShift if it doesn't. 0 disables the check.")
+	cmd.Flags().Int("boot-retries", 0, "How often to retry before starting any service if etcd's data directory is still locked, e.g. by a previous instance that is stopping, instead of exiting. Failed services are not restarted, use systemd's Restart= for that.")
+	cmd.Flags().Duration("boot-backoff", defaultBootBackoff, "How long to wait before the first retry, doubling for each further retry up to "+maxBootBackoff.String()+".")
+	cmd.Flags().Bool("strict-security", false, "Fail instead of warning if SELinux or AppArmor likely deny operations MicroShift requires.")
+	cmd.Flags().Bool("strict-no-proxy", false, "Fail on malformed NO_PROXY or no_proxy entries instead of only logging them.")
+	cmd.Flags().Bool("restrict-dir-permissions", false, "Restrict existing data and audit log directories that others can access to the owner. Symlinks and mount points are always left as they are.")
+	cmd.Flags().Bool("skip-privilege-check", false, "Start even if MicroShift isn't run privileged, e.g. in unprivileged test containers. Unsafe, for development and testing only.")
+	cmd.Flags().Bool("config-check-only", false, "Validate the configuration and generate all certificates and kubeconfigs in a temporary directory, then exit without starting MicroShift.")
 
 	return cmd
 }
 
+// checkPrivileges fails unless MicroShift runs privileged, only warning instead if skip
+// is set.
+func checkPrivileges(skip bool) error {
+	if geteuid() == 0 {
+		return nil
+	}
+	if !skip {
+		return errors.New("MicroShift must be run privileged")
+	}
+	klog.Warningf("UNSAFE: MicroShift isn't run privileged and --skip-privilege-check is set, services requiring privileges will fail. Use this for development and testing only.")
+	return nil
+}
+
 func RunMicroshift(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) error {
-	if err := cfg.ReadAndValidate("", flags); err != nil {
-		klog.Fatalf("Error in reading and validating flags", err)
+	if err := cfg.ReadAndValidate(configFileFlag(flags), flags); err != nil {
+		return exitError(ExitConfigError, fmt.Errorf("error in reading and validating flags: %w", err))
+	}
+	klog.SetMicroshiftUTCTimestamps(cfg.Logging.UTCTimestamps)
+	if warning := shutdownBudgetWarning(cfg, time.Duration(gracefulShutdownTimeout)*time.Second); warning != "" {
+		klog.Warning(warning)
+	}
+
+	initTimeout, err := flags.GetDuration("init-timeout")
+	if err != nil {
+		initTimeout = defaultInitTimeout
+	}
+	readyDelay, _ := flags.GetDuration("ready-delay")
+	if readyDelay < 0 {
+		return exitError(ExitConfigError, fmt.Errorf("invalid --ready-delay %s, must not be negative", readyDelay))
+	}
+	readyDNSTimeout, _ := flags.GetDuration("ready-dns-timeout")
+	if readyDNSTimeout < 0 {
+		return exitError(ExitConfigError, fmt.Errorf("invalid --ready-dns-timeout %s, must not be negative", readyDNSTimeout))
+	}
+	bootRetries, _ := flags.GetInt("boot-retries")
+	if bootRetries < 0 {
+		return exitError(ExitConfigError, fmt.Errorf("invalid --boot-retries %d, must not be negative", bootRetries))
+	}
+	bootBackoff, err := flags.GetDuration("boot-backoff")
+	if err != nil {
+		bootBackoff = defaultBootBackoff
+	}
+	if bootBackoff < 0 {
+		return exitError(ExitConfigError, fmt.Errorf("invalid --boot-backoff %s, must not be negative", bootBackoff))
+	}
+
+	if checkOnly, err := flags.GetBool("config-check-only"); err == nil && checkOnly {
+		ctx, cancel := context.WithTimeout(context.Background(), initTimeout)
+		defer cancel()
+		if err := selfTest(ctx, cfg); err != nil {
+			return exitError(ExitCertError, fmt.Errorf("self-test failed: %w", err))
+		}
+		klog.Infof("Self-test passed")
+		return nil
+	}
+
+	if cfg.Logging.File != "" {
+		logFile, err := logToFile(cfg.Logging)
+		if err != nil {
+			return exitError(ExitConfigError, err)
+		}
+		defer func() {
+			klog.Flush()
+			logFile.Close()
+		}()
 	}
 
 	// fail early if we don't have enough privileges
-	if os.Geteuid() > 0 {
-		klog.Fatalf("MicroShift must be run privileged")
+	skipPrivilegeCheck, _ := flags.GetBool("skip-privilege-check")
+	if err := checkPrivileges(skipPrivilegeCheck); err != nil {
+		return exitError(ExitConfigError, err)
 	}
 
+	if err := runHooks("pre-start", cfg.Hooks.PreStart); err != nil {
+		return exitError(ExitHookError, err)
+	}
+	defer func() {
+		if err := runHooks("post-stop", cfg.Hooks.PostStop); err != nil {
+			klog.Errorf("%v", err)
+		}
+	}()
+
 	// TO-DO: When multi-node is ready, we need to add the controller host-name/mDNS hostname
 	//        or VIP to this list on start
 	//        see https://github.com/openshift/microshift/pull/471
 
-	if err := util.AddToNoProxyEnv(
-		cfg.NodeIP,
-		cfg.NodeName,
-		cfg.Cluster.ClusterCIDR,
-		cfg.Cluster.ServiceCIDR,
-		".svc",
-		"."+cfg.Cluster.Domain); err != nil {
-		klog.Fatal(err)
+	strictNoProxy, _ := flags.GetBool("strict-no-proxy")
+	if err := addNoProxyEntries(cfg, strictNoProxy); err != nil {
+		return exitError(ExitConfigError, err)
 	}
 
-	os.MkdirAll(microshiftDataDir, 0700)
+	bindTimeout, err := flags.GetDuration("bind-timeout")
+	if err != nil {
+		bindTimeout = defaultBindTimeout
+	}
+	if err := checkPorts(cfg, bindTimeout); err != nil {
+		return exitError(ExitServiceError, err)
+	}
+
+	strictSecurity, _ := flags.GetBool("strict-security")
+	if err := checkSecurityPolicies(securityProbes(cfg), strictSecurity); err != nil {
+		return exitError(ExitConfigError, err)
+	}
+	restrictDirs, _ := flags.GetBool("restrict-dir-permissions")
+	if err := ensureDirectories(cfg, restrictDirs); err != nil {
+		return exitError(ExitConfigError, err)
+	}
+	if err := writeDataVersion(cfg); err != nil {
+		return exitError(ExitConfigError, fmt.Errorf("failed to record the version of the data directory: %w", err))
+	}
 
 	// TODO: change to only initialize what is strictly necessary for the selected role(s)
-	if err := initAll(cfg); err != nil {
-		klog.Fatalf("failed to retrieve the necessary certificates: %v", err)
+	kubeconfigs, err := initWithTimeout(cfg, initTimeout)
+	if err != nil {
+		return err
 	}
 
-	m := servicemanager.NewServiceManager()
-	util.Must(m.AddService(controllers.NewEtcd(cfg)))
-	util.Must(m.AddService(sysconfwatch.NewSysConfWatchController(cfg)))
-	util.Must(m.AddService(controllers.NewKubeAPIServer(cfg)))
-	util.Must(m.AddService(controllers.NewKubeScheduler(cfg)))
-	util.Must(m.AddService(controllers.NewKubeControllerManager(cfg)))
-	util.Must(m.AddService(controllers.NewOpenShiftCRDManager(cfg)))
-	util.Must(m.AddService(controllers.NewRouteControllerManager(cfg)))
-	util.Must(m.AddService(controllers.NewClusterPolicyController(cfg)))
-	util.Must(m.AddService(controllers.NewOpenShiftDefaultSCCManager(cfg)))
-	util.Must(m.AddService(mdns.NewMicroShiftmDNSController(cfg)))
-	util.Must(m.AddService(controllers.NewInfrastructureServices(cfg)))
-	util.Must(m.AddService((controllers.NewVersionManager((cfg)))))
-	util.Must(m.AddService(kustomize.NewKustomizer(cfg)))
-	util.Must(m.AddService(node.NewKubeletServer(cfg)))
+	// the in-process components pick up the additional trust bundle through the system trust store
+	if cfg.AdditionalTrustBundle != "" {
+		if err := addToSSLCertDirEnv(
+			cryptomaterial.AdditionalTrustBundleDir(cryptomaterial.CertsDirectory(cfg.DataDir))); err != nil {
+			return exitError(ExitCertError, err)
+		}
+	}
 
 	// Storing and clearing the env, so other components don't send the READY=1 until MicroShift is fully ready
 	notifySocket := os.Getenv("NOTIFY_SOCKET")
 	os.Unsetenv("NOTIFY_SOCKET")
 
+	sigTerm := make(chan os.Signal, 1)
+	signal.Notify(sigTerm, os.Interrupt, syscall.SIGTERM)
+
+	// a previous instance may still be stopping, wait for it to release etcd's data
+	// before starting any service, restarts after services failed are left to systemd
+	if cfg.HasRole(config.ControlPlaneRole) {
+		if err := bootWithRetries(bootRetries, bootBackoff, sigTerm, func() error {
+			return controllers.CheckEtcdUnlocked(cfg)
+		}); err != nil {
+			return exitError(ExitServiceError, err)
+		}
+	}
+
+	m := newServiceManager(cfg)
+
 	klog.Infof("Starting MicroShift")
 
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// closed once systemd was notified of the readiness
+	notified := make(chan struct{})
+	if err := serveControlSocket(ctx, cfg.ControlSocketPath(), m, kubeconfigs, notified); err != nil {
+		return exitError(ExitServiceError, err)
+	}
 	ready, stopped := make(chan struct{}), make(chan struct{})
+	go reportStatus(ctx, m, ready, statusUpdatePeriod, func(status string) {
+		if err := notifyStatus(notifySocket, status); err != nil {
+			klog.V(2).Infof("error sending sd_notify status message: %v", err)
+		}
+	})
 	go func() {
 		klog.Infof("Started %s", m.Name())
 		if err := m.Run(ctx, ready, stopped); err != nil {
@@ -121,34 +291,471 @@ func RunMicroshift(cfg *config.MicroshiftConfig, flags *pflag.FlagSet) error {
 		}
 	}()
 
-	sigTerm := make(chan os.Signal, 1)
-	signal.Notify(sigTerm, os.Interrupt, syscall.SIGTERM)
-
 	select {
 	case <-ready:
 		klog.Infof("MicroShift is ready")
+		for _, status := range m.Status() {
+			if status.State == servicemanager.StateDegraded {
+				klog.Warningf("MicroShift is degraded, %s is not running", status.Name)
+			}
+		}
+		// services may be ready while workloads can't resolve names yet
+		dnsReady, dnsErr := true, error(nil)
+		if readyDNSTimeout > 0 {
+			dnsReady, dnsErr = waitForClusterDNS(clusterDNSResolver(cfg), clusterDNSCheckName(cfg), readyDNSTimeout, clusterDNSCheckInterval, sigTerm)
+		}
 		os.Setenv("NOTIFY_SOCKET", notifySocket)
-		if supported, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
-			klog.Warningf("error sending sd_notify readiness message: %v", err)
-		} else if supported {
-			klog.Info("sent sd_notify readiness message")
+		if dnsReady && notifyReady(readyDelay, sigTerm) {
+			close(notified)
+			go pingWatchdog(ctx)
+			<-sigTerm
+		}
+		if dnsErr != nil {
+			klog.Errorf("%v. Stopping services", dnsErr)
 		} else {
-			klog.Info("service does not support sd_notify readiness messages")
+			klog.Infof("Interrupt received. Stopping services")
+		}
+		cancel()
+		if err := waitStopped(stopped, sigTerm); err != nil {
+			return err
+		}
+		if dnsErr != nil {
+			return exitError(ExitServiceError, dnsErr)
 		}
-
-		<-sigTerm
 	case <-sigTerm:
+		klog.Infof("Interrupt received. Stopping services")
+		cancel()
+		if err := waitStopped(stopped, sigTerm); err != nil {
+			return err
+		}
+	}
+	klog.Infof("MicroShift stopped")
+
+	// services stop MicroShift when they fail, report that as the reason for stopping
+	if err := m.Err(); err != nil {
+		return exitError(ExitServiceError, err)
 	}
-	klog.Infof("Interrupt received. Stopping services")
-	cancel()
+	return nil
+}
+
+// initWithTimeout creates or loads the certificates and kubeconfigs, giving up after timeout.
+func initWithTimeout(cfg *config.MicroshiftConfig, timeout time.Duration) ([]kubeconfigInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	kubeconfigs, err := initAll(ctx, cfg)
+	if err != nil {
+		return nil, exitError(ExitCertError, fmt.Errorf("failed to retrieve the necessary certificates: %w", err))
+	}
+	return kubeconfigs, nil
+}
 
+// bootWithRetries calls check until it succeeds, retrying up to retries times. The
+// backoff between the attempts doubles after each one, up to maxBootBackoff. It gives
+// up if interrupted during a backoff.
+func bootWithRetries(retries int, backoff time.Duration, interrupted <-chan os.Signal, check func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := check()
+		if err == nil || attempt >= retries {
+			return err
+		}
+		delay := backoff << attempt
+		if delay > maxBootBackoff || delay < backoff {
+			delay = maxBootBackoff
+		}
+		klog.Warningf("Boot attempt %d of %d failed: %v, retrying in %s", attempt+1, retries+1, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-interrupted:
+			return err
+		}
+	}
+}
+
+// waitStopped waits for the canceled services to stop, giving up on another interrupt
+// or after the graceful shutdown timeout.
+func waitStopped(stopped <-chan struct{}, interrupted <-chan os.Signal) error {
 	select {
 	case <-stopped:
-	case <-sigTerm:
+	case <-interrupted:
 		klog.Infof("Another interrupt received. Force terminating services")
 	case <-time.After(time.Duration(gracefulShutdownTimeout) * time.Second):
 		klog.Infof("Timed out waiting for services to stop")
+		return exitError(ExitShutdownTimeout, fmt.Errorf("services did not stop within %ds", gracefulShutdownTimeout))
 	}
-	klog.Infof("MicroShift stopped")
 	return nil
 }
+
+// addNoProxyEntries excludes the cluster's addresses from proxying. Malformed entries
+// the environment already contains only fail if strict is set, as clients ignore them.
+func addNoProxyEntries(cfg *config.MicroshiftConfig, strict bool) error {
+	entries := append(cfg.ClusterCIDRs(), cfg.ServiceCIDRs()...)
+	err := util.AddToNoProxyEnv(append(entries,
+		cfg.NodeIP,
+		cfg.KubeletNodeName(),
+		".svc",
+		"."+cfg.Cluster.Domain)...)
+	if err == nil {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("%w, fix the environment of MicroShift or drop --strict-no-proxy", err)
+	}
+	klog.Warningf("%v, clients may ignore it", err)
+	return nil
+}
+
+// shutdownBudgetWarning returns a warning if the apiserver's shutdown delay doesn't
+// leave the services enough of the graceful shutdown timeout to stop.
+func shutdownBudgetWarning(cfg *config.MicroshiftConfig, timeout time.Duration) string {
+	if !cfg.HasRole(config.ControlPlaneRole) {
+		return ""
+	}
+	// validated with the config
+	delay, _ := time.ParseDuration(cfg.APIServer.ShutdownDelayDuration)
+	if delay+shutdownDrainMargin <= timeout {
+		return ""
+	}
+	return fmt.Sprintf("apiServer.shutdownDelayDuration %s leaves less than %s of the %s shutdown timeout to drain requests and stop the services, MicroShift may be stopped forcefully", delay, shutdownDrainMargin, timeout)
+}
+
+// notifyReady tells systemd that MicroShift is ready once delay passed. It returns
+// false without notifying if MicroShift is interrupted in the meantime.
+func notifyReady(delay time.Duration, interrupted <-chan os.Signal) bool {
+	if delay > 0 {
+		klog.Infof("waiting %s before sending the sd_notify readiness message", delay)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-interrupted:
+			return false
+		}
+	}
+	if supported, err := sdNotify(false, daemon.SdNotifyReady); err != nil {
+		klog.Warningf("error sending sd_notify readiness message: %v", err)
+	} else if supported {
+		klog.Info("sent sd_notify readiness message")
+	} else {
+		klog.Info("service does not support sd_notify readiness messages")
+	}
+	return true
+}
+
+// pingWatchdog notifies systemd's watchdog at half of its timeout as long as
+// MicroShift is running, so that systemd restarts it if it hangs.
+func pingWatchdog(ctx context.Context) {
+	timeout, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		klog.Warningf("error reading the systemd watchdog settings: %v", err)
+		return
+	}
+	if timeout == 0 {
+		return
+	}
+	klog.Infof("pinging the systemd watchdog every %s", timeout/2)
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+				klog.Warningf("error sending sd_notify watchdog message: %v", err)
+			}
+		}
+	}
+}
+
+// notifyStatus sends the status to systemd, which shows it for the unit. Unlike
+// sdNotify, it takes the notification socket, which isn't in the environment before
+// MicroShift is ready.
+func notifyStatus(socket, status string) error {
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("STATUS=" + status))
+	return err
+}
+
+// reportStatus passes a summary of the services' states to notify whenever it
+// changes, checking every period until ctx is done.
+func reportStatus(ctx context.Context, m *servicemanager.ServiceManager, ready <-chan struct{}, period time.Duration, notify func(status string)) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	last := ""
+	for {
+		isReady := false
+		select {
+		case <-ready:
+			isReady = true
+		default:
+		}
+		if status := statusSummary(m.Status(), isReady); status != last {
+			notify(status)
+			last = status
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// statusSummary summarizes the states of the services for the systemd status, e.g.
+// "Starting: 3/8 services ready, starting kube-apiserver". Services that completed
+// count as ready.
+func statusSummary(statuses []servicemanager.ServiceStatus, ready bool) string {
+	settled := 0
+	var starting, degraded, failed []string
+	for _, s := range statuses {
+		switch s.State {
+		case servicemanager.StateReady, servicemanager.StateStopped:
+			settled++
+		case servicemanager.StateStarting:
+			starting = append(starting, s.Name)
+		case servicemanager.StateDegraded:
+			degraded = append(degraded, s.Name)
+		case servicemanager.StateFailed:
+			failed = append(failed, s.Name)
+		}
+	}
+
+	phase := "Running"
+	if !ready {
+		phase = "Starting"
+	}
+	summary := fmt.Sprintf("%s: %d/%d services ready", phase, settled, len(statuses))
+	if !ready && len(starting) > 0 {
+		summary += ", starting " + strings.Join(starting, ", ")
+	}
+	if len(degraded) > 0 {
+		summary += ", degraded " + strings.Join(degraded, ", ")
+	}
+	if len(failed) > 0 {
+		summary += ", failed " + strings.Join(failed, ", ")
+	}
+	return summary
+}
+
+// newServiceManager returns a manager running the services of the enabled roles and
+// features.
+func newServiceManager(cfg *config.MicroshiftConfig) *servicemanager.ServiceManager {
+	m := servicemanager.NewServiceManager()
+	util.Must(m.AddService(sysconfwatch.NewSysConfWatchController(cfg)))
+	util.Must(m.AddService(controllers.NewClockMonitor(cfg)))
+	if cfg.HasRole(config.ControlPlaneRole) {
+		util.Must(m.AddService(controllers.NewEtcd(cfg)))
+		if cfg.APIServer.Konnectivity.Enabled {
+			util.Must(m.AddService(controllers.NewKonnectivity(cfg)))
+		}
+		util.Must(m.AddService(controllers.NewKubeAPIServer(cfg)))
+		if cfg.APIServer.Socket != "" {
+			util.Must(m.AddService(controllers.NewAPIServerSocket(cfg)))
+		}
+		util.Must(m.AddService(controllers.NewAuditLogSweeper(cfg)))
+		if cfg.APIServer.Watchdog.Enabled {
+			util.Must(m.AddService(controllers.NewAPIServerWatchdog(cfg)))
+		}
+		util.Must(m.AddService(controllers.NewKubeScheduler(cfg)))
+		util.Must(m.AddService(controllers.NewKubeControllerManager(cfg)))
+		if cfg.Node.RotateServerCertificates {
+			util.Must(m.AddService(controllers.NewKubeletServingCSRApprover(cfg)))
+		}
+		if cfg.ComponentEnabled(config.ComponentOpenShiftCRDs) {
+			util.Must(m.AddService(controllers.NewOpenShiftCRDManager(cfg)))
+		}
+		if cfg.ComponentEnabled(config.ComponentOpenShiftControllerManager) {
+			util.Must(m.AddService(controllers.NewRouteControllerManager(cfg)))
+		}
+		util.Must(m.AddService(controllers.NewClusterPolicyController(cfg)))
+		if cfg.ComponentEnabled(config.ComponentOpenShiftSCC) {
+			util.Must(m.AddService(controllers.NewOpenShiftDefaultSCCManager(cfg)))
+		}
+		if cfg.Cluster.DefaultNetworkPolicy != config.DefaultNetworkPolicyNone {
+			util.Must(m.AddService(controllers.NewDefaultNetworkPolicyController(cfg)))
+		}
+		if cfg.Cluster.HasDefaultResources() {
+			util.Must(m.AddService(controllers.NewDefaultResourcesController(cfg)))
+		}
+		if len(cfg.Scheduler.PriorityClasses) > 0 {
+			util.Must(m.AddService(controllers.NewPriorityClassManager(cfg)))
+		}
+		if cfg.MDNS.Enabled {
+			util.Must(m.AddService(mdns.NewMicroShiftmDNSController(cfg)))
+		}
+		util.Must(m.AddService(controllers.NewInfrastructureServices(cfg)))
+		if cfg.Ingress.CertFile != "" && cfg.ComponentEnabled(config.ComponentIngress) {
+			util.Must(m.AddService(controllers.NewIngressCertificateWatcher(cfg)))
+		}
+		util.Must(m.AddService((controllers.NewVersionManager((cfg)))))
+		if cfg.Manifests.Enabled {
+			util.Must(m.AddService(kustomize.NewKustomizer(cfg)))
+		}
+	}
+	if cfg.HasRole(config.NodeRole) {
+		util.Must(m.AddService(node.NewKubeletServer(cfg)))
+		if len(cfg.Node.PrePullImages) > 0 {
+			util.Must(m.AddService(node.NewImagePrePuller(cfg)))
+		}
+	}
+
+	added := map[string]bool{}
+	for _, status := range m.Status() {
+		added[status.Name] = true
+		util.Must(m.SetOptional(status.Name, cfg.ServiceOptional(status.Name)))
+	}
+	for _, name := range append(cfg.Services.Optional, cfg.Services.Critical...) {
+		if !added[name] {
+			klog.Warningf("service %q classified in the configuration doesn't run", name)
+		}
+	}
+	return m
+}
+
+// requiredPorts returns the ports bound by the services of the enabled roles.
+func requiredPorts(cfg *config.MicroshiftConfig) ([]componentPort, error) {
+	ports := []componentPort{}
+	if cfg.HasRole(config.ControlPlaneRole) {
+		apiServerPort, err := cfg.Cluster.ApiServerPort()
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports,
+			componentPort{"etcd", 2379},
+			componentPort{"etcd", 2380},
+			componentPort{"kube-apiserver", apiServerPort},
+			componentPort{"kube-controller-manager", 10257},
+			componentPort{"kube-scheduler", 10259},
+		)
+		if cfg.ComponentEnabled(config.ComponentOpenShiftControllerManager) {
+			ports = append(ports, componentPort{"route-controller-manager", 8445})
+		}
+		if cfg.Metrics.Enabled {
+			ports = append(ports, componentPort{"etcd", 2381})
+		}
+	}
+	if cfg.HasRole(config.NodeRole) {
+		ports = append(ports,
+			componentPort{"kubelet", 10248},
+			componentPort{"kubelet", 10250},
+		)
+	}
+	return ports, nil
+}
+
+// checkPorts makes sure none of the ports required by MicroShift is in use, waiting up
+// to timeout for each of them to be released, e.g. by a previous instance shutting down.
+func checkPorts(cfg *config.MicroshiftConfig, timeout time.Duration) error {
+	ports, err := requiredPorts(cfg)
+	if err != nil {
+		return err
+	}
+	for _, p := range ports {
+		if err := util.WaitForPortFree(p.component, p.port, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureDirectories creates the directories MicroShift writes to and makes sure they are
+// writable, so that a read-only location is reported before any component starts. With
+// restrict, existing directories are restricted to the owner like the ones created.
+func ensureDirectories(cfg *config.MicroshiftConfig, restrict bool) error {
+	dirs := []struct {
+		flag string
+		path string
+	}{
+		{"data-dir", cfg.DataDir},
+		{"audit-log-dir", cfg.AuditLogDir},
+	}
+	for _, d := range dirs {
+		err := prepareDirectory(d.path, restrict)
+		if err == nil {
+			err = access(d.path, unix.W_OK)
+		}
+		if errors.Is(err, unix.EROFS) {
+			return fmt.Errorf("directory %q is on a read-only filesystem, use --%s to select a writable location", d.path, d.flag)
+		}
+		if err != nil {
+			return fmt.Errorf("directory %q is not usable, use --%s to select a different location: %w", d.path, d.flag, err)
+		}
+	}
+	return nil
+}
+
+// prepareDirectory creates the directory at path if it doesn't exist. Symlinks and
+// mount points were set up by the administrator, e.g. to keep the audit logs on another
+// disk, so their permissions are left alone, as changing them may fail or be undone by
+// the next mount.
+func prepareDirectory(path string, restrict bool) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		klog.Infof("Creating directory %s", path)
+		return mkdirAll(path, 0700)
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve symlink: %w", err)
+		}
+		if info, err = os.Stat(target); err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("symlink target %s is not a directory", target)
+		}
+		klog.Infof("Directory %s is a symlink to %s, leaving its permissions %v", path, target, info.Mode().Perm())
+		return nil
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	if mounted, err := isMountPoint(path); err != nil || mounted {
+		if err != nil {
+			klog.Warningf("Failed to check whether %s is a mount point, leaving its permissions %v: %v", path, info.Mode().Perm(), err)
+		} else {
+			klog.Infof("Directory %s is a mount point, leaving its permissions %v", path, info.Mode().Perm())
+		}
+		return nil
+	}
+
+	if info.Mode().Perm()&0077 == 0 {
+		return nil
+	}
+	if !restrict {
+		klog.Warningf("Directory %s can be accessed by other users with permissions %v, pass --restrict-dir-permissions to restrict it to the owner", path, info.Mode().Perm())
+		return nil
+	}
+	if err := chmod(path, info.Mode().Perm()&0700); err != nil {
+		klog.Warningf("Failed to restrict the permissions %v of directory %s to the owner: %v", info.Mode().Perm(), path, err)
+		return nil
+	}
+	klog.Infof("Restricted the permissions of directory %s from %v to %v", path, info.Mode().Perm(), info.Mode().Perm()&0700)
+	return nil
+}
+
+// mountPoint returns whether path is on a different device than its parent, which
+// misses bind mounts of the same filesystem.
+func mountPoint(path string) (bool, error) {
+	var st, parent unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return false, err
+	}
+	if err := unix.Stat(filepath.Dir(path), &parent); err != nil {
+		return false, err
+	}
+	return st.Dev != parent.Dev || st.Ino == parent.Ino, nil
+}
@@ -0,0 +1,596 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-systemd/daemon"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/v2"
+)
+
+func TestEnsureDirectories(t *testing.T) {
+	defer func() {
+		mkdirAll = os.MkdirAll
+		access = unix.Access
+	}()
+
+	var tests = []struct {
+		name     string
+		mkdirAll func(string, os.FileMode) error
+		access   func(string, uint32) error
+		// errSubstrings are expected in the error, no error is expected if empty
+		errSubstrings []string
+	}{
+		{
+			name:     "writable directories",
+			mkdirAll: os.MkdirAll,
+			access:   unix.Access,
+		},
+		{
+			name: "read-only data dir cannot be created",
+			mkdirAll: func(path string, perm os.FileMode) error {
+				return &os.PathError{Op: "mkdir", Path: path, Err: unix.EROFS}
+			},
+			access:        unix.Access,
+			errSubstrings: []string{"read-only filesystem", "--data-dir"},
+		},
+		{
+			name:     "existing data dir on read-only filesystem",
+			mkdirAll: os.MkdirAll,
+			access: func(path string, mode uint32) error {
+				return unix.EROFS
+			},
+			errSubstrings: []string{"read-only filesystem", "--data-dir"},
+		},
+		{
+			name: "audit log dir cannot be created",
+			mkdirAll: func(path string, perm os.FileMode) error {
+				if strings.HasSuffix(path, "audit") {
+					return &os.PathError{Op: "mkdir", Path: path, Err: unix.EACCES}
+				}
+				return os.MkdirAll(path, perm)
+			},
+			access:        unix.Access,
+			errSubstrings: []string{"permission denied", "--audit-log-dir"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			cfg := config.NewMicroshiftConfig()
+			cfg.DataDir = filepath.Join(tmpDir, "data")
+			cfg.AuditLogDir = filepath.Join(tmpDir, "audit")
+			mkdirAll = tt.mkdirAll
+			access = tt.access
+
+			err := ensureDirectories(cfg, true)
+			if len(tt.errSubstrings) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			for _, substr := range tt.errSubstrings {
+				if !strings.Contains(err.Error(), substr) {
+					t.Errorf("expected error %q to contain %q", err, substr)
+				}
+			}
+		})
+	}
+}
+
+func TestEnsureDirectoriesExisting(t *testing.T) {
+	defer func() {
+		chmod = os.Chmod
+		isMountPoint = mountPoint
+	}()
+
+	tmpDir := t.TempDir()
+	newDir := func(name string, perm os.FileMode) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.Mkdir(path, perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, perm); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	permOf := func(path string) os.FileMode {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	var tests = []struct {
+		name     string
+		restrict bool
+		mounted  bool
+		chmod    func(string, os.FileMode) error
+		symlink  bool
+		expected os.FileMode
+	}{
+		{name: "restricted", restrict: true, chmod: os.Chmod, expected: 0700},
+		{name: "not restricted", restrict: false, chmod: os.Chmod, expected: 0755},
+		{name: "mount point", restrict: true, mounted: true, chmod: os.Chmod, expected: 0755},
+		{name: "symlink", restrict: true, symlink: true, chmod: os.Chmod, expected: 0755},
+		{name: "chmod denied", restrict: true, expected: 0755, chmod: func(string, os.FileMode) error {
+			return &os.PathError{Op: "chmod", Err: unix.EPERM}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := strings.ReplaceAll(tt.name, " ", "-")
+			cfg := config.NewMicroshiftConfig()
+			cfg.DataDir = newDir(name+"-data", 0755)
+			cfg.AuditLogDir = newDir(name+"-audit", 0750)
+			if tt.symlink {
+				link := filepath.Join(tmpDir, name+"-link")
+				if err := os.Symlink(cfg.DataDir, link); err != nil {
+					t.Fatal(err)
+				}
+				cfg.DataDir = link
+			}
+			chmod = tt.chmod
+			isMountPoint = func(string) (bool, error) { return tt.mounted, nil }
+
+			if err := ensureDirectories(cfg, tt.restrict); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if perm := permOf(cfg.DataDir); perm != tt.expected {
+				t.Errorf("expected data dir permissions %v, got %v", tt.expected, perm)
+			}
+		})
+	}
+}
+
+func TestEnsureDirectoriesBrokenSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = filepath.Join(tmpDir, "data")
+	cfg.AuditLogDir = filepath.Join(tmpDir, "audit")
+	if err := os.Symlink(filepath.Join(tmpDir, "missing"), cfg.DataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ensureDirectories(cfg, true)
+	if err == nil || !strings.Contains(err.Error(), "--data-dir") {
+		t.Errorf("expected an error about the data dir symlink, got %v", err)
+	}
+}
+
+func TestUTCLogTimestamps(t *testing.T) {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("logtostderr", "false"); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Set("logtostderr", "true")
+
+	var buf bytes.Buffer
+	klog.SetOutput(&buf)
+	defer klog.SetOutput(os.Stderr)
+
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	defer func() { time.Local = local }()
+
+	klog.SetMicroshiftUTCTimestamps(true)
+	defer klog.SetMicroshiftUTCTimestamps(false)
+
+	before := time.Now().UTC()
+	klog.Info("test message")
+	klog.Flush()
+
+	// ??? I2006-01-02T15:04:05.999999999Z pid file:line] msg
+	fields := strings.Fields(buf.String())
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "I") {
+		t.Fatalf("unexpected log line %q", buf.String())
+	}
+	ts, err := time.Parse(time.RFC3339Nano, strings.TrimPrefix(fields[1], "I"))
+	if err != nil {
+		t.Fatalf("log timestamp is not RFC3339: %v", err)
+	}
+	if !strings.HasSuffix(fields[1], "Z") {
+		t.Errorf("expected a UTC timestamp, got %q", fields[1])
+	}
+	if ts.Before(before.Truncate(time.Second)) || ts.Sub(before) > time.Minute {
+		t.Errorf("timestamp %v is not the current time %v", ts, before)
+	}
+}
+
+func TestDisableFlags(t *testing.T) {
+	var ttests = []struct {
+		args         []string
+		wantServices map[string]bool
+		wantPorts    map[int]bool
+	}{
+		{
+			args:         []string{},
+			wantServices: map[string]bool{"microshift-mdns-controller": true, "kustomizer": true},
+			wantPorts:    map[int]bool{2381: true},
+		},
+		{
+			args:         []string{"--disable-mdns"},
+			wantServices: map[string]bool{"microshift-mdns-controller": false, "kustomizer": true},
+			wantPorts:    map[int]bool{2381: true},
+		},
+		{
+			args:         []string{"--disable-manifests"},
+			wantServices: map[string]bool{"microshift-mdns-controller": true, "kustomizer": false},
+			wantPorts:    map[int]bool{2381: true},
+		},
+		{
+			args:         []string{"--disable-metrics"},
+			wantServices: map[string]bool{"microshift-mdns-controller": true, "kustomizer": true},
+			wantPorts:    map[int]bool{2381: false},
+		},
+	}
+
+	for _, tt := range ttests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cmd := &cobra.Command{}
+		addRunFlags(cmd, cfg)
+		if err := cmd.Flags().Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.ReadFromCmdLine(cmd.Flags()); err != nil {
+			t.Fatal(err)
+		}
+
+		services := map[string]bool{}
+		for _, status := range newServiceManager(cfg).Status() {
+			services[status.Name] = true
+		}
+		for name, want := range tt.wantServices {
+			if services[name] != want {
+				t.Errorf("%v: expected service %s to run: %v", tt.args, name, want)
+			}
+		}
+
+		ports, err := requiredPorts(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		required := map[int]bool{}
+		for _, p := range ports {
+			required[p.port] = true
+		}
+		for port, want := range tt.wantPorts {
+			if required[port] != want {
+				t.Errorf("%v: expected port %d to be required: %v", tt.args, port, want)
+			}
+		}
+	}
+}
+
+// test that the flags override the configuration file in both directions
+func TestDisableFlagsOverrideConfig(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.MDNS.Enabled = false
+	cfg.Manifests.Enabled = false
+	cmd := &cobra.Command{}
+	addRunFlags(cmd, cfg)
+	if err := cmd.Flags().Parse([]string{"--disable-mdns=false", "--disable-metrics"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ReadFromCmdLine(cmd.Flags()); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.MDNS.Enabled || cfg.Manifests.Enabled || cfg.Metrics.Enabled {
+		t.Errorf("expected mDNS enabled, manifests and metrics disabled, got %v, %v and %v", cfg.MDNS.Enabled, cfg.Manifests.Enabled, cfg.Metrics.Enabled)
+	}
+}
+
+func TestServiceClassification(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Services.Optional = []string{"version-manager"}
+	cfg.Services.Critical = []string{"kustomizer"}
+
+	optional := map[string]bool{}
+	for _, status := range newServiceManager(cfg).Status() {
+		optional[status.Name] = status.Optional
+	}
+	expected := map[string]bool{
+		"etcd":                       false,
+		"kube-apiserver":             false,
+		"microshift-mdns-controller": true,
+		"version-manager":            true,
+		"kustomizer":                 false,
+	}
+	for name, want := range expected {
+		if got, ok := optional[name]; !ok || got != want {
+			t.Errorf("expected %s to be classified optional: %v, got %v (found %v)", name, want, got, ok)
+		}
+	}
+}
+
+func TestNotifyReadyDelay(t *testing.T) {
+	defer func() { sdNotify = daemon.SdNotify }()
+
+	var notifiedAfter time.Duration
+	start := time.Now()
+	sdNotify = func(unsetEnvironment bool, state string) (bool, error) {
+		if state != daemon.SdNotifyReady {
+			t.Errorf("expected the readiness message, got %q", state)
+		}
+		notifiedAfter = time.Since(start)
+		return true, nil
+	}
+
+	delay := 100 * time.Millisecond
+	if !notifyReady(delay, make(chan os.Signal)) {
+		t.Fatal("expected notifyReady to notify when not interrupted")
+	}
+	if notifiedAfter < delay {
+		t.Errorf("expected the notification after %s, got it after %s", delay, notifiedAfter)
+	}
+}
+
+func TestNotifyReadyInterrupted(t *testing.T) {
+	defer func() { sdNotify = daemon.SdNotify }()
+
+	sdNotify = func(bool, string) (bool, error) {
+		t.Error("expected no notification when interrupted during the delay")
+		return true, nil
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	interrupted <- os.Interrupt
+	if notifyReady(time.Hour, interrupted) {
+		t.Error("expected notifyReady to report the interruption")
+	}
+}
+
+func TestBootWithRetries(t *testing.T) {
+	locked := errors.New("member/wal/0.wal is in use")
+	var tests = []struct {
+		name       string
+		retries    int
+		failures   int
+		wantErr    bool
+		wantChecks int
+	}{
+		{name: "unlocked", retries: 1, wantChecks: 1},
+		{name: "locked once", retries: 1, failures: 1, wantChecks: 2},
+		{name: "locked without retries", retries: 0, failures: 1, wantErr: true, wantChecks: 1},
+		{name: "retries exhausted", retries: 1, failures: 2, wantErr: true, wantChecks: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := 0
+			err := bootWithRetries(tt.retries, time.Millisecond, make(chan os.Signal), func() error {
+				checks++
+				if checks <= tt.failures {
+					return locked
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if checks != tt.wantChecks {
+				t.Errorf("expected %d checks, got %d", tt.wantChecks, checks)
+			}
+		})
+	}
+}
+
+func TestBootWithRetriesInterrupted(t *testing.T) {
+	interrupted := make(chan os.Signal, 1)
+	interrupted <- os.Interrupt
+	checks := 0
+	err := bootWithRetries(3, time.Hour, interrupted, func() error {
+		checks++
+		return errors.New("member/wal/0.wal is in use")
+	})
+	if err == nil || checks != 1 {
+		t.Errorf("expected the failed check not to be retried when interrupted during the backoff, got %d checks (%v)", checks, err)
+	}
+}
+
+func TestShutdownBudgetWarning(t *testing.T) {
+	var tests = []struct {
+		delay   string
+		roles   []string
+		warning bool
+	}{
+		{delay: "0s", warning: false},
+		{delay: "50s", warning: false},
+		{delay: "51s", warning: true},
+		{delay: "70s", warning: true},
+		{delay: "70s", roles: []string{config.NodeRole}, warning: false},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.APIServer.ShutdownDelayDuration = tt.delay
+		if tt.roles != nil {
+			cfg.Roles = tt.roles
+		}
+		if got := shutdownBudgetWarning(cfg, time.Minute); (got != "") != tt.warning {
+			t.Errorf("shutdownBudgetWarning() with delay %s and roles %v = %q, want warning %v", tt.delay, cfg.Roles, got, tt.warning)
+		}
+	}
+}
+
+func TestStatusSummary(t *testing.T) {
+	var tests = []struct {
+		statuses []servicemanager.ServiceStatus
+		ready    bool
+		expected string
+	}{
+		{
+			statuses: testTopology,
+			expected: "Starting: 1/3 services ready, starting kube-apiserver",
+		},
+		{
+			statuses: []servicemanager.ServiceStatus{
+				{Name: "etcd", State: servicemanager.StateReady},
+				{Name: "kube-apiserver", State: servicemanager.StateReady},
+				{Name: "kustomizer", State: servicemanager.StateStopped},
+			},
+			ready:    true,
+			expected: "Running: 3/3 services ready",
+		},
+		{
+			statuses: []servicemanager.ServiceStatus{
+				{Name: "etcd", State: servicemanager.StateReady},
+				{Name: "kube-apiserver", State: servicemanager.StateStarting},
+				{Name: "microshift-mdns-controller", State: servicemanager.StateDegraded},
+			},
+			expected: "Starting: 1/3 services ready, starting kube-apiserver, degraded microshift-mdns-controller",
+		},
+		{
+			statuses: []servicemanager.ServiceStatus{
+				{Name: "etcd", State: servicemanager.StateReady},
+				{Name: "kube-apiserver", State: servicemanager.StateFailed},
+				{Name: "microshift-mdns-controller", State: servicemanager.StateDegraded},
+			},
+			ready:    true,
+			expected: "Running: 1/3 services ready, degraded microshift-mdns-controller, failed kube-apiserver",
+		},
+	}
+	for _, tt := range tests {
+		if got := statusSummary(tt.statuses, tt.ready); got != tt.expected {
+			t.Errorf("statusSummary() = %q, expected %q", got, tt.expected)
+		}
+	}
+}
+
+func TestReportStatus(t *testing.T) {
+	m := servicemanager.NewServiceManager()
+	if err := m.AddService(servicemanager.NewGenericService("etcd", []string{}, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := make(chan string, 10)
+	ready := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reportStatus(ctx, m, ready, 10*time.Millisecond, func(status string) { statuses <- status })
+
+	expectStatus := func(expected string) {
+		select {
+		case status := <-statuses:
+			if status != expected {
+				t.Errorf("expected status %q, got %q", expected, status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected status %q", expected)
+		}
+	}
+	expectStatus("Starting: 0/1 services ready")
+	close(ready)
+	expectStatus("Running: 0/1 services ready")
+	select {
+	case status := <-statuses:
+		t.Errorf("expected the status only to be sent when it changes, got %q", status)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifyStatus(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := notifyStatus(socket, "Running: 3/3 services ready"); err != nil {
+		t.Fatalf("notifyStatus() error = %v", err)
+	}
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "STATUS=Running: 3/3 services ready" {
+		t.Errorf("expected the status message, got %q", got)
+	}
+
+	if err := notifyStatus("", "Running: 3/3 services ready"); err != nil {
+		t.Errorf("expected no error without a notification socket, got %v", err)
+	}
+}
+
+func TestAddNoProxyEntriesStrictness(t *testing.T) {
+	defer func() {
+		os.Unsetenv("NO_PROXY")
+		os.Unsetenv("no_proxy")
+	}()
+	cfg := config.NewMicroshiftConfig()
+
+	os.Setenv("NO_PROXY", "registry.example.com,10.0.0.0/33")
+	err := addNoProxyEntries(cfg, true)
+	if err == nil || !strings.Contains(err.Error(), `"10.0.0.0/33" in NO_PROXY`) {
+		t.Errorf("expected strict mode to fail naming the malformed entry, got %v", err)
+	}
+
+	os.Setenv("NO_PROXY", "registry.example.com,10.0.0.0/33")
+	if err := addNoProxyEntries(cfg, false); err != nil {
+		t.Errorf("expected non-strict mode to continue, got %v", err)
+	}
+	if noProxy := os.Getenv("NO_PROXY"); !strings.Contains(noProxy, ".svc") || !strings.Contains(noProxy, "registry.example.com") {
+		t.Errorf("expected the cluster's entries to be added to NO_PROXY, got %q", noProxy)
+	}
+}
+
+func TestSubcommandsReadConfigFlag(t *testing.T) {
+	ioStreams := genericclioptions.IOStreams{Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+	for _, cmd := range []*cobra.Command{
+		NewRunMicroshiftCommand(),
+		NewConfigDiffCommand(ioStreams),
+		NewDiagnosticsCommand(ioStreams),
+		NewInspectCertsCommand(ioStreams),
+		NewKubeconfigRotateCommand(ioStreams),
+		NewKubeconfigsCommand(ioStreams),
+		NewListServicesCommand(ioStreams),
+		NewLogsCommand(ioStreams),
+		NewShowConfigCommand(ioStreams),
+		NewTopologyCommand(ioStreams),
+		NewUpgradeCheckCommand(ioStreams),
+		NewWaitForReadyCommand(ioStreams),
+	} {
+		if cmd.Flags().Lookup("config") == nil {
+			t.Errorf("expected %s to take the config file of the service with --config", cmd.Name())
+		}
+	}
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte("apiVersion: microshift.openshift.io/v1beta1\nkind: MicroShiftConfig\ndataDir: /srv/microshift\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	cmd := NewShowConfigCommand(genericclioptions.IOStreams{Out: out, ErrOut: out})
+	cmd.SetArgs([]string{"--mode", "effective", "--config", configFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "dataDir: /srv/microshift") {
+		t.Errorf("expected the data directory of the config file, got:\n%s", out.String())
+	}
+}
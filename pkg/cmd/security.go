@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+const (
+	selinuxEnforceFile  = "/sys/fs/selinux/enforce"
+	apparmorEnabledFile = "/sys/module/apparmor/parameters/enabled"
+	crioSocket          = "/var/run/crio/crio.sock"
+)
+
+// macStatus is the state of the mandatory access control systems of the host
+type macStatus struct {
+	SELinuxEnforcing bool
+	AppArmorEnabled  bool
+}
+
+func (s macStatus) active() bool { return s.SELinuxEnforcing || s.AppArmorEnabled }
+
+// readMACStatus is a variable so that tests can simulate the host's security status
+var readMACStatus = func() macStatus {
+	enforce, _ := os.ReadFile(selinuxEnforceFile)
+	enabled, _ := os.ReadFile(apparmorEnabledFile)
+	return macStatus{
+		SELinuxEnforcing: strings.TrimSpace(string(enforce)) == "1",
+		AppArmorEnabled:  strings.TrimSpace(string(enabled)) == "Y",
+	}
+}
+
+// securityProbe performs an operation on path that MicroShift requires
+type securityProbe struct {
+	description string
+	path        string
+	probe       func(path string) error
+}
+
+func securityProbes(cfg *config.MicroshiftConfig) []securityProbe {
+	probes := []securityProbe{
+		{"write to the data directory", cfg.DataDir, probeWrite},
+		{"write to the audit log directory", cfg.AuditLogDir, probeWrite},
+	}
+	if cfg.HasRole(config.NodeRole) {
+		probes = append(probes, securityProbe{"connect to the CRI-O socket", crioSocket, probeDial})
+	}
+	return probes
+}
+
+func probeWrite(dir string) error {
+	f, err := os.CreateTemp(dir, ".microshift-probe-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func probeDial(path string) error {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// securityDenials runs the probes and returns guidance for those that are denied
+// while a mandatory access control system is active. MicroShift runs as root, so a
+// denied permission most likely is a policy denial. Paths that don't exist yet are
+// skipped, they are reported when they are used.
+func securityDenials(status macStatus, probes []securityProbe) []string {
+	if !status.active() {
+		return nil
+	}
+	denials := []string{}
+	for _, p := range probes {
+		err := p.probe(p.path)
+		if err == nil || !errors.Is(err, os.ErrPermission) {
+			continue
+		}
+		denials = append(denials, fmt.Sprintf("MicroShift can't %s %s: %v. %s", p.description, p.path, err, macGuidance(status, p.path)))
+	}
+	return denials
+}
+
+func macGuidance(status macStatus, path string) string {
+	var guidance []string
+	if status.SELinuxEnforcing {
+		guidance = append(guidance, fmt.Sprintf("SELinux is enforcing, check the denials with 'ausearch -m avc -ts recent' and restore the labels with 'restorecon -Rv %s'", filepath.Clean(path)))
+	}
+	if status.AppArmorEnabled {
+		guidance = append(guidance, "AppArmor is enabled, check the denials with 'journalctl -k | grep apparmor=\"DENIED\"' and the profile confining MicroShift")
+	}
+	return strings.Join(guidance, ". ")
+}
+
+// checkSecurityPolicies warns about probed operations the host's security policies
+// deny, failing instead if strict is set.
+func checkSecurityPolicies(probes []securityProbe, strict bool) error {
+	denials := securityDenials(readMACStatus(), probes)
+	if len(denials) == 0 {
+		return nil
+	}
+	for _, denial := range denials {
+		klog.Warning(denial)
+	}
+	if strict {
+		return fmt.Errorf("security policies deny %d operations MicroShift requires, see the warnings above", len(denials))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func fakeProbe(description string, err error) securityProbe {
+	return securityProbe{description, "/var/lib/microshift", func(string) error { return err }}
+}
+
+func TestSecurityDenials(t *testing.T) {
+	var tests = []struct {
+		name   string
+		status macStatus
+		probes []securityProbe
+		// want are expected in the denials, one per entry
+		want []string
+	}{
+		{
+			name:   "no mandatory access control",
+			status: macStatus{},
+			probes: []securityProbe{fakeProbe("write to the data directory", syscall.EACCES)},
+		},
+		{
+			name:   "allowed",
+			status: macStatus{SELinuxEnforcing: true},
+			probes: []securityProbe{fakeProbe("write to the data directory", nil)},
+		},
+		{
+			name:   "missing path",
+			status: macStatus{SELinuxEnforcing: true},
+			probes: []securityProbe{fakeProbe("connect to the CRI-O socket", syscall.ENOENT)},
+		},
+		{
+			name:   "selinux denial",
+			status: macStatus{SELinuxEnforcing: true},
+			probes: []securityProbe{
+				fakeProbe("write to the data directory", syscall.EACCES),
+				fakeProbe("write to the audit log directory", nil),
+			},
+			want: []string{"can't write to the data directory /var/lib/microshift: permission denied. SELinux is enforcing, check the denials with 'ausearch -m avc -ts recent' and restore the labels with 'restorecon -Rv /var/lib/microshift'"},
+		},
+		{
+			name:   "apparmor denial",
+			status: macStatus{AppArmorEnabled: true},
+			probes: []securityProbe{fakeProbe("connect to the CRI-O socket", &os.PathError{Op: "dial", Path: "/var/run/crio/crio.sock", Err: syscall.EPERM})},
+			want:   []string{"can't connect to the CRI-O socket", "AppArmor is enabled"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denials := securityDenials(tt.status, tt.probes)
+			if len(tt.want) == 0 {
+				if len(denials) != 0 {
+					t.Errorf("expected no denials, got %v", denials)
+				}
+				return
+			}
+			if len(denials) != 1 {
+				t.Fatalf("expected one denial, got %v", denials)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(denials[0], want) {
+					t.Errorf("expected the denial to contain %q, got %q", want, denials[0])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSecurityPoliciesStrict(t *testing.T) {
+	defer func(read func() macStatus) { readMACStatus = read }(readMACStatus)
+	readMACStatus = func() macStatus { return macStatus{SELinuxEnforcing: true} }
+
+	probes := []securityProbe{fakeProbe("write to the data directory", syscall.EACCES)}
+	if err := checkSecurityPolicies(probes, false); err != nil {
+		t.Errorf("expected only a warning without --strict-security, got %v", err)
+	}
+	if err := checkSecurityPolicies(probes, true); err == nil {
+		t.Error("expected an error with --strict-security")
+	}
+	if err := checkSecurityPolicies([]securityProbe{fakeProbe("write to the data directory", nil)}, true); err != nil {
+		t.Errorf("expected no error without denials, got %v", err)
+	}
+}
+
+func TestSecurityProbes(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.AuditLogDir = t.TempDir()
+	cfg.Roles = []string{config.ControlPlaneRole, config.NodeRole}
+
+	probes := securityProbes(cfg)
+	if len(probes) != 3 || probes[2].path != crioSocket {
+		t.Fatalf("expected the directories and the CRI-O socket to be probed, got %v", probes)
+	}
+	for _, p := range probes[:2] {
+		if err := p.probe(p.path); err != nil {
+			t.Errorf("expected to %s %s, got %v", p.description, p.path, err)
+		}
+	}
+	if err := probeDial(cfg.DataDir + "/missing.sock"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("expected a missing socket not to be reported as denied, got %v", err)
+	}
+
+	cfg.Roles = []string{config.ControlPlaneRole}
+	if probes := securityProbes(cfg); len(probes) != 2 {
+		t.Errorf("expected the CRI-O socket not to be probed without the node role, got %v", probes)
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+)
+
+// mkdirTemp is a variable so that tests can find the self-test's data directory
+var mkdirTemp = os.MkdirTemp
+
+// selfTest runs the initialization against a temporary data directory and verifies the
+// certificates and kubeconfigs it produces, leaving the configured data directory untouched.
+func selfTest(ctx context.Context, cfg *config.MicroshiftConfig) error {
+	dataDir, err := mkdirTemp("", "microshift-self-test-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary data directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dataDir); err != nil {
+			klog.Warningf("failed to remove self-test data directory %s: %v", dataDir, err)
+		}
+	}()
+
+	testCfg := *cfg
+	testCfg.DataDir = dataDir
+	kubeconfigs, err := initAll(ctx, &testCfg)
+	if err != nil {
+		return fmt.Errorf("initialization failed: %w", err)
+	}
+	if err := verifyCertificates(cryptomaterial.CertsDirectory(dataDir)); err != nil {
+		return err
+	}
+	for _, k := range kubeconfigs {
+		if err := verifyKubeconfig(k.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyCertificates checks that the certificates the components are configured with
+// exist, and that every certificate in certsDir is currently valid and matches its key.
+func verifyCertificates(certsDir string) error {
+	required := []string{
+		cryptomaterial.TotalClientCABundlePath(certsDir),
+		cryptomaterial.KubeletClientCAPath(certsDir),
+		cryptomaterial.ServiceAccountTokenCABundlePath(certsDir),
+		cryptomaterial.ServingCertPath(cryptomaterial.KubeAPIServerExternalServingCertDir(certsDir)),
+		cryptomaterial.ServingCertPath(cryptomaterial.KubeAPIServerLocalhostServingCertDir(certsDir)),
+		cryptomaterial.ServingCertPath(cryptomaterial.KubeAPIServerServiceNetworkServingCertDir(certsDir)),
+		cryptomaterial.ClientCertPath(cryptomaterial.KubeAPIServerToKubeletClientCertDir(certsDir)),
+		cryptomaterial.ClientCertPath(cryptomaterial.AggregatorClientCertDir(certsDir)),
+		cryptomaterial.ClientCertPath(cryptomaterial.EtcdAPIServerClientCertDir(certsDir)),
+		cryptomaterial.PeerCertPath(cryptomaterial.EtcdPeerCertDir(certsDir)),
+		cryptomaterial.PeerCertPath(cryptomaterial.EtcdServingCertDir(certsDir)),
+		cryptomaterial.CACertPath(cryptomaterial.ServiceCADir(certsDir)),
+	}
+	for _, path := range required {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("expected certificate was not created: %w", err)
+		}
+	}
+
+	now := time.Now()
+	return filepath.WalkDir(certsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".crt" {
+			return err
+		}
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		certs, err := cert.ParseCertsPEM(pemBytes)
+		if err != nil {
+			return fmt.Errorf("invalid certificate %s: %w", path, err)
+		}
+		for _, c := range certs {
+			if now.Before(c.NotBefore) || now.After(c.NotAfter) {
+				return fmt.Errorf("certificate %s for %q is not valid at %v", path, c.Subject.CommonName, now)
+			}
+		}
+
+		keyPath := strings.TrimSuffix(path, ".crt") + ".key"
+		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+			return nil
+		}
+		if _, err := tls.LoadX509KeyPair(path, keyPath); err != nil {
+			return fmt.Errorf("certificate %s does not match its key: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// verifyKubeconfig checks that the kubeconfig at path trusts a valid CA bundle and
+// carries a matching client certificate and key.
+func verifyKubeconfig(path string) error {
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid kubeconfig %s: %w", path, err)
+	}
+	kubeContext, ok := kubeconfig.Contexts[kubeconfig.CurrentContext]
+	if !ok {
+		return fmt.Errorf("kubeconfig %s has no current context", path)
+	}
+	cluster, ok := kubeconfig.Clusters[kubeContext.Cluster]
+	if !ok {
+		return fmt.Errorf("kubeconfig %s has no cluster %q", path, kubeContext.Cluster)
+	}
+	if _, err := cert.ParseCertsPEM(cluster.CertificateAuthorityData); err != nil {
+		return fmt.Errorf("kubeconfig %s has an invalid CA bundle: %w", path, err)
+	}
+	authInfo, ok := kubeconfig.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		return fmt.Errorf("kubeconfig %s has no user %q", path, kubeContext.AuthInfo)
+	}
+	if _, err := tls.X509KeyPair(authInfo.ClientCertificateData, authInfo.ClientKeyData); err != nil {
+		return fmt.Errorf("kubeconfig %s has an invalid client certificate: %w", path, err)
+	}
+	return nil
+}
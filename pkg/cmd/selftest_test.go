@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func TestSelfTest(t *testing.T) {
+	defer func() {
+		mkdirTemp = os.MkdirTemp
+	}()
+
+	var ttests = []struct {
+		name                  string
+		additionalTrustBundle string
+		wantErr               bool
+	}{
+		{name: "default config", wantErr: false},
+		{name: "missing trust bundle", additionalTrustBundle: "/nonexistent/ca-bundle.crt", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		var selfTestDir string
+		mkdirTemp = func(dir, pattern string) (string, error) {
+			var err error
+			selfTestDir, err = os.MkdirTemp(t.TempDir(), pattern)
+			return selfTestDir, err
+		}
+
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = filepath.Join(t.TempDir(), "data")
+		cfg.AdditionalTrustBundle = tt.additionalTrustBundle
+
+		if err := selfTest(context.Background(), cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: selfTest() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if selfTestDir == "" {
+			t.Fatalf("%s: self-test did not create a temporary data directory", tt.name)
+		}
+		if _, err := os.Stat(selfTestDir); !os.IsNotExist(err) {
+			t.Errorf("%s: expected self-test data directory %s to be removed, stat error = %v", tt.name, selfTestDir, err)
+		}
+		if _, err := os.Stat(cfg.DataDir); !os.IsNotExist(err) {
+			t.Errorf("%s: expected configured data directory %s to be left untouched, stat error = %v", tt.name, cfg.DataDir, err)
+		}
+	}
+}
+
+func TestVerifyCertificatesMissing(t *testing.T) {
+	if err := verifyCertificates(t.TempDir()); err == nil {
+		t.Errorf("expected an error verifying an empty certificates directory")
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+)
+
+type topologyOptions struct {
+	Output string
+	genericclioptions.IOStreams
+}
+
+func NewTopologyCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := topologyOptions{
+		Output:    "text",
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "topology",
+		Short: "Print the services of the running MicroShift instance, their dependencies and states",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.Output, "output", "o", opts.Output, "One of 'text', 'json' or 'dot'.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *topologyOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if opts.Output != "text" && opts.Output != "json" && opts.Output != "dot" {
+		return fmt.Errorf("unknown output format %q", opts.Output)
+	}
+	// the data directory holding the control socket may be configured
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+
+	statuses, err := getTopology(cfg.ControlSocketPath())
+	if err != nil {
+		return err
+	}
+
+	switch opts.Output {
+	case "json":
+		marshalled, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Out, "%s\n", string(marshalled))
+		return nil
+	case "dot":
+		return renderTopologyDOT(opts.Out, statuses)
+	default:
+		return renderTopologyText(opts.Out, statuses)
+	}
+}
+
+func renderTopologyText(w io.Writer, statuses []servicemanager.ServiceStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tSTATE\tDEPENDENCIES")
+	for _, s := range statuses {
+		deps := strings.Join(s.Dependencies, ",")
+		if deps == "" {
+			deps = "<none>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, s.State, deps)
+	}
+	return tw.Flush()
+}
+
+// renderTopologyDOT writes the services as a Graphviz digraph, with edges pointing from
+// each service to its dependencies.
+func renderTopologyDOT(w io.Writer, statuses []servicemanager.ServiceStatus) error {
+	var b strings.Builder
+	b.WriteString("digraph microshift {\n")
+	for _, s := range statuses {
+		fmt.Fprintf(&b, "  %q [label=%q];\n", s.Name, s.Name+"\n"+s.State)
+	}
+	for _, s := range statuses {
+		for _, dep := range s.Dependencies {
+			fmt.Fprintf(&b, "  %q -> %q;\n", s.Name, dep)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
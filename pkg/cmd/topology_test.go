@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/servicemanager"
+)
+
+var testTopology = []servicemanager.ServiceStatus{
+	{Name: "etcd", Dependencies: []string{}, State: servicemanager.StateReady},
+	{Name: "kube-apiserver", Dependencies: []string{"etcd"}, State: servicemanager.StateStarting},
+	{Name: "kube-scheduler", Dependencies: []string{"kube-apiserver"}, State: servicemanager.StatePending},
+}
+
+func TestRenderTopologyDOT(t *testing.T) {
+	expected := `digraph microshift {
+  "etcd" [label="etcd\nready"];
+  "kube-apiserver" [label="kube-apiserver\nstarting"];
+  "kube-scheduler" [label="kube-scheduler\npending"];
+  "kube-apiserver" -> "etcd";
+  "kube-scheduler" -> "kube-apiserver";
+}
+`
+	var out bytes.Buffer
+	if err := renderTopologyDOT(&out, testTopology); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("expected DOT output\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestRenderTopologyText(t *testing.T) {
+	expected := `SERVICE         STATE     DEPENDENCIES
+etcd            ready     <none>
+kube-apiserver  starting  etcd
+kube-scheduler  pending   kube-apiserver
+`
+	var out bytes.Buffer
+	if err := renderTopologyText(&out, testTopology); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("expected text output\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestControlSocketTopology(t *testing.T) {
+	m := servicemanager.NewServiceManager()
+	for _, s := range []*servicemanager.GenericService{
+		servicemanager.NewGenericService("etcd", []string{}, nil),
+		servicemanager.NewGenericService("kube-apiserver", []string{"etcd"}, nil),
+	} {
+		if err := m.AddService(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "microshift.sock")
+	if err := serveControlSocket(ctx, path, m, nil, nil); err != nil {
+		t.Fatalf("failed to serve control socket: %v", err)
+	}
+
+	statuses, err := getTopology(path)
+	if err != nil {
+		t.Fatalf("failed to get topology: %v", err)
+	}
+	expected := []servicemanager.ServiceStatus{
+		{Name: "etcd", Dependencies: []string{}, State: servicemanager.StatePending},
+		{Name: "kube-apiserver", Dependencies: []string{"etcd"}, State: servicemanager.StatePending},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected topology %v, got %v", expected, statuses)
+	}
+}
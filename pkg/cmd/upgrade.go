@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/v2"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/version"
+)
+
+// legacyDataVersion stands for the version of data written before MicroShift
+// recorded its version
+const legacyDataVersion = "legacy"
+
+// binaryVersion is a variable so that tests can simulate other versions
+var binaryVersion = func() string { return version.Get().String() }
+
+// dataVersion is the MicroShift version that last used the data directory
+type dataVersion struct {
+	Version string `json:"version"`
+}
+
+// migration is a step that runs on the first start of a MicroShift version whose
+// minor version is Minor, when upgrading from an older minor version.
+type migration struct {
+	Minor       uint   `json:"minor"`
+	Description string `json:"description"`
+}
+
+// migrations lists the steps required to upgrade the data of earlier versions
+var migrations = []migration{}
+
+// upgradeCheck is the result of checking an upgrade of the data directory
+type upgradeCheck struct {
+	From       string      `json:"from,omitempty"`
+	To         string      `json:"to"`
+	Supported  bool        `json:"supported"`
+	Reason     string      `json:"reason,omitempty"`
+	Migrations []migration `json:"migrations"`
+}
+
+type upgradeCheckOptions struct {
+	To     string
+	Output string
+	genericclioptions.IOStreams
+}
+
+func versionFilePath(cfg *config.MicroshiftConfig) string {
+	return filepath.Join(cfg.DataDir, "version")
+}
+
+// writeDataVersion records the version of this binary as the one using the data
+// directory. Data of a newer version, e.g. after rolling back the OS while the data
+// directory persists, is only warned about and its version kept, and versions that
+// can't be compared, e.g. of development builds, aren't recorded.
+func writeDataVersion(cfg *config.MicroshiftConfig) error {
+	current := binaryVersion()
+	target, err := utilversion.ParseGeneric(current)
+	if err != nil {
+		klog.Warningf("Not recording the version %q in the data directory, it can't be compared to other versions", current)
+		return nil
+	}
+	recorded, err := readDataVersion(cfg)
+	if err != nil {
+		return err
+	}
+	if previous, err := utilversion.ParseGeneric(recorded); err == nil && target.LessThan(previous) {
+		klog.Warningf("The data directory was used by the newer version %s, %s may not be able to read the data", recorded, current)
+		return nil
+	}
+
+	marshalled, err := json.Marshal(dataVersion{Version: current})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(versionFilePath(cfg), append(marshalled, '\n'), 0600)
+}
+
+// readDataVersion returns the version that last used the data directory, an empty
+// string if it wasn't used yet, or legacyDataVersion if it holds etcd data written
+// before the version was recorded.
+func readDataVersion(cfg *config.MicroshiftConfig) (string, error) {
+	contents, err := os.ReadFile(versionFilePath(cfg))
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(cfg.RoleDataDir(config.ControlPlaneRole), "etcd")); err == nil {
+			return legacyDataVersion, nil
+		}
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	persisted := dataVersion{}
+	if err := json.Unmarshal(contents, &persisted); err != nil {
+		return "", fmt.Errorf("decoding %s: %v", versionFilePath(cfg), err)
+	}
+	return persisted.Version, nil
+}
+
+// checkUpgrade determines whether data written by the from version can be used by
+// the to version. Upgrades may only move forward, by at most one minor version at a
+// time, like for the Kubernetes components embedded in MicroShift.
+func checkUpgrade(from, to string) (*upgradeCheck, error) {
+	target, err := utilversion.ParseGeneric(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target version %q: %v", to, err)
+	}
+	result := &upgradeCheck{From: from, To: to, Migrations: []migration{}}
+	if from == "" {
+		result.Supported = true
+		result.Reason = "the data directory wasn't used yet"
+		return result, nil
+	}
+	if from == legacyDataVersion {
+		result.Reason = "the data directory was used by a version that didn't record its version, the upgrade can't be checked"
+		return result, nil
+	}
+	current, err := utilversion.ParseGeneric(from)
+	if err != nil {
+		result.Reason = fmt.Sprintf("the recorded version %q can't be compared to other versions, the upgrade can't be checked", from)
+		return result, nil
+	}
+
+	switch {
+	case target.LessThan(current):
+		result.Reason = "downgrades are not supported, older versions may not be able to read the data"
+	case target.Major() != current.Major():
+		result.Reason = "upgrades to another major version are not supported"
+	case target.Minor() > current.Minor()+1:
+		result.Reason = fmt.Sprintf("upgrades may skip no minor version, upgrade to %d.%d first", current.Major(), current.Minor()+1)
+	default:
+		result.Supported = true
+		for _, m := range migrations {
+			if m.Minor > current.Minor() && m.Minor <= target.Minor() {
+				result.Migrations = append(result.Migrations, m)
+			}
+		}
+	}
+	return result, nil
+}
+
+func NewUpgradeCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Prepare upgrades of MicroShift",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewUpgradeCheckCommand(ioStreams))
+	return cmd
+}
+
+func NewUpgradeCheckCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := upgradeCheckOptions{
+		Output:    "text",
+		IOStreams: ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check whether a version can take over the data of this MicroShift instance, without making changes",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.To, "to", opts.To, "Version to upgrade to, e.g. 4.12.1.")
+	flags.StringVarP(&opts.Output, "output", "o", opts.Output, "One of 'text' or 'json'.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *upgradeCheckOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if opts.Output != "text" && opts.Output != "json" {
+		return fmt.Errorf("unknown output format %q", opts.Output)
+	}
+	if opts.To == "" {
+		return fmt.Errorf("the version to upgrade to must be specified with --to")
+	}
+	// the data directory may be configured
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+
+	from, err := readDataVersion(cfg)
+	if err != nil {
+		return err
+	}
+	result, err := checkUpgrade(from, opts.To)
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		marshalled, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.Out, "%s\n", string(marshalled))
+	} else {
+		renderUpgradeCheckText(opts.Out, result)
+	}
+	if !result.Supported {
+		return fmt.Errorf("upgrade from %s to %s is not supported", result.From, result.To)
+	}
+	return nil
+}
+
+func renderUpgradeCheckText(w io.Writer, result *upgradeCheck) {
+	from := result.From
+	if from == "" {
+		from = "(none)"
+	}
+	fmt.Fprintf(w, "Data directory version: %s\n", from)
+	fmt.Fprintf(w, "Target version: %s\n", result.To)
+	if !result.Supported {
+		fmt.Fprintf(w, "Not supported: %s\n", result.Reason)
+		return
+	}
+	if result.Reason != "" {
+		fmt.Fprintf(w, "Supported: %s\n", result.Reason)
+	} else {
+		fmt.Fprintln(w, "Supported")
+	}
+	if len(result.Migrations) == 0 {
+		fmt.Fprintln(w, "No migrations will run")
+		return
+	}
+	fmt.Fprintln(w, "Migrations that will run on the first start:")
+	for _, m := range result.Migrations {
+		fmt.Fprintf(w, "  - %s\n", m.Description)
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func TestCheckUpgrade(t *testing.T) {
+	var ttests = []struct {
+		name      string
+		from      string
+		to        string
+		supported bool
+		reason    string
+	}{
+		{name: "first start", from: "", to: "4.12.0", supported: true},
+		{name: "patch upgrade", from: "4.12.0", to: "4.12.3", supported: true},
+		{name: "same version", from: "4.12.3", to: "4.12.3", supported: true},
+		{name: "minor upgrade", from: "4.12.3", to: "4.13.0", supported: true},
+		{name: "build suffix", from: "4.12.0-0.microshift-2022-10-14-083000", to: "4.13.0", supported: true},
+		{name: "skipped minor version", from: "4.11.2", to: "4.13.0", reason: "upgrade to 4.12 first"},
+		{name: "major upgrade", from: "4.12.0", to: "5.0.0", reason: "another major version"},
+		{name: "minor downgrade", from: "4.13.0", to: "4.12.5", reason: "downgrades are not supported"},
+		{name: "patch downgrade", from: "4.12.3", to: "4.12.1", reason: "downgrades are not supported"},
+	}
+	for _, tt := range ttests {
+		result, err := checkUpgrade(tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s: checkUpgrade() error = %v", tt.name, err)
+		}
+		if result.Supported != tt.supported {
+			t.Errorf("%s: expected supported to be %v, got %v (%s)", tt.name, tt.supported, result.Supported, result.Reason)
+		}
+		if !strings.Contains(result.Reason, tt.reason) {
+			t.Errorf("%s: expected reason containing %q, got %q", tt.name, tt.reason, result.Reason)
+		}
+	}
+}
+
+func TestCheckUpgradeInvalidVersions(t *testing.T) {
+	if _, err := checkUpgrade("4.12.0", "latest"); err == nil {
+		t.Errorf("expected an invalid target version to fail")
+	}
+	for _, from := range []string{"unknown", legacyDataVersion} {
+		result, err := checkUpgrade(from, "4.12.0")
+		if err != nil {
+			t.Fatalf("%s: checkUpgrade() error = %v", from, err)
+		}
+		if result.Supported || !strings.Contains(result.Reason, "can't be checked") {
+			t.Errorf("%s: expected the upgrade not to be checkable, got %+v", from, result)
+		}
+	}
+}
+
+func TestCheckUpgradeMigrations(t *testing.T) {
+	defer func(m []migration) { migrations = m }(migrations)
+	migrations = []migration{
+		{Minor: 12, Description: "migrate A"},
+		{Minor: 13, Description: "migrate B"},
+		{Minor: 14, Description: "migrate C"},
+	}
+
+	result, err := checkUpgrade("4.12.4", "4.13.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := migrations[1:2]; !reflect.DeepEqual(result.Migrations, want) {
+		t.Errorf("expected migrations %v, got %v", want, result.Migrations)
+	}
+
+	var out bytes.Buffer
+	renderUpgradeCheckText(&out, result)
+	if !strings.Contains(out.String(), "  - migrate B\n") {
+		t.Errorf("expected the migrations to be listed, got:\n%s", out.String())
+	}
+}
+
+func TestDataVersion(t *testing.T) {
+	defer func(v func() string) { binaryVersion = v }(binaryVersion)
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+
+	if v, err := readDataVersion(cfg); err != nil || v != "" {
+		t.Fatalf("expected no version before the first start, got %q, %v", v, err)
+	}
+	binaryVersion = func() string { return "unknown" }
+	if err := writeDataVersion(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := readDataVersion(cfg); err != nil || v != "" {
+		t.Errorf("expected a version that can't be compared not to be recorded, got %q, %v", v, err)
+	}
+	binaryVersion = func() string { return "4.12.3" }
+	if err := writeDataVersion(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := readDataVersion(cfg); err != nil || v != "4.12.3" {
+		t.Errorf("expected the recorded version, got %q, %v", v, err)
+	}
+	binaryVersion = func() string { return "4.12.1" }
+	if err := writeDataVersion(cfg); err != nil {
+		t.Errorf("expected a rollback to an older version to keep starting, got %v", err)
+	}
+	if v, err := readDataVersion(cfg); err != nil || v != "4.12.3" {
+		t.Errorf("expected the recorded version to be kept on a downgrade, got %q, %v", v, err)
+	}
+
+	if err := os.WriteFile(versionFilePath(cfg), []byte("4.12"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDataVersion(cfg); err == nil {
+		t.Errorf("expected a malformed version file to fail")
+	}
+}
+
+func TestDataVersionLegacy(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(cfg.DataDir, "etcd"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := readDataVersion(cfg); err != nil || v != legacyDataVersion {
+		t.Errorf("expected etcd data without a version file to be legacy, got %q, %v", v, err)
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+const (
+	defaultWaitForReadyTimeout  = 5 * time.Minute
+	defaultWaitForReadyInterval = time.Second
+	waitForReadyProgressPeriod  = 10 * time.Second
+)
+
+type waitForReadyOptions struct {
+	Timeout  time.Duration
+	Interval time.Duration
+	// progressPeriod is how often the services MicroShift waits for are printed
+	progressPeriod time.Duration
+	genericclioptions.IOStreams
+}
+
+func NewWaitForReadyCommand(ioStreams genericclioptions.IOStreams) *cobra.Command {
+	opts := waitForReadyOptions{
+		Timeout:        defaultWaitForReadyTimeout,
+		Interval:       defaultWaitForReadyInterval,
+		progressPeriod: waitForReadyProgressPeriod,
+		IOStreams:      ioStreams,
+	}
+
+	cfg := config.NewMicroshiftConfig()
+
+	cmd := &cobra.Command{
+		Use:   "wait-for-ready",
+		Short: "Wait until the running MicroShift instance is ready, e.g. in provisioning scripts",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.Run(cfg, cmd))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "How long to wait for MicroShift to become ready before giving up.")
+	flags.DurationVar(&opts.Interval, "interval", opts.Interval, "How often to check whether MicroShift is ready.")
+	addRunFlags(cmd, cfg)
+
+	return cmd
+}
+
+func (opts *waitForReadyOptions) Run(cfg *config.MicroshiftConfig, cmd *cobra.Command) error {
+	if opts.Timeout <= 0 || opts.Interval <= 0 {
+		return fmt.Errorf("--timeout and --interval must be positive")
+	}
+	// the data directory holding the control socket may be configured
+	if err := cfg.ReadAndValidate(configFileFlag(cmd.Flags()), cmd.Flags()); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	return opts.wait(ctx, cfg.ControlSocketPath())
+}
+
+// wait polls the readiness of the instance listening on the control socket until it
+// is ready or ctx is done. MicroShift may not be running yet, so failing to connect
+// is retried like not being ready.
+func (opts *waitForReadyOptions) wait(ctx context.Context, path string) error {
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var status string
+	var lastProgress time.Time
+	for {
+		r, err := getReadiness(path)
+		switch {
+		case err != nil:
+			status = err.Error()
+		case r.Ready:
+			fmt.Fprintln(opts.Out, "MicroShift is ready")
+			return nil
+		case len(r.Failed) > 0:
+			status = strings.Join(r.Failed, ", ") + " failed"
+		case len(r.Waiting) > 0:
+			status = "waiting for " + strings.Join(r.Waiting, ", ")
+		default:
+			status = "waiting for the readiness checks"
+		}
+		if time.Since(lastProgress) >= opts.progressPeriod {
+			fmt.Fprintf(opts.ErrOut, "MicroShift is not ready: %s\n", status)
+			lastProgress = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("MicroShift did not become ready within %s: %s", opts.Timeout, status)
+		case <-ticker.C:
+		}
+	}
+}
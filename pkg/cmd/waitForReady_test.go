@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/openshift/microshift/pkg/servicemanager"
+)
+
+// serveFakeReadyz serves a readiness endpoint on a unix socket that reports MicroShift
+// as ready from the given call on.
+func serveFakeReadyz(t *testing.T, readyFrom int32) (string, *int32) {
+	path := filepath.Join(t.TempDir(), "microshift.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc(readyzPath, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < readyFrom {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(readiness{Waiting: []string{"kube-apiserver"}, Failed: []string{}})
+			return
+		}
+		json.NewEncoder(w).Encode(readiness{Ready: true, Waiting: []string{}, Failed: []string{}})
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return path, &calls
+}
+
+func TestWaitForReadyBecomesReady(t *testing.T) {
+	path, calls := serveFakeReadyz(t, 3)
+
+	var out, errOut bytes.Buffer
+	opts := &waitForReadyOptions{
+		Timeout:   5 * time.Second,
+		Interval:  10 * time.Millisecond,
+		IOStreams: genericclioptions.IOStreams{Out: &out, ErrOut: &errOut},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	if err := opts.wait(ctx, path); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	if got := atomic.LoadInt32(calls); got != 3 {
+		t.Errorf("expected to poll until the third check, polled %d times", got)
+	}
+	if out.String() != "MicroShift is ready\n" {
+		t.Errorf("expected the readiness to be reported, got %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), "MicroShift is not ready: waiting for kube-apiserver") {
+		t.Errorf("expected the progress to name the services not ready, got %q", errOut.String())
+	}
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	path, _ := serveFakeReadyz(t, 1000)
+
+	var out bytes.Buffer
+	opts := &waitForReadyOptions{
+		Timeout:   100 * time.Millisecond,
+		Interval:  10 * time.Millisecond,
+		IOStreams: genericclioptions.IOStreams{Out: &out, ErrOut: &out},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	err := opts.wait(ctx, path)
+	if err == nil {
+		t.Fatal("expected an error when MicroShift doesn't become ready")
+	}
+	if !strings.Contains(err.Error(), "waiting for kube-apiserver") {
+		t.Errorf("expected the error to name the services not ready, got %v", err)
+	}
+}
+
+func TestWaitForReadyNotRunning(t *testing.T) {
+	var out bytes.Buffer
+	opts := &waitForReadyOptions{
+		Timeout:   50 * time.Millisecond,
+		Interval:  10 * time.Millisecond,
+		IOStreams: genericclioptions.IOStreams{Out: &out, ErrOut: &out},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	if err := opts.wait(ctx, filepath.Join(t.TempDir(), "microshift.sock")); err == nil || !strings.Contains(err.Error(), "is it running?") {
+		t.Errorf("expected an error about MicroShift not running, got %v", err)
+	}
+}
+
+func TestControlSocketReadiness(t *testing.T) {
+	m := servicemanager.NewServiceManager()
+	if err := m.AddService(servicemanager.NewGenericService("etcd", []string{}, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "microshift.sock")
+	if err := serveControlSocket(ctx, path, m, nil, nil); err != nil {
+		t.Fatalf("failed to serve control socket: %v", err)
+	}
+
+	r, err := getReadiness(path)
+	if err != nil {
+		t.Fatalf("failed to get readiness: %v", err)
+	}
+	if expected := (readiness{Ready: false, Waiting: []string{"etcd"}, Failed: []string{}}); !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected readiness %v, got %v", expected, r)
+	}
+}
+
+func TestReadinessOf(t *testing.T) {
+	statuses := []servicemanager.ServiceStatus{
+		{Name: "etcd", State: servicemanager.StateReady},
+		{Name: "openshift-crd-manager", State: servicemanager.StateStopped},
+		{Name: "kustomizer", State: servicemanager.StateStopped, Optional: true},
+		{Name: "mdns", State: servicemanager.StateDegraded, Optional: true},
+	}
+	if r := readinessOf(statuses, true); !r.Ready {
+		t.Errorf("expected ready services, completed services and settled optional services to be ready, got %v", r)
+	}
+	// the same gates as the systemd readiness notification apply
+	expected := readiness{Ready: false, Waiting: []string{}, Failed: []string{"openshift-crd-manager"}}
+	if r := readinessOf(statuses, false); !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected readiness %v before the notification, got %v", expected, r)
+	}
+
+	failed := append(statuses, servicemanager.ServiceStatus{Name: "kube-apiserver", State: servicemanager.StateFailed})
+	expected = readiness{Ready: false, Waiting: []string{}, Failed: []string{"kube-apiserver"}}
+	if r := readinessOf(failed, true); !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected readiness %v with a failed critical service, got %v", expected, r)
+	}
+
+	statuses = append(statuses, testTopology...)
+	expected = readiness{Ready: false, Waiting: []string{"kube-apiserver", "kube-scheduler"}, Failed: []string{}}
+	if r := readinessOf(statuses, true); !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected readiness %v, got %v", expected, r)
+	}
+}
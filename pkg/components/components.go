@@ -5,33 +5,41 @@ import (
 	"k8s.io/klog/v2"
 )
 
-var microshiftDataDir = config.GetDataDir()
+type component struct {
+	name        string
+	description string
+	start       func(cfg *config.MicroshiftConfig, kubeconfigPath string) error
+}
+
+// components are started in order, the service-ca first as others depend on it
+var components = []component{
+	{config.ComponentServiceCA, "service-ca controller", startServiceCAController},
+	{config.ComponentStorage, "csi plugin", startCSIPlugin},
+	{config.ComponentIngress, "ingress router controller", startIngressController},
+	{config.ComponentDNS, "DNS controller", startDNSController},
+	{config.ComponentNetwork, "OVNKubernetes", startOVNKubernetes},
+}
 
 func StartComponents(cfg *config.MicroshiftConfig) error {
 	kubeAdminConfig := cfg.KubeConfigPath(config.KubeAdmin)
 
-	if err := startServiceCAController(cfg, kubeAdminConfig); err != nil {
-		klog.Warningf("Failed to start service-ca controller: %v", err)
-		return err
-	}
-
-	if err := startCSIPlugin(cfg, cfg.KubeConfigPath(config.KubeAdmin)); err != nil {
-		klog.Warningf("Failed to start csi plugin: %v", err)
-		return err
-	}
-
-	if err := startIngressController(cfg, kubeAdminConfig); err != nil {
-		klog.Warningf("Failed to start ingress router controller: %v", err)
-		return err
-	}
-	if err := startDNSController(cfg, kubeAdminConfig); err != nil {
-		klog.Warningf("Failed to start DNS controller: %v", err)
-		return err
+	for _, c := range enabledComponents(cfg) {
+		if err := c.start(cfg, kubeAdminConfig); err != nil {
+			klog.Warningf("Failed to start %s: %v", c.description, err)
+			return err
+		}
 	}
+	return nil
+}
 
-	if err := startOVNKubernetes(cfg, kubeAdminConfig); err != nil {
-		klog.Warningf("Failed to start OVNKubernetes: %v", err)
-		return err
+func enabledComponents(cfg *config.MicroshiftConfig) []component {
+	enabled := []component{}
+	for _, c := range components {
+		if !cfg.ComponentEnabled(c.name) {
+			klog.Infof("Skipping disabled component %s", c.name)
+			continue
+		}
+		enabled = append(enabled, c)
 	}
-	return nil
+	return enabled
 }
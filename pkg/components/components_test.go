@@ -0,0 +1,47 @@
+package components
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func Test_enabledComponents(t *testing.T) {
+	var tests = []struct {
+		name     string
+		disabled []string
+		want     []string
+	}{
+		{
+			name:     "all enabled",
+			disabled: nil,
+			want:     []string{config.ComponentServiceCA, config.ComponentStorage, config.ComponentIngress, config.ComponentDNS, config.ComponentNetwork},
+		},
+		{
+			name:     "storage disabled",
+			disabled: []string{config.ComponentStorage},
+			want:     []string{config.ComponentServiceCA, config.ComponentIngress, config.ComponentDNS, config.ComponentNetwork},
+		},
+		{
+			name:     "ingress and storage disabled",
+			disabled: []string{config.ComponentIngress, config.ComponentStorage},
+			want:     []string{config.ComponentServiceCA, config.ComponentDNS, config.ComponentNetwork},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewMicroshiftConfig()
+			cfg.Components.Disabled = tt.disabled
+
+			got := []string{}
+			for _, c := range enabledComponents(cfg) {
+				got = append(got, c.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enabledComponents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
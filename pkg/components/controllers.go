@@ -2,6 +2,7 @@ package components
 
 import (
 	"os"
+	"strings"
 
 	"github.com/openshift/microshift/pkg/assets"
 	"github.com/openshift/microshift/pkg/config"
@@ -39,7 +40,7 @@ func startServiceCAController(cfg *config.MicroshiftConfig, kubeconfigPath strin
 		cmName     = "signing-cabundle"
 	)
 
-	serviceCADir := cryptomaterial.ServiceCADir(cryptomaterial.CertsDirectory(microshiftDataDir))
+	serviceCADir := cryptomaterial.ServiceCADir(cryptomaterial.CertsDirectory(cfg.DataDir))
 	caCertPath := cryptomaterial.CACertPath(serviceCADir)
 	caKeyPath := cryptomaterial.CAKeyPath(serviceCADir)
 
@@ -58,7 +59,7 @@ func startServiceCAController(cfg *config.MicroshiftConfig, kubeconfigPath strin
 	secretData["tls.crt"] = caCertPEM
 	secretData["tls.key"] = caKeyPEM
 
-	if err := assets.ApplyNamespaces(ns, kubeconfigPath); err != nil {
+	if err := assets.ApplyNamespaces(ns, cfg.Namespaces, kubeconfigPath); err != nil {
 		klog.Warningf("Failed to apply ns %v: %v", ns, err)
 		return err
 	}
@@ -129,9 +130,8 @@ func startIngressController(cfg *config.MicroshiftConfig, kubeconfigPath string)
 		extSvc = []string{
 			"components/openshift-router/service-cloud.yaml",
 		}
-		servingKeypairSecret = "components/openshift-router/serving-certificate.yaml"
 	)
-	if err := assets.ApplyNamespaces(ns, kubeconfigPath); err != nil {
+	if err := assets.ApplyNamespaces(ns, cfg.Namespaces, kubeconfigPath); err != nil {
 		klog.Warningf("Failed to apply namespaces %v: %v", ns, err)
 		return err
 	}
@@ -159,23 +159,43 @@ func startIngressController(cfg *config.MicroshiftConfig, kubeconfigPath string)
 		klog.Warningf("Failed to apply external ingress svc %v: %v", extSvc, err)
 		return err
 	}
+	if err := ApplyIngressServingCertificate(cfg.Ingress.ServingCertificate, cfg.Ingress.ServingKey, kubeconfigPath); err != nil {
+		return err
+	}
+
+	if err := assets.ApplyDeployments(apps, renderTemplate, renderParamsFromConfig(cfg, nil), kubeconfigPath); err != nil {
+		klog.Warningf("Failed to apply apps %v: %v", apps, err)
+		return err
+	}
+	return nil
+}
+
+// ApplyIngressServingCertificate creates or updates the secret holding the router's
+// serving certificate.
+func ApplyIngressServingCertificate(cert, key []byte, kubeconfigPath string) error {
+	servingKeypairSecret := "components/openshift-router/serving-certificate.yaml"
 	if err := assets.ApplySecretWithData(
 		servingKeypairSecret,
 		map[string][]byte{
-			"tls.crt": cfg.Ingress.ServingCertificate,
-			"tls.key": cfg.Ingress.ServingKey,
+			"tls.crt": cert,
+			"tls.key": key,
 		},
 		kubeconfigPath,
 	); err != nil {
 		klog.Warningf("failed to apply secret %q: %v", servingKeypairSecret, err)
 		return err
 	}
+	return nil
+}
 
-	if err := assets.ApplyDeployments(apps, renderTemplate, renderParamsFromConfig(cfg, nil), kubeconfigPath); err != nil {
-		klog.Warningf("Failed to apply apps %v: %v", apps, err)
-		return err
+// dnsParams returns the render parameters of the cluster DNS config. Queries for names
+// outside the cluster go to the configured forwarders, or the host's resolvers.
+func dnsParams(cfg *config.MicroshiftConfig) assets.RenderParams {
+	forwarders := "/etc/resolv.conf"
+	if len(cfg.Cluster.DNSForwarders) > 0 {
+		forwarders = strings.Join(cfg.Cluster.DNSForwarders, " ")
 	}
-	return nil
+	return assets.RenderParams{"DNSForwarders": forwarders}
 }
 
 func startDNSController(cfg *config.MicroshiftConfig, kubeconfigPath string) error {
@@ -204,7 +224,7 @@ func startDNSController(cfg *config.MicroshiftConfig, kubeconfigPath string) err
 			"components/openshift-dns/dns/service.yaml",
 		}
 	)
-	if err := assets.ApplyNamespaces(ns, kubeconfigPath); err != nil {
+	if err := assets.ApplyNamespaces(ns, cfg.Namespaces, kubeconfigPath); err != nil {
 		klog.Warningf("Failed to apply", "namespace", ns, "err", err)
 		return err
 	}
@@ -228,7 +248,7 @@ func startDNSController(cfg *config.MicroshiftConfig, kubeconfigPath string) err
 		klog.Warningf("Failed to apply serviceAccount %v %v", sa, err)
 		return err
 	}
-	if err := assets.ApplyConfigMaps(cm, nil, nil, kubeconfigPath); err != nil {
+	if err := assets.ApplyConfigMaps(cm, renderTemplate, renderParamsFromConfig(cfg, dnsParams(cfg)), kubeconfigPath); err != nil {
 		klog.Warningf("Failed to apply configMap %v %v", cm, err)
 		return err
 	}
@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"sigs.k8s.io/yaml"
@@ -12,7 +14,6 @@ import (
 	"github.com/openshift/microshift/pkg/assets"
 	"github.com/openshift/microshift/pkg/config"
 	"github.com/openshift/microshift/pkg/config/lvmd"
-	"github.com/openshift/microshift/pkg/release"
 )
 
 var templateFuncs = map[string]interface{}{
@@ -21,14 +22,17 @@ var templateFuncs = map[string]interface{}{
 }
 
 func renderParamsFromConfig(cfg *config.MicroshiftConfig, extra assets.RenderParams) assets.RenderParams {
+	// overrides are validated with the config
+	images, _ := cfg.ComponentImages()
 	params := map[string]interface{}{
-		"ReleaseImage":  release.Image,
-		"NodeName":      cfg.NodeName,
+		"ReleaseImage":  images,
+		"NodeName":      cfg.KubeletNodeName(),
 		"NodeIP":        cfg.NodeIP,
-		"ClusterCIDR":   cfg.Cluster.ClusterCIDR,
-		"ServiceCIDR":   cfg.Cluster.ServiceCIDR,
+		"ClusterCIDR":   strings.Join(cfg.ClusterCIDRs(), ","),
+		"ServiceCIDR":   strings.Join(cfg.ServiceCIDRs(), ","),
 		"ClusterDNS":    cfg.Cluster.DNS,
 		"ClusterDomain": cfg.Cluster.Domain,
+		"IngressDomain": cfg.IngressDomain(),
 		"MTU":           cfg.Cluster.MTU,
 	}
 	for k, v := range extra {
@@ -37,6 +41,13 @@ func renderParamsFromConfig(cfg *config.MicroshiftConfig, extra assets.RenderPar
 	return params
 }
 
+func renderStorageClassParams(cfg *config.MicroshiftConfig) assets.RenderParams {
+	return assets.RenderParams{
+		"StorageClassName":    cfg.Storage.ClassName,
+		"StorageClassDefault": strconv.FormatBool(cfg.Storage.DefaultClass),
+	}
+}
+
 func renderTemplate(tb []byte, data assets.RenderParams) ([]byte, error) {
 	tmpl, err := template.New("").Option("missingkey=error").Funcs(templateFuncs).Parse(string(tb))
 	if err != nil {
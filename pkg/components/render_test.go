@@ -175,3 +175,126 @@ func Test_renderTopolvmDaemonsetTemplate(t *testing.T) {
 		})
 	}
 }
+
+func Test_renderDNSConfigMap(t *testing.T) {
+	tb := embedded.MustAsset("components/openshift-dns/dns/configmap.yaml")
+
+	tests := []struct {
+		name       string
+		forwarders []string
+		want       string
+	}{
+		{
+			name: "forwards to the host resolvers by default",
+			want: "forward . /etc/resolv.conf {",
+		},
+		{
+			name:       "forwards to the configured upstream servers",
+			forwarders: []string{"192.168.1.1", "192.168.1.2:5353", "[fd00::1]:53"},
+			want:       "forward . 192.168.1.1 192.168.1.2:5353 [fd00::1]:53 {",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewMicroshiftConfig()
+			cfg.Cluster.DNSForwarders = tt.forwarders
+			got, err := renderTemplate(tb, renderParamsFromConfig(cfg, dnsParams(cfg)))
+			if err != nil {
+				t.Fatalf("renderTemplate() error = %v", err)
+			}
+			if !bytes.Contains(got, []byte(tt.want)) {
+				t.Errorf("renderTemplate() expected Corefile to contain %q, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_renderRouterDeployment(t *testing.T) {
+	tb := embedded.MustAsset("components/openshift-router/deployment.yaml")
+
+	tests := []struct {
+		name          string
+		ingressDomain string
+		want          string
+	}{
+		{
+			name: "derives the domain from the node name",
+			want: "value: router-default.apps.edge-node-1\n",
+		},
+		{
+			name:          "uses the configured domain",
+			ingressDomain: "apps.example.com",
+			want:          "value: router-default.apps.example.com\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewMicroshiftConfig()
+			cfg.NodeName = "edge-node-1"
+			cfg.Cluster.IngressDomain = tt.ingressDomain
+			got, err := renderTemplate(tb, renderParamsFromConfig(cfg, nil))
+			if err != nil {
+				t.Fatalf("renderTemplate() error = %v", err)
+			}
+			if !bytes.Contains(got, []byte(tt.want)) {
+				t.Errorf("renderTemplate() expected the router deployment to contain %q, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_renderImageOverrides(t *testing.T) {
+	tb := embedded.MustAsset("components/openshift-dns/dns/daemonset.yaml")
+
+	cfg := config.NewMicroshiftConfig()
+	cfg.Images.Overrides = map[string]string{"coredns": "registry.local:5000/coredns:v4.12"}
+	got, err := renderTemplate(tb, renderParamsFromConfig(cfg, assets.RenderParams{"ClusterIP": cfg.Cluster.DNS}))
+	if err != nil {
+		t.Fatalf("renderTemplate() error = %v", err)
+	}
+	if !bytes.Contains(got, []byte("image: registry.local:5000/coredns:v4.12")) {
+		t.Errorf("expected the overridden coredns image, got %s", got)
+	}
+	if !bytes.Contains(got, []byte("image: "+release.Image["kube_rbac_proxy"])) {
+		t.Errorf("expected the embedded kube-rbac-proxy image, got %s", got)
+	}
+}
+
+func Test_renderStorageClass(t *testing.T) {
+	tb := embedded.MustAsset("components/odf-lvm/topolvm_default-storage-class.yaml")
+
+	tests := []struct {
+		name         string
+		className    string
+		defaultClass bool
+		want         []string
+	}{
+		{
+			name:         "defaults",
+			className:    "topolvm-provisioner",
+			defaultClass: true,
+			want:         []string{"name: topolvm-provisioner\n", `storageclass.kubernetes.io/is-default-class: "true"`},
+		},
+		{
+			name:      "renamed, not the default",
+			className: "local-lvm",
+			want:      []string{"name: local-lvm\n", `storageclass.kubernetes.io/is-default-class: "false"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewMicroshiftConfig()
+			cfg.Storage.ClassName = tt.className
+			cfg.Storage.DefaultClass = tt.defaultClass
+			got, err := renderTemplate(tb, renderStorageClassParams(cfg))
+			if err != nil {
+				t.Fatalf("renderTemplate() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !bytes.Contains(got, []byte(want)) {
+					t.Errorf("renderTemplate() expected the storage class to contain %q, got %s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -75,7 +75,7 @@ func startCSIPlugin(cfg *config.MicroshiftConfig, kubeconfigPath string) error {
 		return fmt.Errorf("rendering lvmd params: %v", err)
 	}
 
-	if err := assets.ApplyStorageClasses(sc, nil, nil, kubeconfigPath); err != nil {
+	if err := assets.ApplyStorageClasses(sc, renderTemplate, renderStorageClassParams(cfg), kubeconfigPath); err != nil {
 		klog.Warningf("Failed to apply storage cass %v: %v", sc, err)
 		return err
 	}
@@ -83,7 +83,7 @@ func startCSIPlugin(cfg *config.MicroshiftConfig, kubeconfigPath string) error {
 		klog.Warningf("Failed to apply csiDriver %v: %v", sc, err)
 		return err
 	}
-	if err := assets.ApplyNamespaces(ns, kubeconfigPath); err != nil {
+	if err := assets.ApplyNamespaces(ns, cfg.Namespaces, kubeconfigPath); err != nil {
 		klog.Warningf("Failed to apply ns %v: %v", ns, err)
 		return err
 	}
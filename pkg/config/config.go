@@ -3,20 +3,37 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/pflag"
+	"go.etcd.io/etcd/client/pkg/v3/tlsutil"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/server/egressselector"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
 	"k8s.io/component-base/logs"
 	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/util/parsers"
 	"sigs.k8s.io/yaml"
 
+	"github.com/openshift/microshift/pkg/release"
 	"github.com/openshift/microshift/pkg/util"
 )
 
@@ -25,16 +42,167 @@ const (
 	defaultUserDataDir      = "~/.microshift/data"
 	defaultGlobalConfigFile = "/etc/microshift/config.yaml"
 	defaultGlobalDataDir    = "/var/lib/microshift"
+	defaultAuditLogDir      = "/var/log/kube-apiserver"
+	defaultLogMaxSizeMB     = 100
+	defaultLogMaxBackups    = 5
+	maxLogSizeMB            = 10 * 1024
+	maxLogBackups           = 1000
+	maxLogAgeDays           = 10 * 365
+	// upstream retains events for 1h and OpenShift for 3h, which bloats etcd on
+	// edge devices with a lot of event churn
+	defaultEventTTL = "30m"
+	defaultMaxPods  = 250
+	// kubelets rotate their certificates when most of their lifetime passed, shorter
+	// ones are rotated too often. The kube-csr-signer CA is valid for 30 days, which
+	// caps the lifetime of the certificates it signs.
+	minClusterSigningDuration = time.Hour
+	maxClusterSigningDuration = 30 * 24 * time.Hour
+	// the kubelet's default of 10Mi is rotated too often on busy nodes
+	defaultContainerLogMaxSize  = "50Mi"
+	defaultContainerLogMaxFiles = 5
+	// the apiserver keeps up to 10 rotated audit logs of 100MB each
+	defaultAuditLogMaxTotalSizeMB = 512
+	// the upstream defaults of the apiserver
+	defaultDeleteCollectionWorkers = 1
+	// the apiserver refuses larger values, which disrupt clients too often
+	maxGoawayChance = 0.02
+	// higher values are reserved for the system priority classes
+	maxPriorityClassValue = 1000000000
+	// retrying for about two minutes in total
+	defaultManifestsApplyRetries = 6
+	defaultManifestsApplyBackoff = "2s"
+	defaultStorageClassName      = "topolvm-provisioner"
+	// a single node isn't behind a load balancer that needs time to take it out of rotation
+	defaultShutdownDelayDuration = "0s"
+	defaultServiceAccountIssuer  = "https://kubernetes.default.svc"
 	// for files managed via management system in /etc, i.e. user applications
 	defaultManifestDirEtc = "/etc/microshift/manifests"
 	// for files embedded in ostree. i.e. cni/other component customizations
 	defaultManifestDirLib = "/usr/lib/microshift/manifests"
 )
 
+const (
+	ControlPlaneRole = "controlplane"
+	NodeRole         = "node"
+)
+
+// Infrastructure components deployed by MicroShift that can be disabled
+const (
+	ComponentServiceCA = "service-ca"
+	ComponentStorage   = "storage"
+	ComponentIngress   = "ingress"
+	ComponentDNS       = "dns"
+	ComponentNetwork   = "network"
+)
+
+// OpenShift-specific services run by MicroShift that can be disabled, e.g. to run
+// plain Kubernetes workloads only
+const (
+	// ComponentOpenShiftCRDs are the OpenShift API CRDs, e.g. routes and SCCs
+	ComponentOpenShiftCRDs = "openshift-crds"
+	// ComponentOpenShiftControllerManager runs the route controllers
+	ComponentOpenShiftControllerManager = "openshift-controller-manager"
+	// ComponentOpenShiftSCC are the default SCCs and their admission plugins
+	ComponentOpenShiftSCC = "openshift-scc"
+)
+
+const (
+	AuditLogFormatJSON   = "json"
+	AuditLogFormatLegacy = "legacy"
+
+	StorageMediaTypeJSON     = "application/json"
+	StorageMediaTypeYAML     = "application/yaml"
+	StorageMediaTypeProtobuf = "application/vnd.kubernetes.protobuf"
+
+	ApplyModeServer = "server"
+	ApplyModeClient = "client"
+
+	DefaultNetworkPolicyNone           = "none"
+	DefaultNetworkPolicyDenyAllIngress = "deny-all-ingress"
+	DefaultNetworkPolicyDenyAll        = "deny-all"
+
+	IPFamilyPolicySingleStack = "SingleStack"
+	IPFamilyPolicyDualStack   = "DualStack"
+
+	IPFamilyIPv4 = "IPv4"
+	IPFamilyIPv6 = "IPv6"
+
+	PreemptionPolicyPreemptLowerPriority = "PreemptLowerPriority"
+	PreemptionPolicyNever                = "Never"
+
+	TLSVersion12 = "VersionTLS12"
+	TLSVersion13 = "VersionTLS13"
+
+	CPUManagerPolicyNone   = "none"
+	CPUManagerPolicyStatic = "static"
+
+	AuthorizationModeScope         = "Scope"
+	AuthorizationModeSystemMasters = "SystemMasters"
+	AuthorizationModeRBAC          = "RBAC"
+	AuthorizationModeNode          = "Node"
+	AuthorizationModeWebhook       = "Webhook"
+
+	SwapBehaviorOff           = "off"
+	SwapBehaviorLimitedSwap   = "LimitedSwap"
+	SwapBehaviorUnlimitedSwap = "UnlimitedSwap"
+
+	TopologyManagerPolicyNone           = "none"
+	TopologyManagerPolicyBestEffort     = "best-effort"
+	TopologyManagerPolicyRestricted     = "restricted"
+	TopologyManagerPolicySingleNUMANode = "single-numa-node"
+
+	// WatchdogActionExit stops MicroShift, so that systemd restarts it
+	WatchdogActionExit = "exit"
+	// WatchdogActionLog only reports the unresponsive apiserver
+	WatchdogActionLog = "log"
+)
+
 var (
 	configFile   = findConfigFile()
-	dataDir      = findDataDir()
 	manifestsDir = findManifestsDir()
+
+	// getInterfaceIP is replaced in tests to not depend on the host's interfaces
+	getInterfaceIP = util.GetInterfaceIP
+
+	validRoles           = []string{ControlPlaneRole, NodeRole}
+	validAuditLogFormats = []string{AuditLogFormatJSON, AuditLogFormatLegacy}
+	validStorageMedia    = []string{StorageMediaTypeJSON, StorageMediaTypeYAML, StorageMediaTypeProtobuf}
+	validTLSMinVersions  = []string{TLSVersion12, TLSVersion13}
+	validWatchdogActions = []string{WatchdogActionExit, WatchdogActionLog}
+	validWorkloadKinds   = []string{"Deployment", "DaemonSet", "StatefulSet"}
+	validApplyModes      = []string{ApplyModeServer, ApplyModeClient}
+
+	validDefaultNetworkPolicies = []string{DefaultNetworkPolicyNone, DefaultNetworkPolicyDenyAllIngress, DefaultNetworkPolicyDenyAll}
+	validIPFamilyPolicies       = []string{IPFamilyPolicySingleStack, IPFamilyPolicyDualStack}
+	validIPFamilies             = []string{IPFamilyIPv4, IPFamilyIPv6}
+	validPreemptionPolicies     = []string{PreemptionPolicyPreemptLowerPriority, PreemptionPolicyNever}
+	validAuthorizationModes     = []string{AuthorizationModeScope, AuthorizationModeSystemMasters, AuthorizationModeRBAC, AuthorizationModeNode, AuthorizationModeWebhook}
+	validComponents             = []string{ComponentServiceCA, ComponentStorage, ComponentIngress, ComponentDNS, ComponentNetwork, ComponentOpenShiftCRDs, ComponentOpenShiftControllerManager, ComponentOpenShiftSCC}
+
+	validCPUManagerPolicies      = []string{CPUManagerPolicyNone, CPUManagerPolicyStatic}
+	validSwapBehaviors           = []string{SwapBehaviorOff, SwapBehaviorLimitedSwap, SwapBehaviorUnlimitedSwap}
+	validTopologyManagerPolicies = []string{TopologyManagerPolicyNone, TopologyManagerPolicyBestEffort, TopologyManagerPolicyRestricted, TopologyManagerPolicySingleNUMANode}
+
+	// sysctls are namespaced by the subsystem they configure
+	sysctlKeyPattern = regexp.MustCompile(`^(fs|kernel|net|user|vm)(\.[a-zA-Z0-9_-]+)+$`)
+	// requiredSysctls are the kernel parameters the network and services of the node
+	// depend on
+	requiredSysctls = map[string]string{
+		"net.ipv4.ip_forward":                 "1",
+		"net.bridge.bridge-nf-call-iptables":  "1",
+		"net.bridge.bridge-nf-call-ip6tables": "1",
+	}
+
+	// componentDependencies lists the components each component can't run without,
+	// e.g. the router and DNS services use serving certificates from the service-ca
+	// and the router serves routes, which are OpenShift CRDs
+	componentDependencies = map[string][]string{
+		ComponentIngress:                    {ComponentServiceCA, ComponentOpenShiftCRDs, ComponentOpenShiftControllerManager},
+		ComponentDNS:                        {ComponentServiceCA},
+		ComponentStorage:                    {ComponentOpenShiftCRDs},
+		ComponentOpenShiftControllerManager: {ComponentOpenShiftCRDs},
+		ComponentOpenShiftSCC:               {ComponentOpenShiftCRDs},
+	}
 )
 
 type ClusterConfig struct {
@@ -46,32 +214,546 @@ type ClusterConfig struct {
 	DNS                  string `json:"dns"`
 	Domain               string `json:"domain"`
 	MTU                  string `json:"mtu"`
+
+	// DNSForwarders are the upstream servers the cluster DNS forwards queries for
+	// names outside the cluster to, as IP or IP:port. By default the host's
+	// /etc/resolv.conf is used.
+	DNSForwarders []string `json:"dnsForwarders,omitempty"`
+
+	// DefaultNetworkPolicy is the baseline NetworkPolicy created in each new
+	// namespace outside of the system namespaces, either none, deny-all-ingress
+	// or deny-all.
+	DefaultNetworkPolicy string `json:"defaultNetworkPolicy"`
+	// DefaultResourceQuota and DefaultLimitRange are created in each new namespace
+	// outside of the system namespaces, e.g. to bound the resources runaway
+	// workloads may use.
+	DefaultResourceQuota DefaultResourceQuotaConfig `json:"defaultResourceQuota"`
+	DefaultLimitRange    DefaultLimitRangeConfig    `json:"defaultLimitRange"`
+
+	// IngressDomain is the base domain the default router serves the applications
+	// under, e.g. apps.example.com. Defaults to apps.<node name>.
+	IngressDomain string `json:"ingressDomain"`
+
+	// IPFamilyPolicy is either SingleStack, with a single cluster and service CIDR,
+	// or DualStack, with a comma-separated IPv4 and IPv6 CIDR pair for each.
+	IPFamilyPolicy string `json:"ipFamilyPolicy"`
+	// PrimaryIPFamily is the family listed first to the apiserver and the
+	// controller-manager, either IPv4 or IPv6, which e.g. the kubernetes service
+	// and single-stack services get their IP from. By default the CIDRs are
+	// listed in the configured order.
+	PrimaryIPFamily string `json:"primaryIPFamily"`
+}
+
+type APIServerConfig struct {
+	// ExtraArgs are passed to kube-apiserver in addition to the arguments
+	// managed by MicroShift, which take precedence.
+	ExtraArgs map[string][]string `json:"extraArgs,omitempty"`
+
+	// AuditLogFormat is the format of the audit log, either json or legacy
+	AuditLogFormat string `json:"auditLogFormat"`
+	// AuditLogMaxTotalSizeMB caps the size of the audit log directory. The oldest
+	// rotated audit logs are deleted once it is exceeded.
+	AuditLogMaxTotalSizeMB int `json:"auditLogMaxTotalSizeMB"`
+
+	// StorageMediaType is the encoding the apiserver stores objects in etcd with.
+	// Protobuf is smaller and faster to decode, JSON is readable with etcdctl.
+	StorageMediaType string `json:"storageMediaType"`
+
+	// WatchCache keeps the resources in the apiserver's memory to serve watches
+	// and lists without reading from etcd. Disabling it saves memory at the cost
+	// of load on etcd.
+	WatchCache bool `json:"watchCache"`
+	// DisabledWatchCaches lists the resources not to cache, e.g. "events" or
+	// "deployments.apps". The apiserver sizes the caches automatically, so they can
+	// only be disabled.
+	DisabledWatchCaches []string `json:"disabledWatchCaches,omitempty"`
+	// DeleteCollectionWorkers is the number of workers deleting the objects of a
+	// collection concurrently, e.g. when a namespace is deleted.
+	DeleteCollectionWorkers int `json:"deleteCollectionWorkers"`
+
+	// AggregatorRouting routes the requests to aggregated apiservers, e.g. metrics-server,
+	// directly to the endpoints of their services instead of through the service IP.
+	AggregatorRouting bool `json:"aggregatorRouting"`
+
+	// GoawayChance is the probability with which the apiserver asks HTTP/2 clients
+	// to reconnect, spreading long-lived connections after a restart. Upstream
+	// recommends 0.001, at most 0.02.
+	GoawayChance float64 `json:"goawayChance"`
+
+	// Socket is the path of a unix socket forwarding to the apiserver for local
+	// admin access, e.g. with curl --unix-socket. Empty disables it.
+	Socket string `json:"socket"`
+
+	// ExternalURL is the https URL under which the apiserver is reachable from
+	// outside, e.g. through a reverse proxy with a public hostname.
+	ExternalURL string `json:"externalURL"`
+	// SNICerts are additional serving certificates the apiserver selects by the
+	// hostname clients request, e.g. for names with certificates of their own.
+	SNICerts []SNICert `json:"sniCerts,omitempty"`
+
+	// ServiceAccountIssuer is the issuer of service account tokens and their default
+	// audience, e.g. the URL publishing the OIDC discovery documents for federation.
+	ServiceAccountIssuer string `json:"serviceAccountIssuer"`
+	// ServiceAccountSigningKeyFile is the path to the PEM encoded RSA or ECDSA private
+	// key signing service account tokens. A key is generated if it is not set.
+	ServiceAccountSigningKeyFile string `json:"serviceAccountSigningKeyFile"`
+
+	// EventTTL is the duration for which the apiserver retains events
+	EventTTL string `json:"eventTTL"`
+
+	// ShutdownDelayDuration is how long the apiserver keeps serving requests after
+	// it was asked to stop, reporting itself as not ready so that clients move away.
+	ShutdownDelayDuration string `json:"shutdownDelayDuration"`
+	// ShutdownSendRetryAfter makes the apiserver reject new requests with a
+	// Retry-After response while it shuts down, instead of accepting them.
+	ShutdownSendRetryAfter bool `json:"shutdownSendRetryAfter"`
+
+	// AnonymousAuth allows unauthenticated requests to the apiserver
+	AnonymousAuth bool `json:"anonymousAuth"`
+	// BootstrapTokenAuth authenticates requests with the bootstrap tokens stored
+	// as secrets in kube-system
+	BootstrapTokenAuth bool `json:"bootstrapTokenAuth"`
+	// Profiling exposes the apiserver's pprof handlers
+	Profiling bool `json:"profiling"`
+
+	// Konnectivity routes the apiserver's traffic to the cluster through a
+	// konnectivity server.
+	Konnectivity KonnectivityConfig `json:"konnectivity"`
+	// EgressSelectorConfigFile is an EgressSelectorConfiguration routing the
+	// apiserver's traffic, e.g. to the nodes through a proxy of its own. It
+	// can't be combined with Konnectivity, which configures the egress selector.
+	EgressSelectorConfigFile string `json:"egressSelectorConfigFile"`
+
+	// AuthorizationModes are the authorizers of the apiserver in the order they
+	// are consulted, e.g. Webhook after RBAC and Node.
+	AuthorizationModes []string `json:"authorizationModes,omitempty"`
+	// AuthorizationWebhookConfigFile is a kubeconfig of the authorization
+	// webhook consulted by the Webhook mode.
+	AuthorizationWebhookConfigFile string `json:"authorizationWebhookConfigFile"`
+
+	// Watchdog acts on an apiserver that stopped responding.
+	Watchdog WatchdogConfig `json:"watchdog"`
+}
+
+type SNICert struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// Names are the domain patterns, e.g. *.example.com, the certificate is
+	// served for. Defaults to the names in the certificate.
+	Names []string `json:"names,omitempty"`
+}
+
+type WatchdogConfig struct {
+	// Enabled starts checking the apiserver's liveness once it is ready
+	Enabled bool `json:"enabled"`
+	// Interval is the duration between two liveness checks
+	Interval string `json:"interval"`
+	// FailureThreshold is the duration the apiserver may stay unhealthy for
+	// before Action is taken.
+	FailureThreshold string `json:"failureThreshold"`
+	// Action is either exit or log
+	Action string `json:"action"`
+}
+
+type KonnectivityConfig struct {
+	// Enabled switches the apiserver network proxy integration on
+	Enabled bool `json:"enabled"`
+	// UDSName is the unix domain socket the konnectivity server listens on
+	// for connections from the apiserver.
+	UDSName string `json:"udsName"`
+}
+
+type EtcdConfig struct {
+	// TLSMinVersion is the minimum TLS version etcd accepts from clients and
+	// peers, either VersionTLS12 or VersionTLS13.
+	TLSMinVersion string `json:"tlsMinVersion"`
+	// TLSCipherSuites are the IANA names of the TLS 1.2 cipher suites etcd
+	// accepts. MicroShift's defaults are used if empty.
+	TLSCipherSuites []string `json:"tlsCipherSuites,omitempty"`
+	// Ephemeral keeps etcd's data in memory instead of the data directory, e.g.
+	// for CI and demos. All cluster state is lost when MicroShift restarts.
+	Ephemeral bool `json:"ephemeral"`
+	// RecoverStaleFiles removes the temporary files an unclean shutdown leaves in
+	// etcd's data directory before starting etcd. The WAL, snapshots and database
+	// are never removed.
+	RecoverStaleFiles bool `json:"recoverStaleFiles"`
+}
+
+type ControllerManagerConfig struct {
+	// ExtraArgs are passed to kube-controller-manager in addition to the
+	// arguments managed by MicroShift, which take precedence.
+	ExtraArgs map[string][]string `json:"extraArgs,omitempty"`
+
+	LeaderElection LeaderElectionConfig `json:"leaderElection"`
+
+	// ClusterSigningDuration is the lifetime of the kubelet client and serving
+	// certificates the controller-manager signs. If empty, they are valid until
+	// the signing CA expires.
+	ClusterSigningDuration string `json:"clusterSigningDuration"`
+
+	// TokenCleaner runs the controller deleting expired bootstrap tokens, which
+	// requires apiServer.bootstrapTokenAuth.
+	TokenCleaner bool `json:"tokenCleaner"`
+}
+
+type SchedulerConfig struct {
+	// ExtraArgs are passed to kube-scheduler in addition to the arguments
+	// managed by MicroShift, which take precedence.
+	ExtraArgs map[string][]string `json:"extraArgs,omitempty"`
+
+	LeaderElection LeaderElectionConfig `json:"leaderElection"`
+
+	// Preemption lets kube-scheduler evict pods of a lower priority to schedule
+	// pending pods of a higher priority.
+	Preemption bool `json:"preemption"`
+	// PriorityClasses are created at boot, e.g. to give workloads a default
+	// priority below the system components.
+	PriorityClasses []PriorityClass `json:"priorityClasses,omitempty"`
+}
+
+type PriorityClass struct {
+	Name  string `json:"name"`
+	Value int32  `json:"value"`
+	// GlobalDefault makes the class the priority of pods without a priorityClassName.
+	// At most one class may be the global default.
+	GlobalDefault bool `json:"globalDefault"`
+	// PreemptionPolicy is PreemptLowerPriority or Never, which keeps pods of the
+	// class from preempting others. Defaults to PreemptLowerPriority.
+	PreemptionPolicy string `json:"preemptionPolicy,omitempty"`
+	Description      string `json:"description,omitempty"`
+}
+
+// LeaderElectionConfig configures the leader election of a control plane component.
+// A single node has no other instance to fail over to, so it is disabled by default
+// to save the latency and etcd writes of renewing the lease.
+type LeaderElectionConfig struct {
+	// Enabled makes the component acquire a lease before it starts working
+	Enabled bool `json:"enabled"`
+	// LeaseDuration is the duration other candidates wait for before taking over
+	// a lease that wasn't renewed
+	LeaseDuration string `json:"leaseDuration"`
+	// RenewDeadline is the duration the leader retries renewing the lease for
+	// before it gives up leadership, shorter than LeaseDuration
+	RenewDeadline string `json:"renewDeadline"`
+	// RetryPeriod is the duration between two attempts to acquire or renew the
+	// lease, shorter than RenewDeadline
+	RetryPeriod string `json:"retryPeriod"`
+}
+
+type NodeConfig struct {
+	// HostnameOverride is the name the node registers with instead of the
+	// hostname. It is also announced via mDNS and included in the certificates.
+	HostnameOverride string `json:"hostnameOverride"`
+	// NodeIPInterface is the network interface the node IP is selected from, e.g.
+	// the management interface of a host with several. NodeIP is kept if it is
+	// one of the addresses of the interface.
+	NodeIPInterface string `json:"nodeIPInterface"`
+
+	// ExtraArgs are passed to the kubelet in addition to the arguments
+	// managed by MicroShift, which take precedence.
+	ExtraArgs map[string][]string `json:"extraArgs,omitempty"`
+
+	// PrePullImages are pulled by the container runtime once the kubelet is
+	// ready, so that workloads don't stall on first boot.
+	PrePullImages []string `json:"prePullImages,omitempty"`
+	// WaitForPrePull delays MicroShift readiness until PrePullImages are pulled.
+	WaitForPrePull bool `json:"waitForPrePull"`
+	// WaitForNodeReady delays the kubelet's readiness until its Node is Ready in
+	// the apiserver, instead of only until the kubelet is healthy.
+	WaitForNodeReady bool `json:"waitForNodeReady"`
+
+	// ShutdownGracePeriod is the total time the node delays shutdown by to
+	// terminate pods, e.g. "30s". Graceful node shutdown is disabled if empty.
+	ShutdownGracePeriod string `json:"shutdownGracePeriod"`
+	// ShutdownGracePeriodCriticalPods is the part of ShutdownGracePeriod that is
+	// reserved for terminating critical pods.
+	ShutdownGracePeriodCriticalPods string `json:"shutdownGracePeriodCriticalPods"`
+
+	// NodeStatusUpdateFrequency is how often the kubelet computes the status of the
+	// node, e.g. "10s". NodeStatusReportFrequency is how often it posts the status
+	// when it didn't change, e.g. "5m". The kubelet's defaults are used if empty.
+	NodeStatusUpdateFrequency string `json:"nodeStatusUpdateFrequency"`
+	NodeStatusReportFrequency string `json:"nodeStatusReportFrequency"`
+
+	// MaxPods is the number of pods the kubelet runs at most
+	MaxPods int `json:"maxPods"`
+
+	// ContainerLogMaxSize is the size at which the kubelet rotates a container's
+	// log, e.g. "10Mi"
+	ContainerLogMaxSize string `json:"containerLogMaxSize"`
+	// ContainerLogMaxFiles is the number of log files kept per container,
+	// including the one being written
+	ContainerLogMaxFiles int `json:"containerLogMaxFiles"`
+
+	// ServingCertSANs are host names and IP addresses included in the kubelet's
+	// serving certificate in addition to the node name and IP.
+	ServingCertSANs []string `json:"servingCertSANs,omitempty"`
+	// RotateServerCertificates makes the kubelet request its serving certificate
+	// from the control plane, which approves requests for the node's addresses.
+	RotateServerCertificates bool `json:"rotateServerCertificates"`
+
+	// CPUManagerPolicy is the kubelet's CPU manager policy, static pins the
+	// containers of guaranteed pods requesting whole CPUs to exclusive CPUs.
+	CPUManagerPolicy string `json:"cpuManagerPolicy"`
+	// TopologyManagerPolicy is the kubelet's policy for aligning the CPUs and
+	// devices of containers to NUMA nodes.
+	TopologyManagerPolicy string `json:"topologyManagerPolicy"`
+
+	// SwapBehavior is how workloads may use the host's swap, off to not use it,
+	// LimitedSwap to limit their swap usage to their memory limit or UnlimitedSwap
+	// to use as much as they need.
+	SwapBehavior string `json:"swapBehavior"`
+
+	// ManageSysctls sets the kernel parameters the node requires and Sysctls
+	// when the node starts.
+	ManageSysctls bool `json:"manageSysctls"`
+	// Sysctls are kernel parameters set in addition to the required ones, e.g.
+	// "net.ipv4.ip_local_port_range": "32768 60999".
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+}
+
+// ResourceRef identifies a workload applied from the manifests.
+type ResourceRef struct {
+	// Kind is the kind of the workload, one of Deployment, DaemonSet or StatefulSet
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (r ResourceRef) String() string {
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+type ManifestsConfig struct {
+	// Enabled applies the manifests from the manifests directories on start
+	Enabled bool `json:"enabled"`
+	// WaitForReady lists workloads applied from the manifests that must become
+	// ready before MicroShift reports itself ready.
+	WaitForReady []ResourceRef `json:"waitForReady,omitempty"`
+	// WorkDir is the writable directory kustomize writes to while rendering,
+	// so that the manifests directories may be read-only. Defaults to a
+	// directory in the data directory.
+	WorkDir string `json:"workDir"`
+	// ApplyRetries is the number of times applying the manifests on start is
+	// retried, e.g. while the apiserver isn't fully serving yet.
+	ApplyRetries int `json:"applyRetries"`
+	// ApplyBackoff is the delay before the first retry, doubled for each of the
+	// following retries up to a minute.
+	ApplyBackoff string `json:"applyBackoff"`
+	// ApplyMode is how the manifests are applied, either with server-side apply
+	// or with client-side apply like a plain "kubectl apply".
+	ApplyMode string `json:"applyMode"`
+	// ForceConflicts makes server-side apply take ownership of fields other
+	// managers, e.g. users editing the objects, have set. Otherwise applying
+	// objects with conflicting fields fails.
+	ForceConflicts bool `json:"forceConflicts"`
+	// Selector is a label selector, e.g. "site=edge-1,!canary", the rendered
+	// resources must match to be applied. All resources are applied if empty.
+	Selector string `json:"selector"`
+	// Include are glob patterns, e.g. "busybox-*", one of which the names of the
+	// applied resources must match if set. Exclude are patterns of names of
+	// resources that are not applied, even if they are included.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+type LoggingConfig struct {
+	// UTCTimestamps switches log timestamps to RFC3339 in UTC, independent
+	// of the host timezone.
+	UTCTimestamps bool `json:"utcTimestamps"`
+
+	// File MicroShift logs to instead of stderr, rotated once it reaches
+	// MaxSizeMB. Errors are logged to stderr as well.
+	File string `json:"file"`
+	// MaxSizeMB is the size in megabytes at which the log file is rotated
+	MaxSizeMB int `json:"maxSizeMB"`
+	// MaxBackups is the number of rotated log files to keep, 0 keeps all
+	MaxBackups int `json:"maxBackups"`
+	// MaxAgeDays is the number of days to keep rotated log files, 0 keeps them
+	// regardless of their age.
+	MaxAgeDays int `json:"maxAgeDays"`
+}
+
+type MDNSConfig struct {
+	// Enabled announces the node and routes via mDNS
+	Enabled bool `json:"enabled"`
+	// Hostname is announced via mDNS instead of the node name, e.g. to provide
+	// a stable alias. Single-label names are announced in the .local domain.
+	Hostname string `json:"hostname"`
+}
+
+type MetricsConfig struct {
+	// Enabled serves etcd's metrics on 127.0.0.1:2381
+	Enabled bool `json:"enabled"`
+}
+
+type ClockConfig struct {
+	// JumpThreshold is the difference between the wall clock and the monotonic
+	// clock above which a change of the system time is reported, e.g. "1m"
+	JumpThreshold string `json:"jumpThreshold"`
+	// CheckCertificates reports the certificates that aren't valid at the new
+	// time after a jump was detected
+	CheckCertificates bool `json:"checkCertificates"`
+}
+
+type ComponentsConfig struct {
+	// Disabled lists the infrastructure components MicroShift doesn't deploy and
+	// the OpenShift-specific services it doesn't run
+	Disabled []string `json:"disabled,omitempty"`
+}
+
+type DefaultResourceQuotaConfig struct {
+	// Hard maps the names of resources to the total the pods of a namespace may
+	// request or use, e.g. requests.cpu: "2" or pods: "20".
+	Hard map[string]string `json:"hard,omitempty"`
+	// File is the path of a ResourceQuota manifest whose spec is used instead.
+	File string `json:"file"`
+}
+
+type DefaultLimitRangeConfig struct {
+	// Default and DefaultRequest map the names of resources to the limit and the
+	// request of containers that don't set one, Max to the highest limit they may
+	// set, e.g. cpu: 500m.
+	Default        map[string]string `json:"default,omitempty"`
+	DefaultRequest map[string]string `json:"defaultRequest,omitempty"`
+	Max            map[string]string `json:"max,omitempty"`
+	// File is the path of a LimitRange manifest whose spec is used instead.
+	File string `json:"file"`
+}
+
+type NamespacesConfig struct {
+	// Labels and Annotations are added to the namespaces MicroShift creates for its
+	// components, e.g. Pod Security levels for policy engines. The ones set by
+	// MicroShift take precedence.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ImagesConfig struct {
+	// Overrides maps the names of component images, e.g. coredns, to the image
+	// references used instead of the ones embedded in MicroShift, e.g. to pull
+	// them from a local registry.
+	Overrides map[string]string `json:"overrides,omitempty"`
+	// OverridesFile is the path to a YAML file with more overrides in the same
+	// format. Overrides in the config take precedence.
+	OverridesFile string `json:"overridesFile"`
+}
+
+type StorageConfig struct {
+	// ClassName is the name of the storage class provisioning volumes with the
+	// ODF-LVM CSI plugin
+	ClassName string `json:"className"`
+	// DefaultClass annotates the storage class as the cluster's default, used by
+	// claims that don't name a storage class
+	DefaultClass bool `json:"defaultClass"`
 }
 
 type IngressConfig struct {
+	// CertFile and KeyFile are an externally managed serving certificate for
+	// the router, used instead of the generated one. The router is updated
+	// when the files change.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+
 	ServingCertificate []byte
 	ServingKey         []byte
 }
 
+// Hook is a command run at a phase of MicroShift's lifecycle.
+type Hook struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Timeout after which the command is killed, e.g. "30s". Defaults to one minute.
+	Timeout string `json:"timeout"`
+}
+
+type HooksConfig struct {
+	// PreStart hooks run in order before any service starts. A failing hook aborts
+	// the startup.
+	PreStart []Hook `json:"preStart,omitempty"`
+	// PostStop hooks run in order after all services stopped.
+	PostStop []Hook `json:"postStop,omitempty"`
+}
+
+type ServicesConfig struct {
+	// Optional lists services that don't stop MicroShift when they fail, in
+	// addition to the ones that are optional by default.
+	Optional []string `json:"optional,omitempty"`
+	// Critical lists services that stop MicroShift when they fail, even if they
+	// are optional by default.
+	Critical []string `json:"critical,omitempty"`
+}
+
+// defaultOptionalServices are the services MicroShift can do without, e.g. because
+// they only provide conveniences.
+var defaultOptionalServices = []string{
+	"microshift-mdns-controller",
+	"kustomizer",
+	"ingress-certificate-watcher",
+	"image-prepuller",
+}
+
 type MicroshiftConfig struct {
-	LogVLevel int `json:"logVLevel"`
+	APIVersion string `json:"apiVersion" ignored:"true"`
+	Kind       string `json:"kind" ignored:"true"`
+
+	LogVLevel int           `json:"logVLevel"`
+	Logging   LoggingConfig `json:"logging"`
+
+	Profile     string   `json:"profile"`
+	Roles       []string `json:"roles"`
+	DataDir     string   `json:"dataDir"`
+	AuditLogDir string   `json:"auditLogDir"`
+	// RoleDataDirs keeps the state of each role's services in a sub-directory of
+	// DataDir named after the role, instead of directly in DataDir.
+	RoleDataDirs bool `json:"roleDataDirs"`
 
 	NodeName string `json:"nodeName"`
 	NodeIP   string `json:"nodeIP"`
 
 	Cluster ClusterConfig `json:"cluster"`
 
+	Etcd              EtcdConfig              `json:"etcd"`
+	APIServer         APIServerConfig         `json:"apiServer"`
+	ControllerManager ControllerManagerConfig `json:"controllerManager"`
+	Scheduler         SchedulerConfig         `json:"scheduler"`
+	Node              NodeConfig              `json:"node"`
+
+	Manifests ManifestsConfig `json:"manifests"`
+
+	Components ComponentsConfig `json:"components"`
+
+	Namespaces NamespacesConfig `json:"namespaces"`
+
+	Storage StorageConfig `json:"storage"`
+
+	MDNS MDNSConfig `json:"mdns"`
+
+	Metrics MetricsConfig `json:"metrics"`
+
+	Clock ClockConfig `json:"clock"`
+
+	Images ImagesConfig `json:"images"`
+
 	Ingress IngressConfig `json:"ingress"`
+
+	Hooks HooksConfig `json:"hooks"`
+
+	Services ServicesConfig `json:"services"`
+
+	// AdditionalTrustBundle is the path to a PEM bundle of CA certificates to
+	// trust in addition to the system trust store when MicroShift components
+	// connect to TLS endpoints.
+	AdditionalTrustBundle string `json:"additionalTrustBundle"`
+
+	// unsubstituted is the config before its references were substituted
+	unsubstituted *MicroshiftConfig
 }
 
 func GetConfigFile() string {
 	return configFile
 }
 
-func GetDataDir() string {
-	return dataDir
-}
-
 func GetManifestsDir() []string {
 	return manifestsDir
 }
@@ -81,6 +763,7 @@ type KubeConfigID string
 
 const (
 	KubeAdmin             KubeConfigID = "kubeadmin"
+	KubeAdminExternal     KubeConfigID = "kubeadmin-external"
 	KubeControllerManager KubeConfigID = "kube-controller-manager"
 	KubeScheduler         KubeConfigID = "kube-scheduler"
 	Kubelet               KubeConfigID = "kubelet"
@@ -88,7 +771,125 @@ const (
 
 // KubeConfigPath returns the path to the specified kubeconfig file.
 func (cfg *MicroshiftConfig) KubeConfigPath(id KubeConfigID) string {
-	return filepath.Join(dataDir, "resources", string(id), "kubeconfig")
+	return filepath.Join(cfg.DataDir, "resources", string(id), "kubeconfig")
+}
+
+// ManifestsWorkDir returns the directory the manifests are rendered in.
+func (cfg *MicroshiftConfig) ManifestsWorkDir() string {
+	if cfg.Manifests.WorkDir != "" {
+		return cfg.Manifests.WorkDir
+	}
+	return filepath.Join(cfg.RoleDataDir(ControlPlaneRole), "manifests-work")
+}
+
+// RoleDataDir returns the directory holding the state of the given role's services.
+// Certificates and kubeconfigs are shared by the roles and always live in DataDir.
+func (cfg *MicroshiftConfig) RoleDataDir(role string) string {
+	if cfg.RoleDataDirs {
+		return filepath.Join(cfg.DataDir, role)
+	}
+	return cfg.DataDir
+}
+
+// ServiceAccountKeyDir returns the directory of the key pair that signs and verifies
+// service account tokens.
+func (cfg *MicroshiftConfig) ServiceAccountKeyDir() string {
+	return filepath.Join(cfg.RoleDataDir(ControlPlaneRole), "resources", "kube-apiserver", "secrets", "service-account-key")
+}
+
+// ServiceAccountSigningKeyPath returns the private key signing service account tokens,
+// the configured one or the one generated during init.
+func (cfg *MicroshiftConfig) ServiceAccountSigningKeyPath() string {
+	if cfg.APIServer.ServiceAccountSigningKeyFile != "" {
+		return cfg.APIServer.ServiceAccountSigningKeyFile
+	}
+	return filepath.Join(cfg.ServiceAccountKeyDir(), "service-account.key")
+}
+
+// ServiceAccountPublicKeyPath returns the key verifying service account tokens. The
+// apiserver reads the public key from a configured private key.
+func (cfg *MicroshiftConfig) ServiceAccountPublicKeyPath() string {
+	if cfg.APIServer.ServiceAccountSigningKeyFile != "" {
+		return cfg.APIServer.ServiceAccountSigningKeyFile
+	}
+	return filepath.Join(cfg.ServiceAccountKeyDir(), "service-account.crt")
+}
+
+// ServiceAccountPreviousIssuerPath returns the file recording the issuer of service
+// account tokens before it was changed.
+func (cfg *MicroshiftConfig) ServiceAccountPreviousIssuerPath() string {
+	return filepath.Join(cfg.ServiceAccountKeyDir(), "previous-issuer")
+}
+
+// ServiceAccountPreviousPublicKeyPath returns the key that verified service account
+// tokens before the signing key was changed.
+func (cfg *MicroshiftConfig) ServiceAccountPreviousPublicKeyPath() string {
+	return filepath.Join(cfg.ServiceAccountKeyDir(), "service-account-previous.pub")
+}
+
+// KubeletNodeName returns the name the node registers with, the hostname override if
+// one is set.
+func (cfg *MicroshiftConfig) KubeletNodeName() string {
+	if cfg.Node.HostnameOverride != "" {
+		return cfg.Node.HostnameOverride
+	}
+	return cfg.NodeName
+}
+
+// IngressDomain returns the base domain of the default router, the configured one or
+// the one derived from the node name.
+func (cfg *MicroshiftConfig) IngressDomain() string {
+	if cfg.Cluster.IngressDomain != "" {
+		return cfg.Cluster.IngressDomain
+	}
+	return "apps." + cfg.KubeletNodeName()
+}
+
+// ClusterCIDRs returns the pod CIDRs with the one of the primary family first.
+func (cfg *MicroshiftConfig) ClusterCIDRs() []string {
+	return orderCIDRs(cfg.Cluster.ClusterCIDR, cfg.Cluster.PrimaryIPFamily)
+}
+
+// ServiceCIDRs returns the service CIDRs with the one of the primary family first.
+func (cfg *MicroshiftConfig) ServiceCIDRs() []string {
+	return orderCIDRs(cfg.Cluster.ServiceCIDR, cfg.Cluster.PrimaryIPFamily)
+}
+
+// orderCIDRs splits the comma-separated CIDRs and moves the one of the primary family,
+// if any, to the front.
+func orderCIDRs(cidrs, primary string) []string {
+	ordered := []string{}
+	for _, cidr := range strings.Split(cidrs, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if primary != "" && cidrFamily(cidr) == primary {
+			ordered = append([]string{cidr}, ordered...)
+		} else {
+			ordered = append(ordered, cidr)
+		}
+	}
+	return ordered
+}
+
+// cidrFamily returns the IP family of the CIDR, or "" if it is malformed.
+func cidrFamily(cidr string) string {
+	ip, _, err := net.ParseCIDR(cidr)
+	switch {
+	case err != nil:
+		return ""
+	case ip.To4() != nil:
+		return IPFamilyIPv4
+	default:
+		return IPFamilyIPv6
+	}
+}
+
+// ControlSocketPath returns the path to the unix socket on which a running instance
+// reports the state of its services.
+func (cfg *MicroshiftConfig) ControlSocketPath() string {
+	return filepath.Join(cfg.DataDir, "microshift.sock")
 }
 
 func NewMicroshiftConfig() *MicroshiftConfig {
@@ -102,9 +903,18 @@ func NewMicroshiftConfig() *MicroshiftConfig {
 	}
 
 	return &MicroshiftConfig{
-		LogVLevel: 0,
-		NodeName:  nodeName,
-		NodeIP:    nodeIP,
+		APIVersion:  CurrentAPIVersion,
+		Kind:        Kind,
+		LogVLevel:   0,
+		Roles:       []string{ControlPlaneRole, NodeRole},
+		DataDir:     findDataDir(),
+		AuditLogDir: defaultAuditLogDir,
+		NodeName:    nodeName,
+		NodeIP:      nodeIP,
+		Logging: LoggingConfig{
+			MaxSizeMB:  defaultLogMaxSizeMB,
+			MaxBackups: defaultLogMaxBackups,
+		},
 		Cluster: ClusterConfig{
 			URL:                  "https://127.0.0.1:6443",
 			ClusterCIDR:          "10.42.0.0/16",
@@ -113,8 +923,95 @@ func NewMicroshiftConfig() *MicroshiftConfig {
 			DNS:                  "10.43.0.10",
 			Domain:               "cluster.local",
 			MTU:                  "1400",
+			DefaultNetworkPolicy: DefaultNetworkPolicyNone,
+			IPFamilyPolicy:       IPFamilyPolicySingleStack,
+		},
+		Etcd: EtcdConfig{
+			TLSMinVersion: TLSVersion12,
+		},
+		APIServer: APIServerConfig{
+			AuditLogFormat:          AuditLogFormatJSON,
+			AuditLogMaxTotalSizeMB:  defaultAuditLogMaxTotalSizeMB,
+			StorageMediaType:        StorageMediaTypeProtobuf,
+			WatchCache:              true,
+			DeleteCollectionWorkers: defaultDeleteCollectionWorkers,
+			AggregatorRouting:       true,
+			AuthorizationModes:      []string{AuthorizationModeScope, AuthorizationModeSystemMasters, AuthorizationModeRBAC, AuthorizationModeNode},
+			ServiceAccountIssuer:    defaultServiceAccountIssuer,
+			EventTTL:                defaultEventTTL,
+			ShutdownDelayDuration:   defaultShutdownDelayDuration,
+			ShutdownSendRetryAfter:  true,
+			Watchdog: WatchdogConfig{
+				Interval:         "10s",
+				FailureThreshold: "2m",
+				Action:           WatchdogActionExit,
+			},
+		},
+		ControllerManager: ControllerManagerConfig{
+			LeaderElection: defaultLeaderElection(),
+		},
+		Scheduler: SchedulerConfig{
+			LeaderElection: defaultLeaderElection(),
+			Preemption:     true,
+		},
+		Node: NodeConfig{
+			MaxPods:               defaultMaxPods,
+			ContainerLogMaxSize:   defaultContainerLogMaxSize,
+			ContainerLogMaxFiles:  defaultContainerLogMaxFiles,
+			CPUManagerPolicy:      CPUManagerPolicyNone,
+			TopologyManagerPolicy: TopologyManagerPolicyNone,
+			SwapBehavior:          SwapBehaviorOff,
+			ManageSysctls:         true,
+		},
+		Manifests: ManifestsConfig{
+			Enabled:      true,
+			ApplyRetries: defaultManifestsApplyRetries,
+			ApplyBackoff: defaultManifestsApplyBackoff,
+			ApplyMode:    ApplyModeClient,
 		},
+		Storage: StorageConfig{
+			ClassName:    defaultStorageClassName,
+			DefaultClass: true,
+		},
+		MDNS: MDNSConfig{
+			Enabled: true,
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		Clock: ClockConfig{
+			JumpThreshold:     "1m",
+			CheckCertificates: true,
+		},
+	}
+}
+
+// defaultLeaderElection returns the upstream lease durations, with leader election
+// disabled.
+func defaultLeaderElection() LeaderElectionConfig {
+	return LeaderElectionConfig{
+		LeaseDuration: "15s",
+		RenewDeadline: "10s",
+		RetryPeriod:   "2s",
+	}
+}
+
+// ExternalHostname returns the host of the external URL, or an empty string if none
+// is configured.
+func (c *APIServerConfig) ExternalHostname() string {
+	parsed, err := url.Parse(c.ExternalURL)
+	if err != nil {
+		return ""
 	}
+	return parsed.Hostname()
+}
+
+// HasDefaultResources returns whether a ResourceQuota or LimitRange is configured to
+// be created in new namespaces.
+func (c *ClusterConfig) HasDefaultResources() bool {
+	quota, limits := c.DefaultResourceQuota, c.DefaultLimitRange
+	return len(quota.Hard) > 0 || quota.File != "" ||
+		len(limits.Default) > 0 || len(limits.DefaultRequest) > 0 || len(limits.Max) > 0 || limits.File != ""
 }
 
 // extract the api server port from the cluster URL
@@ -186,11 +1083,28 @@ func StringInList(s string, list []string) bool {
 // Note: add a configFile parameter here because of unit test requiring custom
 // local directory
 func (c *MicroshiftConfig) ReadFromConfigFile(configFile string) error {
+	contents, err := readConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	return c.decodeConfigFile(configFile, contents)
+}
+
+// readConfigFile reads a config file and converts it to CurrentAPIVersion.
+func readConfigFile(configFile string) ([]byte, error) {
 	contents, err := os.ReadFile(configFile)
 	if err != nil {
-		return fmt.Errorf("reading config file %s: %v", configFile, err)
+		return nil, fmt.Errorf("reading config file %s: %v", configFile, err)
+	}
+
+	contents, err = convertToCurrentVersion(contents)
+	if err != nil {
+		return nil, fmt.Errorf("decoding config file %s: %v", configFile, err)
 	}
+	return contents, nil
+}
 
+func (c *MicroshiftConfig) decodeConfigFile(configFile string, contents []byte) error {
 	if err := yaml.Unmarshal(contents, c); err != nil {
 		return fmt.Errorf("decoding config file %s: %v", configFile, err)
 	}
@@ -209,6 +1123,21 @@ func (c *MicroshiftConfig) ReadFromCmdLine(flags *pflag.FlagSet) error {
 	if f := flags.Lookup("v"); f != nil && flags.Changed("v") {
 		c.LogVLevel, _ = strconv.Atoi(f.Value.String())
 	}
+	if s, err := flags.GetString("profile"); err == nil && flags.Changed("profile") {
+		c.Profile = s
+	}
+	if s, err := flags.GetStringSlice("roles"); err == nil && flags.Changed("roles") {
+		c.Roles = s
+	}
+	if s, err := flags.GetStringSlice("disabled-components"); err == nil && flags.Changed("disabled-components") {
+		c.Components.Disabled = s
+	}
+	if s, err := flags.GetString("data-dir"); err == nil && flags.Changed("data-dir") {
+		c.DataDir = s
+	}
+	if s, err := flags.GetString("audit-log-dir"); err == nil && flags.Changed("audit-log-dir") {
+		c.AuditLogDir = s
+	}
 	if s, err := flags.GetString("node-name"); err == nil && flags.Changed("node-name") {
 		c.NodeName = s
 	}
@@ -236,6 +1165,18 @@ func (c *MicroshiftConfig) ReadFromCmdLine(flags *pflag.FlagSet) error {
 	if s, err := flags.GetString("cluster-mtu"); err == nil && flags.Changed("cluster-mtu") {
 		c.Cluster.MTU = s
 	}
+	if b, err := flags.GetBool("disable-mdns"); err == nil && flags.Changed("disable-mdns") {
+		c.MDNS.Enabled = !b
+	}
+	if b, err := flags.GetBool("disable-manifests"); err == nil && flags.Changed("disable-manifests") {
+		c.Manifests.Enabled = !b
+	}
+	if b, err := flags.GetBool("no-sysctl"); err == nil && flags.Changed("no-sysctl") {
+		c.Node.ManageSysctls = !b
+	}
+	if b, err := flags.GetBool("disable-metrics"); err == nil && flags.Changed("disable-metrics") {
+		c.Metrics.Enabled = !b
+	}
 
 	return nil
 }
@@ -246,7 +1187,23 @@ func (c *MicroshiftConfig) ReadAndValidate(configFile string, flags *pflag.FlagS
 	if configFile == "" {
 		configFile = findConfigFile()
 	}
-	if err := c.ReadFromConfigFile(configFile); err != nil {
+	// the file is converted only once, so that conversion warnings are logged once
+	contents, err := readConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	// the profile's defaults are overridden by any explicitly configured value, so
+	// they need to be applied before reading the configuration
+	profile, err := readProfile(configFile, contents, flags)
+	if err != nil {
+		return err
+	}
+	if profile != "" {
+		if err := c.applyProfile(profile); err != nil {
+			return err
+		}
+	}
+	if err := c.decodeConfigFile(configFile, contents); err != nil {
 		return err
 	}
 	if err := c.ReadFromEnv(); err != nil {
@@ -255,10 +1212,947 @@ func (c *MicroshiftConfig) ReadAndValidate(configFile string, flags *pflag.FlagS
 	if err := c.ReadFromCmdLine(flags); err != nil {
 		return err
 	}
+	if c.unsubstituted, err = c.deepCopy(); err != nil {
+		return err
+	}
+	if err := c.substituteReferences(); err != nil {
+		return err
+	}
+	if err := c.resolveNodeIP(); err != nil {
+		return err
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
 
+// resolveNodeIP selects the node IP from the addresses of node.nodeIPInterface, before
+// the node IP is included in the certificates and the no-proxy entries.
+func (c *MicroshiftConfig) resolveNodeIP() error {
+	if c.Node.NodeIPInterface == "" {
+		return nil
+	}
+	ip, err := getInterfaceIP(c.Node.NodeIPInterface, c.NodeIP)
+	if err != nil {
+		return fmt.Errorf("invalid node.nodeIPInterface %q: %w", c.Node.NodeIPInterface, err)
+	}
+	c.NodeIP = ip
 	return nil
 }
 
+func (c *MicroshiftConfig) validate() error {
+	if _, ok := profiles[c.Profile]; c.Profile != "" && !ok {
+		return fmt.Errorf("unknown profile %q, valid profiles are %v", c.Profile, ProfileNames())
+	}
+	if len(c.Roles) == 0 {
+		return fmt.Errorf("at least one role must be specified, valid roles are %v", validRoles)
+	}
+	for _, role := range c.Roles {
+		if !StringInList(role, validRoles) {
+			return fmt.Errorf("unknown role %q, valid roles are %v", role, validRoles)
+		}
+	}
+	if c.DataDir == "" {
+		return fmt.Errorf("data directory must not be empty")
+	}
+	if c.AuditLogDir == "" {
+		return fmt.Errorf("audit log directory must not be empty")
+	}
+	if err := validateLogging(c.Logging); err != nil {
+		return err
+	}
+	if err := validateDisabledComponents(c.Components.Disabled); err != nil {
+		return err
+	}
+	// mDNS announces the hosts of routes
+	if c.MDNS.Enabled && !c.ComponentEnabled(ComponentOpenShiftCRDs) {
+		return fmt.Errorf("mdns.enabled requires component %q, which is disabled", ComponentOpenShiftCRDs)
+	}
+	if errs := validation.IsDNS1123Subdomain(c.Storage.ClassName); len(errs) > 0 {
+		return fmt.Errorf("invalid storage.className %q: %s", c.Storage.ClassName, strings.Join(errs, ", "))
+	}
+	if (c.Ingress.CertFile == "") != (c.Ingress.KeyFile == "") {
+		return fmt.Errorf("ingress.certFile and ingress.keyFile must be set together")
+	}
+	if !StringInList(c.Cluster.DefaultNetworkPolicy, validDefaultNetworkPolicies) {
+		return fmt.Errorf("invalid cluster.defaultNetworkPolicy %q, valid policies are %v", c.Cluster.DefaultNetworkPolicy, validDefaultNetworkPolicies)
+	}
+	if _, err := c.DefaultResourceQuota(); err != nil {
+		return err
+	}
+	if _, err := c.DefaultLimitRange(); err != nil {
+		return err
+	}
+	if c.Cluster.IngressDomain != "" {
+		if errs := validation.IsDNS1123Subdomain(c.Cluster.IngressDomain); len(errs) > 0 {
+			return fmt.Errorf("invalid cluster.ingressDomain %q: %s", c.Cluster.IngressDomain, strings.Join(errs, ", "))
+		}
+	}
+	if err := validateIPFamilies(c.Cluster); err != nil {
+		return err
+	}
+	if err := validateDNSForwarders(c.Cluster.DNSForwarders); err != nil {
+		return err
+	}
+	if err := validateNamespaceMetadata(c.Namespaces); err != nil {
+		return err
+	}
+	if c.MDNS.Hostname != "" {
+		if err := validateMDNSHostname(c.MDNS.Hostname); err != nil {
+			return err
+		}
+	}
+	if err := validateEtcdTLS(c.Etcd.TLSMinVersion, c.Etcd.TLSCipherSuites); err != nil {
+		return err
+	}
+	if c.APIServer.ExternalURL != "" {
+		if err := validateExternalURL(c.APIServer.ExternalURL); err != nil {
+			return err
+		}
+	}
+	for _, sniCert := range c.APIServer.SNICerts {
+		if err := validateSNICert(sniCert); err != nil {
+			return err
+		}
+	}
+	if ttl, err := time.ParseDuration(c.APIServer.EventTTL); err != nil || ttl <= 0 {
+		return fmt.Errorf("invalid apiServer.eventTTL %q, must be a positive duration", c.APIServer.EventTTL)
+	}
+	if delay, err := time.ParseDuration(c.APIServer.ShutdownDelayDuration); err != nil || delay < 0 {
+		return fmt.Errorf("invalid apiServer.shutdownDelayDuration %q, must be a non-negative duration", c.APIServer.ShutdownDelayDuration)
+	}
+	if c.APIServer.Watchdog.Enabled {
+		if err := validateWatchdog(c.APIServer.Watchdog); err != nil {
+			return err
+		}
+	}
+	if c.APIServer.Konnectivity.Enabled {
+		if err := c.validateKonnectivity(); err != nil {
+			return err
+		}
+	}
+	if err := c.validateDisabledWatchCaches(); err != nil {
+		return err
+	}
+	if c.APIServer.DeleteCollectionWorkers < 0 {
+		return fmt.Errorf("invalid apiServer.deleteCollectionWorkers %d, must not be negative", c.APIServer.DeleteCollectionWorkers)
+	}
+	if err := c.validateAuthorization(); err != nil {
+		return err
+	}
+	if c.APIServer.EgressSelectorConfigFile != "" {
+		if err := c.validateEgressSelectorConfigFile(); err != nil {
+			return err
+		}
+	}
+	if c.ControllerManager.LeaderElection.Enabled {
+		if err := validateLeaderElection("controllerManager", c.ControllerManager.LeaderElection); err != nil {
+			return err
+		}
+	}
+	if c.ControllerManager.TokenCleaner && !c.APIServer.BootstrapTokenAuth {
+		return fmt.Errorf("controllerManager.tokenCleaner requires apiServer.bootstrapTokenAuth")
+	}
+	if c.ControllerManager.ClusterSigningDuration != "" {
+		if d, err := time.ParseDuration(c.ControllerManager.ClusterSigningDuration); err != nil || d < minClusterSigningDuration || d > maxClusterSigningDuration {
+			return fmt.Errorf("invalid controllerManager.clusterSigningDuration %q, must be a duration between %s and %s", c.ControllerManager.ClusterSigningDuration, minClusterSigningDuration, maxClusterSigningDuration)
+		}
+	}
+	if c.Scheduler.LeaderElection.Enabled {
+		if err := validateLeaderElection("scheduler", c.Scheduler.LeaderElection); err != nil {
+			return err
+		}
+	}
+	if err := validatePriorityClasses(c.Scheduler.PriorityClasses); err != nil {
+		return err
+	}
+	if !StringInList(c.APIServer.AuditLogFormat, validAuditLogFormats) {
+		return fmt.Errorf("unknown audit log format %q, valid formats are %v", c.APIServer.AuditLogFormat, validAuditLogFormats)
+	}
+	if c.APIServer.AuditLogMaxTotalSizeMB <= 0 {
+		return fmt.Errorf("invalid apiServer.auditLogMaxTotalSizeMB %d, must be positive", c.APIServer.AuditLogMaxTotalSizeMB)
+	}
+	if c.APIServer.GoawayChance < 0 || c.APIServer.GoawayChance > maxGoawayChance {
+		return fmt.Errorf("invalid apiServer.goawayChance %v, must be between 0 and %v", c.APIServer.GoawayChance, maxGoawayChance)
+	}
+	if err := validateServiceAccountIssuer(c.APIServer.ServiceAccountIssuer); err != nil {
+		return err
+	}
+	if c.APIServer.ServiceAccountSigningKeyFile != "" {
+		if err := validateServiceAccountSigningKey(c.APIServer.ServiceAccountSigningKeyFile); err != nil {
+			return err
+		}
+	}
+	if c.APIServer.Socket != "" && !filepath.IsAbs(c.APIServer.Socket) {
+		return fmt.Errorf("apiServer.socket %q must be an absolute path", c.APIServer.Socket)
+	}
+	if !StringInList(c.APIServer.StorageMediaType, validStorageMedia) {
+		return fmt.Errorf("unknown storage media type %q, valid media types are %v", c.APIServer.StorageMediaType, validStorageMedia)
+	}
+	if err := validateManifestsWorkDir(c.Manifests.WorkDir, manifestsDir); err != nil {
+		return err
+	}
+	if c.Manifests.ApplyRetries < 0 {
+		return fmt.Errorf("manifests.applyRetries must not be negative, got %d", c.Manifests.ApplyRetries)
+	}
+	if backoff, err := time.ParseDuration(c.Manifests.ApplyBackoff); err != nil || backoff <= 0 {
+		return fmt.Errorf("invalid manifests.applyBackoff %q, must be a positive duration", c.Manifests.ApplyBackoff)
+	}
+	if !StringInList(c.Manifests.ApplyMode, validApplyModes) {
+		return fmt.Errorf("invalid manifests.applyMode %q, valid modes are %v", c.Manifests.ApplyMode, validApplyModes)
+	}
+	if _, err := labels.Parse(c.Manifests.Selector); err != nil {
+		return fmt.Errorf("invalid manifests.selector %q: %v", c.Manifests.Selector, err)
+	}
+	for _, pattern := range append(append([]string{}, c.Manifests.Include...), c.Manifests.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid manifests name pattern %q: %v", pattern, err)
+		}
+	}
+	if !c.Manifests.Enabled && len(c.Manifests.WaitForReady) > 0 {
+		return fmt.Errorf("manifests.waitForReady can't be set with the manifests disabled")
+	}
+	for _, ref := range c.Manifests.WaitForReady {
+		if !StringInList(ref.Kind, validWorkloadKinds) {
+			return fmt.Errorf("unsupported kind %q in manifests.waitForReady, supported kinds are %v", ref.Kind, validWorkloadKinds)
+		}
+		if ref.Namespace == "" || ref.Name == "" {
+			return fmt.Errorf("%s in manifests.waitForReady must specify a namespace and a name", ref)
+		}
+	}
+	if c.Node.HostnameOverride != "" {
+		if errs := validation.IsDNS1123Subdomain(c.Node.HostnameOverride); len(errs) > 0 {
+			return fmt.Errorf("invalid node.hostnameOverride %q: %s", c.Node.HostnameOverride, strings.Join(errs, ", "))
+		}
+	}
+	for _, san := range c.Node.ServingCertSANs {
+		if net.ParseIP(san) != nil {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(san); len(errs) > 0 {
+			return fmt.Errorf("invalid entry %q in node.servingCertSANs, must be an IP address or a DNS name: %s", san, strings.Join(errs, ", "))
+		}
+	}
+	if threshold, err := time.ParseDuration(c.Clock.JumpThreshold); err != nil || threshold <= 0 {
+		return fmt.Errorf("invalid clock.jumpThreshold %q, must be a positive duration", c.Clock.JumpThreshold)
+	}
+	if err := validateResourceManagers(c.Node); err != nil {
+		return err
+	}
+	if !StringInList(c.Node.SwapBehavior, validSwapBehaviors) {
+		return fmt.Errorf("unknown node.swapBehavior %q, valid behaviors are %v", c.Node.SwapBehavior, validSwapBehaviors)
+	}
+	// the kubelet only uses swap while not refusing to start with it
+	if _, ok := c.Node.ExtraArgs["fail-swap-on"]; ok && c.Node.SwapBehavior != SwapBehaviorOff {
+		return fmt.Errorf("node.extraArgs fail-swap-on can't be set with node.swapBehavior %s", c.Node.SwapBehavior)
+	}
+	if err := validateSysctls(c.Node.Sysctls); err != nil {
+		return err
+	}
+	if c.Node.MaxPods <= 0 {
+		return fmt.Errorf("node.maxPods must be positive, got %d", c.Node.MaxPods)
+	}
+	if size, err := resource.ParseQuantity(c.Node.ContainerLogMaxSize); err != nil || size.Sign() <= 0 {
+		return fmt.Errorf("invalid node.containerLogMaxSize %q, must be a positive quantity, e.g. 10Mi", c.Node.ContainerLogMaxSize)
+	}
+	// the kubelet needs a file to rotate to
+	if c.Node.ContainerLogMaxFiles < 2 {
+		return fmt.Errorf("node.containerLogMaxFiles must be at least 2, got %d", c.Node.ContainerLogMaxFiles)
+	}
+	if err := validateShutdownGracePeriods(c.Node.ShutdownGracePeriod, c.Node.ShutdownGracePeriodCriticalPods); err != nil {
+		return err
+	}
+	if err := validateNodeStatusFrequencies(c.Node.NodeStatusUpdateFrequency, c.Node.NodeStatusReportFrequency); err != nil {
+		return err
+	}
+	for _, image := range c.Node.PrePullImages {
+		if _, _, _, err := parsers.ParseImageName(image); err != nil {
+			return fmt.Errorf("invalid image %q in node.prePullImages: %v", image, err)
+		}
+	}
+	for _, name := range c.Services.Optional {
+		if StringInList(name, c.Services.Critical) {
+			return fmt.Errorf("service %q can't be both in services.optional and services.critical", name)
+		}
+	}
+	for _, hook := range c.Hooks.PreStart {
+		if err := validateHook("hooks.preStart", hook); err != nil {
+			return err
+		}
+	}
+	for _, hook := range c.Hooks.PostStop {
+		if err := validateHook("hooks.postStop", hook); err != nil {
+			return err
+		}
+	}
+	if _, err := c.ComponentImages(); err != nil {
+		return err
+	}
+	if c.AdditionalTrustBundle != "" {
+		if err := validateTrustBundle(c.AdditionalTrustBundle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateLogging checks the log file path and that the rotation settings are within bounds.
+func validateLogging(logging LoggingConfig) error {
+	if logging.File != "" && !filepath.IsAbs(logging.File) {
+		return fmt.Errorf("logging.file %q must be an absolute path", logging.File)
+	}
+	if logging.MaxSizeMB < 1 || logging.MaxSizeMB > maxLogSizeMB {
+		return fmt.Errorf("logging.maxSizeMB must be between 1 and %d, got %d", maxLogSizeMB, logging.MaxSizeMB)
+	}
+	if logging.MaxBackups < 0 || logging.MaxBackups > maxLogBackups {
+		return fmt.Errorf("logging.maxBackups must be between 0 and %d, got %d", maxLogBackups, logging.MaxBackups)
+	}
+	if logging.MaxAgeDays < 0 || logging.MaxAgeDays > maxLogAgeDays {
+		return fmt.Errorf("logging.maxAgeDays must be between 0 and %d, got %d", maxLogAgeDays, logging.MaxAgeDays)
+	}
+	return nil
+}
+
+// validateDisabledComponents checks that only known components are disabled and that
+// no enabled component depends on a disabled one.
+func validateDisabledComponents(disabled []string) error {
+	for _, name := range disabled {
+		if !StringInList(name, validComponents) {
+			return fmt.Errorf("unknown component %q, valid components are %v", name, validComponents)
+		}
+	}
+	for _, name := range validComponents {
+		if StringInList(name, disabled) {
+			continue
+		}
+		for _, dependency := range componentDependencies[name] {
+			if StringInList(dependency, disabled) {
+				return fmt.Errorf("component %q requires %q, which is disabled", name, dependency)
+			}
+		}
+	}
+	return nil
+}
+
+// validateEtcdTLS checks that etcd supports the TLS version and cipher suites.
+func validateEtcdTLS(minVersion string, cipherSuites []string) error {
+	if !StringInList(minVersion, validTLSMinVersions) {
+		return fmt.Errorf("unsupported etcd.tlsMinVersion %q, supported versions are %v", minVersion, validTLSMinVersions)
+	}
+	if minVersion == TLSVersion13 && len(cipherSuites) > 0 {
+		return fmt.Errorf("etcd.tlsCipherSuites can't be configured for %s", TLSVersion13)
+	}
+	for _, name := range cipherSuites {
+		if _, ok := tlsutil.GetCipherSuite(name); !ok {
+			return fmt.Errorf("unsupported cipher suite %q in etcd.tlsCipherSuites", name)
+		}
+	}
+	return nil
+}
+
+// validateKonnectivity checks the settings the apiserver network proxy integration
+// depends on.
+func (c *MicroshiftConfig) validateKonnectivity() error {
+	if !c.HasRole(ControlPlaneRole) {
+		return fmt.Errorf("apiServer.konnectivity requires the %q role", ControlPlaneRole)
+	}
+	if c.APIServer.Konnectivity.UDSName == "" {
+		return fmt.Errorf("apiServer.konnectivity.udsName must be set when konnectivity is enabled")
+	}
+	if !filepath.IsAbs(c.APIServer.Konnectivity.UDSName) {
+		return fmt.Errorf("apiServer.konnectivity.udsName %q must be an absolute path", c.APIServer.Konnectivity.UDSName)
+	}
+	return nil
+}
+
+// validateEgressSelectorConfigFile checks that the file holds an egress selector
+// configuration the apiserver accepts.
+func (c *MicroshiftConfig) validateEgressSelectorConfigFile() error {
+	path := c.APIServer.EgressSelectorConfigFile
+	if c.APIServer.Konnectivity.Enabled {
+		return fmt.Errorf("apiServer.egressSelectorConfigFile can't be set with apiServer.konnectivity enabled")
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("apiServer.egressSelectorConfigFile %q must be an absolute path", path)
+	}
+	egressSelector, err := egressselector.ReadEgressSelectorConfiguration(path)
+	if err != nil {
+		return fmt.Errorf("invalid apiServer.egressSelectorConfigFile: %v", err)
+	}
+	if errs := egressselector.ValidateEgressSelectorConfiguration(egressSelector); len(errs) > 0 {
+		return fmt.Errorf("invalid apiServer.egressSelectorConfigFile %s: %v", path, errs.ToAggregate())
+	}
+	return nil
+}
+
+// validateAuthorization checks the authorization modes and that the webhook is
+// configured exactly if the Webhook mode is used. MicroShift's own components are
+// authorized by RBAC, so it can't be left out.
+func (c *MicroshiftConfig) validateAuthorization() error {
+	modes := sets.NewString()
+	for _, mode := range c.APIServer.AuthorizationModes {
+		if !StringInList(mode, validAuthorizationModes) {
+			return fmt.Errorf("unknown apiServer.authorizationModes mode %q, valid modes are %v", mode, validAuthorizationModes)
+		}
+		if modes.Has(mode) {
+			return fmt.Errorf("duplicate apiServer.authorizationModes mode %q", mode)
+		}
+		modes.Insert(mode)
+	}
+	if !modes.Has(AuthorizationModeRBAC) {
+		return fmt.Errorf("apiServer.authorizationModes must include %s", AuthorizationModeRBAC)
+	}
+
+	path := c.APIServer.AuthorizationWebhookConfigFile
+	if modes.Has(AuthorizationModeWebhook) != (path != "") {
+		return fmt.Errorf("apiServer.authorizationWebhookConfigFile must be set exactly if apiServer.authorizationModes include %s", AuthorizationModeWebhook)
+	}
+	if path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("apiServer.authorizationWebhookConfigFile %q must be an absolute path", path)
+	}
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid apiServer.authorizationWebhookConfigFile: %v", err)
+	}
+	if err := clientcmd.ConfirmUsable(*kubeconfig, ""); err != nil {
+		return fmt.Errorf("invalid apiServer.authorizationWebhookConfigFile %s: %v", path, err)
+	}
+	return nil
+}
+
+func validateWatchdog(watchdog WatchdogConfig) error {
+	interval, err := time.ParseDuration(watchdog.Interval)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid apiServer.watchdog.interval %q, must be a positive duration", watchdog.Interval)
+	}
+	threshold, err := time.ParseDuration(watchdog.FailureThreshold)
+	if err != nil || threshold < interval {
+		return fmt.Errorf("invalid apiServer.watchdog.failureThreshold %q, must be a duration of at least the interval", watchdog.FailureThreshold)
+	}
+	if !StringInList(watchdog.Action, validWatchdogActions) {
+		return fmt.Errorf("unknown apiServer.watchdog.action %q, valid actions are %v", watchdog.Action, validWatchdogActions)
+	}
+	return nil
+}
+
+func validateLeaderElection(component string, le LeaderElectionConfig) error {
+	lease, err := time.ParseDuration(le.LeaseDuration)
+	if err != nil || lease <= 0 {
+		return fmt.Errorf("invalid %s.leaderElection.leaseDuration %q, must be a positive duration", component, le.LeaseDuration)
+	}
+	renew, err := time.ParseDuration(le.RenewDeadline)
+	if err != nil || renew <= 0 || renew >= lease {
+		return fmt.Errorf("invalid %s.leaderElection.renewDeadline %q, must be a positive duration shorter than the leaseDuration", component, le.RenewDeadline)
+	}
+	retry, err := time.ParseDuration(le.RetryPeriod)
+	if err != nil || retry <= 0 || retry >= renew {
+		return fmt.Errorf("invalid %s.leaderElection.retryPeriod %q, must be a positive duration shorter than the renewDeadline", component, le.RetryPeriod)
+	}
+	return nil
+}
+
+func validateExternalURL(externalURL string) error {
+	parsed, err := url.Parse(externalURL)
+	if err != nil {
+		return fmt.Errorf("invalid apiServer.externalURL %q: %v", externalURL, err)
+	}
+	if parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return fmt.Errorf("invalid apiServer.externalURL %q, must be an https URL with a host", externalURL)
+	}
+	return nil
+}
+
+// validateSNICert checks that the certificate and key files exist and belong together,
+// and that the names are hostnames or IP addresses, optionally with a wildcard prefix.
+func validateSNICert(sniCert SNICert) error {
+	if !filepath.IsAbs(sniCert.CertFile) || !filepath.IsAbs(sniCert.KeyFile) {
+		return fmt.Errorf("apiServer.sniCerts certFile %q and keyFile %q must be absolute paths", sniCert.CertFile, sniCert.KeyFile)
+	}
+	if _, _, err := util.LoadCertKeyPair(sniCert.CertFile, sniCert.KeyFile); err != nil {
+		return fmt.Errorf("invalid apiServer.sniCerts entry: %v", err)
+	}
+	for _, name := range sniCert.Names {
+		if net.ParseIP(name) != nil {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(name, "*.")); len(errs) > 0 {
+			return fmt.Errorf("invalid name %q in apiServer.sniCerts entry %s: %s", name, sniCert.CertFile, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// validateDisabledWatchCaches checks that the resources are given as resource[.group]
+// like the apiserver expects, which otherwise fails to start.
+func (c *MicroshiftConfig) validateDisabledWatchCaches() error {
+	if len(c.APIServer.DisabledWatchCaches) > 0 && !c.APIServer.WatchCache {
+		return fmt.Errorf("apiServer.disabledWatchCaches have no effect with apiServer.watchCache disabled")
+	}
+	for _, resource := range c.APIServer.DisabledWatchCaches {
+		name, group, _ := strings.Cut(resource, ".")
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return fmt.Errorf("invalid apiServer.disabledWatchCaches resource %q, must be resource[.group]: %s", resource, strings.Join(errs, ", "))
+		}
+		if group != "" {
+			if errs := validation.IsDNS1123Subdomain(group); len(errs) > 0 {
+				return fmt.Errorf("invalid apiServer.disabledWatchCaches resource %q, must be resource[.group]: %s", resource, strings.Join(errs, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// validatePriorityClasses checks the classes against the restrictions of the
+// apiserver, which would otherwise only refuse them after MicroShift started.
+func validatePriorityClasses(classes []PriorityClass) error {
+	names := sets.NewString()
+	globalDefault := ""
+	for _, pc := range classes {
+		if errs := validation.IsDNS1123Subdomain(pc.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid scheduler.priorityClasses name %q: %s", pc.Name, strings.Join(errs, ", "))
+		}
+		if strings.HasPrefix(pc.Name, "system-") {
+			return fmt.Errorf("invalid scheduler.priorityClasses name %q, the system- prefix is reserved", pc.Name)
+		}
+		if names.Has(pc.Name) {
+			return fmt.Errorf("scheduler.priorityClasses %q is listed more than once", pc.Name)
+		}
+		names.Insert(pc.Name)
+		if pc.Value > maxPriorityClassValue {
+			return fmt.Errorf("invalid scheduler.priorityClasses %s value %d, must be at most %d", pc.Name, pc.Value, maxPriorityClassValue)
+		}
+		if pc.PreemptionPolicy != "" && !StringInList(pc.PreemptionPolicy, validPreemptionPolicies) {
+			return fmt.Errorf("invalid scheduler.priorityClasses %s preemptionPolicy %q, valid policies are %v", pc.Name, pc.PreemptionPolicy, validPreemptionPolicies)
+		}
+		if pc.GlobalDefault {
+			if globalDefault != "" {
+				return fmt.Errorf("scheduler.priorityClasses %s and %s are both the global default, only one may be", globalDefault, pc.Name)
+			}
+			globalDefault = pc.Name
+		}
+	}
+	return nil
+}
+
+// validateServiceAccountIssuer checks that the issuer is an https URL, as required for
+// publishing its OIDC discovery documents.
+func validateServiceAccountIssuer(issuer string) error {
+	parsed, err := url.Parse(issuer)
+	if err != nil {
+		return fmt.Errorf("invalid apiServer.serviceAccountIssuer %q: %v", issuer, err)
+	}
+	if parsed.Scheme != "https" || parsed.Hostname() == "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("invalid apiServer.serviceAccountIssuer %q, must be an https URL with a host and without query or fragment", issuer)
+	}
+	return nil
+}
+
+// validateServiceAccountSigningKey checks that the file at path contains a private key
+// the apiserver can sign tokens with.
+func validateServiceAccountSigningKey(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("apiServer.serviceAccountSigningKeyFile %q must be an absolute path", path)
+	}
+	if _, err := keyutil.PrivateKeyFromFile(path); err != nil {
+		return fmt.Errorf("invalid apiServer.serviceAccountSigningKeyFile: %v", err)
+	}
+	return nil
+}
+
+// validateIPFamilies checks that the cluster and service CIDRs match the IP family
+// policy: a single CIDR each of the same family in single-stack, an IPv4 and an IPv6
+// CIDR each in dual-stack. The primary family must be among the configured ones.
+func validateIPFamilies(cluster ClusterConfig) error {
+	if !StringInList(cluster.IPFamilyPolicy, validIPFamilyPolicies) {
+		return fmt.Errorf("invalid cluster.ipFamilyPolicy %q, valid policies are %v", cluster.IPFamilyPolicy, validIPFamilyPolicies)
+	}
+	if cluster.PrimaryIPFamily != "" && !StringInList(cluster.PrimaryIPFamily, validIPFamilies) {
+		return fmt.Errorf("invalid cluster.primaryIPFamily %q, valid families are %v", cluster.PrimaryIPFamily, validIPFamilies)
+	}
+	var families []string
+	for _, field := range []struct{ name, cidrs string }{
+		{"cluster.clusterCIDR", cluster.ClusterCIDR},
+		{"cluster.serviceCIDR", cluster.ServiceCIDR},
+	} {
+		fieldFamilies := []string{}
+		for _, cidr := range orderCIDRs(field.cidrs, "") {
+			family := cidrFamily(cidr)
+			if family == "" {
+				return fmt.Errorf("invalid CIDR %q in %s", cidr, field.name)
+			}
+			fieldFamilies = append(fieldFamilies, family)
+		}
+		switch {
+		case cluster.IPFamilyPolicy == IPFamilyPolicySingleStack && len(fieldFamilies) != 1:
+			return fmt.Errorf("invalid %s %q, must be a single CIDR with cluster.ipFamilyPolicy %s", field.name, field.cidrs, cluster.IPFamilyPolicy)
+		case cluster.IPFamilyPolicy == IPFamilyPolicyDualStack && (len(fieldFamilies) != 2 || fieldFamilies[0] == fieldFamilies[1]):
+			return fmt.Errorf("invalid %s %q, must be an IPv4 and an IPv6 CIDR with cluster.ipFamilyPolicy %s", field.name, field.cidrs, cluster.IPFamilyPolicy)
+		}
+		if families != nil && families[0] != fieldFamilies[0] && cluster.IPFamilyPolicy == IPFamilyPolicySingleStack {
+			return fmt.Errorf("cluster.clusterCIDR and cluster.serviceCIDR must be of the same IP family")
+		}
+		families = fieldFamilies
+	}
+	if cluster.PrimaryIPFamily != "" && !StringInList(cluster.PrimaryIPFamily, families) {
+		return fmt.Errorf("cluster.primaryIPFamily %s doesn't match the configured CIDRs", cluster.PrimaryIPFamily)
+	}
+	return nil
+}
+
+// validateNamespaceMetadata checks that the keys are qualified names, e.g.
+// pod-security.kubernetes.io/enforce, and the label values valid label values.
+func validateNamespaceMetadata(namespaces NamespacesConfig) error {
+	for key, value := range namespaces.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid namespaces.labels key %q: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid namespaces.labels value %q of %q: %s", value, key, strings.Join(errs, ", "))
+		}
+	}
+	for key := range namespaces.Annotations {
+		if errs := validation.IsQualifiedName(strings.ToLower(key)); len(errs) > 0 {
+			return fmt.Errorf("invalid namespaces.annotations key %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// validateDNSForwarders checks that each forwarder is an IP address, optionally with a
+// port, e.g. 192.168.1.1, 192.168.1.1:5353 or [fd00::1]:53.
+func validateDNSForwarders(forwarders []string) error {
+	for _, forwarder := range forwarders {
+		if net.ParseIP(forwarder) != nil {
+			continue
+		}
+		host, port, err := net.SplitHostPort(forwarder)
+		if err != nil || net.ParseIP(host) == nil {
+			return fmt.Errorf("invalid entry %q in cluster.dnsForwarders, must be an IP address or IP:port", forwarder)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port in cluster.dnsForwarders entry %q", forwarder)
+		}
+	}
+	return nil
+}
+
+// validateMDNSHostname checks that the name is either a single DNS label or a
+// name in the .local domain.
+func validateMDNSHostname(name string) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) == 0 {
+		return nil
+	}
+	if !strings.HasSuffix(name, ".local") {
+		return fmt.Errorf("invalid mdns.hostname %q, must be a single label or end with .local", name)
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid mdns.hostname %q: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// validateShutdownGracePeriods checks that the periods are valid durations and that
+// the period for critical pods fits into the total one.
+func validateShutdownGracePeriods(total, critical string) error {
+	var totalPeriod, criticalPeriod time.Duration
+	var err error
+	if total != "" {
+		if totalPeriod, err = time.ParseDuration(total); err != nil || totalPeriod < 0 {
+			return fmt.Errorf("invalid node.shutdownGracePeriod %q, must be a non-negative duration", total)
+		}
+	}
+	if critical != "" {
+		if criticalPeriod, err = time.ParseDuration(critical); err != nil || criticalPeriod < 0 {
+			return fmt.Errorf("invalid node.shutdownGracePeriodCriticalPods %q, must be a non-negative duration", critical)
+		}
+	}
+	if criticalPeriod > totalPeriod {
+		return fmt.Errorf("node.shutdownGracePeriodCriticalPods (%v) must not exceed node.shutdownGracePeriod (%v)", criticalPeriod, totalPeriod)
+	}
+	return nil
+}
+
+// validateNodeStatusFrequencies checks that the frequencies are positive durations and
+// that the status isn't reported more often than it is updated.
+func validateNodeStatusFrequencies(update, report string) error {
+	var updateFrequency, reportFrequency time.Duration
+	var err error
+	if update != "" {
+		if updateFrequency, err = time.ParseDuration(update); err != nil || updateFrequency <= 0 {
+			return fmt.Errorf("invalid node.nodeStatusUpdateFrequency %q, must be a positive duration", update)
+		}
+	}
+	if report != "" {
+		if reportFrequency, err = time.ParseDuration(report); err != nil || reportFrequency <= 0 {
+			return fmt.Errorf("invalid node.nodeStatusReportFrequency %q, must be a positive duration", report)
+		}
+	}
+	if update != "" && report != "" && reportFrequency < updateFrequency {
+		return fmt.Errorf("node.nodeStatusReportFrequency (%v) must not be shorter than node.nodeStatusUpdateFrequency (%v)", reportFrequency, updateFrequency)
+	}
+	return nil
+}
+
+// validateHook checks that the hook has a command and a positive timeout.
+func validateHook(field string, hook Hook) error {
+	if hook.Command == "" {
+		return fmt.Errorf("%s must specify a command", field)
+	}
+	if hook.Timeout != "" {
+		if timeout, err := time.ParseDuration(hook.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q of %s %q, must be a positive duration", hook.Timeout, field, hook.Command)
+		}
+	}
+	return nil
+}
+
+// validateTrustBundle checks that the file at path contains at least one
+// PEM encoded certificate and nothing that fails to parse as one.
+func validateTrustBundle(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading additional trust bundle %s: %v", path, err)
+	}
+	if _, err := cert.ParseCertsPEM(contents); err != nil {
+		return fmt.Errorf("parsing additional trust bundle %s: %v", path, err)
+	}
+	return nil
+}
+
+// validateManifestsWorkDir makes sure that rendering the manifests never writes
+// into their directories.
+func validateManifestsWorkDir(workDir string, sources []string) error {
+	if workDir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(workDir) {
+		return fmt.Errorf("manifests.workDir %q must be an absolute path", workDir)
+	}
+	for _, source := range sources {
+		if isWithinDir(source, workDir) {
+			return fmt.Errorf("manifests.workDir %q must not be in the manifests directory %s", workDir, source)
+		}
+		if isWithinDir(workDir, source) {
+			return fmt.Errorf("manifests.workDir %q must not contain the manifests directory %s", workDir, source)
+		}
+	}
+	return nil
+}
+
+// isWithinDir returns whether path is dir or below it
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func validateResourceManagers(node NodeConfig) error {
+	if !StringInList(node.CPUManagerPolicy, validCPUManagerPolicies) {
+		return fmt.Errorf("unknown node.cpuManagerPolicy %q, valid policies are %v", node.CPUManagerPolicy, validCPUManagerPolicies)
+	}
+	if !StringInList(node.TopologyManagerPolicy, validTopologyManagerPolicies) {
+		return fmt.Errorf("unknown node.topologyManagerPolicy %q, valid policies are %v", node.TopologyManagerPolicy, validTopologyManagerPolicies)
+	}
+	// the kubelet refuses to start the static policy without CPUs left for the system
+	if node.CPUManagerPolicy == CPUManagerPolicyStatic {
+		reserved := false
+		for _, arg := range []string{"reserved-cpus", "kube-reserved", "system-reserved"} {
+			if _, ok := node.ExtraArgs[arg]; ok {
+				reserved = true
+			}
+		}
+		if !reserved {
+			return fmt.Errorf("node.cpuManagerPolicy %s requires reserving CPUs with the reserved-cpus, kube-reserved or system-reserved argument in node.extraArgs", CPUManagerPolicyStatic)
+		}
+	}
+	return nil
+}
+
+func validateSysctls(sysctls map[string]string) error {
+	for key, value := range sysctls {
+		if !sysctlKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid key %q in node.sysctls, must be a dot-separated kernel parameter under fs, kernel, net, user or vm", key)
+		}
+		if value == "" || strings.ContainsAny(value, "\n\x00") {
+			return fmt.Errorf("invalid value %q of node.sysctls[%s], must be a non-empty single line", value, key)
+		}
+		if required, ok := requiredSysctls[key]; ok && value != required {
+			return fmt.Errorf("node.sysctls[%s] can't be changed from %q, MicroShift requires it", key, required)
+		}
+	}
+	return nil
+}
+
+// NodeSysctls returns the kernel parameters to set on the node, the required ones
+// and the configured ones.
+func (c *MicroshiftConfig) NodeSysctls() map[string]string {
+	sysctls := make(map[string]string, len(requiredSysctls)+len(c.Node.Sysctls))
+	for key, value := range requiredSysctls {
+		sysctls[key] = value
+	}
+	for key, value := range c.Node.Sysctls {
+		sysctls[key] = value
+	}
+	return sysctls
+}
+
+// DefaultResourceQuota returns the spec of the ResourceQuota created in new namespaces,
+// or nil if cluster.defaultResourceQuota isn't set.
+func (c *MicroshiftConfig) DefaultResourceQuota() (*corev1.ResourceQuotaSpec, error) {
+	quota := c.Cluster.DefaultResourceQuota
+	if quota.File != "" {
+		if len(quota.Hard) > 0 {
+			return nil, fmt.Errorf("cluster.defaultResourceQuota.hard and cluster.defaultResourceQuota.file can't be set together")
+		}
+		obj := &corev1.ResourceQuota{}
+		if err := readManifest("cluster.defaultResourceQuota.file", quota.File, "ResourceQuota", obj); err != nil {
+			return nil, err
+		}
+		return &obj.Spec, nil
+	}
+	if len(quota.Hard) == 0 {
+		return nil, nil
+	}
+	hard, err := parseResourceList("cluster.defaultResourceQuota.hard", quota.Hard)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ResourceQuotaSpec{Hard: hard}, nil
+}
+
+// DefaultLimitRange returns the spec of the LimitRange created in new namespaces, or
+// nil if cluster.defaultLimitRange isn't set. The configured values apply to
+// containers.
+func (c *MicroshiftConfig) DefaultLimitRange() (*corev1.LimitRangeSpec, error) {
+	limits := c.Cluster.DefaultLimitRange
+	inline := len(limits.Default) > 0 || len(limits.DefaultRequest) > 0 || len(limits.Max) > 0
+	if limits.File != "" {
+		if inline {
+			return nil, fmt.Errorf("cluster.defaultLimitRange.file can't be set together with the limits of cluster.defaultLimitRange")
+		}
+		obj := &corev1.LimitRange{}
+		if err := readManifest("cluster.defaultLimitRange.file", limits.File, "LimitRange", obj); err != nil {
+			return nil, err
+		}
+		return &obj.Spec, nil
+	}
+	if !inline {
+		return nil, nil
+	}
+	var err error
+	item := corev1.LimitRangeItem{Type: corev1.LimitTypeContainer}
+	if item.Default, err = parseResourceList("cluster.defaultLimitRange.default", limits.Default); err != nil {
+		return nil, err
+	}
+	if item.DefaultRequest, err = parseResourceList("cluster.defaultLimitRange.defaultRequest", limits.DefaultRequest); err != nil {
+		return nil, err
+	}
+	if item.Max, err = parseResourceList("cluster.defaultLimitRange.max", limits.Max); err != nil {
+		return nil, err
+	}
+	return &corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{item}}, nil
+}
+
+// parseResourceList parses the quantities of the resources of the field, returning nil
+// if there are none.
+func parseResourceList(field string, values map[string]string) (corev1.ResourceList, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	list := corev1.ResourceList{}
+	for name, value := range values {
+		q, err := resource.ParseQuantity(value)
+		if err != nil || q.Sign() < 0 {
+			return nil, fmt.Errorf("invalid %s %q of %s, must be a non-negative quantity", field, value, name)
+		}
+		list[corev1.ResourceName(name)] = q
+	}
+	return list, nil
+}
+
+// readManifest decodes the manifest at path, which must be of the kind, into obj.
+func readManifest(field, path, kind string, obj runtime.Object) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s %s: %v", field, path, err)
+	}
+	if err := yaml.UnmarshalStrict(contents, obj); err != nil {
+		return fmt.Errorf("decoding %s %s: %v", field, path, err)
+	}
+	if got := obj.GetObjectKind().GroupVersionKind(); got.Kind != kind || got.GroupVersion() != corev1.SchemeGroupVersion {
+		return fmt.Errorf("%s %s must be a %s of %s, got %s", field, path, kind, corev1.SchemeGroupVersion, got)
+	}
+	return nil
+}
+
+// pauseImageName is the name of the pause image in release.Image
+const pauseImageName = "pod"
+
+// ComponentImages returns the images of the components by name, with the configured
+// overrides applied. On error the embedded images are returned along with it.
+func (c *MicroshiftConfig) ComponentImages() (map[string]string, error) {
+	images := make(map[string]string, len(release.Image))
+	for name, image := range release.Image {
+		images[name] = image
+	}
+
+	overrides := map[string]string{}
+	if c.Images.OverridesFile != "" {
+		contents, err := os.ReadFile(c.Images.OverridesFile)
+		if err != nil {
+			return images, fmt.Errorf("reading images.overridesFile %s: %v", c.Images.OverridesFile, err)
+		}
+		if err := yaml.UnmarshalStrict(contents, &overrides); err != nil {
+			return images, fmt.Errorf("decoding images.overridesFile %s: %v", c.Images.OverridesFile, err)
+		}
+	}
+	for name, image := range c.Images.Overrides {
+		overrides[name] = image
+	}
+
+	for name, image := range overrides {
+		// CRI-O creates the pod sandboxes from the pause_image of its own config
+		if name == pauseImageName {
+			return images, fmt.Errorf("invalid image %q in images.overrides, the pause image is set by pause_image in the CRI-O config", name)
+		}
+		if _, ok := images[name]; !ok {
+			return images, fmt.Errorf("unknown image %q in images.overrides, valid images are %v", name, sets.StringKeySet(release.Image).Delete(pauseImageName).List())
+		}
+		if _, _, _, err := parsers.ParseImageName(image); err != nil {
+			return images, fmt.Errorf("invalid override %q of image %q: %v", image, name, err)
+		}
+	}
+	for name, image := range overrides {
+		images[name] = image
+	}
+	return images, nil
+}
+
+// ComponentEnabled returns whether the infrastructure component is to be deployed
+// or the OpenShift-specific service is to be run.
+func (c *MicroshiftConfig) ComponentEnabled(name string) bool {
+	return !StringInList(name, c.Components.Disabled)
+}
+
+// ServiceOptional returns whether MicroShift continues degraded when the named service
+// fails, instead of stopping.
+func (c *MicroshiftConfig) ServiceOptional(name string) bool {
+	if StringInList(name, c.Services.Critical) {
+		return false
+	}
+	return StringInList(name, c.Services.Optional) || StringInList(name, defaultOptionalServices)
+}
+
+// HasRole returns whether the given role is enabled in the config.
+func (c *MicroshiftConfig) HasRole(role string) bool {
+	return StringInList(role, c.Roles)
+}
+
 func HideUnsupportedFlags(flags *pflag.FlagSet) {
 	// hide logging flags that we do not use/support
 	loggingFlags := pflag.NewFlagSet("logging-flags", pflag.ContinueOnError)
@@ -1,12 +1,19 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/openshift/microshift/pkg/release"
 	"github.com/spf13/pflag"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
 )
 
 const (
@@ -41,9 +48,18 @@ func TestCommandLineConfig(t *testing.T) {
 	}{
 		{
 			config: &MicroshiftConfig{
-				LogVLevel: 4,
-				NodeName:  "node1",
-				NodeIP:    "1.2.3.4",
+				APIVersion:  CurrentAPIVersion,
+				Kind:        Kind,
+				LogVLevel:   4,
+				Roles:       []string{"controlplane", "node"},
+				DataDir:     "/tmp/microshift/data",
+				AuditLogDir: "/tmp/microshift/logs",
+				NodeName:    "node1",
+				NodeIP:      "1.2.3.4",
+				Logging: LoggingConfig{
+					MaxSizeMB:  100,
+					MaxBackups: 5,
+				},
 				Cluster: ClusterConfig{
 					URL:                  "https://1.2.3.4:6443",
 					ClusterCIDR:          "10.20.30.40/16",
@@ -52,6 +68,73 @@ func TestCommandLineConfig(t *testing.T) {
 					DNS:                  "cluster.dns",
 					Domain:               "cluster.local",
 					MTU:                  "1200",
+					DefaultNetworkPolicy: "none",
+					IPFamilyPolicy:       "SingleStack",
+				},
+				Etcd: EtcdConfig{
+					TLSMinVersion: TLSVersion12,
+				},
+				APIServer: APIServerConfig{
+					AuditLogFormat:          AuditLogFormatJSON,
+					AuditLogMaxTotalSizeMB:  512,
+					StorageMediaType:        "application/vnd.kubernetes.protobuf",
+					WatchCache:              true,
+					DeleteCollectionWorkers: 1,
+					AggregatorRouting:       true,
+					AuthorizationModes:      []string{"Scope", "SystemMasters", "RBAC", "Node"},
+					ServiceAccountIssuer:    "https://kubernetes.default.svc",
+					EventTTL:                "30m",
+					ShutdownDelayDuration:   "0s",
+					ShutdownSendRetryAfter:  true,
+					Watchdog: WatchdogConfig{
+						Interval:         "10s",
+						FailureThreshold: "2m",
+						Action:           WatchdogActionExit,
+					},
+				},
+				ControllerManager: ControllerManagerConfig{
+					LeaderElection: LeaderElectionConfig{
+						LeaseDuration: "15s",
+						RenewDeadline: "10s",
+						RetryPeriod:   "2s",
+					},
+				},
+				Scheduler: SchedulerConfig{
+					LeaderElection: LeaderElectionConfig{
+						LeaseDuration: "15s",
+						RenewDeadline: "10s",
+						RetryPeriod:   "2s",
+					},
+					Preemption: true,
+				},
+				Node: NodeConfig{
+					MaxPods:               250,
+					ContainerLogMaxSize:   "50Mi",
+					ContainerLogMaxFiles:  5,
+					CPUManagerPolicy:      "none",
+					TopologyManagerPolicy: "none",
+					SwapBehavior:          "off",
+					ManageSysctls:         true,
+				},
+				Manifests: ManifestsConfig{
+					Enabled:      true,
+					ApplyRetries: 6,
+					ApplyBackoff: "2s",
+					ApplyMode:    "client",
+				},
+				Storage: StorageConfig{
+					ClassName:    "topolvm-provisioner",
+					DefaultClass: true,
+				},
+				MDNS: MDNSConfig{
+					Enabled: true,
+				},
+				Metrics: MetricsConfig{
+					Enabled: true,
+				},
+				Clock: ClockConfig{
+					JumpThreshold:     "1m",
+					CheckCertificates: true,
 				},
 			},
 			err: nil,
@@ -64,6 +147,9 @@ func TestCommandLineConfig(t *testing.T) {
 		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
 		// all other flags unbound (looked up by name) and defaulted
 		flags.Int("v", config.LogVLevel, "")
+		flags.StringSlice("roles", config.Roles, "")
+		flags.String("data-dir", config.DataDir, "")
+		flags.String("audit-log-dir", config.AuditLogDir, "")
 		flags.String("node-name", config.NodeName, "")
 		flags.String("node-ip", config.NodeIP, "")
 		flags.String("url", config.Cluster.URL, "")
@@ -78,6 +164,9 @@ func TestCommandLineConfig(t *testing.T) {
 		var err error
 		err = flags.Parse([]string{
 			"--v=" + strconv.Itoa(tt.config.LogVLevel),
+			"--roles=" + strings.Join(tt.config.Roles, ","),
+			"--data-dir=" + tt.config.DataDir,
+			"--audit-log-dir=" + tt.config.AuditLogDir,
 			"--node-name=" + tt.config.NodeName,
 			"--node-ip=" + tt.config.NodeIP,
 			"--url=" + tt.config.Cluster.URL,
@@ -116,9 +205,18 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 	}{
 		{
 			desiredMicroShiftConfig: &MicroshiftConfig{
-				LogVLevel: 23,
-				NodeName:  "node1",
-				NodeIP:    "1.2.3.4",
+				APIVersion:  CurrentAPIVersion,
+				Kind:        Kind,
+				LogVLevel:   23,
+				Roles:       []string{"controlplane", "node"},
+				DataDir:     "/tmp/microshift/data",
+				AuditLogDir: "/tmp/microshift/logs",
+				NodeName:    "node1",
+				NodeIP:      "1.2.3.4",
+				Logging: LoggingConfig{
+					MaxSizeMB:  100,
+					MaxBackups: 5,
+				},
 				Cluster: ClusterConfig{
 					URL:                  "https://cluster.com:4343/endpoint",
 					ClusterCIDR:          "10.20.30.40/16",
@@ -127,6 +225,73 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 					DNS:                  "10.43.0.10",
 					Domain:               "cluster.local",
 					MTU:                  "1400",
+					DefaultNetworkPolicy: "none",
+					IPFamilyPolicy:       "SingleStack",
+				},
+				Etcd: EtcdConfig{
+					TLSMinVersion: TLSVersion12,
+				},
+				APIServer: APIServerConfig{
+					AuditLogFormat:          AuditLogFormatJSON,
+					AuditLogMaxTotalSizeMB:  512,
+					StorageMediaType:        "application/vnd.kubernetes.protobuf",
+					WatchCache:              true,
+					DeleteCollectionWorkers: 1,
+					AggregatorRouting:       true,
+					AuthorizationModes:      []string{"Scope", "SystemMasters", "RBAC", "Node"},
+					ServiceAccountIssuer:    "https://kubernetes.default.svc",
+					EventTTL:                "30m",
+					ShutdownDelayDuration:   "0s",
+					ShutdownSendRetryAfter:  true,
+					Watchdog: WatchdogConfig{
+						Interval:         "10s",
+						FailureThreshold: "2m",
+						Action:           WatchdogActionExit,
+					},
+				},
+				ControllerManager: ControllerManagerConfig{
+					LeaderElection: LeaderElectionConfig{
+						LeaseDuration: "15s",
+						RenewDeadline: "10s",
+						RetryPeriod:   "2s",
+					},
+				},
+				Scheduler: SchedulerConfig{
+					LeaderElection: LeaderElectionConfig{
+						LeaseDuration: "15s",
+						RenewDeadline: "10s",
+						RetryPeriod:   "2s",
+					},
+					Preemption: true,
+				},
+				Node: NodeConfig{
+					MaxPods:               250,
+					ContainerLogMaxSize:   "50Mi",
+					ContainerLogMaxFiles:  5,
+					CPUManagerPolicy:      "none",
+					TopologyManagerPolicy: "none",
+					SwapBehavior:          "off",
+					ManageSysctls:         true,
+				},
+				Manifests: ManifestsConfig{
+					Enabled:      true,
+					ApplyRetries: 6,
+					ApplyBackoff: "2s",
+					ApplyMode:    "client",
+				},
+				Storage: StorageConfig{
+					ClassName:    "topolvm-provisioner",
+					DefaultClass: true,
+				},
+				MDNS: MDNSConfig{
+					Enabled: true,
+				},
+				Metrics: MetricsConfig{
+					Enabled: true,
+				},
+				Clock: ClockConfig{
+					JumpThreshold:     "1m",
+					CheckCertificates: true,
 				},
 			},
 			err: nil,
@@ -135,6 +300,9 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 				value   string
 			}{
 				{"MICROSHIFT_LOGVLEVEL", "23"},
+				{"MICROSHIFT_ROLES", "controlplane,node"},
+				{"MICROSHIFT_DATADIR", "/tmp/microshift/data"},
+				{"MICROSHIFT_AUDITLOGDIR", "/tmp/microshift/logs"},
 				{"MICROSHIFT_NODENAME", "node1"},
 				{"MICROSHIFT_NODEIP", "1.2.3.4"},
 				{"MICROSHIFT_CLUSTER_URL", "https://cluster.com:4343/endpoint"},
@@ -148,9 +316,18 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 		},
 		{
 			desiredMicroShiftConfig: &MicroshiftConfig{
-				LogVLevel: 23,
-				NodeName:  "node1",
-				NodeIP:    "1.2.3.4",
+				APIVersion:  CurrentAPIVersion,
+				Kind:        Kind,
+				LogVLevel:   23,
+				Roles:       []string{"controlplane", "node"},
+				DataDir:     "/tmp/microshift/data",
+				AuditLogDir: "/tmp/microshift/logs",
+				NodeName:    "node1",
+				NodeIP:      "1.2.3.4",
+				Logging: LoggingConfig{
+					MaxSizeMB:  100,
+					MaxBackups: 5,
+				},
 				Cluster: ClusterConfig{
 					URL:                  "https://cluster.com:4343/endpoint",
 					ClusterCIDR:          "10.20.30.40/16",
@@ -159,6 +336,73 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 					DNS:                  "10.43.0.10",
 					Domain:               "cluster.local",
 					MTU:                  "1300",
+					DefaultNetworkPolicy: "none",
+					IPFamilyPolicy:       "SingleStack",
+				},
+				Etcd: EtcdConfig{
+					TLSMinVersion: TLSVersion12,
+				},
+				APIServer: APIServerConfig{
+					AuditLogFormat:          AuditLogFormatJSON,
+					AuditLogMaxTotalSizeMB:  512,
+					StorageMediaType:        "application/vnd.kubernetes.protobuf",
+					WatchCache:              true,
+					DeleteCollectionWorkers: 1,
+					AggregatorRouting:       true,
+					AuthorizationModes:      []string{"Scope", "SystemMasters", "RBAC", "Node"},
+					ServiceAccountIssuer:    "https://kubernetes.default.svc",
+					EventTTL:                "30m",
+					ShutdownDelayDuration:   "0s",
+					ShutdownSendRetryAfter:  true,
+					Watchdog: WatchdogConfig{
+						Interval:         "10s",
+						FailureThreshold: "2m",
+						Action:           WatchdogActionExit,
+					},
+				},
+				ControllerManager: ControllerManagerConfig{
+					LeaderElection: LeaderElectionConfig{
+						LeaseDuration: "15s",
+						RenewDeadline: "10s",
+						RetryPeriod:   "2s",
+					},
+				},
+				Scheduler: SchedulerConfig{
+					LeaderElection: LeaderElectionConfig{
+						LeaseDuration: "15s",
+						RenewDeadline: "10s",
+						RetryPeriod:   "2s",
+					},
+					Preemption: true,
+				},
+				Node: NodeConfig{
+					MaxPods:               250,
+					ContainerLogMaxSize:   "50Mi",
+					ContainerLogMaxFiles:  5,
+					CPUManagerPolicy:      "none",
+					TopologyManagerPolicy: "none",
+					SwapBehavior:          "off",
+					ManageSysctls:         true,
+				},
+				Manifests: ManifestsConfig{
+					Enabled:      true,
+					ApplyRetries: 6,
+					ApplyBackoff: "2s",
+					ApplyMode:    "client",
+				},
+				Storage: StorageConfig{
+					ClassName:    "topolvm-provisioner",
+					DefaultClass: true,
+				},
+				MDNS: MDNSConfig{
+					Enabled: true,
+				},
+				Metrics: MetricsConfig{
+					Enabled: true,
+				},
+				Clock: ClockConfig{
+					JumpThreshold:     "1m",
+					CheckCertificates: true,
 				},
 			},
 			err: nil,
@@ -167,6 +411,9 @@ func TestEnvironmentVariableConfig(t *testing.T) {
 				value   string
 			}{
 				{"MICROSHIFT_LOGVLEVEL", "23"},
+				{"MICROSHIFT_ROLES", "controlplane,node"},
+				{"MICROSHIFT_DATADIR", "/tmp/microshift/data"},
+				{"MICROSHIFT_AUDITLOGDIR", "/tmp/microshift/logs"},
 				{"MICROSHIFT_NODENAME", "node1"},
 				{"MICROSHIFT_NODEIP", "1.2.3.4"},
 				{"MICROSHIFT_CLUSTER_URL", "https://cluster.com:4343/endpoint"},
@@ -209,6 +456,1808 @@ func TestMicroshiftConfigReadAndValidate(t *testing.T) {
 	}
 }
 
+// test that roles from the config file are respected unless overridden on the commandline
+func TestRolesFromConfigFile(t *testing.T) {
+	var ttests = []struct {
+		args  []string
+		roles []string
+	}{
+		{args: []string{}, roles: []string{"controlplane"}},
+		{args: []string{"--roles=node"}, roles: []string{"node"}},
+		{args: []string{"--roles=controlplane,node"}, roles: []string{"controlplane", "node"}},
+	}
+	// make sure roles set by previous tests in the environment don't take precedence
+	os.Unsetenv("MICROSHIFT_ROLES")
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.StringSlice("roles", c.Roles, "")
+		if err := flags.Parse(tt.args); err != nil {
+			t.Fatalf("failed to parse command line flags: %s", err)
+		}
+
+		if err := c.ReadAndValidate(testConfigFile, flags); err != nil {
+			t.Fatalf("failed to read and validate config: %v", err)
+		}
+		if !reflect.DeepEqual(c.Roles, tt.roles) {
+			t.Errorf("roles do not match for args %v: expected %v, got %v", tt.args, tt.roles, c.Roles)
+		}
+	}
+}
+
+func writeTestConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	header := "apiVersion: " + CurrentAPIVersion + "\nkind: " + Kind + "\n"
+	if err := os.WriteFile(path, []byte(header+contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// test that references to environment variables and files are substituted in config values
+func TestSubstituteReferences(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_NODE_NAME", "edge-1")
+	t.Setenv("TEST_REGISTRY", "registry.example.com")
+
+	configFile := writeTestConfigFile(t, `node:
+  hostnameOverride: ${TEST_NODE_NAME}
+  prePullImages:
+  - ${TEST_REGISTRY}/app:1.0
+apiServer:
+  extraArgs:
+    token-auth-file: ["${file:`+tokenFile+`}"]
+hooks:
+  preStart:
+  - command: /bin/sh
+    args: ["-c", "echo $${HOME}"]
+`)
+	c := NewMicroshiftConfig()
+	if err := c.ReadAndValidate(configFile, pflag.NewFlagSet("test", pflag.ContinueOnError)); err != nil {
+		t.Fatalf("failed to read and validate config: %v", err)
+	}
+
+	if c.Node.HostnameOverride != "edge-1" {
+		t.Errorf("expected the hostname override from the environment, got %q", c.Node.HostnameOverride)
+	}
+	if want := []string{"registry.example.com/app:1.0"}; !reflect.DeepEqual(c.Node.PrePullImages, want) {
+		t.Errorf("expected pre-pull images %v, got %v", want, c.Node.PrePullImages)
+	}
+	if want := []string{"s3cr3t"}; !reflect.DeepEqual(c.APIServer.ExtraArgs["token-auth-file"], want) {
+		t.Errorf("expected the extra argument from the file, got %v", c.APIServer.ExtraArgs["token-auth-file"])
+	}
+	if want := []string{"-c", "echo ${HOME}"}; !reflect.DeepEqual(c.Hooks.PreStart[0].Args, want) {
+		t.Errorf("expected escaped references to be kept, got %v", c.Hooks.PreStart[0].Args)
+	}
+
+	// the references are kept in the config as it was read
+	u := c.Unsubstituted()
+	if want := []string{"${file:" + tokenFile + "}"}; !reflect.DeepEqual(u.APIServer.ExtraArgs["token-auth-file"], want) {
+		t.Errorf("expected the reference in the unsubstituted config, got %v", u.APIServer.ExtraArgs["token-auth-file"])
+	}
+	if u.Node.HostnameOverride != "${TEST_NODE_NAME}" || u.DataDir != c.DataDir {
+		t.Errorf("expected the unsubstituted config to match the config file, got %+v", u)
+	}
+}
+
+func TestSubstituteReferencesUnresolved(t *testing.T) {
+	os.Unsetenv("TEST_UNSET_VARIABLE")
+	missingFile := filepath.Join(t.TempDir(), "missing")
+
+	var ttests = []struct {
+		contents string
+		wantErr  string
+	}{
+		{contents: "node:\n  hostnameOverride: ${TEST_UNSET_VARIABLE}\n", wantErr: "node.hostnameOverride: environment variable TEST_UNSET_VARIABLE"},
+		{contents: "roles: [controlplane, \"${TEST_UNSET_VARIABLE}\"]\n", wantErr: "roles[1]: environment variable TEST_UNSET_VARIABLE"},
+		{contents: "ingress:\n  certFile: ${file:" + missingFile + "}\n", wantErr: "ingress.certFile: reading ${file:" + missingFile + "}"},
+		{contents: "node:\n  hostnameOverride: ${}\n", wantErr: "node.hostnameOverride: empty reference"},
+	}
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		err := c.ReadAndValidate(writeTestConfigFile(t, tt.contents), pflag.NewFlagSet("test", pflag.ContinueOnError))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("config %q: expected error containing %q, got %v", tt.contents, tt.wantErr, err)
+		}
+	}
+}
+
+// test that each profile selects its roles, components and defaults
+func TestProfiles(t *testing.T) {
+	var ttests = []struct {
+		profile   string
+		roles     []string
+		disabled  []string
+		eventTTL  string
+		maxPods   int
+		wantError bool
+	}{
+		{profile: "", roles: []string{ControlPlaneRole, NodeRole}, eventTTL: "30m", maxPods: 250},
+		{profile: ProfileFull, roles: []string{ControlPlaneRole, NodeRole}, eventTTL: "30m", maxPods: 250},
+		{profile: ProfileEdgeMinimal, roles: []string{ControlPlaneRole, NodeRole}, disabled: []string{ComponentStorage}, eventTTL: "10m", maxPods: 50},
+		{profile: ProfileWorker, roles: []string{NodeRole}, eventTTL: "30m", maxPods: 250},
+		{profile: "tiny", wantError: true},
+	}
+	os.Unsetenv("MICROSHIFT_PROFILE")
+	os.Unsetenv("MICROSHIFT_ROLES")
+
+	for _, tt := range ttests {
+		configFile := writeTestConfigFile(t, "profile: "+tt.profile+"\n")
+		c := NewMicroshiftConfig()
+		err := c.ReadAndValidate(configFile, pflag.NewFlagSet("test", pflag.ContinueOnError))
+		if (err != nil) != tt.wantError {
+			t.Fatalf("profile %q: ReadAndValidate() error = %v, wantErr %v", tt.profile, err, tt.wantError)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(c.Roles, tt.roles) {
+			t.Errorf("profile %q: expected roles %v, got %v", tt.profile, tt.roles, c.Roles)
+		}
+		if !reflect.DeepEqual(c.Components.Disabled, tt.disabled) {
+			t.Errorf("profile %q: expected disabled components %v, got %v", tt.profile, tt.disabled, c.Components.Disabled)
+		}
+		if c.APIServer.EventTTL != tt.eventTTL {
+			t.Errorf("profile %q: expected event TTL %q, got %q", tt.profile, tt.eventTTL, c.APIServer.EventTTL)
+		}
+		if c.Node.MaxPods != tt.maxPods {
+			t.Errorf("profile %q: expected max pods %d, got %d", tt.profile, tt.maxPods, c.Node.MaxPods)
+		}
+	}
+}
+
+// test that explicitly configured values take precedence over the profile's defaults
+func TestProfileOverrides(t *testing.T) {
+	configFile := writeTestConfigFile(t, "profile: edge-minimal\napiServer:\n  eventTTL: 1h\n")
+	os.Unsetenv("MICROSHIFT_PROFILE")
+	os.Unsetenv("MICROSHIFT_ROLES")
+
+	var ttests = []struct {
+		args     []string
+		profile  string
+		roles    []string
+		eventTTL string
+		maxPods  int
+	}{
+		{args: []string{}, profile: ProfileEdgeMinimal, roles: []string{ControlPlaneRole, NodeRole}, eventTTL: "1h", maxPods: 50},
+		{args: []string{"--roles=node"}, profile: ProfileEdgeMinimal, roles: []string{NodeRole}, eventTTL: "1h", maxPods: 50},
+		{args: []string{"--profile=full"}, profile: ProfileFull, roles: []string{ControlPlaneRole, NodeRole}, eventTTL: "1h", maxPods: 250},
+	}
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("profile", c.Profile, "")
+		flags.StringSlice("roles", c.Roles, "")
+		if err := flags.Parse(tt.args); err != nil {
+			t.Fatalf("failed to parse command line flags: %s", err)
+		}
+
+		if err := c.ReadAndValidate(configFile, flags); err != nil {
+			t.Fatalf("failed to read and validate config: %v", err)
+		}
+		if c.Profile != tt.profile || !reflect.DeepEqual(c.Roles, tt.roles) || c.APIServer.EventTTL != tt.eventTTL || c.Node.MaxPods != tt.maxPods {
+			t.Errorf("args %v: expected profile %q, roles %v, event TTL %q and max pods %d, got %q, %v, %q and %d",
+				tt.args, tt.profile, tt.roles, tt.eventTTL, tt.maxPods, c.Profile, c.Roles, c.APIServer.EventTTL, c.Node.MaxPods)
+		}
+	}
+}
+
+// test that invalid roles are rejected
+func TestValidateRoles(t *testing.T) {
+	var ttests = []struct {
+		roles   []string
+		wantErr bool
+	}{
+		{roles: []string{"controlplane", "node"}, wantErr: false},
+		{roles: []string{"node"}, wantErr: false},
+		{roles: []string{}, wantErr: true},
+		{roles: []string{"controlplane", "worker"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Roles = tt.roles
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with roles %v error = %v, wantErr %v", tt.roles, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateDisabledComponents(t *testing.T) {
+	var ttests = []struct {
+		disabled []string
+		wantErr  bool
+	}{
+		{disabled: nil, wantErr: false},
+		{disabled: []string{"ingress", "storage"}, wantErr: false},
+		{disabled: []string{"service-ca", "ingress", "dns"}, wantErr: false},
+		{disabled: []string{"service-ca"}, wantErr: true},
+		{disabled: []string{"router"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Components.Disabled = tt.disabled
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with disabled components %v error = %v, wantErr %v", tt.disabled, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateOpenShiftComponents(t *testing.T) {
+	var ttests = []struct {
+		disabled []string
+		mdns     bool
+		wantErr  bool
+	}{
+		{disabled: []string{"openshift-scc"}, mdns: true, wantErr: false},
+		{disabled: []string{"ingress", "openshift-controller-manager"}, mdns: true, wantErr: false},
+		{disabled: []string{"ingress", "storage", "openshift-crds", "openshift-controller-manager", "openshift-scc"}, mdns: false, wantErr: false},
+		{disabled: []string{"openshift-controller-manager"}, mdns: true, wantErr: true},
+		{disabled: []string{"ingress", "openshift-crds", "openshift-controller-manager", "openshift-scc"}, mdns: false, wantErr: true},
+		{disabled: []string{"ingress", "storage", "openshift-crds", "openshift-controller-manager"}, mdns: false, wantErr: true},
+		{disabled: []string{"ingress", "storage", "openshift-crds", "openshift-controller-manager", "openshift-scc"}, mdns: true, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Components.Disabled = tt.disabled
+		c.MDNS.Enabled = tt.mdns
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with disabled components %v and mdns %v error = %v, wantErr %v", tt.disabled, tt.mdns, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateIngressCertificate(t *testing.T) {
+	var ttests = []struct {
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{certFile: "", keyFile: "", wantErr: false},
+		{certFile: "/etc/certs/tls.crt", keyFile: "/etc/certs/tls.key", wantErr: false},
+		{certFile: "/etc/certs/tls.crt", keyFile: "", wantErr: true},
+		{certFile: "", keyFile: "/etc/certs/tls.key", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Ingress.CertFile = tt.certFile
+		c.Ingress.KeyFile = tt.keyFile
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with ingress cert %q and key %q error = %v, wantErr %v", tt.certFile, tt.keyFile, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateEtcdTLS(t *testing.T) {
+	var ttests = []struct {
+		minVersion   string
+		cipherSuites []string
+		wantErr      bool
+	}{
+		{minVersion: TLSVersion12, wantErr: false},
+		{minVersion: TLSVersion13, wantErr: false},
+		{minVersion: TLSVersion12, cipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, wantErr: false},
+		{minVersion: "VersionTLS11", wantErr: true},
+		{minVersion: "", wantErr: true},
+		{minVersion: TLSVersion12, cipherSuites: []string{"TLS_RSA_WITH_UNKNOWN"}, wantErr: true},
+		{minVersion: TLSVersion13, cipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Etcd.TLSMinVersion = tt.minVersion
+		c.Etcd.TLSCipherSuites = tt.cipherSuites
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with etcd TLS min version %q and cipher suites %v error = %v, wantErr %v", tt.minVersion, tt.cipherSuites, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateLogging(t *testing.T) {
+	var ttests = []struct {
+		file       string
+		maxSizeMB  int
+		maxBackups int
+		maxAgeDays int
+		wantErr    bool
+	}{
+		{file: "", maxSizeMB: 100, maxBackups: 5, maxAgeDays: 0, wantErr: false},
+		{file: "/var/log/microshift/microshift.log", maxSizeMB: 1, maxBackups: 0, maxAgeDays: 30, wantErr: false},
+		{file: "microshift.log", maxSizeMB: 100, maxBackups: 5, maxAgeDays: 0, wantErr: true},
+		{file: "/var/log/microshift/microshift.log", maxSizeMB: 0, maxBackups: 5, maxAgeDays: 0, wantErr: true},
+		{file: "/var/log/microshift/microshift.log", maxSizeMB: 20 * 1024, maxBackups: 5, maxAgeDays: 0, wantErr: true},
+		{file: "/var/log/microshift/microshift.log", maxSizeMB: 100, maxBackups: -1, maxAgeDays: 0, wantErr: true},
+		{file: "/var/log/microshift/microshift.log", maxSizeMB: 100, maxBackups: 5, maxAgeDays: -1, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Logging.File = tt.file
+		c.Logging.MaxSizeMB = tt.maxSizeMB
+		c.Logging.MaxBackups = tt.maxBackups
+		c.Logging.MaxAgeDays = tt.maxAgeDays
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with logging %+v error = %v, wantErr %v", c.Logging, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateHostnameOverride(t *testing.T) {
+	var ttests = []struct {
+		hostnameOverride string
+		wantErr          bool
+	}{
+		{hostnameOverride: "", wantErr: false},
+		{hostnameOverride: "edge-node-1", wantErr: false},
+		{hostnameOverride: "edge-node-1.example.com", wantErr: false},
+		{hostnameOverride: "Edge_Node", wantErr: true},
+		{hostnameOverride: "edge-node-", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.HostnameOverride = tt.hostnameOverride
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with node hostname override %q error = %v, wantErr %v", tt.hostnameOverride, err, tt.wantErr)
+		}
+		if tt.hostnameOverride != "" && c.KubeletNodeName() != tt.hostnameOverride {
+			t.Errorf("expected node name %q, got %q", tt.hostnameOverride, c.KubeletNodeName())
+		}
+	}
+}
+
+func TestComponentImages(t *testing.T) {
+	overridesFile := filepath.Join(t.TempDir(), "overrides.yaml")
+	if err := os.WriteFile(overridesFile, []byte("coredns: registry.local:5000/coredns:v4.12\ncli: registry.local:5000/cli:v4.12\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewMicroshiftConfig()
+	c.Images.OverridesFile = overridesFile
+	c.Images.Overrides = map[string]string{"cli": "registry.local:5000/cli@sha256:0c87eb7e6708319081e2f11b2f11c863563c317436aba983549f3d99a420ecab"}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	images, err := c.ComponentImages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"coredns":             "registry.local:5000/coredns:v4.12",
+		"cli":                 c.Images.Overrides["cli"],
+		"service_ca_operator": release.Image["service_ca_operator"],
+	}
+	for name, image := range expected {
+		if images[name] != image {
+			t.Errorf("expected image %q to be %q, got %q", name, image, images[name])
+		}
+	}
+	if len(images) != len(release.Image) {
+		t.Errorf("expected %d images, got %d", len(release.Image), len(images))
+	}
+}
+
+func TestValidateImageOverrides(t *testing.T) {
+	var ttests = []struct {
+		overrides map[string]string
+		file      string
+		wantErr   bool
+	}{
+		{overrides: nil, wantErr: false},
+		{overrides: map[string]string{"coredns": "registry.local/coredns:v1"}, wantErr: false},
+		{overrides: map[string]string{"unknown": "registry.local/unknown:v1"}, wantErr: true},
+		{overrides: map[string]string{"pod": "registry.local/pause:v1"}, wantErr: true},
+		{overrides: map[string]string{"coredns": "registry.local/CoreDNS:v1"}, wantErr: true},
+		{overrides: map[string]string{"coredns": "registry.local/coredns@sha256:abc"}, wantErr: true},
+		{file: "/nonexistent/overrides.yaml", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Images.Overrides = tt.overrides
+		c.Images.OverridesFile = tt.file
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with image overrides %v and file %q error = %v, wantErr %v", tt.overrides, tt.file, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateClockJumpThreshold(t *testing.T) {
+	var ttests = []struct {
+		threshold string
+		wantErr   bool
+	}{
+		{threshold: "1m", wantErr: false},
+		{threshold: "5s", wantErr: false},
+		{threshold: "0s", wantErr: true},
+		{threshold: "-1m", wantErr: true},
+		{threshold: "60", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Clock.JumpThreshold = tt.threshold
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with clock jump threshold %q error = %v, wantErr %v", tt.threshold, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateContainerLogRotation(t *testing.T) {
+	var ttests = []struct {
+		maxSize  string
+		maxFiles int
+		wantErr  bool
+	}{
+		{maxSize: "50Mi", maxFiles: 5, wantErr: false},
+		{maxSize: "10M", maxFiles: 2, wantErr: false},
+		{maxSize: "", maxFiles: 5, wantErr: true},
+		{maxSize: "10 MB", maxFiles: 5, wantErr: true},
+		{maxSize: "0", maxFiles: 5, wantErr: true},
+		{maxSize: "-10Mi", maxFiles: 5, wantErr: true},
+		{maxSize: "50Mi", maxFiles: 1, wantErr: true},
+		{maxSize: "50Mi", maxFiles: 0, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.ContainerLogMaxSize = tt.maxSize
+		c.Node.ContainerLogMaxFiles = tt.maxFiles
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with container log max size %q and max files %d error = %v, wantErr %v", tt.maxSize, tt.maxFiles, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateManifestsApplyRetries(t *testing.T) {
+	var ttests = []struct {
+		retries int
+		backoff string
+		wantErr bool
+	}{
+		{retries: 6, backoff: "2s", wantErr: false},
+		{retries: 0, backoff: "2s", wantErr: false},
+		{retries: -1, backoff: "2s", wantErr: true},
+		{retries: 6, backoff: "0s", wantErr: true},
+		{retries: 6, backoff: "", wantErr: true},
+		{retries: 6, backoff: "2", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Manifests.ApplyRetries = tt.retries
+		c.Manifests.ApplyBackoff = tt.backoff
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %d apply retries and backoff %q error = %v, wantErr %v", tt.retries, tt.backoff, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateManifestsApplyMode(t *testing.T) {
+	var ttests = []struct {
+		mode    string
+		wantErr bool
+	}{
+		{mode: "server", wantErr: false},
+		{mode: "client", wantErr: false},
+		{mode: "", wantErr: true},
+		{mode: "Server", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Manifests.ApplyMode = tt.mode
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with apply mode %q error = %v, wantErr %v", tt.mode, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateManifestsSelection(t *testing.T) {
+	var ttests = []struct {
+		selector string
+		include  []string
+		exclude  []string
+		wantErr  bool
+	}{
+		{selector: "", wantErr: false},
+		{selector: "site=edge-1,!canary", include: []string{"busybox-*"}, exclude: []string{"*-debug", "test-[0-9]"}, wantErr: false},
+		{selector: "site in (edge-1, edge-2)", wantErr: false},
+		{selector: "site=", wantErr: false},
+		{selector: "site==edge=1", wantErr: true},
+		{selector: "site in edge-1", wantErr: true},
+		{include: []string{"busybox-["}, wantErr: true},
+		{exclude: []string{"[a-"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Manifests.Selector = tt.selector
+		c.Manifests.Include = tt.include
+		c.Manifests.Exclude = tt.exclude
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with selector %q, include %v and exclude %v error = %v, wantErr %v", tt.selector, tt.include, tt.exclude, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateManifestsWorkDir(t *testing.T) {
+	sources := []string{"/usr/lib/microshift/manifests", "/etc/microshift/manifests"}
+	var ttests = []struct {
+		workDir string
+		wantErr bool
+	}{
+		{workDir: "", wantErr: false},
+		{workDir: "/var/lib/microshift/manifests-work", wantErr: false},
+		{workDir: "/etc/microshift/manifests-work", wantErr: false},
+		{workDir: "manifests-work", wantErr: true},
+		{workDir: "/etc/microshift/manifests", wantErr: true},
+		{workDir: "/etc/microshift/manifests/work", wantErr: true},
+		{workDir: "/etc/microshift", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		if err := validateManifestsWorkDir(tt.workDir, sources); (err != nil) != tt.wantErr {
+			t.Errorf("validateManifestsWorkDir(%q) error = %v, wantErr %v", tt.workDir, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateResourceManagers(t *testing.T) {
+	reserved := map[string][]string{"reserved-cpus": {"0"}}
+	var ttests = []struct {
+		cpuManagerPolicy      string
+		topologyManagerPolicy string
+		extraArgs             map[string][]string
+		wantErr               bool
+	}{
+		{cpuManagerPolicy: "none", topologyManagerPolicy: "none", wantErr: false},
+		{cpuManagerPolicy: "static", topologyManagerPolicy: "single-numa-node", extraArgs: reserved, wantErr: false},
+		{cpuManagerPolicy: "static", topologyManagerPolicy: "best-effort", extraArgs: map[string][]string{"kube-reserved": {"cpu=500m"}}, wantErr: false},
+		{cpuManagerPolicy: "none", topologyManagerPolicy: "restricted", wantErr: false},
+		{cpuManagerPolicy: "static", topologyManagerPolicy: "none", wantErr: true},
+		{cpuManagerPolicy: "dynamic", topologyManagerPolicy: "none", extraArgs: reserved, wantErr: true},
+		{cpuManagerPolicy: "", topologyManagerPolicy: "none", wantErr: true},
+		{cpuManagerPolicy: "none", topologyManagerPolicy: "numa", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.CPUManagerPolicy = tt.cpuManagerPolicy
+		c.Node.TopologyManagerPolicy = tt.topologyManagerPolicy
+		c.Node.ExtraArgs = tt.extraArgs
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with cpu manager policy %q, topology manager policy %q and args %v error = %v, wantErr %v",
+				tt.cpuManagerPolicy, tt.topologyManagerPolicy, tt.extraArgs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSwapBehavior(t *testing.T) {
+	var ttests = []struct {
+		swapBehavior string
+		extraArgs    map[string][]string
+		wantErr      bool
+	}{
+		{swapBehavior: "off", wantErr: false},
+		{swapBehavior: "LimitedSwap", wantErr: false},
+		{swapBehavior: "UnlimitedSwap", wantErr: false},
+		{swapBehavior: "off", extraArgs: map[string][]string{"fail-swap-on": {"true"}}, wantErr: false},
+		{swapBehavior: "LimitedSwap", extraArgs: map[string][]string{"fail-swap-on": {"true"}}, wantErr: true},
+		{swapBehavior: "limitedswap", wantErr: true},
+		{swapBehavior: "", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.SwapBehavior = tt.swapBehavior
+		c.Node.ExtraArgs = tt.extraArgs
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with swap behavior %q and args %v error = %v, wantErr %v", tt.swapBehavior, tt.extraArgs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSysctls(t *testing.T) {
+	var ttests = []struct {
+		sysctls map[string]string
+		wantErr bool
+	}{
+		{sysctls: nil, wantErr: false},
+		{sysctls: map[string]string{"vm.max_map_count": "262144"}, wantErr: false},
+		{sysctls: map[string]string{"net.ipv4.ip_local_port_range": "32768 60999"}, wantErr: false},
+		{sysctls: map[string]string{"net.netfilter.nf_conntrack_max": "32768", "net.netfilter.nf_conntrack_tcp_timeout_established": "86400"}, wantErr: false},
+		{sysctls: map[string]string{"net.ipv4.ip_forward": "1"}, wantErr: false},
+		{sysctls: map[string]string{"net.ipv4.ip_forward": "0"}, wantErr: true},
+		{sysctls: map[string]string{"dev.cdrom.autoclose": "0"}, wantErr: true},
+		{sysctls: map[string]string{"vm": "1"}, wantErr: true},
+		{sysctls: map[string]string{"vm/max_map_count": "1"}, wantErr: true},
+		{sysctls: map[string]string{"vm.max_map_count": ""}, wantErr: true},
+		{sysctls: map[string]string{"vm.max_map_count": "1\n2"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.Sysctls = tt.sysctls
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with sysctls %v error = %v, wantErr %v", tt.sysctls, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateServingCertSANs(t *testing.T) {
+	var ttests = []struct {
+		sans    []string
+		wantErr bool
+	}{
+		{sans: nil, wantErr: false},
+		{sans: []string{"edge-node-1.example.com", "192.168.1.10", "fd00::10"}, wantErr: false},
+		{sans: []string{"Edge_Node"}, wantErr: true},
+		{sans: []string{"*.example.com"}, wantErr: true},
+		{sans: []string{"192.168.1.10/24"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.ServingCertSANs = tt.sans
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with node serving certificate SANs %v error = %v, wantErr %v", tt.sans, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateDefaultNetworkPolicy(t *testing.T) {
+	var ttests = []struct {
+		policy  string
+		wantErr bool
+	}{
+		{policy: "none", wantErr: false},
+		{policy: "deny-all-ingress", wantErr: false},
+		{policy: "deny-all", wantErr: false},
+		{policy: "", wantErr: true},
+		{policy: "deny-all-egress", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Cluster.DefaultNetworkPolicy = tt.policy
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with default network policy %q error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateIngressDomain(t *testing.T) {
+	var ttests = []struct {
+		domain  string
+		wantErr bool
+	}{
+		{domain: "", wantErr: false},
+		{domain: "apps.example.com", wantErr: false},
+		{domain: "apps.edge-node-1", wantErr: false},
+		{domain: "*.apps.example.com", wantErr: true},
+		{domain: "Apps.Example.com", wantErr: true},
+		{domain: "apps..example.com", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Cluster.IngressDomain = tt.domain
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with ingress domain %q error = %v, wantErr %v", tt.domain, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateIPFamilies(t *testing.T) {
+	var ttests = []struct {
+		policy      string
+		primary     string
+		clusterCIDR string
+		serviceCIDR string
+		wantErr     bool
+	}{
+		{policy: "SingleStack", clusterCIDR: "10.42.0.0/16", serviceCIDR: "10.43.0.0/16", wantErr: false},
+		{policy: "SingleStack", primary: "IPv6", clusterCIDR: "fd01::/48", serviceCIDR: "fd02::/112", wantErr: false},
+		{policy: "SingleStack", primary: "IPv6", clusterCIDR: "10.42.0.0/16", serviceCIDR: "10.43.0.0/16", wantErr: true},
+		{policy: "SingleStack", clusterCIDR: "10.42.0.0/16", serviceCIDR: "fd02::/112", wantErr: true},
+		{policy: "SingleStack", clusterCIDR: "10.42.0.0/16,fd01::/48", serviceCIDR: "10.43.0.0/16", wantErr: true},
+		{policy: "DualStack", clusterCIDR: "10.42.0.0/16,fd01::/48", serviceCIDR: "fd02::/112, 10.43.0.0/16", wantErr: false},
+		{policy: "DualStack", primary: "IPv6", clusterCIDR: "10.42.0.0/16,fd01::/48", serviceCIDR: "10.43.0.0/16,fd02::/112", wantErr: false},
+		{policy: "DualStack", clusterCIDR: "10.42.0.0/16", serviceCIDR: "10.43.0.0/16,fd02::/112", wantErr: true},
+		{policy: "DualStack", clusterCIDR: "10.42.0.0/16,10.44.0.0/16", serviceCIDR: "10.43.0.0/16,fd02::/112", wantErr: true},
+		{policy: "DualStack", clusterCIDR: "10.42.0.0/16,fd01::", serviceCIDR: "10.43.0.0/16,fd02::/112", wantErr: true},
+		{policy: "DualStack", primary: "ipv6", clusterCIDR: "10.42.0.0/16,fd01::/48", serviceCIDR: "10.43.0.0/16,fd02::/112", wantErr: true},
+		{policy: "PreferDualStack", clusterCIDR: "10.42.0.0/16", serviceCIDR: "10.43.0.0/16", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Cluster.IPFamilyPolicy = tt.policy
+		c.Cluster.PrimaryIPFamily = tt.primary
+		c.Cluster.ClusterCIDR = tt.clusterCIDR
+		c.Cluster.ServiceCIDR = tt.serviceCIDR
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %s %s %q and %q error = %v, wantErr %v", tt.policy, tt.primary, tt.clusterCIDR, tt.serviceCIDR, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCIDROrdering(t *testing.T) {
+	var ttests = []struct {
+		primary  string
+		cidrs    string
+		expected []string
+	}{
+		{primary: "", cidrs: "10.43.0.0/16", expected: []string{"10.43.0.0/16"}},
+		{primary: "", cidrs: "fd02::/112,10.43.0.0/16", expected: []string{"fd02::/112", "10.43.0.0/16"}},
+		{primary: "IPv4", cidrs: "fd02::/112, 10.43.0.0/16", expected: []string{"10.43.0.0/16", "fd02::/112"}},
+		{primary: "IPv6", cidrs: "10.43.0.0/16,fd02::/112", expected: []string{"fd02::/112", "10.43.0.0/16"}},
+		{primary: "IPv6", cidrs: "fd02::/112,10.43.0.0/16", expected: []string{"fd02::/112", "10.43.0.0/16"}},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Cluster.PrimaryIPFamily = tt.primary
+		c.Cluster.ClusterCIDR = tt.cidrs
+		c.Cluster.ServiceCIDR = tt.cidrs
+		if got := c.ServiceCIDRs(); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ServiceCIDRs() with primary family %q and %q = %v, want %v", tt.primary, tt.cidrs, got, tt.expected)
+		}
+		if got := c.ClusterCIDRs(); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ClusterCIDRs() with primary family %q and %q = %v, want %v", tt.primary, tt.cidrs, got, tt.expected)
+		}
+	}
+}
+
+func TestValidateNamespaceMetadata(t *testing.T) {
+	var ttests = []struct {
+		labels      map[string]string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{wantErr: false},
+		{labels: map[string]string{"pod-security.kubernetes.io/enforce": "restricted", "site": ""}, wantErr: false},
+		{annotations: map[string]string{"example.com/Owner": "Platform team, see https://example.com"}, wantErr: false},
+		{labels: map[string]string{"example.com/": "a"}, wantErr: true},
+		{labels: map[string]string{"-site": "a"}, wantErr: true},
+		{labels: map[string]string{"site": "edge 1"}, wantErr: true},
+		{annotations: map[string]string{"example.com/owner/team": "a"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Namespaces.Labels = tt.labels
+		c.Namespaces.Annotations = tt.annotations
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with namespace labels %v and annotations %v error = %v, wantErr %v", tt.labels, tt.annotations, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateClusterSigningDuration(t *testing.T) {
+	var ttests = []struct {
+		duration string
+		wantErr  bool
+	}{
+		{duration: "", wantErr: false},
+		{duration: "1h", wantErr: false},
+		{duration: "168h", wantErr: false},
+		{duration: "720h", wantErr: false},
+		{duration: "59m", wantErr: true},
+		{duration: "721h", wantErr: true},
+		{duration: "-24h", wantErr: true},
+		{duration: "7d", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.ControllerManager.ClusterSigningDuration = tt.duration
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with cluster signing duration %q error = %v, wantErr %v", tt.duration, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateTokenCleaner(t *testing.T) {
+	var ttests = []struct {
+		bootstrapTokenAuth bool
+		tokenCleaner       bool
+		wantErr            bool
+	}{
+		{bootstrapTokenAuth: false, tokenCleaner: false, wantErr: false},
+		{bootstrapTokenAuth: true, tokenCleaner: false, wantErr: false},
+		{bootstrapTokenAuth: true, tokenCleaner: true, wantErr: false},
+		{bootstrapTokenAuth: false, tokenCleaner: true, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.BootstrapTokenAuth = tt.bootstrapTokenAuth
+		c.ControllerManager.TokenCleaner = tt.tokenCleaner
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with bootstrapTokenAuth=%v tokenCleaner=%v error = %v, wantErr %v", tt.bootstrapTokenAuth, tt.tokenCleaner, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveNodeIP(t *testing.T) {
+	defer func(f func(string, string) (string, error)) { getInterfaceIP = f }(getInterfaceIP)
+	getInterfaceIP = func(name, preferred string) (string, error) {
+		if name != "mgmt0" {
+			return "", fmt.Errorf("route ip+net: no such network interface")
+		}
+		return "192.168.1.10", nil
+	}
+
+	var ttests = []struct {
+		iface   string
+		want    string
+		wantErr bool
+	}{
+		{iface: "", want: "10.0.0.5"},
+		{iface: "mgmt0", want: "192.168.1.10"},
+		{iface: "eth9", want: "10.0.0.5", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.NodeIP = "10.0.0.5"
+		c.Node.NodeIPInterface = tt.iface
+		if err := c.resolveNodeIP(); (err != nil) != tt.wantErr {
+			t.Errorf("resolveNodeIP() with interface %q error = %v, wantErr %v", tt.iface, err, tt.wantErr)
+		}
+		if c.NodeIP != tt.want {
+			t.Errorf("expected node IP %q with interface %q, got %q", tt.want, tt.iface, c.NodeIP)
+		}
+	}
+}
+
+func TestDefaultResourceQuota(t *testing.T) {
+	dir := t.TempDir()
+	quotaFile := filepath.Join(dir, "quota.yaml")
+	if err := os.WriteFile(quotaFile, []byte("apiVersion: v1\nkind: ResourceQuota\nmetadata:\n  name: edge\nspec:\n  hard:\n    pods: \"10\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	limitsFile := filepath.Join(dir, "limits.yaml")
+	if err := os.WriteFile(limitsFile, []byte("apiVersion: v1\nkind: LimitRange\nspec:\n  limits:\n  - type: Container\n    max:\n      cpu: \"1\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var ttests = []struct {
+		name     string
+		quota    DefaultResourceQuotaConfig
+		expected string
+		wantErr  bool
+	}{
+		{name: "none", quota: DefaultResourceQuotaConfig{}, expected: ""},
+		{name: "inline", quota: DefaultResourceQuotaConfig{Hard: map[string]string{"pods": "20", "requests.cpu": "2"}}, expected: "pods=20,requests.cpu=2"},
+		{name: "file", quota: DefaultResourceQuotaConfig{File: quotaFile}, expected: "pods=10"},
+		{name: "inline and file", quota: DefaultResourceQuotaConfig{Hard: map[string]string{"pods": "20"}, File: quotaFile}, wantErr: true},
+		{name: "invalid quantity", quota: DefaultResourceQuotaConfig{Hard: map[string]string{"pods": "twenty"}}, wantErr: true},
+		{name: "negative quantity", quota: DefaultResourceQuotaConfig{Hard: map[string]string{"pods": "-1"}}, wantErr: true},
+		{name: "file of another kind", quota: DefaultResourceQuotaConfig{File: limitsFile}, wantErr: true},
+		{name: "missing file", quota: DefaultResourceQuotaConfig{File: filepath.Join(dir, "missing.yaml")}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Cluster.DefaultResourceQuota = tt.quota
+		spec, err := c.DefaultResourceQuota()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: DefaultResourceQuota() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if c.Cluster.HasDefaultResources() != (tt.name != "none") {
+			t.Errorf("%s: expected HasDefaultResources() to be %v", tt.name, tt.name != "none")
+		}
+		if tt.wantErr {
+			continue
+		}
+		got := ""
+		if spec != nil {
+			items := []string{}
+			for name, q := range spec.Hard {
+				items = append(items, string(name)+"="+q.String())
+			}
+			sort.Strings(items)
+			got = strings.Join(items, ",")
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected the quota %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestDefaultLimitRange(t *testing.T) {
+	limitsFile := filepath.Join(t.TempDir(), "limits.yaml")
+	if err := os.WriteFile(limitsFile, []byte("apiVersion: v1\nkind: LimitRange\nspec:\n  limits:\n  - type: Pod\n    max:\n      memory: 1Gi\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewMicroshiftConfig()
+	if spec, err := c.DefaultLimitRange(); err != nil || spec != nil {
+		t.Errorf("expected no LimitRange by default, got %v, %v", spec, err)
+	}
+
+	c.Cluster.DefaultLimitRange = DefaultLimitRangeConfig{
+		Default:        map[string]string{"cpu": "500m", "memory": "256Mi"},
+		DefaultRequest: map[string]string{"cpu": "100m"},
+		Max:            map[string]string{"memory": "1Gi"},
+	}
+	spec, err := c.DefaultLimitRange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Limits) != 1 || spec.Limits[0].Type != "Container" {
+		t.Fatalf("expected the limits of containers, got %v", spec.Limits)
+	}
+	item := spec.Limits[0]
+	if cpu := item.Default["cpu"]; cpu.String() != "500m" || len(item.Default) != 2 {
+		t.Errorf("expected the default limits, got %v", item.Default)
+	}
+	if cpu := item.DefaultRequest["cpu"]; cpu.String() != "100m" || len(item.DefaultRequest) != 1 {
+		t.Errorf("expected the default requests, got %v", item.DefaultRequest)
+	}
+	if memory := item.Max["memory"]; memory.String() != "1Gi" || len(item.Max) != 1 {
+		t.Errorf("expected the max limits, got %v", item.Max)
+	}
+
+	c.Cluster.DefaultLimitRange = DefaultLimitRangeConfig{File: limitsFile}
+	if spec, err := c.DefaultLimitRange(); err != nil || len(spec.Limits) != 1 || spec.Limits[0].Type != "Pod" {
+		t.Errorf("expected the limits of the file, got %v, %v", spec, err)
+	}
+
+	for _, limits := range []DefaultLimitRangeConfig{
+		{Max: map[string]string{"memory": "1Gi"}, File: limitsFile},
+		{DefaultRequest: map[string]string{"cpu": "a lot"}},
+	} {
+		c.Cluster.DefaultLimitRange = limits
+		if err := c.validate(); err == nil {
+			t.Errorf("expected validate() to fail with %+v", limits)
+		}
+	}
+}
+
+func TestValidateDisabledWatchCaches(t *testing.T) {
+	var ttests = []struct {
+		watchCache bool
+		disabled   []string
+		wantErr    bool
+	}{
+		{watchCache: true, disabled: nil, wantErr: false},
+		{watchCache: false, disabled: nil, wantErr: false},
+		{watchCache: true, disabled: []string{"secrets", "deployments.apps", "events"}, wantErr: false},
+		{watchCache: true, disabled: []string{"routes.route.openshift.io"}, wantErr: false},
+		{watchCache: false, disabled: []string{"secrets"}, wantErr: true},
+		{watchCache: true, disabled: []string{"Secrets"}, wantErr: true},
+		{watchCache: true, disabled: []string{"secrets#0"}, wantErr: true},
+		{watchCache: true, disabled: []string{"deployments.apps_"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.WatchCache = tt.watchCache
+		c.APIServer.DisabledWatchCaches = tt.disabled
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with watch cache %v and disabled caches %v error = %v, wantErr %v", tt.watchCache, tt.disabled, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateAPIServerTunables(t *testing.T) {
+	var ttests = []struct {
+		deleteCollectionWorkers int
+		wantErr                 bool
+	}{
+		{deleteCollectionWorkers: 1, wantErr: false},
+		{deleteCollectionWorkers: 0, wantErr: false},
+		{deleteCollectionWorkers: 4, wantErr: false},
+		{deleteCollectionWorkers: -1, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.DeleteCollectionWorkers = tt.deleteCollectionWorkers
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %d delete collection workers error = %v, wantErr %v", tt.deleteCollectionWorkers, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePriorityClasses(t *testing.T) {
+	var ttests = []struct {
+		name    string
+		classes []PriorityClass
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", []PriorityClass{
+			{Name: "workload-default", Value: 1000, GlobalDefault: true},
+			{Name: "best-effort", Value: -10, PreemptionPolicy: PreemptionPolicyNever},
+			{Name: "critical-app", Value: 1000000000, PreemptionPolicy: PreemptionPolicyPreemptLowerPriority},
+		}, false},
+		{"invalid name", []PriorityClass{{Name: "Workload", Value: 1000}}, true},
+		{"reserved name", []PriorityClass{{Name: "system-workload", Value: 1000}}, true},
+		{"duplicate name", []PriorityClass{{Name: "workload", Value: 1000}, {Name: "workload", Value: 2000}}, true},
+		{"reserved value", []PriorityClass{{Name: "workload", Value: 1000000001}}, true},
+		{"invalid preemption policy", []PriorityClass{{Name: "workload", Value: 1000, PreemptionPolicy: "never"}}, true},
+		{"two global defaults", []PriorityClass{{Name: "low", Value: 10, GlobalDefault: true}, {Name: "high", Value: 20, GlobalDefault: true}}, true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Scheduler.PriorityClasses = tt.classes
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %s priority classes error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateDNSForwarders(t *testing.T) {
+	var ttests = []struct {
+		forwarders []string
+		wantErr    bool
+	}{
+		{forwarders: nil, wantErr: false},
+		{forwarders: []string{"192.168.1.1", "192.168.1.2:5353", "fd00::1", "[fd00::1]:53"}, wantErr: false},
+		{forwarders: []string{"dns.example.com"}, wantErr: true},
+		{forwarders: []string{"dns.example.com:53"}, wantErr: true},
+		{forwarders: []string{"192.168.1.1:0"}, wantErr: true},
+		{forwarders: []string{"192.168.1.1:dns"}, wantErr: true},
+		{forwarders: []string{"192.168.1.0/24"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Cluster.DNSForwarders = tt.forwarders
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with DNS forwarders %v error = %v, wantErr %v", tt.forwarders, err, tt.wantErr)
+		}
+	}
+}
+
+func TestServiceOptional(t *testing.T) {
+	var ttests = []struct {
+		optional []string
+		critical []string
+		service  string
+		want     bool
+		wantErr  bool
+	}{
+		{service: "kustomizer", want: true},
+		{service: "etcd", want: false},
+		{critical: []string{"kustomizer"}, service: "kustomizer", want: false},
+		{optional: []string{"version-manager"}, service: "version-manager", want: true},
+		{optional: []string{"kustomizer"}, critical: []string{"kustomizer"}, service: "kustomizer", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Services.Optional = tt.optional
+		c.Services.Critical = tt.critical
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with optional services %v and critical services %v error = %v, wantErr %v", tt.optional, tt.critical, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got := c.ServiceOptional(tt.service); got != tt.want {
+			t.Errorf("optional services %v, critical services %v: expected %s optional %v, got %v", tt.optional, tt.critical, tt.service, tt.want, got)
+		}
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	var ttests = []struct {
+		hook    Hook
+		wantErr bool
+	}{
+		{hook: Hook{Command: "/usr/bin/mount-data"}, wantErr: false},
+		{hook: Hook{Command: "/usr/bin/mount-data", Args: []string{"/dev/sdb1"}, Timeout: "30s"}, wantErr: false},
+		{hook: Hook{Command: ""}, wantErr: true},
+		{hook: Hook{Command: "/usr/bin/mount-data", Timeout: "soon"}, wantErr: true},
+		{hook: Hook{Command: "/usr/bin/mount-data", Timeout: "0s"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Hooks.PreStart = []Hook{tt.hook}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with pre-start hook %+v error = %v, wantErr %v", tt.hook, err, tt.wantErr)
+		}
+		c = NewMicroshiftConfig()
+		c.Hooks.PostStop = []Hook{tt.hook}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with post-stop hook %+v error = %v, wantErr %v", tt.hook, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateKonnectivity(t *testing.T) {
+	var ttests = []struct {
+		enabled bool
+		roles   []string
+		udsName string
+		wantErr bool
+	}{
+		{enabled: false, roles: []string{ControlPlaneRole, NodeRole}, udsName: "", wantErr: false},
+		{enabled: true, roles: []string{ControlPlaneRole, NodeRole}, udsName: "/run/konnectivity/konnectivity-server.socket", wantErr: false},
+		{enabled: true, roles: []string{ControlPlaneRole, NodeRole}, udsName: "", wantErr: true},
+		{enabled: true, roles: []string{ControlPlaneRole, NodeRole}, udsName: "konnectivity-server.socket", wantErr: true},
+		{enabled: true, roles: []string{NodeRole}, udsName: "/run/konnectivity/konnectivity-server.socket", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Roles = tt.roles
+		c.APIServer.Konnectivity.Enabled = tt.enabled
+		c.APIServer.Konnectivity.UDSName = tt.udsName
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with konnectivity enabled=%v roles %v udsName %q error = %v, wantErr %v", tt.enabled, tt.roles, tt.udsName, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRoleDataDir(t *testing.T) {
+	c := NewMicroshiftConfig()
+	c.DataDir = "/var/lib/microshift"
+	if dir := c.RoleDataDir(NodeRole); dir != c.DataDir {
+		t.Errorf("expected the flat layout by default, got %q", dir)
+	}
+
+	c.RoleDataDirs = true
+	for role, expected := range map[string]string{
+		ControlPlaneRole: "/var/lib/microshift/controlplane",
+		NodeRole:         "/var/lib/microshift/node",
+	} {
+		if dir := c.RoleDataDir(role); dir != expected {
+			t.Errorf("expected data directory %q for role %q, got %q", expected, role, dir)
+		}
+	}
+	if dir := c.ServiceAccountKeyDir(); !strings.HasPrefix(dir, "/var/lib/microshift/controlplane/") {
+		t.Errorf("expected the service account key in the control plane's data directory, got %q", dir)
+	}
+}
+
+func TestValidateExternalURL(t *testing.T) {
+	var ttests = []struct {
+		externalURL string
+		wantErr     bool
+	}{
+		{externalURL: "", wantErr: false},
+		{externalURL: "https://api.example.com", wantErr: false},
+		{externalURL: "https://api.example.com:8443/", wantErr: false},
+		{externalURL: "http://api.example.com", wantErr: true},
+		{externalURL: "https://", wantErr: true},
+		{externalURL: "api.example.com", wantErr: true},
+		{externalURL: "https://api.example.com:port", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.ExternalURL = tt.externalURL
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with externalURL %q error = %v, wantErr %v", tt.externalURL, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateMDNSHostname(t *testing.T) {
+	var ttests = []struct {
+		hostname string
+		wantErr  bool
+	}{
+		{hostname: "", wantErr: false},
+		{hostname: "edge-gateway", wantErr: false},
+		{hostname: "edge-gateway.local", wantErr: false},
+		{hostname: "site1.edge-gateway.local", wantErr: false},
+		{hostname: "edge-gateway.example.com", wantErr: true},
+		{hostname: "Edge_Gateway", wantErr: true},
+		{hostname: "-edge.local", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.MDNS.Hostname = tt.hostname
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with mdns hostname %q error = %v, wantErr %v", tt.hostname, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateAuditLogMaxTotalSize(t *testing.T) {
+	var ttests = []struct {
+		maxTotalSizeMB int
+		wantErr        bool
+	}{
+		{maxTotalSizeMB: 512, wantErr: false},
+		{maxTotalSizeMB: 1, wantErr: false},
+		{maxTotalSizeMB: 0, wantErr: true},
+		{maxTotalSizeMB: -1, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.AuditLogMaxTotalSizeMB = tt.maxTotalSizeMB
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with audit log cap %d error = %v, wantErr %v", tt.maxTotalSizeMB, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateGoawayChance(t *testing.T) {
+	var ttests = []struct {
+		chance  float64
+		wantErr bool
+	}{
+		{chance: 0, wantErr: false},
+		{chance: 0.001, wantErr: false},
+		{chance: 0.02, wantErr: false},
+		{chance: 0.021, wantErr: true},
+		{chance: 1, wantErr: true},
+		{chance: -0.001, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.GoawayChance = tt.chance
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with goaway chance %v error = %v, wantErr %v", tt.chance, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateAPIServerSocket(t *testing.T) {
+	var ttests = []struct {
+		socket  string
+		wantErr bool
+	}{
+		{socket: "", wantErr: false},
+		{socket: "/run/microshift/apiserver.sock", wantErr: false},
+		{socket: "apiserver.sock", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.Socket = tt.socket
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with socket %q error = %v, wantErr %v", tt.socket, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateStorageClassName(t *testing.T) {
+	var ttests = []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "topolvm-provisioner", wantErr: false},
+		{name: "local.storage", wantErr: false},
+		{name: "", wantErr: true},
+		{name: "Local_Storage", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Storage.ClassName = tt.name
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with storage class %q error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateServiceAccountIssuer(t *testing.T) {
+	var ttests = []struct {
+		issuer  string
+		wantErr bool
+	}{
+		{issuer: "https://kubernetes.default.svc", wantErr: false},
+		{issuer: "https://oidc.example.com/microshift", wantErr: false},
+		{issuer: "", wantErr: true},
+		{issuer: "http://oidc.example.com", wantErr: true},
+		{issuer: "https://oidc.example.com?cluster=edge", wantErr: true},
+		{issuer: "microshift", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.ServiceAccountIssuer = tt.issuer
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with service account issuer %q error = %v, wantErr %v", tt.issuer, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateServiceAccountSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	keyPEM, err := keyutil.MakeEllipticPrivateKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validKey := filepath.Join(dir, "sa.key")
+	if err := os.WriteFile(validKey, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalidKey := filepath.Join(dir, "invalid.key")
+	if err := os.WriteFile(invalidKey, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var ttests = []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "generated", path: "", wantErr: false},
+		{name: "valid key", path: validKey, wantErr: false},
+		{name: "relative path", path: "sa.key", wantErr: true},
+		{name: "missing file", path: filepath.Join(dir, "missing.key"), wantErr: true},
+		{name: "not a key", path: invalidKey, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.ServiceAccountSigningKeyFile = tt.path
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateStorageMediaType(t *testing.T) {
+	var ttests = []struct {
+		mediaType string
+		wantErr   bool
+	}{
+		{mediaType: StorageMediaTypeProtobuf, wantErr: false},
+		{mediaType: StorageMediaTypeJSON, wantErr: false},
+		{mediaType: StorageMediaTypeYAML, wantErr: false},
+		{mediaType: "json", wantErr: true},
+		{mediaType: "", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.StorageMediaType = tt.mediaType
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with storage media type %q error = %v, wantErr %v", tt.mediaType, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateLeaderElection(t *testing.T) {
+	var ttests = []struct {
+		enabled       bool
+		leaseDuration string
+		renewDeadline string
+		retryPeriod   string
+		wantErr       bool
+	}{
+		{enabled: true, leaseDuration: "15s", renewDeadline: "10s", retryPeriod: "2s", wantErr: false},
+		{enabled: true, leaseDuration: "60s", renewDeadline: "40s", retryPeriod: "10s", wantErr: false},
+		{enabled: true, leaseDuration: "10s", renewDeadline: "10s", retryPeriod: "2s", wantErr: true},
+		{enabled: true, leaseDuration: "15s", renewDeadline: "10s", retryPeriod: "10s", wantErr: true},
+		{enabled: true, leaseDuration: "0s", renewDeadline: "10s", retryPeriod: "2s", wantErr: true},
+		{enabled: true, leaseDuration: "15", renewDeadline: "10s", retryPeriod: "2s", wantErr: true},
+		{enabled: false, leaseDuration: "15", renewDeadline: "10s", retryPeriod: "2s", wantErr: false},
+	}
+
+	for _, tt := range ttests {
+		le := LeaderElectionConfig{
+			Enabled:       tt.enabled,
+			LeaseDuration: tt.leaseDuration,
+			RenewDeadline: tt.renewDeadline,
+			RetryPeriod:   tt.retryPeriod,
+		}
+		for _, set := range []func(c *MicroshiftConfig){
+			func(c *MicroshiftConfig) { c.ControllerManager.LeaderElection = le },
+			func(c *MicroshiftConfig) { c.Scheduler.LeaderElection = le },
+		} {
+			c := NewMicroshiftConfig()
+			set(c)
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() with leader election %+v error = %v, wantErr %v", le, err, tt.wantErr)
+			}
+		}
+	}
+}
+
+func TestValidateWatchdog(t *testing.T) {
+	var ttests = []struct {
+		interval         string
+		failureThreshold string
+		action           string
+		wantErr          bool
+	}{
+		{interval: "10s", failureThreshold: "2m", action: WatchdogActionExit, wantErr: false},
+		{interval: "5s", failureThreshold: "5s", action: WatchdogActionLog, wantErr: false},
+		{interval: "0s", failureThreshold: "2m", action: WatchdogActionExit, wantErr: true},
+		{interval: "ten", failureThreshold: "2m", action: WatchdogActionExit, wantErr: true},
+		{interval: "10s", failureThreshold: "5s", action: WatchdogActionExit, wantErr: true},
+		{interval: "10s", failureThreshold: "", action: WatchdogActionExit, wantErr: true},
+		{interval: "10s", failureThreshold: "2m", action: "reboot", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.Watchdog = WatchdogConfig{Enabled: true, Interval: tt.interval, FailureThreshold: tt.failureThreshold, Action: tt.action}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with watchdog %+v error = %v, wantErr %v", c.APIServer.Watchdog, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateEventTTL(t *testing.T) {
+	var ttests = []struct {
+		ttl     string
+		wantErr bool
+	}{
+		{ttl: "30m", wantErr: false},
+		{ttl: "3h", wantErr: false},
+		{ttl: "", wantErr: true},
+		{ttl: "0s", wantErr: true},
+		{ttl: "-1h", wantErr: true},
+		{ttl: "1 hour", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.EventTTL = tt.ttl
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with eventTTL %q error = %v, wantErr %v", tt.ttl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateShutdownDelayDuration(t *testing.T) {
+	var ttests = []struct {
+		delay   string
+		wantErr bool
+	}{
+		{delay: "0s", wantErr: false},
+		{delay: "15s", wantErr: false},
+		{delay: "", wantErr: true},
+		{delay: "-1s", wantErr: true},
+		{delay: "15", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.ShutdownDelayDuration = tt.delay
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with shutdownDelayDuration %q error = %v, wantErr %v", tt.delay, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateAuditLogFormat(t *testing.T) {
+	var ttests = []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: "json", wantErr: false},
+		{format: "legacy", wantErr: false},
+		{format: "", wantErr: true},
+		{format: "yaml", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.AuditLogFormat = tt.format
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with audit log format %q error = %v, wantErr %v", tt.format, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateWaitForReady(t *testing.T) {
+	var ttests = []struct {
+		ref     ResourceRef
+		wantErr bool
+	}{
+		{ref: ResourceRef{Kind: "Deployment", Namespace: "busybox", Name: "busybox"}, wantErr: false},
+		{ref: ResourceRef{Kind: "StatefulSet", Namespace: "busybox", Name: "busybox"}, wantErr: false},
+		{ref: ResourceRef{Kind: "Pod", Namespace: "busybox", Name: "busybox"}, wantErr: true},
+		{ref: ResourceRef{Kind: "DaemonSet", Name: "busybox"}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Manifests.WaitForReady = []ResourceRef{tt.ref}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %s error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePrePullImages(t *testing.T) {
+	var ttests = []struct {
+		image   string
+		wantErr bool
+	}{
+		{image: "registry.k8s.io/busybox", wantErr: false},
+		{image: "quay.io/example/app:v1", wantErr: false},
+		{image: "quay.io/example/app@sha256:4bcdec2fa4b8e8e3b1b9b1d8f2f9c5e7b3d9c1a6e9f6a7d2c5b8e1f4a7d0c3b6", wantErr: false},
+		{image: "Quay.io/Example:latest", wantErr: true},
+		{image: "", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.PrePullImages = []string{tt.image}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with image %q error = %v, wantErr %v", tt.image, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateShutdownGracePeriods(t *testing.T) {
+	var ttests = []struct {
+		total    string
+		critical string
+		wantErr  bool
+	}{
+		{total: "", critical: "", wantErr: false},
+		{total: "30s", critical: "", wantErr: false},
+		{total: "30s", critical: "10s", wantErr: false},
+		{total: "1m", critical: "1m", wantErr: false},
+		{total: "10s", critical: "30s", wantErr: true},
+		{total: "", critical: "10s", wantErr: true},
+		{total: "thirty", critical: "", wantErr: true},
+		{total: "-30s", critical: "", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.ShutdownGracePeriod = tt.total
+		c.Node.ShutdownGracePeriodCriticalPods = tt.critical
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with shutdown grace periods %q/%q error = %v, wantErr %v", tt.total, tt.critical, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateNodeStatusFrequencies(t *testing.T) {
+	var ttests = []struct {
+		update  string
+		report  string
+		wantErr bool
+	}{
+		{update: "", report: "", wantErr: false},
+		{update: "1m", report: "", wantErr: false},
+		{update: "", report: "30m", wantErr: false},
+		{update: "1m", report: "30m", wantErr: false},
+		{update: "1m", report: "1m", wantErr: false},
+		{update: "5m", report: "1m", wantErr: true},
+		{update: "0s", report: "", wantErr: true},
+		{update: "", report: "-5m", wantErr: true},
+		{update: "often", report: "", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.Node.NodeStatusUpdateFrequency = tt.update
+		c.Node.NodeStatusReportFrequency = tt.report
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with node status frequencies %q/%q error = %v, wantErr %v", tt.update, tt.report, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateEgressSelectorConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := writeFile("valid.yaml", `apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: HTTPConnect
+    transport:
+      uds:
+        udsName: /run/egress-proxy/proxy.sock
+`)
+	// GRPC requires a transport
+	invalid := writeFile("invalid.yaml", `apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: GRPC
+`)
+	wrongKind := writeFile("wrong-kind.yaml", "apiVersion: v1\nkind: ConfigMap\n")
+	notYAML := writeFile("not-yaml.yaml", "egressSelections: [")
+
+	var ttests = []struct {
+		name         string
+		file         string
+		konnectivity bool
+		wantErr      bool
+	}{
+		{name: "unset", file: "", wantErr: false},
+		{name: "valid configuration", file: valid, wantErr: false},
+		{name: "invalid configuration", file: invalid, wantErr: true},
+		{name: "wrong kind", file: wrongKind, wantErr: true},
+		{name: "not YAML", file: notYAML, wantErr: true},
+		{name: "missing file", file: filepath.Join(dir, "missing.yaml"), wantErr: true},
+		{name: "relative path", file: "egress-selector.yaml", wantErr: true},
+		{name: "konnectivity enabled", file: valid, konnectivity: true, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.EgressSelectorConfigFile = tt.file
+		c.APIServer.Konnectivity.Enabled = tt.konnectivity
+		c.APIServer.Konnectivity.UDSName = "/run/konnectivity/konnectivity-server.socket"
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateAuthorization(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	webhook := writeFile("webhook.kubeconfig", `apiVersion: v1
+kind: Config
+clusters:
+- name: authz
+  cluster:
+    server: https://authz.example.com/authorize
+users:
+- name: apiserver
+  user: {}
+contexts:
+- name: webhook
+  context:
+    cluster: authz
+    user: apiserver
+current-context: webhook
+`)
+	// the current context names a missing cluster
+	unusable := writeFile("unusable.kubeconfig", `apiVersion: v1
+kind: Config
+contexts:
+- name: webhook
+  context:
+    cluster: authz
+current-context: webhook
+`)
+	notYAML := writeFile("not-yaml.kubeconfig", "clusters: [")
+
+	withWebhook := []string{"Node", "RBAC", "Webhook"}
+	var ttests = []struct {
+		name    string
+		modes   []string
+		file    string
+		wantErr bool
+	}{
+		{name: "defaults", modes: []string{"Scope", "SystemMasters", "RBAC", "Node"}, wantErr: false},
+		{name: "webhook", modes: withWebhook, file: webhook, wantErr: false},
+		{name: "webhook first", modes: []string{"Webhook", "Node", "RBAC"}, file: webhook, wantErr: false},
+		{name: "unknown mode", modes: []string{"RBAC", "AlwaysAllow"}, wantErr: true},
+		{name: "duplicate mode", modes: []string{"RBAC", "Node", "RBAC"}, wantErr: true},
+		{name: "without RBAC", modes: []string{"Node"}, wantErr: true},
+		{name: "webhook without file", modes: withWebhook, wantErr: true},
+		{name: "file without webhook", modes: []string{"Node", "RBAC"}, file: webhook, wantErr: true},
+		{name: "unusable file", modes: withWebhook, file: unusable, wantErr: true},
+		{name: "not YAML", modes: withWebhook, file: notYAML, wantErr: true},
+		{name: "missing file", modes: withWebhook, file: filepath.Join(dir, "missing.kubeconfig"), wantErr: true},
+		{name: "relative path", modes: withWebhook, file: "webhook.kubeconfig", wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.AuthorizationModes = tt.modes
+		c.APIServer.AuthorizationWebhookConfigFile = tt.file
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSNICerts(t *testing.T) {
+	dir := t.TempDir()
+	writePair := func(name string) (string, string) {
+		certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey(name, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to generate certificate: %v", err)
+		}
+		certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+		if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return certFile, keyFile
+	}
+	apiCert, apiKey := writePair("api.example.com")
+	_, otherKey := writePair("other.example.com")
+
+	var ttests = []struct {
+		name    string
+		sniCert SNICert
+		wantErr bool
+	}{
+		{name: "extracted names", sniCert: SNICert{CertFile: apiCert, KeyFile: apiKey}, wantErr: false},
+		{name: "explicit names", sniCert: SNICert{CertFile: apiCert, KeyFile: apiKey, Names: []string{"api.example.com", "*.example.com", "192.168.1.10"}}, wantErr: false},
+		{name: "mismatched key", sniCert: SNICert{CertFile: apiCert, KeyFile: otherKey}, wantErr: true},
+		{name: "missing cert", sniCert: SNICert{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: apiKey}, wantErr: true},
+		{name: "missing key file", sniCert: SNICert{CertFile: apiCert}, wantErr: true},
+		{name: "relative paths", sniCert: SNICert{CertFile: "api.crt", KeyFile: "api.key"}, wantErr: true},
+		{name: "invalid name", sniCert: SNICert{CertFile: apiCert, KeyFile: apiKey, Names: []string{"api_example.com"}}, wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.APIServer.SNICerts = []SNICert{tt.sniCert}
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// test that the additional trust bundle must contain valid PEM certificates
+func TestValidateAdditionalTrustBundle(t *testing.T) {
+	certPEM, _, err := cert.GenerateSelfSignedCertKey("test-ca", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to generate certificate: %v", err)
+	}
+
+	dir := t.TempDir()
+	validBundle := filepath.Join(dir, "valid.crt")
+	invalidBundle := filepath.Join(dir, "invalid.crt")
+	if err := os.WriteFile(validBundle, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(invalidBundle, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var ttests = []struct {
+		bundle  string
+		wantErr bool
+	}{
+		{bundle: "", wantErr: false},
+		{bundle: validBundle, wantErr: false},
+		{bundle: invalidBundle, wantErr: true},
+		{bundle: filepath.Join(dir, "missing.crt"), wantErr: true},
+	}
+
+	for _, tt := range ttests {
+		c := NewMicroshiftConfig()
+		c.AdditionalTrustBundle = tt.bundle
+		if err := c.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with trust bundle %q error = %v, wantErr %v", tt.bundle, err, tt.wantErr)
+		}
+	}
+}
+
 // tests that the global flags have been initialized
 func TestHideUnsupportedFlags(t *testing.T) {
 	flags := pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// Profiles select a curated set of roles and components, together with defaults tuned
+// for the kind of deployment.
+const (
+	// ProfileFull runs both roles with all components and the regular defaults
+	ProfileFull = "full"
+	// ProfileEdgeMinimal runs both roles without optional components, tuned for
+	// devices with little memory and storage
+	ProfileEdgeMinimal = "edge-minimal"
+	// ProfileWorker only runs the node role, to join a separately run control plane
+	ProfileWorker = "worker"
+)
+
+var profiles = map[string]func(c *MicroshiftConfig){
+	ProfileFull: func(c *MicroshiftConfig) {
+		c.Roles = []string{ControlPlaneRole, NodeRole}
+	},
+	ProfileEdgeMinimal: func(c *MicroshiftConfig) {
+		c.Roles = []string{ControlPlaneRole, NodeRole}
+		c.Components.Disabled = []string{ComponentStorage}
+		c.APIServer.EventTTL = "10m"
+		c.Node.MaxPods = 50
+	},
+	ProfileWorker: func(c *MicroshiftConfig) {
+		c.Roles = []string{NodeRole}
+	},
+}
+
+// ProfileNames returns the names of the predefined profiles.
+func ProfileNames() []string {
+	return []string{ProfileFull, ProfileEdgeMinimal, ProfileWorker}
+}
+
+// applyProfile sets the defaults of the named profile.
+func (c *MicroshiftConfig) applyProfile(name string) error {
+	apply, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, valid profiles are %v", name, ProfileNames())
+	}
+	c.Profile = name
+	apply(c)
+	return nil
+}
+
+// readProfile returns the profile selected on the command line, in the environment or
+// in the contents of the config file, in that order of precedence.
+func readProfile(configFile string, contents []byte, flags *pflag.FlagSet) (string, error) {
+	if flags != nil {
+		if s, err := flags.GetString("profile"); err == nil && flags.Changed("profile") {
+			return s, nil
+		}
+	}
+	if s, ok := os.LookupEnv("MICROSHIFT_PROFILE"); ok {
+		return s, nil
+	}
+
+	selected := struct {
+		Profile string `json:"profile"`
+	}{}
+	if err := yaml.Unmarshal(contents, &selected); err != nil {
+		return "", fmt.Errorf("decoding config file %s: %v", configFile, err)
+	}
+	return selected.Profile, nil
+}
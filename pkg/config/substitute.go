@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// references are ${NAME} for the value of an environment variable and ${file:PATH}
+// for the contents of a file. $${ escapes a literal ${, e.g. for hook arguments.
+var referencePattern = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// substituteReferences replaces the references in all string values of the config,
+// so that secrets can be injected without embedding them in the config file.
+func (c *MicroshiftConfig) substituteReferences() error {
+	return substituteValue(reflect.ValueOf(c).Elem(), "")
+}
+
+// Unsubstituted returns the config as it was before the references were substituted,
+// e.g. to report it without the secrets they referenced.
+func (c *MicroshiftConfig) Unsubstituted() *MicroshiftConfig {
+	if c.unsubstituted == nil {
+		return c
+	}
+	return c.unsubstituted
+}
+
+// deepCopy copies the values of the config, which don't share any maps or slices with it.
+func (c *MicroshiftConfig) deepCopy() (*MicroshiftConfig, error) {
+	marshalled, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	copied := &MicroshiftConfig{}
+	if err := json.Unmarshal(marshalled, copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+func substituteValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := substituteString(v.String())
+		if err != nil {
+			return fmt.Errorf("invalid config value of %s: %v", path, err)
+		}
+		v.SetString(s)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return substituteValue(v.Elem(), path)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := substituteValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// map values aren't addressable, so they are substituted on a copy
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			if err := substituteValue(value, fmt.Sprintf("%s[%v]", path, key)); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := substituteValue(v.Field(i), name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func substituteString(s string) (string, error) {
+	var errs []string
+	substituted := referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := referencePattern.FindStringSubmatch(ref)[1]
+		if path := strings.TrimPrefix(name, "file:"); path != name {
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("reading %s: %v", ref, err))
+				return ref
+			}
+			return strings.TrimSuffix(string(contents), "\n")
+		}
+		if name == "" {
+			errs = append(errs, fmt.Sprintf("empty reference %s", ref))
+			return ref
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("environment variable %s referenced by %s is not set", name, ref))
+			return ref
+		}
+		return value
+	})
+	if len(errs) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return substituted, nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Kind is the kind of the MicroShift config file
+	Kind = "MicroShiftConfig"
+
+	// CurrentAPIVersion is the version of the config file schema implemented by MicroShiftConfig
+	CurrentAPIVersion = "microshift.openshift.io/v1beta1"
+
+	// APIVersionV1Alpha1 is the original schema. It is converted to the current version on read
+	APIVersionV1Alpha1 = "microshift.openshift.io/v1alpha1"
+)
+
+// conversions maps known, older config schema versions to a function that converts
+// a config file of that version into the next one.
+var conversions = map[string]func(map[string]interface{}) (string, error){
+	APIVersionV1Alpha1: convertV1Alpha1ToV1Beta1,
+}
+
+type typeMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// convertToCurrentVersion checks the apiVersion and kind of a config file and converts
+// its contents to CurrentAPIVersion. Files without an apiVersion are assumed to be of
+// the current version.
+func convertToCurrentVersion(contents []byte) ([]byte, error) {
+	var meta typeMeta
+	if err := yaml.Unmarshal(contents, &meta); err != nil {
+		return nil, err
+	}
+
+	if meta.Kind != "" && meta.Kind != Kind {
+		return nil, fmt.Errorf("unknown kind %q, expected %q", meta.Kind, Kind)
+	}
+
+	switch meta.APIVersion {
+	case CurrentAPIVersion:
+		return contents, nil
+	case "":
+		klog.Warningf("config file does not specify an apiVersion, assuming %q. Unversioned config files are deprecated", CurrentAPIVersion)
+		return contents, nil
+	}
+
+	if _, ok := conversions[meta.APIVersion]; !ok {
+		return nil, fmt.Errorf("unsupported apiVersion %q, this version of MicroShift supports up to %q", meta.APIVersion, CurrentAPIVersion)
+	}
+
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(contents, &obj); err != nil {
+		return nil, err
+	}
+
+	version := meta.APIVersion
+	for version != CurrentAPIVersion {
+		convert, ok := conversions[version]
+		if !ok {
+			return nil, fmt.Errorf("no conversion from apiVersion %q to %q", version, CurrentAPIVersion)
+		}
+		next, err := convert(obj)
+		if err != nil {
+			return nil, fmt.Errorf("converting from apiVersion %q: %v", version, err)
+		}
+		klog.Infof("converted config file from apiVersion %q to %q", version, next)
+		version = next
+	}
+	obj["apiVersion"] = CurrentAPIVersion
+	obj["kind"] = Kind
+
+	return json.Marshal(obj)
+}
+
+// convertV1Alpha1ToV1Beta1 converts a v1alpha1 config to v1beta1:
+//   - cluster.mtu changed from a number to a string
+//   - the manifests list of directories was removed, manifests are always loaded from the
+//     default locations. The manifests settings are the same in both versions
+func convertV1Alpha1ToV1Beta1(obj map[string]interface{}) (string, error) {
+	if cluster, ok := obj["cluster"].(map[string]interface{}); ok {
+		if mtu, ok := cluster["mtu"].(float64); ok {
+			cluster["mtu"] = fmt.Sprintf("%d", int(mtu))
+		}
+	}
+	if manifests, ok := obj["manifests"]; ok {
+		switch manifests.(type) {
+		case map[string]interface{}:
+			// kept as is
+		case []interface{}:
+			klog.Warningf("the manifests list is no longer supported and will be ignored, manifests are loaded from %v", GetManifestsDir())
+			delete(obj, "manifests")
+		default:
+			return "", fmt.Errorf("invalid manifests %v, must be a list of directories or the manifests settings", manifests)
+		}
+	}
+	return CurrentAPIVersion, nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+// test that a v1alpha1 config file is converted to the current schema
+func TestConvertV1Alpha1(t *testing.T) {
+	configFile := writeConfigFile(t, `
+apiVersion: microshift.openshift.io/v1alpha1
+kind: MicroShiftConfig
+nodeName: node1
+cluster:
+  mtu: 1300
+  domain: example.local
+manifests:
+- /etc/microshift/manifests
+`)
+
+	c := NewMicroshiftConfig()
+	if err := c.ReadFromConfigFile(configFile); err != nil {
+		t.Fatalf("failed to read v1alpha1 config file: %v", err)
+	}
+	if c.APIVersion != CurrentAPIVersion {
+		t.Errorf("expected apiVersion %q, got %q", CurrentAPIVersion, c.APIVersion)
+	}
+	if c.Cluster.MTU != "1300" {
+		t.Errorf("expected mtu to be converted to \"1300\", got %q", c.Cluster.MTU)
+	}
+	if c.Cluster.Domain != "example.local" || c.NodeName != "node1" {
+		t.Errorf("expected unchanged fields to be preserved, got %+v", c)
+	}
+	// fields not set in the file keep their defaults
+	if c.Cluster.ServiceCIDR != NewMicroshiftConfig().Cluster.ServiceCIDR {
+		t.Errorf("expected defaulted serviceCIDR, got %q", c.Cluster.ServiceCIDR)
+	}
+}
+
+// test that the manifests key of a v1alpha1 config file is converted
+func TestConvertV1Alpha1Manifests(t *testing.T) {
+	var tests = []struct {
+		name      string
+		manifests string
+		expected  ManifestsConfig
+		wantErr   bool
+	}{
+		{
+			name:      "list of directories",
+			manifests: "manifests:\n- /etc/microshift/manifests\n",
+			expected:  NewMicroshiftConfig().Manifests,
+		},
+		{
+			name:      "settings",
+			manifests: "manifests:\n  enabled: false\n  applyMode: server\n",
+			expected: func() ManifestsConfig {
+				m := NewMicroshiftConfig().Manifests
+				m.Enabled = false
+				m.ApplyMode = ApplyModeServer
+				return m
+			}(),
+		},
+		{
+			name:      "invalid",
+			manifests: "manifests: /etc/microshift/manifests\n",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMicroshiftConfig()
+			err := c.ReadFromConfigFile(writeConfigFile(t, "apiVersion: microshift.openshift.io/v1alpha1\nkind: MicroShiftConfig\n"+tt.manifests))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFromConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(c.Manifests, tt.expected) {
+				t.Errorf("expected manifests %+v, got %+v", tt.expected, c.Manifests)
+			}
+		})
+	}
+}
+
+func TestConfigFileVersions(t *testing.T) {
+	var ttests = []struct {
+		name     string
+		contents string
+		wantErr  bool
+	}{
+		{
+			name:     "current version",
+			contents: "apiVersion: " + CurrentAPIVersion + "\nkind: MicroShiftConfig\nnodeName: node1\n",
+		},
+		{
+			name:     "unversioned",
+			contents: "nodeName: node1\n",
+		},
+		{
+			name:     "future version",
+			contents: "apiVersion: microshift.openshift.io/v2\nkind: MicroShiftConfig\nnodeName: node1\n",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown kind",
+			contents: "apiVersion: " + CurrentAPIVersion + "\nkind: KubeletConfiguration\nnodeName: node1\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range ttests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMicroshiftConfig()
+			err := c.ReadFromConfigFile(writeConfigFile(t, tt.contents))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFromConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (c.APIVersion != CurrentAPIVersion || c.NodeName != "node1") {
+				t.Errorf("unexpected config read: %+v", c)
+			}
+		})
+	}
+}
+
+// test that the deprecation warning of an unversioned config file is logged once
+func TestConvertLoggedOnce(t *testing.T) {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("logtostderr", "false"); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Set("logtostderr", "true")
+	// write each message once, not to each level up to its severity
+	if err := fs.Set("one_output", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Set("one_output", "false")
+	var buf bytes.Buffer
+	klog.SetOutput(&buf)
+	defer klog.SetOutput(os.Stderr)
+
+	os.Unsetenv("MICROSHIFT_PROFILE")
+	c := NewMicroshiftConfig()
+	if err := c.ReadAndValidate(writeConfigFile(t, "nodeName: node1\n"), pflag.NewFlagSet("test", pflag.ContinueOnError)); err != nil {
+		t.Fatalf("ReadAndValidate() error = %v", err)
+	}
+	klog.Flush()
+	if n := strings.Count(buf.String(), "does not specify an apiVersion"); n != 1 {
+		t.Errorf("expected the deprecation warning to be logged once, got %d times:\n%s", n, buf.String())
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+const (
+	acceptMinBackoff = 5 * time.Millisecond
+	acceptMaxBackoff = time.Second
+)
+
+// APIServerSocket serves the apiserver on a unix socket, so that local admin tooling
+// can reach it without a network client. The apiserver can't listen on unix sockets,
+// so the connections are forwarded to its local TCP port. TLS is passed through and
+// terminated by the apiserver, which authenticates the clients as usual.
+type APIServerSocket struct {
+	path     string
+	upstream string
+	// configureErr is reported when running, like for the apiserver
+	configureErr error
+}
+
+func NewAPIServerSocket(cfg *config.MicroshiftConfig) *APIServerSocket {
+	s := &APIServerSocket{path: cfg.APIServer.Socket}
+	port, err := cfg.Cluster.ApiServerPort()
+	if err != nil {
+		s.configureErr = err
+	}
+	s.upstream = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	return s
+}
+
+func (s *APIServerSocket) Name() string           { return "kube-apiserver-socket" }
+func (s *APIServerSocket) Dependencies() []string { return []string{"kube-apiserver"} }
+
+func (s *APIServerSocket) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+	if s.configureErr != nil {
+		return fmt.Errorf("configuration failed: %w", s.configureErr)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	// a socket left behind by a previous instance prevents listening
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.path, err)
+	}
+	defer os.Remove(s.path)
+	if err := os.Chmod(s.path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	klog.Infof("%s forwarding %s to %s", s.Name(), s.path, s.upstream)
+	close(ready)
+
+	return s.serve(ctx, l)
+}
+
+// serve forwards the connections accepted on l until ctx is done or l is closed.
+func (s *APIServerSocket) serve(ctx context.Context, l net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// like net/http, back off on accept errors, e.g. running out of file descriptors,
+	// instead of spinning on them
+	var backoff time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			if backoff == 0 {
+				backoff = acceptMinBackoff
+			} else if backoff *= 2; backoff > acceptMaxBackoff {
+				backoff = acceptMaxBackoff
+			}
+			klog.Warningf("%s failed to accept a connection, retrying in %s: %v", s.Name(), backoff, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.forward(ctx, conn)
+		}()
+	}
+}
+
+// forward copies the traffic between the client connection and the apiserver until
+// either side closes its connection or ctx is done.
+func (s *APIServerSocket) forward(ctx context.Context, client net.Conn) {
+	defer client.Close()
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	upstream, err := dialer.DialContext(ctx, "tcp", s.upstream)
+	if err != nil {
+		klog.Warningf("%s failed to connect to %s: %v", s.Name(), s.upstream, err)
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
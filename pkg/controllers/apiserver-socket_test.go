@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// shortTempDir returns a directory for sockets, whose paths are limited to about
+// 100 bytes, which those below t.TempDir() may exceed.
+func shortTempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestNewAPIServerSocket(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.APIServer.Socket = "/run/microshift/apiserver.sock"
+	s := NewAPIServerSocket(cfg)
+
+	port, err := cfg.Cluster.ApiServerPort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.path != cfg.APIServer.Socket {
+		t.Errorf("expected the socket at %q, got %q", cfg.APIServer.Socket, s.path)
+	}
+	if want := net.JoinHostPort("127.0.0.1", strconv.Itoa(port)); s.upstream != want {
+		t.Errorf("expected the socket to forward to %q, got %q", want, s.upstream)
+	}
+}
+
+func TestAPIServerSocketForwards(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				conn.Write([]byte("echo " + line))
+			}()
+		}
+	}()
+
+	s := &APIServerSocket{
+		path:     filepath.Join(shortTempDir(t), "apiserver.sock"),
+		upstream: backend.Addr().String(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go s.Run(ctx, ready, stopped)
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket didn't become ready")
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a socket only accessible by its owner, got mode %v", info.Mode())
+	}
+
+	conn, err := net.Dial("unix", s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "echo ping\n" {
+		t.Errorf("expected the backend's reply, got %q", reply)
+	}
+}
+
+func TestAPIServerSocketRemovesStaleSocket(t *testing.T) {
+	path := filepath.Join(shortTempDir(t), "apiserver.sock")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &APIServerSocket{path: path, upstream: "127.0.0.1:1"}
+	ctx, cancel := context.WithCancel(context.Background())
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go s.Run(ctx, ready, stopped)
+	select {
+	case <-ready:
+	case <-stopped:
+		t.Fatal("expected the stale socket to be replaced")
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket didn't become ready")
+	}
+	cancel()
+	<-stopped
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed when stopping, got %v", err)
+	}
+}
+
+// failingListener fails to accept connections a number of times, then is closed.
+type failingListener struct {
+	net.Listener
+	failures int
+	accepts  []time.Time
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.accepts = append(l.accepts, time.Now())
+	if len(l.accepts) > l.failures {
+		return nil, net.ErrClosed
+	}
+	return nil, errors.New("too many open files")
+}
+
+func TestAPIServerSocketAcceptBackoff(t *testing.T) {
+	s := &APIServerSocket{path: "/run/microshift/apiserver.sock"}
+	l := &failingListener{failures: 5}
+	if err := s.serve(context.Background(), l); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected the socket to stop once the listener is closed, got %v", err)
+	}
+
+	if len(l.accepts) != l.failures+1 {
+		t.Fatalf("expected %d accepts, got %d", l.failures+1, len(l.accepts))
+	}
+	// 5ms, 10ms, 20ms, 40ms and 80ms
+	if elapsed := l.accepts[len(l.accepts)-1].Sub(l.accepts[0]); elapsed < 155*time.Millisecond {
+		t.Errorf("expected the accept errors to be backed off, retried them within %s", elapsed)
+	}
+}
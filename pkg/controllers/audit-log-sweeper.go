@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+const auditLogSweepInterval = time.Minute
+
+// AuditLogSweeper keeps the size of the audit log directory below the configured cap
+// by deleting the oldest rotated audit logs. The apiserver only limits the number and
+// size of its backups, which on a busy apiserver may still exceed the disk space set
+// aside for audit logs.
+type AuditLogSweeper struct {
+	dir      string
+	maxBytes int64
+}
+
+func NewAuditLogSweeper(cfg *config.MicroshiftConfig) *AuditLogSweeper {
+	return &AuditLogSweeper{
+		dir:      cfg.AuditLogDir,
+		maxBytes: int64(cfg.APIServer.AuditLogMaxTotalSizeMB) * 1024 * 1024,
+	}
+}
+
+func (s *AuditLogSweeper) Name() string           { return "audit-log-sweeper" }
+func (s *AuditLogSweeper) Dependencies() []string { return []string{} }
+
+func (s *AuditLogSweeper) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+	close(ready)
+
+	ticker := time.NewTicker(auditLogSweepInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.sweep(); err != nil {
+			klog.Warningf("%s failed to clean up %s: %v", s.Name(), s.dir, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep deletes the oldest rotated audit logs until the directory fits the cap. The
+// active audit log is never deleted, so the directory may exceed the cap until the
+// apiserver rotates it.
+func (s *AuditLogSweeper) sweep() error {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	rotated := []os.FileInfo{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		total += info.Size()
+		// the apiserver rotates audit.log to audit-<timestamp>.log
+		if ok, _ := filepath.Match("audit-*", info.Name()); ok {
+			rotated = append(rotated, info)
+		}
+	}
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].ModTime().Before(rotated[j].ModTime()) })
+
+	for _, info := range rotated {
+		if total <= s.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(s.dir, info.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		total -= info.Size()
+		klog.Infof("%s removed %s to keep the audit logs below %d bytes", s.Name(), info.Name(), s.maxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeAuditLog(t *testing.T, dir, name string, size int, age time.Duration) {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestAuditLogSweeperRemovesOldest(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditLog(t, dir, "audit.log", 300, 0)
+	writeAuditLog(t, dir, "audit-2022-10-14T08-00-00.000.log", 300, 3*time.Hour)
+	writeAuditLog(t, dir, "audit-2022-10-14T09-00-00.000.log", 300, 2*time.Hour)
+	writeAuditLog(t, dir, "audit-2022-10-14T10-00-00.000.log", 300, time.Hour)
+
+	s := &AuditLogSweeper{dir: dir, maxBytes: 700}
+	if err := s.sweep(); err != nil {
+		t.Fatalf("sweep() error = %v", err)
+	}
+
+	want := []string{"audit-2022-10-14T10-00-00.000.log", "audit.log"}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the oldest audit logs to be removed, remaining %v, want %v", got, want)
+	}
+}
+
+func TestAuditLogSweeperBelowCap(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditLog(t, dir, "audit.log", 300, 0)
+	writeAuditLog(t, dir, "audit-2022-10-14T08-00-00.000.log", 300, time.Hour)
+
+	s := &AuditLogSweeper{dir: dir, maxBytes: 600}
+	if err := s.sweep(); err != nil {
+		t.Fatalf("sweep() error = %v", err)
+	}
+	if got := listDir(t, dir); len(got) != 2 {
+		t.Errorf("expected no audit logs to be removed below the cap, remaining %v", got)
+	}
+}
+
+func TestAuditLogSweeperKeepsActiveLog(t *testing.T) {
+	dir := t.TempDir()
+	writeAuditLog(t, dir, "audit.log", 1000, 0)
+	writeAuditLog(t, dir, "audit-2022-10-14T08-00-00.000.log", 300, time.Hour)
+	writeAuditLog(t, dir, "policy.yaml", 10, 2*time.Hour)
+
+	s := &AuditLogSweeper{dir: dir, maxBytes: 500}
+	if err := s.sweep(); err != nil {
+		t.Fatalf("sweep() error = %v", err)
+	}
+
+	want := []string{"audit.log", "policy.yaml"}
+	if got := listDir(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected only rotated audit logs to be removed, remaining %v, want %v", got, want)
+	}
+}
+
+func TestAuditLogSweeperMissingDir(t *testing.T) {
+	s := &AuditLogSweeper{dir: filepath.Join(t.TempDir(), "missing"), maxBytes: 500}
+	if err := s.sweep(); err != nil {
+		t.Errorf("expected a missing audit log directory to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+const clockCheckInterval = 10 * time.Second
+
+// ClockMonitor detects jumps of the host's wall clock by comparing it to the monotonic
+// clock, which isn't affected by changes of the system time. Hosts without a real time
+// clock often boot with a wrong time that is corrected once they synced over NTP, which
+// breaks TLS and lease handling if certificates or leases were created in between.
+type ClockMonitor struct {
+	threshold         time.Duration
+	checkCertificates bool
+	certsDir          string
+
+	interval time.Duration
+	// wallNow and monotonicNow return the current wall clock time and a monotonic
+	// reading, replaced in tests by a clock that jumps
+	wallNow      func() time.Time
+	monotonicNow func() time.Duration
+}
+
+func NewClockMonitor(cfg *config.MicroshiftConfig) *ClockMonitor {
+	// the threshold is validated with the config
+	threshold, _ := time.ParseDuration(cfg.Clock.JumpThreshold)
+	start := time.Now()
+	return &ClockMonitor{
+		threshold:         threshold,
+		checkCertificates: cfg.Clock.CheckCertificates,
+		certsDir:          cryptomaterial.CertsDirectory(cfg.DataDir),
+		interval:          clockCheckInterval,
+		wallNow:           func() time.Time { return time.Now().Round(0) },
+		monotonicNow:      func() time.Duration { return time.Since(start) },
+	}
+}
+
+func (s *ClockMonitor) Name() string           { return "clock-monitor" }
+func (s *ClockMonitor) Dependencies() []string { return []string{} }
+
+func (s *ClockMonitor) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+	close(ready)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	lastWall, lastMonotonic := s.wallNow(), s.monotonicNow()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		wall, monotonic := s.wallNow(), s.monotonicNow()
+		jump := wall.Sub(lastWall) - (monotonic - lastMonotonic)
+		lastWall, lastMonotonic = wall, monotonic
+
+		direction := "forward"
+		if jump < 0 {
+			direction, jump = "backward", -jump
+		}
+		if jump < s.threshold {
+			continue
+		}
+		klog.Warningf("%s: the system clock jumped %s by %s, certificates and leases may appear not yet or no longer valid until it is correct",
+			s.Name(), direction, jump.Round(time.Second))
+		if s.checkCertificates {
+			s.verifyCertificates(wall)
+		}
+	}
+}
+
+// verifyCertificates warns about the certificates in certsDir that aren't valid at now.
+func (s *ClockMonitor) verifyCertificates(now time.Time) {
+	err := filepath.WalkDir(s.certsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".crt" {
+			return err
+		}
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		certs, err := cert.ParseCertsPEM(pemBytes)
+		if err != nil {
+			klog.Warningf("%s: invalid certificate %s: %v", s.Name(), path, err)
+			return nil
+		}
+		for _, c := range certs {
+			if now.Before(c.NotBefore) {
+				klog.Warningf("%s: certificate %s (%s) is not valid before %s", s.Name(), path, c.Subject.CommonName, c.NotBefore)
+			} else if now.After(c.NotAfter) {
+				klog.Warningf("%s: certificate %s (%s) expired at %s", s.Name(), path, c.Subject.CommonName, c.NotAfter)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		klog.Warningf("%s failed to check the certificates: %v", s.Name(), err)
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog/v2"
+)
+
+// newTestClockMonitor returns a monitor whose wall clock is offset by the value
+// stored in offset, to simulate the system time being changed.
+func newTestClockMonitor(offset *int64) *ClockMonitor {
+	start := time.Now()
+	base := start.Round(0)
+	return &ClockMonitor{
+		threshold: time.Minute,
+		interval:  5 * time.Millisecond,
+		wallNow: func() time.Time {
+			return base.Add(time.Since(start) + time.Duration(atomic.LoadInt64(offset)))
+		},
+		monotonicNow: func() time.Duration { return time.Since(start) },
+	}
+}
+
+// runClockMonitor runs s while the clock is offset by jump and returns the log.
+func runClockMonitor(t *testing.T, s *ClockMonitor, offset *int64, jump time.Duration) string {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("logtostderr", "false"); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Set("logtostderr", "true")
+	var buf bytes.Buffer
+	klog.SetOutput(&buf)
+	defer klog.SetOutput(os.Stderr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, make(chan struct{}), make(chan struct{})) }()
+
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt64(offset, int64(jump))
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected the monitor to stop with the context, got %v", err)
+	}
+	klog.Flush()
+	return buf.String()
+}
+
+func TestClockMonitorBackwardJump(t *testing.T) {
+	var offset int64
+	s := newTestClockMonitor(&offset)
+
+	logs := runClockMonitor(t, s, &offset, -2*time.Hour)
+	if !strings.Contains(logs, "the system clock jumped backward by 2h0m0s") {
+		t.Errorf("expected a warning about the clock jump, got:\n%s", logs)
+	}
+}
+
+func TestClockMonitorForwardJump(t *testing.T) {
+	var offset int64
+	s := newTestClockMonitor(&offset)
+
+	logs := runClockMonitor(t, s, &offset, 10*time.Minute)
+	if !strings.Contains(logs, "the system clock jumped forward by 10m0s") {
+		t.Errorf("expected a warning about the clock jump, got:\n%s", logs)
+	}
+}
+
+func TestClockMonitorBelowThreshold(t *testing.T) {
+	var offset int64
+	s := newTestClockMonitor(&offset)
+
+	logs := runClockMonitor(t, s, &offset, 30*time.Second)
+	if strings.Contains(logs, "the system clock jumped") {
+		t.Errorf("expected no warning for a jump below the threshold, got:\n%s", logs)
+	}
+}
+
+func TestClockMonitorCertificates(t *testing.T) {
+	var offset int64
+	s := newTestClockMonitor(&offset)
+	s.checkCertificates = true
+	s.certsDir = t.TempDir()
+
+	// valid from an hour ago
+	certPEM, _, err := cert.GenerateSelfSignedCertKey("localhost", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath := filepath.Join(s.certsDir, "server.crt")
+	if err := os.WriteFile(certPath, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := runClockMonitor(t, s, &offset, -2*time.Hour)
+	if !strings.Contains(logs, "certificate "+certPath) || !strings.Contains(logs, "is not valid before") {
+		t.Errorf("expected a warning about the certificate not being valid yet, got:\n%s", logs)
+	}
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// the resync retries namespaces the baseline policy couldn't be created in
+	defaultNetworkPolicyResync = 10 * time.Minute
+	// defaultNetworkPolicyAnnotation marks the namespaces the baseline policy was
+	// created in with the name of the policy
+	defaultNetworkPolicyAnnotation = "microshift.openshift.io/default-network-policy"
+	// defaultNetworkPolicySinceAnnotation records on the kube-system namespace when the
+	// controller first ran
+	defaultNetworkPolicySinceAnnotation = "microshift.openshift.io/default-network-policy-since"
+)
+
+// DefaultNetworkPolicyController creates a baseline NetworkPolicy in each namespace
+// outside of the system namespaces, e.g. to default to denying all ingress traffic.
+// Namespaces are annotated once their baseline policy is created, so that their
+// owners may remove or loosen it without it being created again. Namespaces created
+// before the controller first ran, e.g. default, are left as they are.
+type DefaultNetworkPolicyController struct {
+	kubeconfig string
+	policy     string
+	// namespaces created before are left as they are
+	since time.Time
+}
+
+func NewDefaultNetworkPolicyController(cfg *config.MicroshiftConfig) *DefaultNetworkPolicyController {
+	return &DefaultNetworkPolicyController{
+		kubeconfig: cfg.KubeConfigPath(config.KubeAdmin),
+		policy:     cfg.Cluster.DefaultNetworkPolicy,
+	}
+}
+
+func (s *DefaultNetworkPolicyController) Name() string { return "default-network-policy-controller" }
+func (s *DefaultNetworkPolicyController) Dependencies() []string {
+	return []string{"kube-apiserver"}
+}
+
+func (s *DefaultNetworkPolicyController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", s.kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(rest.AddUserAgent(restConfig, s.Name()))
+	if err != nil {
+		return err
+	}
+	return s.run(ctx, client, ready)
+}
+
+// run baselines each new namespace until ctx is done
+func (s *DefaultNetworkPolicyController) run(ctx context.Context, client kubernetes.Interface, ready chan<- struct{}) error {
+	since, err := enabledSince(ctx, client, defaultNetworkPolicySinceAnnotation)
+	if err != nil {
+		return err
+	}
+	s.since = since
+
+	err = informNamespaces(ctx, client, defaultNetworkPolicyResync, func(ns *corev1.Namespace) {
+		if err := s.ensure(ctx, client, ns); err != nil {
+			klog.Warningf("%s failed to create the %s policy in namespace %s: %v", s.Name(), s.policy, ns.Name, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("%s creating the %s policy in new namespaces", s.Name(), s.policy)
+	close(ready)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// ensure creates the baseline policy in ns unless it is a system namespace, predates
+// the controller or was baselined before.
+func (s *DefaultNetworkPolicyController) ensure(ctx context.Context, client kubernetes.Interface, ns *corev1.Namespace) error {
+	if isSystemNamespace(ns.Name) || ns.Status.Phase == corev1.NamespaceTerminating || ns.CreationTimestamp.Time.Before(s.since) {
+		return nil
+	}
+	if _, ok := ns.Annotations[defaultNetworkPolicyAnnotation]; ok {
+		return nil
+	}
+
+	policy := newDefaultNetworkPolicy(s.policy, ns.Name)
+	if _, err := client.NetworkingV1().NetworkPolicies(ns.Name).Create(ctx, policy, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, defaultNetworkPolicyAnnotation, policy.Name)
+	if _, err := client.CoreV1().Namespaces().Patch(ctx, ns.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	klog.Infof("%s created the %s policy in namespace %s", s.Name(), policy.Name, ns.Name)
+	return nil
+}
+
+// enabledSince returns when a controller first ran, recording it in the given
+// annotation of the kube-system namespace on the first run, so that the namespaces
+// existing before keep their settings. The time is truncated to seconds like the
+// creation timestamps of namespaces.
+func enabledSince(ctx context.Context, client kubernetes.Interface, annotation string) (time.Time, error) {
+	ns, err := client.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if value, ok := ns.Annotations[annotation]; ok {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s annotation %q of namespace %s: %v", annotation, value, ns.Name, err)
+		}
+		return since, nil
+	}
+	since := metav1.Now().Rfc3339Copy()
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, annotation, since.UTC().Format(time.RFC3339))
+	if _, err := client.CoreV1().Namespaces().Patch(ctx, ns.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return time.Time{}, err
+	}
+	return since.Time, nil
+}
+
+// informNamespaces calls handle with each namespace once it is added or updated, and
+// on every resync, until ctx is done. It returns once the existing namespaces are
+// known.
+func informNamespaces(ctx context.Context, client kubernetes.Interface, resync time.Duration, handle func(ns *corev1.Namespace)) error {
+	factory := informers.NewSharedInformerFactory(client, resync)
+	informer := factory.Core().V1().Namespaces().Informer()
+	handleObj := func(obj interface{}) {
+		if ns, ok := obj.(*corev1.Namespace); ok {
+			handle(ns)
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    handleObj,
+		UpdateFunc: func(_, obj interface{}) { handleObj(obj) },
+	})
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// isSystemNamespace returns whether the namespace belongs to Kubernetes or MicroShift,
+// whose components a baseline policy would cut off.
+func isSystemNamespace(name string) bool {
+	return strings.HasPrefix(name, "kube-") || name == "openshift" || strings.HasPrefix(name, "openshift-")
+}
+
+// newDefaultNetworkPolicy returns the baseline policy, which selects all pods of the
+// namespace without allowing any traffic for the denied directions.
+func newDefaultNetworkPolicy(policy, namespace string) *networkingv1.NetworkPolicy {
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if policy == config.DefaultNetworkPolicyDenyAll {
+		policyTypes = append(policyTypes, networkingv1.PolicyTypeEgress)
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "microshift-" + policy,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: policyTypes,
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestNamespace(name string, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+func TestNewDefaultNetworkPolicy(t *testing.T) {
+	var tests = []struct {
+		policy      string
+		name        string
+		policyTypes []networkingv1.PolicyType
+	}{
+		{config.DefaultNetworkPolicyDenyAllIngress, "microshift-deny-all-ingress", []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+		{config.DefaultNetworkPolicyDenyAll, "microshift-deny-all", []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}},
+	}
+	for _, tt := range tests {
+		p := newDefaultNetworkPolicy(tt.policy, "busybox")
+		if p.Name != tt.name || p.Namespace != "busybox" {
+			t.Errorf("expected %s policy busybox/%s, got %s/%s", tt.policy, tt.name, p.Namespace, p.Name)
+		}
+		if !reflect.DeepEqual(p.Spec.PolicyTypes, tt.policyTypes) {
+			t.Errorf("expected %s policy to deny %v, got %v", tt.policy, tt.policyTypes, p.Spec.PolicyTypes)
+		}
+		if len(p.Spec.PodSelector.MatchLabels) > 0 || len(p.Spec.PodSelector.MatchExpressions) > 0 || len(p.Spec.Ingress) > 0 || len(p.Spec.Egress) > 0 {
+			t.Errorf("expected %s policy to select all pods without allowing any traffic, got %+v", tt.policy, p.Spec)
+		}
+	}
+}
+
+func TestDefaultNetworkPolicyController(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newTestNamespace("existing", nil),
+		newTestNamespace("kube-system", nil),
+		newTestNamespace("openshift-dns", nil),
+		// the owner deleted the policy created before
+		newTestNamespace("baselined", map[string]string{defaultNetworkPolicyAnnotation: "microshift-deny-all-ingress"}),
+	)
+	s := &DefaultNetworkPolicyController{policy: config.DefaultNetworkPolicyDenyAllIngress}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	go s.run(ctx, client, ready)
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("controller didn't become ready")
+	}
+
+	newNamespace := newTestNamespace("new", nil)
+	newNamespace.CreationTimestamp = metav1.Now()
+	if _, err := client.CoreV1().Namespaces().Create(ctx, newNamespace, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		ns, err := client.CoreV1().Namespaces().Get(ctx, "new", metav1.GetOptions{})
+		return err == nil && ns.Annotations[defaultNetworkPolicyAnnotation] != "", nil
+	})
+	if err != nil {
+		t.Fatalf("expected the new namespace to be baselined: %v", err)
+	}
+	if _, err := client.NetworkingV1().NetworkPolicies("new").Get(ctx, "microshift-deny-all-ingress", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the baseline policy in the new namespace: %v", err)
+	}
+
+	// the namespaces created before the controller first ran are left as they are
+	for _, namespace := range []string{"existing", "kube-system", "openshift-dns", "baselined"} {
+		policies, err := client.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(policies.Items) > 0 {
+			t.Errorf("expected no baseline policy in namespace %s, got %v", namespace, policies.Items)
+		}
+	}
+}
+
+func TestEnabledSince(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestNamespace("kube-system", nil))
+	since, err := enabledSince(context.Background(), client, defaultNetworkPolicySinceAnnotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(since) > time.Minute {
+		t.Errorf("expected the first run to be recorded as now, got %s", since)
+	}
+	// later runs keep the time of the first one
+	recorded := "2022-05-01T10:00:00Z"
+	client = fake.NewSimpleClientset(newTestNamespace("kube-system", map[string]string{defaultNetworkPolicySinceAnnotation: recorded}))
+	since, err = enabledSince(context.Background(), client, defaultNetworkPolicySinceAnnotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := since.UTC().Format(time.RFC3339); got != recorded {
+		t.Errorf("expected the recorded time %s, got %s", recorded, got)
+	}
+	client = fake.NewSimpleClientset(newTestNamespace("kube-system", map[string]string{defaultNetworkPolicySinceAnnotation: "yesterday"}))
+	if _, err := enabledSince(context.Background(), client, defaultNetworkPolicySinceAnnotation); err == nil {
+		t.Error("expected an invalid recorded time to fail")
+	}
+}
+
+func TestDefaultNetworkPolicyEnsureExisting(t *testing.T) {
+	ns := newTestNamespace("busybox", nil)
+	client := fake.NewSimpleClientset(ns, newDefaultNetworkPolicy(config.DefaultNetworkPolicyDenyAll, "busybox"))
+	s := &DefaultNetworkPolicyController{policy: config.DefaultNetworkPolicyDenyAll}
+
+	if err := s.ensure(context.Background(), client, ns); err != nil {
+		t.Fatalf("expected an existing policy to be kept, got %v", err)
+	}
+	ns, err := client.CoreV1().Namespaces().Get(context.Background(), "busybox", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ns.Annotations[defaultNetworkPolicyAnnotation]; got != "microshift-deny-all" {
+		t.Errorf("expected the namespace to be annotated with the policy, got %q", got)
+	}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// the resync retries namespaces the defaults couldn't be created in
+	defaultResourcesResync = 10 * time.Minute
+	// defaultResourcesAnnotation marks the namespaces the defaults were created in
+	// with the kinds of the objects created
+	defaultResourcesAnnotation = "microshift.openshift.io/default-resources"
+	// defaultResourcesSinceAnnotation records on the kube-system namespace when the
+	// controller first ran
+	defaultResourcesSinceAnnotation = "microshift.openshift.io/default-resources-since"
+	// defaultResourcesName is the name of the ResourceQuota and LimitRange created
+	defaultResourcesName = "microshift-default"
+)
+
+// DefaultResourcesController creates the configured ResourceQuota and LimitRange in
+// each namespace outside of the system namespaces, so that runaway workloads can't
+// exhaust the resources of the device. Like the baseline NetworkPolicy, namespaces
+// are annotated once the defaults are created, so that their owners may change or
+// remove them without them being created again, and the namespaces created before the
+// controller first ran are left as they are.
+type DefaultResourcesController struct {
+	kubeconfig string
+	quota      *corev1.ResourceQuotaSpec
+	limits     *corev1.LimitRangeSpec
+	// namespaces created before are left as they are
+	since time.Time
+}
+
+func NewDefaultResourcesController(cfg *config.MicroshiftConfig) *DefaultResourcesController {
+	// both were validated when reading the configuration
+	quota, _ := cfg.DefaultResourceQuota()
+	limits, _ := cfg.DefaultLimitRange()
+	return &DefaultResourcesController{
+		kubeconfig: cfg.KubeConfigPath(config.KubeAdmin),
+		quota:      quota,
+		limits:     limits,
+	}
+}
+
+func (s *DefaultResourcesController) Name() string { return "default-resources-controller" }
+func (s *DefaultResourcesController) Dependencies() []string {
+	return []string{"kube-apiserver"}
+}
+
+func (s *DefaultResourcesController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", s.kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(rest.AddUserAgent(restConfig, s.Name()))
+	if err != nil {
+		return err
+	}
+	return s.run(ctx, client, ready)
+}
+
+// run creates the defaults in each new namespace until ctx is done
+func (s *DefaultResourcesController) run(ctx context.Context, client kubernetes.Interface, ready chan<- struct{}) error {
+	since, err := enabledSince(ctx, client, defaultResourcesSinceAnnotation)
+	if err != nil {
+		return err
+	}
+	s.since = since
+
+	err = informNamespaces(ctx, client, defaultResourcesResync, func(ns *corev1.Namespace) {
+		if err := s.ensure(ctx, client, ns); err != nil {
+			klog.Warningf("%s failed to create the defaults in namespace %s: %v", s.Name(), ns.Name, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("%s creating the %s defaults in new namespaces", s.Name(), strings.Join(s.kinds(), " and "))
+	close(ready)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// kinds returns the kinds of the configured defaults
+func (s *DefaultResourcesController) kinds() []string {
+	kinds := []string{}
+	if s.quota != nil {
+		kinds = append(kinds, "ResourceQuota")
+	}
+	if s.limits != nil {
+		kinds = append(kinds, "LimitRange")
+	}
+	return kinds
+}
+
+// ensure creates the defaults in ns unless it is a system namespace, predates the
+// controller or they were created before.
+func (s *DefaultResourcesController) ensure(ctx context.Context, client kubernetes.Interface, ns *corev1.Namespace) error {
+	if isSystemNamespace(ns.Name) || ns.Status.Phase == corev1.NamespaceTerminating || ns.CreationTimestamp.Time.Before(s.since) {
+		return nil
+	}
+	if _, ok := ns.Annotations[defaultResourcesAnnotation]; ok {
+		return nil
+	}
+
+	meta := metav1.ObjectMeta{Name: defaultResourcesName, Namespace: ns.Name}
+	if s.quota != nil {
+		quota := &corev1.ResourceQuota{ObjectMeta: meta, Spec: *s.quota.DeepCopy()}
+		if _, err := client.CoreV1().ResourceQuotas(ns.Name).Create(ctx, quota, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	if s.limits != nil {
+		limits := &corev1.LimitRange{ObjectMeta: meta, Spec: *s.limits.DeepCopy()}
+		if _, err := client.CoreV1().LimitRanges(ns.Name).Create(ctx, limits, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	kinds := strings.Join(s.kinds(), ",")
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, defaultResourcesAnnotation, kinds)
+	if _, err := client.CoreV1().Namespaces().Patch(ctx, ns.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	klog.Infof("%s created the %s defaults in namespace %s", s.Name(), kinds, ns.Name)
+	return nil
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDefaultResourcesController(t *testing.T) {
+	quota := &corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("20")}}
+	limits := &corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+		Type:    corev1.LimitTypeContainer,
+		Default: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+	}}}
+	client := fake.NewSimpleClientset(
+		newTestNamespace("existing", nil),
+		newTestNamespace("kube-system", nil),
+		newTestNamespace("openshift-dns", nil),
+		// the owner deleted the defaults created before
+		newTestNamespace("defaulted", map[string]string{defaultResourcesAnnotation: "ResourceQuota,LimitRange"}),
+	)
+	s := &DefaultResourcesController{quota: quota, limits: limits}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	go s.run(ctx, client, ready)
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("controller didn't become ready")
+	}
+
+	newNamespace := newTestNamespace("new", nil)
+	newNamespace.CreationTimestamp = metav1.Now()
+	if _, err := client.CoreV1().Namespaces().Create(ctx, newNamespace, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, namespace := range []string{"new"} {
+		err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+			return err == nil && ns.Annotations[defaultResourcesAnnotation] != "", nil
+		})
+		if err != nil {
+			t.Fatalf("expected the defaults to be created in namespace %s: %v", namespace, err)
+		}
+		q, err := client.CoreV1().ResourceQuotas(namespace).Get(ctx, defaultResourcesName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected the default ResourceQuota in namespace %s: %v", namespace, err)
+		}
+		if !reflect.DeepEqual(q.Spec, *quota) {
+			t.Errorf("expected the default ResourceQuota in namespace %s to be %v, got %v", namespace, *quota, q.Spec)
+		}
+		l, err := client.CoreV1().LimitRanges(namespace).Get(ctx, defaultResourcesName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected the default LimitRange in namespace %s: %v", namespace, err)
+		}
+		if !reflect.DeepEqual(l.Spec, *limits) {
+			t.Errorf("expected the default LimitRange in namespace %s to be %v, got %v", namespace, *limits, l.Spec)
+		}
+	}
+
+	// the namespaces created before the controller first ran are left as they are
+	for _, namespace := range []string{"existing", "kube-system", "openshift-dns", "defaulted"} {
+		quotas, err := client.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		limitRanges, err := client.CoreV1().LimitRanges(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(quotas.Items) > 0 || len(limitRanges.Items) > 0 {
+			t.Errorf("expected no defaults in namespace %s, got %v and %v", namespace, quotas.Items, limitRanges.Items)
+		}
+	}
+}
+
+func TestDefaultResourcesQuotaOnly(t *testing.T) {
+	ns := newTestNamespace("busybox", nil)
+	client := fake.NewSimpleClientset(ns)
+	s := &DefaultResourcesController{quota: &corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("20")}}}
+
+	if err := s.ensure(context.Background(), client, ns); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().ResourceQuotas("busybox").Get(context.Background(), defaultResourcesName, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the default ResourceQuota: %v", err)
+	}
+	limitRanges, err := client.CoreV1().LimitRanges("busybox").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limitRanges.Items) > 0 {
+		t.Errorf("expected no LimitRange without one configured, got %v", limitRanges.Items)
+	}
+	ns, err = client.CoreV1().Namespaces().Get(context.Background(), "busybox", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ns.Annotations[defaultResourcesAnnotation]; got != "ResourceQuota" {
+		t.Errorf("expected the namespace to be annotated with the kinds created, got %q", got)
+	}
+}
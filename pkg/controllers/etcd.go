@@ -17,13 +17,19 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"os"
 	"path/filepath"
 
+	"github.com/openshift/library-go/pkg/crypto"
 	"github.com/openshift/microshift/pkg/config"
 	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
 	etcd "go.etcd.io/etcd/server/v3/embed"
+	"golang.org/x/sys/unix"
 	"k8s.io/klog/v2"
 )
 
@@ -36,15 +42,31 @@ var (
 		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305",
 		"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305",
 	}
-	microshiftDataDir = config.GetDataDir()
 )
 
 const (
 	etcdStartupTimeout = 60
 )
 
+// staleEtcdFiles are the files an unclean shutdown leaves in etcd's data directory,
+// which etcd creates again as needed
+var staleEtcdFiles = []string{
+	// the WAL directory of an interrupted first start
+	"member/wal.tmp",
+	// the preallocated WAL segments, locked while etcd runs
+	"member/wal/*.tmp",
+	// the database copy of an interrupted defragmentation
+	"member/snap/db.tmp.*",
+}
+
+// ephemeralEtcdDir is on tmpfs below a directory only root can write to, unlike
+// /dev/shm, it is a variable so that tests can relocate it
+var ephemeralEtcdDir = "/run/microshift/etcd"
+
 type EtcdService struct {
-	etcdCfg *etcd.Config
+	etcdCfg           *etcd.Config
+	ephemeral         bool
+	recoverStaleFiles bool
 }
 
 func NewEtcd(cfg *config.MicroshiftConfig) *EtcdService {
@@ -57,12 +79,17 @@ func (s *EtcdService) Name() string           { return "etcd" }
 func (s *EtcdService) Dependencies() []string { return []string{} }
 
 func (s *EtcdService) configure(cfg *config.MicroshiftConfig) {
-	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
 
 	etcdServingCertDir := cryptomaterial.EtcdServingCertDir(certsDir)
 	etcdPeerCertDir := cryptomaterial.EtcdPeerCertDir(certsDir)
 	etcdSignerCertPath := cryptomaterial.CACertPath(cryptomaterial.EtcdSignerDir(certsDir))
-	dataDir := filepath.Join(microshiftDataDir, s.Name())
+	dataDir := etcdDataDir(cfg)
+	if cfg.Etcd.Ephemeral {
+		klog.Warningf("%s runs with ephemeral storage in %s, all cluster state is lost when MicroShift restarts", s.Name(), ephemeralEtcdDir)
+		s.ephemeral = true
+	}
+	s.recoverStaleFiles = cfg.Etcd.RecoverStaleFiles
 
 	// based on https://github.com/openshift/cluster-etcd-operator/blob/master/bindata/bootkube/bootstrap-manifests/etcd-member-pod.yaml#L19
 	s.etcdCfg = etcd.NewConfig()
@@ -70,16 +97,27 @@ func (s *EtcdService) configure(cfg *config.MicroshiftConfig) {
 	//s.etcdCfg.ForceNewCluster = true //TODO
 	s.etcdCfg.Logger = "zap"
 	s.etcdCfg.Dir = dataDir
-	s.etcdCfg.APUrls = setURL([]string{cfg.NodeIP}, ":2380")
-	s.etcdCfg.LPUrls = setURL([]string{cfg.NodeIP}, ":2380")
-	s.etcdCfg.ACUrls = setURL([]string{cfg.NodeIP}, ":2379")
-	s.etcdCfg.LCUrls = setURL([]string{"127.0.0.1", cfg.NodeIP}, ":2379")
-	s.etcdCfg.ListenMetricsUrls = setURL([]string{"127.0.0.1"}, ":2381")
+	// durability is pointless for data that is discarded on restart anyway
+	s.etcdCfg.UnsafeNoFsync = s.ephemeral
+	s.etcdCfg.APUrls = setURL([]string{cfg.NodeIP}, "2380")
+	s.etcdCfg.LPUrls = setURL([]string{cfg.NodeIP}, "2380")
+	s.etcdCfg.ACUrls = setURL([]string{cfg.NodeIP}, "2379")
+	s.etcdCfg.LCUrls = setURL([]string{"127.0.0.1", cfg.NodeIP}, "2379")
+	if cfg.Metrics.Enabled {
+		s.etcdCfg.ListenMetricsUrls = setURL([]string{"127.0.0.1"}, "2381")
+	}
 
 	s.etcdCfg.Name = cfg.NodeName
-	s.etcdCfg.InitialCluster = fmt.Sprintf("%s=https://%s:2380", cfg.NodeName, cfg.NodeIP)
+	s.etcdCfg.InitialCluster = fmt.Sprintf("%s=https://%s", cfg.NodeName, net.JoinHostPort(cfg.NodeIP, "2380"))
 
 	s.etcdCfg.CipherSuites = tlsCipherSuites
+	if len(cfg.Etcd.TLSCipherSuites) > 0 {
+		s.etcdCfg.CipherSuites = cfg.Etcd.TLSCipherSuites
+	}
+	// the version has been validated, TLS 1.2 is etcd's default otherwise
+	tlsMinVersion, _ := crypto.TLSVersion(cfg.Etcd.TLSMinVersion)
+	s.etcdCfg.ClientTLSInfo.MinVersion = tlsMinVersion
+	s.etcdCfg.PeerTLSInfo.MinVersion = tlsMinVersion
 	s.etcdCfg.ClientTLSInfo.CertFile = cryptomaterial.PeerCertPath(etcdServingCertDir)
 	s.etcdCfg.ClientTLSInfo.KeyFile = cryptomaterial.PeerKeyPath(etcdServingCertDir)
 	s.etcdCfg.ClientTLSInfo.TrustedCAFile = etcdSignerCertPath
@@ -92,6 +130,17 @@ func (s *EtcdService) configure(cfg *config.MicroshiftConfig) {
 func (s *EtcdService) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
 
+	if s.ephemeral {
+		// start from scratch, as if the memory had been lost with a restart of the host
+		if err := createEphemeralDir(s.etcdCfg.Dir); err != nil {
+			return fmt.Errorf("%s failed to clear ephemeral storage: %v", s.Name(), err)
+		}
+		defer os.RemoveAll(s.etcdCfg.Dir)
+	}
+	if err := s.removeStaleFiles(); err != nil {
+		return fmt.Errorf("%s failed to recover from an unclean shutdown: %v", s.Name(), err)
+	}
+
 	e, err := etcd.StartEtcd(s.etcdCfg)
 	if err != nil {
 		return fmt.Errorf("%s failed to start: %v", s.Name(), err)
@@ -110,10 +159,121 @@ func (s *EtcdService) Run(ctx context.Context, ready chan<- struct{}, stopped ch
 	return ctx.Err()
 }
 
+// createEphemeralDir creates dir afresh and only accessible by its owner, refusing
+// to use a parent directory or a leftover dir that another user owns or, for the
+// parent, can write to, as they could then read or replace etcd's data.
+func createEphemeralDir(dir string) error {
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return err
+	}
+	if err := checkOwnedDir(parent, true); err != nil {
+		return err
+	}
+	if err := checkOwnedDir(dir, false); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	// fails if the directory has been created again in the meantime
+	return os.Mkdir(dir, 0700)
+}
+
+// checkOwnedDir returns an error unless path is a directory, not a symlink to one,
+// owned by the user MicroShift runs as, i.e. root, and, if private, only writable
+// by it.
+func checkOwnedDir(path string, private bool) error {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return &os.PathError{Op: "lstat", Path: path, Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	if int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("%s is owned by uid %d instead of %d", path, st.Uid, os.Geteuid())
+	}
+	if private && st.Mode&0022 != 0 {
+		return fmt.Errorf("%s can be written to by other users", path)
+	}
+	return nil
+}
+
+func etcdDataDir(cfg *config.MicroshiftConfig) string {
+	if cfg.Etcd.Ephemeral {
+		return ephemeralEtcdDir
+	}
+	return filepath.Join(cfg.RoleDataDir(config.ControlPlaneRole), "etcd")
+}
+
+// CheckEtcdUnlocked returns an error if the WAL of etcd is locked, i.e. etcd still runs
+// in another process, e.g. a previous instance that didn't finish stopping yet.
+func CheckEtcdUnlocked(cfg *config.MicroshiftConfig) error {
+	unlock, err := lockWAL(etcdDataDir(cfg))
+	if err != nil {
+		return err
+	}
+	unlock()
+	return nil
+}
+
+// lockWAL locks the WAL files in etcd's data directory, returning a function that
+// unlocks them.
+func lockWAL(dir string) (func(), error) {
+	wals, err := filepath.Glob(filepath.Join(dir, "member", "wal", "*.wal"))
+	if err != nil {
+		return nil, err
+	}
+	var locks []*fileutil.LockedFile
+	unlock := func() {
+		for _, l := range locks {
+			l.Close()
+		}
+	}
+	for _, wal := range wals {
+		l, err := fileutil.TryLockFile(wal, os.O_RDWR, fileutil.PrivateFileMode)
+		if err != nil {
+			unlock()
+			return nil, fmt.Errorf("%s is in use: %v", wal, err)
+		}
+		locks = append(locks, l)
+	}
+	return unlock, nil
+}
+
+// removeStaleFiles removes the files of staleEtcdFiles if recovering them is enabled.
+// Nothing is removed while the WAL is locked, i.e. etcd still runs in another process.
+func (s *EtcdService) removeStaleFiles() error {
+	if !s.recoverStaleFiles {
+		return nil
+	}
+	unlock, err := lockWAL(s.etcdCfg.Dir)
+	if err != nil {
+		return fmt.Errorf("refusing to remove stale files, %v", err)
+	}
+	defer unlock()
+
+	for _, pattern := range staleEtcdFiles {
+		paths, err := filepath.Glob(filepath.Join(s.etcdCfg.Dir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			klog.Warningf("%s removed %s left behind by an unclean shutdown", s.Name(), path)
+		}
+	}
+	return nil
+}
+
 func setURL(hostnames []string, port string) []url.URL {
 	urls := make([]url.URL, len(hostnames))
 	for i, name := range hostnames {
-		u, err := url.Parse("https://" + name + port)
+		// IPv6 addresses must be bracketed in URLs
+		u, err := url.Parse("https://" + net.JoinHostPort(name, port))
 		if err != nil {
 			return []url.URL{}
 		}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	"go.etcd.io/etcd/client/pkg/v3/fileutil"
+	"k8s.io/klog/v2"
+)
+
+func TestSetURL(t *testing.T) {
+	urls := setURL([]string{"127.0.0.1", "fd00::10"}, "2379")
+	expected := []string{"https://127.0.0.1:2379", "https://[fd00::10]:2379"}
+
+	if len(urls) != len(expected) {
+		t.Fatalf("expected %d urls, got %v", len(expected), urls)
+	}
+	for i := range urls {
+		if urls[i].String() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], urls[i].String())
+		}
+	}
+}
+
+func TestEtcdTLS(t *testing.T) {
+	var ttests = []struct {
+		minVersion           string
+		cipherSuites         []string
+		expectedMinVersion   uint16
+		expectedCipherSuites []string
+	}{
+		{
+			minVersion:           config.TLSVersion12,
+			expectedMinVersion:   tls.VersionTLS12,
+			expectedCipherSuites: tlsCipherSuites,
+		},
+		{
+			minVersion:           config.TLSVersion12,
+			cipherSuites:         []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+			expectedMinVersion:   tls.VersionTLS12,
+			expectedCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		},
+		{
+			minVersion:           config.TLSVersion13,
+			expectedMinVersion:   tls.VersionTLS13,
+			expectedCipherSuites: tlsCipherSuites,
+		},
+	}
+
+	for _, tt := range ttests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.Etcd.TLSMinVersion = tt.minVersion
+		cfg.Etcd.TLSCipherSuites = tt.cipherSuites
+
+		s := NewEtcd(cfg)
+		if s.etcdCfg.ClientTLSInfo.MinVersion != tt.expectedMinVersion || s.etcdCfg.PeerTLSInfo.MinVersion != tt.expectedMinVersion {
+			t.Errorf("%s: expected client and peer min version %x, got %x and %x", tt.minVersion, tt.expectedMinVersion,
+				s.etcdCfg.ClientTLSInfo.MinVersion, s.etcdCfg.PeerTLSInfo.MinVersion)
+		}
+		if !reflect.DeepEqual(s.etcdCfg.CipherSuites, tt.expectedCipherSuites) {
+			t.Errorf("%s: expected cipher suites %v, got %v", tt.minVersion, tt.expectedCipherSuites, s.etcdCfg.CipherSuites)
+		}
+	}
+}
+
+func TestEtcdRoleDataDir(t *testing.T) {
+	for _, roleDataDirs := range []bool{false, true} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.RoleDataDirs = roleDataDirs
+
+		expected := filepath.Join(cfg.DataDir, "etcd")
+		if roleDataDirs {
+			expected = filepath.Join(cfg.DataDir, config.ControlPlaneRole, "etcd")
+		}
+		if s := NewEtcd(cfg); s.etcdCfg.Dir != expected {
+			t.Errorf("roleDataDirs=%v: expected etcd data directory %q, got %q", roleDataDirs, expected, s.etcdCfg.Dir)
+		}
+	}
+}
+
+func TestEtcdEphemeral(t *testing.T) {
+	defer func(dir string) { ephemeralEtcdDir = dir }(ephemeralEtcdDir)
+	ephemeralEtcdDir = filepath.Join(t.TempDir(), "tmpfs", "etcd")
+
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("logtostderr", "false"); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Set("logtostderr", "true")
+	var buf bytes.Buffer
+	klog.SetOutput(&buf)
+	defer klog.SetOutput(os.Stderr)
+
+	for _, ephemeral := range []bool{false, true} {
+		buf.Reset()
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Etcd.Ephemeral = ephemeral
+
+		s := NewEtcd(cfg)
+		klog.Flush()
+
+		expected := filepath.Join(cfg.DataDir, "etcd")
+		if ephemeral {
+			expected = ephemeralEtcdDir
+		}
+		if s.etcdCfg.Dir != expected {
+			t.Errorf("ephemeral=%v: expected etcd data directory %q, got %q", ephemeral, expected, s.etcdCfg.Dir)
+		}
+		if s.etcdCfg.UnsafeNoFsync != ephemeral {
+			t.Errorf("ephemeral=%v: expected fsync to be disabled only for ephemeral storage", ephemeral)
+		}
+		if warned := strings.Contains(buf.String(), "all cluster state is lost"); warned != ephemeral {
+			t.Errorf("ephemeral=%v: expected a warning about data loss only for ephemeral storage, got %q", ephemeral, buf.String())
+		}
+	}
+}
+
+func TestCreateEphemeralDir(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "microshift")
+	dir := filepath.Join(parent, "etcd")
+	if err := createEphemeralDir(dir); err != nil {
+		t.Fatalf("createEphemeralDir() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "db"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := createEphemeralDir(dir); err != nil {
+		t.Fatalf("createEphemeralDir() error = %v", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("expected the directory to be private, got %v", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the leftover data to be removed, got %v", err)
+	}
+
+	// a symlink planted in place of the directory is refused
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := createEphemeralDir(dir); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("expected a symlink to be refused, got %v", err)
+	}
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// as is a parent others can write to
+	if err := os.Chmod(parent, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := createEphemeralDir(dir); err == nil || !strings.Contains(err.Error(), "written to by other users") {
+		t.Errorf("expected a world-writable parent to be refused, got %v", err)
+	}
+}
+
+func TestEtcdRecoverStaleFiles(t *testing.T) {
+	kept := []string{"member/wal/0000000000000000-0000000000000000.wal", "member/snap/db", "member/snap/0000000000000002-0000000000000010.snap"}
+	stale := []string{"member/wal/0.tmp", "member/wal.tmp/0000000000000000-0000000000000000.wal", "member/snap/db.tmp.3842"}
+	setup := func(t *testing.T, recoverStaleFiles bool) *EtcdService {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Etcd.RecoverStaleFiles = recoverStaleFiles
+		s := NewEtcd(cfg)
+		for _, name := range append(kept, stale...) {
+			path := filepath.Join(s.etcdCfg.Dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return s
+	}
+	exist := func(s *EtcdService, names []string) []string {
+		existing := []string{}
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(s.etcdCfg.Dir, name)); err == nil {
+				existing = append(existing, name)
+			}
+		}
+		return existing
+	}
+
+	for _, recoverStaleFiles := range []bool{false, true} {
+		s := setup(t, recoverStaleFiles)
+		if err := s.removeStaleFiles(); err != nil {
+			t.Fatalf("recoverStaleFiles=%v: removeStaleFiles() error = %v", recoverStaleFiles, err)
+		}
+		if got := exist(s, kept); !reflect.DeepEqual(got, kept) {
+			t.Errorf("recoverStaleFiles=%v: expected %v to be preserved, got %v", recoverStaleFiles, kept, got)
+		}
+		expected := stale
+		if recoverStaleFiles {
+			expected = []string{}
+		}
+		if got := exist(s, stale); !reflect.DeepEqual(got, expected) {
+			t.Errorf("recoverStaleFiles=%v: expected the stale files %v to remain, got %v", recoverStaleFiles, expected, got)
+		}
+	}
+
+	// another etcd holds the lock of the WAL
+	s := setup(t, true)
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = filepath.Dir(s.etcdCfg.Dir)
+	l, err := fileutil.LockFile(filepath.Join(s.etcdCfg.Dir, kept[0]), os.O_RDWR, fileutil.PrivateFileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := s.removeStaleFiles(); err == nil || !strings.Contains(err.Error(), "is in use") {
+		t.Errorf("expected the stale files of a running etcd to be preserved, got %v", err)
+	}
+	if got := exist(s, stale); !reflect.DeepEqual(got, stale) {
+		t.Errorf("expected the stale files of a running etcd to remain, got %v", got)
+	}
+	if err := CheckEtcdUnlocked(cfg); err == nil {
+		t.Errorf("expected CheckEtcdUnlocked() to fail while the WAL is locked")
+	}
+	l.Close()
+	if err := CheckEtcdUnlocked(cfg); err != nil {
+		t.Errorf("CheckEtcdUnlocked() error = %v", err)
+	}
+}
@@ -37,7 +37,14 @@ func NewInfrastructureServices(cfg *config.MicroshiftConfig) *InfrastructureServ
 
 func (s *InfrastructureServicesManager) Name() string { return "infrastructure-services-manager" }
 func (s *InfrastructureServicesManager) Dependencies() []string {
-	return []string{"kube-apiserver", "openshift-crd-manager", "route-controller-manager"}
+	dependencies := []string{"kube-apiserver"}
+	if s.cfg.ComponentEnabled(config.ComponentOpenShiftCRDs) {
+		dependencies = append(dependencies, "openshift-crd-manager")
+	}
+	if s.cfg.ComponentEnabled(config.ComponentOpenShiftControllerManager) {
+		dependencies = append(dependencies, "route-controller-manager")
+	}
+	return dependencies
 }
 
 func (s *InfrastructureServicesManager) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/openshift/microshift/pkg/components"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util"
+	"k8s.io/klog/v2"
+)
+
+const ingressCertDebounce = 2 * time.Second
+
+// IngressCertificateWatcher updates the router's serving certificate when the
+// externally managed certificate files change.
+type IngressCertificateWatcher struct {
+	certFile   string
+	keyFile    string
+	kubeconfig string
+}
+
+func NewIngressCertificateWatcher(cfg *config.MicroshiftConfig) *IngressCertificateWatcher {
+	return &IngressCertificateWatcher{
+		certFile:   cfg.Ingress.CertFile,
+		keyFile:    cfg.Ingress.KeyFile,
+		kubeconfig: cfg.KubeConfigPath(config.KubeAdmin),
+	}
+}
+
+func (s *IngressCertificateWatcher) Name() string { return "ingress-certificate-watcher" }
+func (s *IngressCertificateWatcher) Dependencies() []string {
+	return []string{"infrastructure-services-manager"}
+}
+
+func (s *IngressCertificateWatcher) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+	close(ready)
+
+	klog.Infof("%s watching %s and %s", s.Name(), s.certFile, s.keyFile)
+	return util.WatchFiles(ctx, []string{s.certFile, s.keyFile}, ingressCertDebounce, s.reload)
+}
+
+func (s *IngressCertificateWatcher) reload() {
+	cert, key, err := util.LoadCertKeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		// the files may be half written, the next change will trigger another reload
+		klog.Warningf("%s not reloading the router serving certificate: %v", s.Name(), err)
+		return
+	}
+	if err := components.ApplyIngressServingCertificate(cert, key, s.kubeconfig); err != nil {
+		klog.Errorf("%s failed to update the router serving certificate: %v", s.Name(), err)
+		return
+	}
+	klog.Infof("%s updated the router serving certificate", s.Name())
+}
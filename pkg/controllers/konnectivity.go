@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	konnectivityStartupTimeout = 60
+)
+
+// KonnectivityService holds back the apiserver until the externally run konnectivity
+// server accepts connections on its unix domain socket, so that the apiserver's
+// traffic to the cluster can be proxied from the start.
+type KonnectivityService struct {
+	udsName string
+}
+
+func NewKonnectivity(cfg *config.MicroshiftConfig) *KonnectivityService {
+	return &KonnectivityService{
+		udsName: cfg.APIServer.Konnectivity.UDSName,
+	}
+}
+
+func (s *KonnectivityService) Name() string           { return "konnectivity" }
+func (s *KonnectivityService) Dependencies() []string { return []string{} }
+
+func (s *KonnectivityService) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	err := wait.PollImmediateWithContext(ctx, time.Second, konnectivityStartupTimeout*time.Second, func(ctx context.Context) (bool, error) {
+		conn, err := net.DialTimeout("unix", s.udsName, time.Second)
+		if err != nil {
+			klog.Infof("%q waiting for konnectivity server at %s: %v", s.Name(), s.udsName, err)
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("konnectivity server at %s not available: %w", s.udsName, err)
+	}
+	klog.Infof("%q is ready", s.Name())
+	close(ready)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	kubecontrolplanev1 "github.com/openshift/api/kubecontrolplane/v1"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/servicemanager"
+	"sigs.k8s.io/yaml"
+)
+
+func TestKubeAPIServerKonnectivity(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.Konnectivity.Enabled = enabled
+		cfg.APIServer.Konnectivity.UDSName = "/run/konnectivity/konnectivity-server.socket"
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		egressSelectorConfig := filepath.Join(cfg.DataDir, "resources", "kube-apiserver", "egress-selector-config.yaml")
+		_, statErr := os.Stat(egressSelectorConfig)
+
+		if enabled {
+			expected := kubecontrolplanev1.Arguments{egressSelectorConfig}
+			if got := kasConfig.APIServerArguments["egress-selector-config-file"]; !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected egress-selector-config-file %v, got %v", expected, got)
+			}
+			if statErr != nil {
+				t.Errorf("expected egress selector config to be written: %v", statErr)
+			}
+			if deps := s.Dependencies(); !reflect.DeepEqual(deps, []string{"etcd", "konnectivity"}) {
+				t.Errorf("expected kube-apiserver to depend on konnectivity, got %v", deps)
+			}
+		} else {
+			if got, ok := kasConfig.APIServerArguments["egress-selector-config-file"]; ok {
+				t.Errorf("expected no egress-selector-config-file with konnectivity disabled, got %v", got)
+			}
+			if statErr == nil {
+				t.Errorf("expected no egress selector config with konnectivity disabled")
+			}
+			if deps := s.Dependencies(); !reflect.DeepEqual(deps, []string{"etcd"}) {
+				t.Errorf("expected kube-apiserver to depend on etcd only, got %v", deps)
+			}
+		}
+	}
+}
+
+func TestKonnectivityServiceRegistration(t *testing.T) {
+	var ttests = []struct {
+		enabled      bool
+		registered   bool
+		wantRegister bool
+	}{
+		{enabled: false, registered: false, wantRegister: true},
+		{enabled: true, registered: true, wantRegister: true},
+		{enabled: true, registered: false, wantRegister: false},
+	}
+
+	for _, tt := range ttests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.Konnectivity.Enabled = tt.enabled
+		cfg.APIServer.Konnectivity.UDSName = "/run/konnectivity/konnectivity-server.socket"
+
+		m := servicemanager.NewServiceManager()
+		if err := m.AddService(servicemanager.NewGenericService("etcd", nil, nil)); err != nil {
+			t.Fatalf("failed to add etcd: %v", err)
+		}
+		if tt.registered {
+			if err := m.AddService(NewKonnectivity(cfg)); err != nil {
+				t.Fatalf("failed to add konnectivity: %v", err)
+			}
+		}
+		if err := m.AddService(NewKubeAPIServer(cfg)); (err == nil) != tt.wantRegister {
+			t.Errorf("adding kube-apiserver with konnectivity enabled=%v registered=%v: error = %v, wantRegister %v", tt.enabled, tt.registered, err, tt.wantRegister)
+		}
+	}
+}
+
+func TestKonnectivityReady(t *testing.T) {
+	udsName := filepath.Join(t.TempDir(), "konnectivity-server.socket")
+	l, err := net.Listen("unix", udsName)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", udsName, err)
+	}
+	defer l.Close()
+
+	cfg := config.NewMicroshiftConfig()
+	cfg.APIServer.Konnectivity.UDSName = udsName
+	s := NewKonnectivity(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go s.Run(ctx, ready, stopped)
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("konnectivity did not become ready")
+	}
+	cancel()
+	<-stopped
+}
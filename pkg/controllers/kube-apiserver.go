@@ -24,7 +24,10 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -42,11 +45,16 @@ import (
 
 	embedded "github.com/openshift/microshift/assets"
 	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util"
 	"github.com/openshift/microshift/pkg/util/cryptomaterial"
 )
 
 const (
 	kubeAPIStartupTimeout = 60
+	// etcd may take a while to serve clients after it reported ready, e.g. on slow disks
+	etcdClientWaitTimeout = 60 * time.Second
+	etcdClientEndpoint    = "127.0.0.1:2379"
+	endpointPollInterval  = 500 * time.Millisecond
 )
 
 var baseKubeAPIServerConfigs = [][]byte{
@@ -55,6 +63,15 @@ var baseKubeAPIServerConfigs = [][]byte{
 	embedded.MustAsset("components/kube-apiserver/config-overrides.yaml"),
 }
 
+// sccAdmissionPlugins enforce the SCCs, which would reject all pods without the
+// default SCCs
+var sccAdmissionPlugins = []string{
+	"security.openshift.io/DefaultSecurityContextConstraints",
+	"security.openshift.io/SCCExecRestrictions",
+	"security.openshift.io/SecurityContextConstraint",
+	"security.openshift.io/ValidateSecurityContextConstraints",
+}
+
 var fixedTLSProfile *configv1.TLSProfileSpec
 
 func init() {
@@ -70,8 +87,19 @@ type KubeAPIServer struct {
 	verbosity      int
 	configureErr   error // todo: report configuration errors immediately
 
-	masterURL     string
-	servingCAPath string
+	masterURL      string
+	servingCAPath  string
+	clientCertPath string
+	clientKeyPath  string
+	auditLogDir    string
+
+	// etcdEndpoint is waited for with backoff before starting the apiserver, which
+	// exits if it can't reach etcd
+	etcdEndpoint    string
+	etcdWaitTimeout time.Duration
+
+	// konnectivity makes the apiserver wait for and proxy through the konnectivity server
+	konnectivity bool
 }
 
 func NewKubeAPIServer(cfg *config.MicroshiftConfig) *KubeAPIServer {
@@ -82,13 +110,18 @@ func NewKubeAPIServer(cfg *config.MicroshiftConfig) *KubeAPIServer {
 	return s
 }
 
-func (s *KubeAPIServer) Name() string           { return "kube-apiserver" }
-func (s *KubeAPIServer) Dependencies() []string { return []string{"etcd"} }
+func (s *KubeAPIServer) Name() string { return "kube-apiserver" }
+func (s *KubeAPIServer) Dependencies() []string {
+	if s.konnectivity {
+		return []string{"etcd", "konnectivity"}
+	}
+	return []string{"etcd"}
+}
 
 func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 	s.verbosity = cfg.LogVLevel
 
-	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
 	kubeCSRSignerDir := cryptomaterial.CSRSignerCertDir(certsDir)
 	kubeletClientDir := cryptomaterial.KubeAPIServerToKubeletClientCertDir(certsDir)
 	clientCABundlePath := cryptomaterial.TotalClientCABundlePath(certsDir)
@@ -109,29 +142,72 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 		return err
 	}
 
+	if cfg.APIServer.AnonymousAuth {
+		klog.Warningf("%s allows anonymous requests, disable apiServer.anonymousAuth to reject them", s.Name())
+	}
+
+	corsAllowedOrigins := []string{
+		`//127\.0\.0\.1(:|$)`,
+		`//localhost(:|$)`,
+	}
+	// web consoles served under the external hostname call the apiserver cross-origin
+	if host := cfg.APIServer.ExternalHostname(); host != "" {
+		corsAllowedOrigins = append(corsAllowedOrigins, "//"+regexp.QuoteMeta(host)+"(:|$)")
+	}
+
+	// tokens issued before the issuer or signing key changed stay valid until replaced
+	serviceAccountIssuers := []string{cfg.APIServer.ServiceAccountIssuer}
+	if previous, err := os.ReadFile(cfg.ServiceAccountPreviousIssuerPath()); err == nil && string(previous) != cfg.APIServer.ServiceAccountIssuer {
+		serviceAccountIssuers = append(serviceAccountIssuers, string(previous))
+	}
+	serviceAccountPublicKeys := []string{cfg.ServiceAccountPublicKeyPath()}
+	if _, err := os.Stat(cfg.ServiceAccountPreviousPublicKeyPath()); err == nil {
+		serviceAccountPublicKeys = append(serviceAccountPublicKeys, cfg.ServiceAccountPreviousPublicKeyPath())
+	}
+
 	s.masterURL = cfg.Cluster.URL
 	s.servingCAPath = cryptomaterial.ServiceAccountTokenCABundlePath(certsDir)
+	// the readiness check authenticates, as anonymous requests may be disabled
+	s.clientCertPath = cryptomaterial.ClientCertPath(cryptomaterial.AdminKubeconfigClientCertDir(certsDir))
+	s.clientKeyPath = cryptomaterial.ClientKeyPath(cryptomaterial.AdminKubeconfigClientCertDir(certsDir))
+	s.auditLogDir = cfg.AuditLogDir
+	s.etcdEndpoint = etcdClientEndpoint
+	s.etcdWaitTimeout = etcdClientWaitTimeout
 
 	overrides := &kubecontrolplanev1.KubeAPIServerConfig{
 		APIServerArguments: map[string]kubecontrolplanev1.Arguments{
 			"advertise-address": {cfg.NodeIP},
-			"audit-policy-file": {microshiftDataDir + "/resources/kube-apiserver-audit-policies/default.yaml"},
+			"anonymous-auth":    {strconv.FormatBool(cfg.APIServer.AnonymousAuth)},
+			"audit-log-format":  {cfg.APIServer.AuditLogFormat},
+			"audit-log-path":    {filepath.Join(cfg.AuditLogDir, "audit.log")},
+			"audit-policy-file": {auditPolicyPath(cfg)},
 			"client-ca-file":    {clientCABundlePath},
 			"etcd-cafile":       {cryptomaterial.CACertPath(cryptomaterial.EtcdSignerDir(certsDir))},
 			"etcd-certfile":     {cryptomaterial.ClientCertPath(etcdClientCertDir)},
 			"etcd-keyfile":      {cryptomaterial.ClientKeyPath(etcdClientCertDir)},
 			"etcd-servers": {
-				"https://127.0.0.1:2379",
+				"https://" + etcdClientEndpoint,
 			},
+			"enable-bootstrap-token-auth":   {strconv.FormatBool(cfg.APIServer.BootstrapTokenAuth)},
+			"event-ttl":                     {cfg.APIServer.EventTTL},
+			"goaway-chance":                 {strconv.FormatFloat(cfg.APIServer.GoawayChance, 'f', -1, 64)},
 			"kubelet-certificate-authority": {cryptomaterial.CABundlePath(kubeCSRSignerDir)},
 			"kubelet-client-certificate":    {cryptomaterial.ClientCertPath(kubeletClientDir)},
 			"kubelet-client-key":            {cryptomaterial.ClientKeyPath(kubeletClientDir)},
+			"profiling":                     {strconv.FormatBool(cfg.APIServer.Profiling)},
 
+			"enable-aggregator-routing":        {strconv.FormatBool(cfg.APIServer.AggregatorRouting)},
 			"proxy-client-cert-file":           {cryptomaterial.ClientCertPath(aggregatorClientCertDir)},
 			"proxy-client-key-file":            {cryptomaterial.ClientKeyPath(aggregatorClientCertDir)},
+			"requestheader-allowed-names":      {cryptomaterial.AggregatorClientUserName},
 			"requestheader-client-ca-file":     {aggregatorCAPath},
-			"service-account-signing-key-file": {microshiftDataDir + "/resources/kube-apiserver/secrets/service-account-key/service-account.key"},
+			"service-account-issuer":           serviceAccountIssuers,
+			"api-audiences":                    serviceAccountIssuers,
+			"service-account-signing-key-file": {cfg.ServiceAccountSigningKeyPath()},
 			"service-node-port-range":          {cfg.Cluster.ServiceNodePortRange},
+			"shutdown-delay-duration":          {cfg.APIServer.ShutdownDelayDuration},
+			"shutdown-send-retry-after":        {strconv.FormatBool(cfg.APIServer.ShutdownSendRetryAfter)},
+			"storage-media-type":               {cfg.APIServer.StorageMediaType},
 			"tls-cert-file":                    {servingCert},
 			"tls-private-key-file":             {servingKey},
 			"disable-admission-plugins": {
@@ -156,10 +232,7 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 		},
 		GenericAPIServerConfig: configv1.GenericAPIServerConfig{
 			// from cluster-kube-apiserver-operator
-			CORSAllowedOrigins: []string{
-				`//127\.0\.0\.1(:|$)`,
-				`//localhost(:|$)`,
-			},
+			CORSAllowedOrigins: corsAllowedOrigins,
 			ServingInfo: configv1.HTTPServingInfo{
 				ServingInfo: configv1.ServingInfo{
 					BindAddress:   net.JoinHostPort("0.0.0.0", strconv.Itoa(apiServerPort)),
@@ -188,11 +261,55 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 				},
 			},
 		},
-		ServiceAccountPublicKeyFiles: []string{
-			microshiftDataDir + "/resources/kube-apiserver/secrets/service-account-key/service-account.crt",
-		},
-		ServicesSubnet:        cfg.Cluster.ServiceCIDR,
-		ServicesNodePortRange: cfg.Cluster.ServiceNodePortRange,
+		ServiceAccountPublicKeyFiles: serviceAccountPublicKeys,
+		ServicesSubnet:               strings.Join(cfg.ServiceCIDRs(), ","),
+		ServicesNodePortRange:        cfg.Cluster.ServiceNodePortRange,
+	}
+
+	// the named certificates become --tls-sni-cert-key arguments
+	for _, sniCert := range cfg.APIServer.SNICerts {
+		overrides.ServingInfo.NamedCertificates = append(overrides.ServingInfo.NamedCertificates, configv1.NamedCertificate{
+			Names: sniCert.Names,
+			CertInfo: configv1.CertInfo{
+				CertFile: sniCert.CertFile,
+				KeyFile:  sniCert.KeyFile,
+			},
+		})
+	}
+
+	if cfg.APIServer.Konnectivity.Enabled {
+		egressSelectorConfig, err := s.configureEgressSelector(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to configure kube-apiserver egress selector: %w", err)
+		}
+		s.konnectivity = true
+		overrides.APIServerArguments["egress-selector-config-file"] = kubecontrolplanev1.Arguments{egressSelectorConfig}
+	}
+	if cfg.APIServer.EgressSelectorConfigFile != "" {
+		overrides.APIServerArguments["egress-selector-config-file"] = kubecontrolplanev1.Arguments{cfg.APIServer.EgressSelectorConfigFile}
+	}
+
+	overrides.APIServerArguments["watch-cache"] = kubecontrolplanev1.Arguments{strconv.FormatBool(cfg.APIServer.WatchCache)}
+	if sizes := watchCacheSizes(cfg.APIServer.DisabledWatchCaches); len(sizes) > 0 {
+		overrides.APIServerArguments["watch-cache-sizes"] = sizes
+	}
+	overrides.APIServerArguments["delete-collection-workers"] = kubecontrolplanev1.Arguments{strconv.Itoa(cfg.APIServer.DeleteCollectionWorkers)}
+	overrides.APIServerArguments["authorization-mode"] = kubecontrolplanev1.Arguments(cfg.APIServer.AuthorizationModes)
+	if cfg.APIServer.AuthorizationWebhookConfigFile != "" {
+		overrides.APIServerArguments["authorization-webhook-config-file"] = kubecontrolplanev1.Arguments{cfg.APIServer.AuthorizationWebhookConfigFile}
+	}
+	if !cfg.ComponentEnabled(config.ComponentOpenShiftSCC) {
+		overrides.APIServerArguments["disable-admission-plugins"] = append(overrides.APIServerArguments["disable-admission-plugins"], sccAdmissionPlugins...)
+	}
+
+	// user provided arguments must not override the ones managed by MicroShift
+	managedArgs := make([]string, 0, len(overrides.APIServerArguments))
+	for name := range overrides.APIServerArguments {
+		managedArgs = append(managedArgs, name)
+	}
+	extraArgs, _ := util.FilterExtraArgs(s.Name(), managedArgs, cfg.APIServer.ExtraArgs)
+	for name, values := range extraArgs {
+		overrides.APIServerArguments[name] = values
 	}
 
 	overridesBytes, err := json.Marshal(overrides)
@@ -232,6 +349,10 @@ func (s *KubeAPIServer) configure(cfg *config.MicroshiftConfig) error {
 	return nil
 }
 
+func auditPolicyPath(cfg *config.MicroshiftConfig) string {
+	return filepath.Join(cfg.RoleDataDir(config.ControlPlaneRole), "resources", "kube-apiserver-audit-policies", "default.yaml")
+}
+
 func (s *KubeAPIServer) configureAuditPolicy(cfg *config.MicroshiftConfig) error {
 	data := []byte(`
 apiVersion: audit.k8s.io/v1
@@ -267,11 +388,44 @@ rules:
   omitStages:
   - "RequestReceived"`)
 
-	path := filepath.Join(microshiftDataDir, "resources", "kube-apiserver-audit-policies", "default.yaml")
+	path := auditPolicyPath(cfg)
 	os.MkdirAll(filepath.Dir(path), os.FileMode(0700))
 	return os.WriteFile(path, data, 0644)
 }
 
+// watchCacheSizes returns the disabled caches in the resource[.group]#0 format of
+// --watch-cache-sizes, sorted to keep the arguments stable across restarts. The
+// apiserver only honors a size of 0 for single resources.
+func watchCacheSizes(disabled []string) kubecontrolplanev1.Arguments {
+	args := kubecontrolplanev1.Arguments{}
+	for _, resource := range disabled {
+		args = append(args, resource+"#0")
+	}
+	sort.Strings(args)
+	return args
+}
+
+// configureEgressSelector writes the configuration proxying the apiserver's traffic to
+// the cluster through the konnectivity server and returns its path.
+func (s *KubeAPIServer) configureEgressSelector(cfg *config.MicroshiftConfig) (string, error) {
+	data := []byte(fmt.Sprintf(`
+apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: GRPC
+    transport:
+      uds:
+        udsName: %s`, cfg.APIServer.Konnectivity.UDSName))
+
+	path := filepath.Join(cfg.RoleDataDir(config.ControlPlaneRole), "resources", "kube-apiserver", "egress-selector-config.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0700)); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0644)
+}
+
 func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	if s.configureErr != nil {
 		return fmt.Errorf("configuration failed: %w", s.configureErr)
@@ -282,6 +436,10 @@ func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	if err := waitForEndpoint(ctx, s.etcdEndpoint, s.etcdWaitTimeout); err != nil {
+		return fmt.Errorf("etcd is not reachable at %s: %w", s.etcdEndpoint, err)
+	}
+
 	// run readiness check
 	go func() {
 		err := wait.PollImmediateWithContext(ctx, time.Second, kubeAPIStartupTimeout*time.Second, func(ctx context.Context) (bool, error) {
@@ -294,6 +452,8 @@ func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped
 			}
 			restConfig.NegotiatedSerializer = serializer.NewCodecFactory(runtime.NewScheme())
 			restConfig.CAFile = s.servingCAPath
+			restConfig.CertFile = s.clientCertPath
+			restConfig.KeyFile = s.clientKeyPath
 
 			restClient, err := rest.UnversionedRESTClientFor(restConfig)
 			if err != nil {
@@ -341,7 +501,7 @@ func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped
 	}
 
 	// audit logs go here
-	os.MkdirAll("/var/log/kube-apiserver", 0700)
+	os.MkdirAll(s.auditLogDir, 0700)
 
 	// Carrying a patch for NewAPIServerCommand to use cmd.Context().Done() as the stop channel
 	// instead of the channel returned by SetupSignalHandler, which expects to be called at most
@@ -356,3 +516,27 @@ func (s *KubeAPIServer) Run(ctx context.Context, ready chan<- struct{}, stopped
 	}()
 	return <-errorChannel
 }
+
+// waitForEndpoint retries connecting to the TCP endpoint every endpointPollInterval
+// until it accepts connections or the timeout expires.
+func waitForEndpoint(ctx context.Context, endpoint string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lastErr error
+	err := wait.PollImmediateUntilWithContext(ctx, endpointPollInterval, func(ctx context.Context) (bool, error) {
+		dialer := net.Dialer{Timeout: time.Second}
+		conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+		if err != nil {
+			klog.V(2).Infof("waiting for %s: %v", endpoint, err)
+			lastErr = err
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+	if err != nil && lastErr != nil {
+		return fmt.Errorf("%w, last error: %v", err, lastErr)
+	}
+	return err
+}
@@ -0,0 +1,707 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	kubecontrolplanev1 "github.com/openshift/api/kubecontrolplane/v1"
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"sigs.k8s.io/yaml"
+)
+
+func TestKubeAPIServerExtraArgs(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.APIServer.ExtraArgs = map[string][]string{
+		"advertise-address":     {"10.0.0.1"},
+		"max-requests-inflight": {"800"},
+		"cors-allowed-origins":  {"//127.0.0.1", "//localhost"},
+	}
+
+	s := NewKubeAPIServer(cfg)
+	if s.configureErr != nil {
+		t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+	}
+
+	var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+	if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+		t.Fatalf("failed to parse kube-apiserver config: %v", err)
+	}
+
+	expected := map[string]kubecontrolplanev1.Arguments{
+		"advertise-address":     {cfg.NodeIP},
+		"max-requests-inflight": {"800"},
+		"cors-allowed-origins":  {"//127.0.0.1", "//localhost"},
+	}
+	for name, values := range expected {
+		if !reflect.DeepEqual(kasConfig.APIServerArguments[name], values) {
+			t.Errorf("argument %q: expected %v, got %v", name, values, kasConfig.APIServerArguments[name])
+		}
+	}
+}
+
+func TestKubeAPIServerAuditLogFormat(t *testing.T) {
+	for _, format := range []string{config.AuditLogFormatJSON, config.AuditLogFormatLegacy} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.AuditLogFormat = format
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		expected := kubecontrolplanev1.Arguments{format}
+		if got := kasConfig.APIServerArguments["audit-log-format"]; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected audit-log-format %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestKubeAPIServerStorageMediaType(t *testing.T) {
+	for _, mediaType := range []string{config.StorageMediaTypeProtobuf, config.StorageMediaTypeJSON} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.StorageMediaType = mediaType
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		expected := kubecontrolplanev1.Arguments{mediaType}
+		if got := kasConfig.APIServerArguments["storage-media-type"]; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected storage-media-type %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestKubeAPIServerGoawayChance(t *testing.T) {
+	var tests = []struct {
+		chance   float64
+		expected string
+	}{
+		{chance: 0, expected: "0"},
+		{chance: 0.001, expected: "0.001"},
+		{chance: 0.02, expected: "0.02"},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.GoawayChance = tt.chance
+		cfg.APIServer.ExtraArgs = map[string][]string{"goaway-chance": {"0.5"}}
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		expected := kubecontrolplanev1.Arguments{tt.expected}
+		if got := kasConfig.APIServerArguments["goaway-chance"]; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected goaway-chance %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestKubeAPIServerSecurityFlags(t *testing.T) {
+	var ttests = []struct {
+		anonymousAuth      bool
+		profiling          bool
+		bootstrapTokenAuth bool
+		expected           map[string]kubecontrolplanev1.Arguments
+	}{
+		{
+			anonymousAuth:      false,
+			profiling:          false,
+			bootstrapTokenAuth: false,
+			expected: map[string]kubecontrolplanev1.Arguments{
+				"anonymous-auth":              {"false"},
+				"profiling":                   {"false"},
+				"enable-bootstrap-token-auth": {"false"},
+			},
+		},
+		{
+			anonymousAuth:      true,
+			profiling:          true,
+			bootstrapTokenAuth: true,
+			expected: map[string]kubecontrolplanev1.Arguments{
+				"anonymous-auth":              {"true"},
+				"profiling":                   {"true"},
+				"enable-bootstrap-token-auth": {"true"},
+			},
+		},
+	}
+
+	for _, tt := range ttests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.AnonymousAuth = tt.anonymousAuth
+		cfg.APIServer.Profiling = tt.profiling
+		cfg.APIServer.BootstrapTokenAuth = tt.bootstrapTokenAuth
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		for name, values := range tt.expected {
+			if got := kasConfig.APIServerArguments[name]; !reflect.DeepEqual(got, values) {
+				t.Errorf("anonymousAuth=%v profiling=%v bootstrapTokenAuth=%v: expected %s %v, got %v", tt.anonymousAuth, tt.profiling, tt.bootstrapTokenAuth, name, values, got)
+			}
+		}
+	}
+}
+
+func TestKubeAPIServerExternalURL(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.APIServer.ExternalURL = "https://api.example.com"
+
+	s := NewKubeAPIServer(cfg)
+	if s.configureErr != nil {
+		t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+	}
+
+	var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+	if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+		t.Fatalf("failed to parse kube-apiserver config: %v", err)
+	}
+	expected := []string{`//127\.0\.0\.1(:|$)`, `//localhost(:|$)`, `//api\.example\.com(:|$)`}
+	if got := kasConfig.CORSAllowedOrigins; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected CORS allowed origins %v, got %v", expected, got)
+	}
+}
+
+func TestKubeAPIServerRoleDataDir(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.RoleDataDirs = true
+
+	s := NewKubeAPIServer(cfg)
+	if s.configureErr != nil {
+		t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+	}
+
+	var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+	if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+		t.Fatalf("failed to parse kube-apiserver config: %v", err)
+	}
+	controlPlaneDir := filepath.Join(cfg.DataDir, config.ControlPlaneRole)
+	expected := map[string]kubecontrolplanev1.Arguments{
+		"audit-policy-file":                {filepath.Join(controlPlaneDir, "resources", "kube-apiserver-audit-policies", "default.yaml")},
+		"service-account-signing-key-file": {filepath.Join(controlPlaneDir, "resources", "kube-apiserver", "secrets", "service-account-key", "service-account.key")},
+	}
+	for name, values := range expected {
+		if !reflect.DeepEqual(kasConfig.APIServerArguments[name], values) {
+			t.Errorf("argument %q: expected %v, got %v", name, values, kasConfig.APIServerArguments[name])
+		}
+	}
+}
+
+func TestServiceAccountIssuerAndSigningKey(t *testing.T) {
+	var tests = []struct {
+		name          string
+		signingKey    string
+		wantSigning   string
+		wantPublicKey string
+	}{
+		{
+			name:          "generated key",
+			wantSigning:   "service-account.key",
+			wantPublicKey: "service-account.crt",
+		},
+		{
+			name:          "configured key",
+			signingKey:    "/etc/microshift/sa.key",
+			wantSigning:   "/etc/microshift/sa.key",
+			wantPublicKey: "/etc/microshift/sa.key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewMicroshiftConfig()
+			cfg.DataDir = t.TempDir()
+			cfg.APIServer.ServiceAccountIssuer = "https://oidc.example.com/microshift"
+			cfg.APIServer.ServiceAccountSigningKeyFile = tt.signingKey
+			if !filepath.IsAbs(tt.wantSigning) {
+				tt.wantSigning = filepath.Join(cfg.ServiceAccountKeyDir(), tt.wantSigning)
+				tt.wantPublicKey = filepath.Join(cfg.ServiceAccountKeyDir(), tt.wantPublicKey)
+			}
+
+			s := NewKubeAPIServer(cfg)
+			if s.configureErr != nil {
+				t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+			}
+			var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+			if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+				t.Fatalf("failed to parse kube-apiserver config: %v", err)
+			}
+			expected := map[string]kubecontrolplanev1.Arguments{
+				"service-account-issuer":           {"https://oidc.example.com/microshift"},
+				"api-audiences":                    {"https://oidc.example.com/microshift"},
+				"service-account-signing-key-file": {tt.wantSigning},
+			}
+			for name, values := range expected {
+				if !reflect.DeepEqual(kasConfig.APIServerArguments[name], values) {
+					t.Errorf("argument %q: expected %v, got %v", name, values, kasConfig.APIServerArguments[name])
+				}
+			}
+			if got := kasConfig.ServiceAccountPublicKeyFiles; !reflect.DeepEqual(got, []string{tt.wantPublicKey}) {
+				t.Errorf("expected service account public key files [%s], got %v", tt.wantPublicKey, got)
+			}
+
+			// the controller-manager signs the legacy service account tokens
+			if got := NewKubeControllerManager(cfg).kubecmOptions.SAController.ServiceAccountKeyFile; got != tt.wantSigning {
+				t.Errorf("expected kube-controller-manager to sign with %s, got %s", tt.wantSigning, got)
+			}
+		})
+	}
+}
+
+func TestServiceAccountPreviousIssuer(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.APIServer.ServiceAccountIssuer = "https://oidc.example.com/microshift"
+	if err := os.MkdirAll(cfg.ServiceAccountKeyDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.ServiceAccountPreviousIssuerPath(), []byte("https://kubernetes.default.svc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.ServiceAccountPreviousPublicKeyPath(), []byte("previous"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewKubeAPIServer(cfg)
+	if s.configureErr != nil {
+		t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+	}
+	var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+	if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+		t.Fatalf("failed to parse kube-apiserver config: %v", err)
+	}
+	// the first issuer signs new tokens
+	issuers := kubecontrolplanev1.Arguments{"https://oidc.example.com/microshift", "https://kubernetes.default.svc"}
+	for _, name := range []string{"service-account-issuer", "api-audiences"} {
+		if got := kasConfig.APIServerArguments[name]; !reflect.DeepEqual(got, issuers) {
+			t.Errorf("argument %q: expected %v, got %v", name, issuers, got)
+		}
+	}
+	publicKeys := []string{cfg.ServiceAccountPublicKeyPath(), cfg.ServiceAccountPreviousPublicKeyPath()}
+	if got := kasConfig.ServiceAccountPublicKeyFiles; !reflect.DeepEqual(got, publicKeys) {
+		t.Errorf("expected service account public key files %v, got %v", publicKeys, got)
+	}
+}
+
+func TestKubeAPIServerSNICerts(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.APIServer.SNICerts = []config.SNICert{
+		{CertFile: "/etc/microshift/certs/api.crt", KeyFile: "/etc/microshift/certs/api.key"},
+		{CertFile: "/etc/microshift/certs/wildcard.crt", KeyFile: "/etc/microshift/certs/wildcard.key", Names: []string{"*.example.com"}},
+	}
+
+	s := NewKubeAPIServer(cfg)
+	if s.configureErr != nil {
+		t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+	}
+
+	var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+	if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+		t.Fatalf("failed to parse kube-apiserver config: %v", err)
+	}
+	named := kasConfig.ServingInfo.NamedCertificates
+	// the SNI certificates follow the serving certificates managed by MicroShift
+	if len(named) != 5 {
+		t.Fatalf("expected 3 managed and 2 SNI certificates, got %+v", named)
+	}
+	expected := []configv1.NamedCertificate{
+		{CertInfo: configv1.CertInfo{CertFile: "/etc/microshift/certs/api.crt", KeyFile: "/etc/microshift/certs/api.key"}},
+		{Names: []string{"*.example.com"}, CertInfo: configv1.CertInfo{CertFile: "/etc/microshift/certs/wildcard.crt", KeyFile: "/etc/microshift/certs/wildcard.key"}},
+	}
+	if !reflect.DeepEqual(named[3:], expected) {
+		t.Errorf("expected the SNI certificates %+v, got %+v", expected, named[3:])
+	}
+}
+
+func TestKubeAPIServerEgressSelectorConfigFile(t *testing.T) {
+	for _, file := range []string{"", "/etc/microshift/egress-selector.yaml"} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.EgressSelectorConfigFile = file
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		got, ok := kasConfig.APIServerArguments["egress-selector-config-file"]
+		if file == "" && ok {
+			t.Errorf("expected no egress-selector-config-file when unset, got %v", got)
+		}
+		if file != "" && !reflect.DeepEqual(got, kubecontrolplanev1.Arguments{file}) {
+			t.Errorf("expected egress-selector-config-file %q, got %v", file, got)
+		}
+	}
+}
+
+func TestKubeAPIServerWatchCache(t *testing.T) {
+	var tests = []struct {
+		watchCache bool
+		disabled   []string
+		expected   kubecontrolplanev1.Arguments
+	}{
+		{watchCache: true, disabled: nil, expected: nil},
+		{watchCache: false, disabled: nil, expected: nil},
+		{watchCache: true, disabled: []string{"secrets", "events", "deployments.apps"}, expected: kubecontrolplanev1.Arguments{"deployments.apps#0", "events#0", "secrets#0"}},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.WatchCache = tt.watchCache
+		cfg.APIServer.DisabledWatchCaches = tt.disabled
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		if got := kasConfig.APIServerArguments["watch-cache"]; !reflect.DeepEqual(got, kubecontrolplanev1.Arguments{strconv.FormatBool(tt.watchCache)}) {
+			t.Errorf("expected watch-cache %v, got %v", tt.watchCache, got)
+		}
+		if got := kasConfig.APIServerArguments["watch-cache-sizes"]; !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("expected watch-cache-sizes %v, got %v", tt.expected, got)
+		}
+	}
+}
+
+func TestKubeAPIServerTunables(t *testing.T) {
+	var tests = []struct {
+		deleteCollectionWorkers int
+	}{
+		{deleteCollectionWorkers: 1},
+		{deleteCollectionWorkers: 4},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.DeleteCollectionWorkers = tt.deleteCollectionWorkers
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		if got, ok := kasConfig.APIServerArguments["default-watch-cache-size"]; ok {
+			t.Errorf("expected the deprecated default-watch-cache-size not to be set, got %v", got)
+		}
+		if got, expected := kasConfig.APIServerArguments["delete-collection-workers"], (kubecontrolplanev1.Arguments{strconv.Itoa(tt.deleteCollectionWorkers)}); !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected delete-collection-workers %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestKubeAPIServerAuthorization(t *testing.T) {
+	var tests = []struct {
+		modes []string
+		file  string
+	}{
+		{modes: []string{"Scope", "SystemMasters", "RBAC", "Node"}, file: ""},
+		{modes: []string{"Node", "RBAC", "Webhook"}, file: "/etc/microshift/authz-webhook.kubeconfig"},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.AuthorizationModes = tt.modes
+		cfg.APIServer.AuthorizationWebhookConfigFile = tt.file
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		if got := kasConfig.APIServerArguments["authorization-mode"]; !reflect.DeepEqual(got, kubecontrolplanev1.Arguments(tt.modes)) {
+			t.Errorf("expected authorization-mode %v, got %v", tt.modes, got)
+		}
+		got, ok := kasConfig.APIServerArguments["authorization-webhook-config-file"]
+		if tt.file == "" && ok {
+			t.Errorf("expected no authorization-webhook-config-file when unset, got %v", got)
+		}
+		if tt.file != "" && !reflect.DeepEqual(got, kubecontrolplanev1.Arguments{tt.file}) {
+			t.Errorf("expected authorization-webhook-config-file %q, got %v", tt.file, got)
+		}
+	}
+}
+
+func TestKubeAPIServerAggregation(t *testing.T) {
+	for _, routing := range []bool{true, false} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.AggregatorRouting = routing
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+		clientCertDir := cryptomaterial.AggregatorClientCertDir(certsDir)
+		expected := map[string]kubecontrolplanev1.Arguments{
+			"enable-aggregator-routing":    {strconv.FormatBool(routing)},
+			"proxy-client-cert-file":       {cryptomaterial.ClientCertPath(clientCertDir)},
+			"proxy-client-key-file":        {cryptomaterial.ClientKeyPath(clientCertDir)},
+			"requestheader-allowed-names":  {"system:openshift-aggregator"},
+			"requestheader-client-ca-file": {cryptomaterial.CACertPath(cryptomaterial.AggregatorSignerDir(certsDir))},
+		}
+		for name, want := range expected {
+			if got := kasConfig.APIServerArguments[name]; !reflect.DeepEqual(got, want) {
+				t.Errorf("aggregator routing %t: expected %s %v, got %v", routing, name, want, got)
+			}
+		}
+	}
+}
+
+func TestKubeAPIServerSCCAdmission(t *testing.T) {
+	for _, sccEnabled := range []bool{true, false} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		if !sccEnabled {
+			cfg.Components.Disabled = []string{config.ComponentOpenShiftSCC}
+		}
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		enabled := kasConfig.APIServerArguments["enable-admission-plugins"]
+		for _, plugin := range sccAdmissionPlugins {
+			if got := config.StringInList(plugin, enabled); got != sccEnabled {
+				t.Errorf("expected %s to be enabled %v with the SCCs enabled %v, got %v", plugin, sccEnabled, sccEnabled, got)
+			}
+		}
+		if !config.StringInList("ValidatingAdmissionWebhook", enabled) {
+			t.Errorf("expected the other admission plugins to be kept, got %v", enabled)
+		}
+	}
+}
+
+func TestKubeAPIServerShutdown(t *testing.T) {
+	var tests = []struct {
+		delay          string
+		sendRetryAfter bool
+	}{
+		{delay: "0s", sendRetryAfter: true},
+		{delay: "15s", sendRetryAfter: false},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.ShutdownDelayDuration = tt.delay
+		cfg.APIServer.ShutdownSendRetryAfter = tt.sendRetryAfter
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		expected := map[string]kubecontrolplanev1.Arguments{
+			"shutdown-delay-duration":   {tt.delay},
+			"shutdown-send-retry-after": {strconv.FormatBool(tt.sendRetryAfter)},
+		}
+		for name, values := range expected {
+			if !reflect.DeepEqual(kasConfig.APIServerArguments[name], values) {
+				t.Errorf("argument %q: expected %v, got %v", name, values, kasConfig.APIServerArguments[name])
+			}
+		}
+	}
+}
+
+func TestKubeAPIServerEventTTL(t *testing.T) {
+	for _, ttl := range []string{"", "2h"} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		expected := kubecontrolplanev1.Arguments{"30m"}
+		if ttl != "" {
+			cfg.APIServer.EventTTL = ttl
+			expected = kubecontrolplanev1.Arguments{ttl}
+		}
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		if got := kasConfig.APIServerArguments["event-ttl"]; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected event-ttl %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestKubeAPIServerDualStack(t *testing.T) {
+	for primary, expected := range map[string]string{
+		"":     "10.43.0.0/16,fd02::/112",
+		"IPv4": "10.43.0.0/16,fd02::/112",
+		"IPv6": "fd02::/112,10.43.0.0/16",
+	} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Cluster.IPFamilyPolicy = config.IPFamilyPolicyDualStack
+		cfg.Cluster.PrimaryIPFamily = primary
+		cfg.Cluster.ClusterCIDR = "10.42.0.0/16,fd01::/48"
+		cfg.Cluster.ServiceCIDR = "10.43.0.0/16,fd02::/112"
+
+		s := NewKubeAPIServer(cfg)
+		if s.configureErr != nil {
+			t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+		}
+
+		var kasConfig kubecontrolplanev1.KubeAPIServerConfig
+		if err := yaml.Unmarshal(s.kasConfigBytes, &kasConfig); err != nil {
+			t.Fatalf("failed to parse kube-apiserver config: %v", err)
+		}
+		if kasConfig.ServicesSubnet != expected {
+			t.Errorf("expected services subnet %q with primary family %q, got %q", expected, primary, kasConfig.ServicesSubnet)
+		}
+	}
+}
+
+// testWaitForEndpoint checks that waitForEndpoint waits for an endpoint that starts
+// listening after delay.
+func testWaitForEndpoint(t *testing.T, delay, timeout time.Duration) {
+	// reserve a free port for the fake etcd, which starts listening after a delay
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := l.Addr().String()
+	l.Close()
+
+	listening := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(delay)
+		l, err := net.Listen("tcp", endpoint)
+		if err != nil {
+			t.Errorf("failed to start the fake etcd: %v", err)
+		}
+		listening <- l
+	}()
+	defer func() {
+		if l := <-listening; l != nil {
+			l.Close()
+		}
+	}()
+
+	start := time.Now()
+	if err := waitForEndpoint(context.Background(), endpoint, timeout); err != nil {
+		t.Fatalf("waitForEndpoint() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("expected to wait for the endpoint to become available, returned after %s", elapsed)
+	}
+}
+
+func TestWaitForEndpointBecomingAvailable(t *testing.T) {
+	testWaitForEndpoint(t, 500*time.Millisecond, 10*time.Second)
+}
+
+// the endpoint is polled until the timeout, not for a limited number of attempts
+func TestWaitForEndpointBecomingAvailableLate(t *testing.T) {
+	testWaitForEndpoint(t, 8*time.Second, 15*time.Second)
+}
+
+func TestWaitForEndpointTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := l.Addr().String()
+	l.Close()
+
+	if err := waitForEndpoint(context.Background(), endpoint, 500*time.Millisecond); err == nil {
+		t.Errorf("expected an error for an endpoint that never becomes available")
+	}
+}
+
+func TestKubeAPIServerWaitsForEtcd(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	s := NewKubeAPIServer(cfg)
+	if s.configureErr != nil {
+		t.Fatalf("failed to configure kube-apiserver: %v", s.configureErr)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.etcdEndpoint = l.Addr().String()
+	l.Close()
+	s.etcdWaitTimeout = 200 * time.Millisecond
+
+	stopped := make(chan struct{})
+	if err := s.Run(context.Background(), make(chan struct{}), stopped); err == nil {
+		t.Fatalf("expected kube-apiserver not to start without etcd")
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("expected stopped to be closed")
+	}
+}
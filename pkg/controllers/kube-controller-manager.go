@@ -18,6 +18,7 @@ package controllers
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -38,6 +39,7 @@ type KubeControllerManager struct {
 	kubecmOptions *kubecmoptions.KubeControllerManagerOptions
 	kubeconfig    string
 	kubeadmConfig string
+	namespaces    config.NamespacesConfig
 }
 
 func NewKubeControllerManager(cfg *config.MicroshiftConfig) *KubeControllerManager {
@@ -50,7 +52,7 @@ func (s *KubeControllerManager) Name() string           { return "kube-controlle
 func (s *KubeControllerManager) Dependencies() []string { return []string{"kube-apiserver"} }
 
 func (s *KubeControllerManager) configure(cfg *config.MicroshiftConfig) {
-	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
 	csrSignerDir := cryptomaterial.CSRSignerCertDir(certsDir)
 	kubeconfig := cfg.KubeConfigPath(config.KubeControllerManager)
 	kubeadmConfig := cfg.KubeConfigPath(config.KubeAdmin)
@@ -62,21 +64,41 @@ func (s *KubeControllerManager) configure(cfg *config.MicroshiftConfig) {
 	s.kubecmOptions = opts
 	s.kubeconfig = kubeconfig
 	s.kubeadmConfig = kubeadmConfig
-
-	args := []string{
-		"--kubeconfig=" + kubeconfig,
-		"--service-account-private-key-file=" + microshiftDataDir + "/resources/kube-apiserver/secrets/service-account-key/service-account.key",
-		"--allocate-node-cidrs=true",
-		"--cluster-cidr=" + cfg.Cluster.ClusterCIDR,
-		"--authorization-kubeconfig=" + kubeconfig,
-		"--authentication-kubeconfig=" + kubeconfig,
-		"--root-ca-file=" + cryptomaterial.ServiceAccountTokenCABundlePath(certsDir),
-		"--bind-address=127.0.0.1",
-		"--secure-port=10257",
-		"--leader-elect=false",
-		"--use-service-account-credentials=true",
-		"--cluster-signing-cert-file=" + cryptomaterial.CACertPath(csrSignerDir),
-		"--cluster-signing-key-file=" + cryptomaterial.CAKeyPath(csrSignerDir),
+	s.namespaces = cfg.Namespaces
+
+	args := map[string][]string{
+		"kubeconfig":                       {kubeconfig},
+		"service-account-private-key-file": {cfg.ServiceAccountSigningKeyPath()},
+		"allocate-node-cidrs":              {"true"},
+		"cluster-cidr":                     {strings.Join(cfg.ClusterCIDRs(), ",")},
+		"service-cluster-ip-range":         {strings.Join(cfg.ServiceCIDRs(), ",")},
+		"authorization-kubeconfig":         {kubeconfig},
+		"authentication-kubeconfig":        {kubeconfig},
+		"root-ca-file":                     {cryptomaterial.ServiceAccountTokenCABundlePath(certsDir)},
+		"bind-address":                     {"127.0.0.1"},
+		"secure-port":                      {"10257"},
+		"use-service-account-credentials":  {"true"},
+		"cluster-signing-cert-file":        {cryptomaterial.CACertPath(csrSignerDir)},
+		"cluster-signing-key-file":         {cryptomaterial.CAKeyPath(csrSignerDir)},
+	}
+	if cfg.ControllerManager.ClusterSigningDuration != "" {
+		args["cluster-signing-duration"] = []string{cfg.ControllerManager.ClusterSigningDuration}
+	}
+	for name, values := range leaderElectionArgs(cfg.ControllerManager.LeaderElection) {
+		args[name] = values
+	}
+	args = util.MergeArgs(s.Name(), args, cfg.ControllerManager.ExtraArgs)
+	// the token cleaner is disabled by default upstream, enable it on top of the
+	// controllers of extraArgs
+	if cfg.ControllerManager.TokenCleaner {
+		controllers := args["controllers"]
+		if len(controllers) == 0 {
+			controllers = []string{"*"}
+		}
+		if !config.StringInList("tokencleaner", controllers) {
+			controllers = append(controllers, "tokencleaner")
+		}
+		args["controllers"] = controllers
 	}
 
 	// fake the kube-controller-manager cobra command to parse args into controllermanager options
@@ -93,11 +115,25 @@ func (s *KubeControllerManager) configure(cfg *config.MicroshiftConfig) {
 	for _, f := range namedFlagSets.FlagSets {
 		cmd.Flags().AddFlagSet(f)
 	}
-	if err := cmd.ParseFlags(args); err != nil {
+	if err := cmd.ParseFlags(util.ArgsToFlags(args)); err != nil {
 		klog.Fatalf("%s failed to parse flags: %v", s.Name(), err)
 	}
 }
 
+// leaderElectionArgs returns the leader election flags shared by the components built
+// on component-base.
+func leaderElectionArgs(le config.LeaderElectionConfig) map[string][]string {
+	if !le.Enabled {
+		return map[string][]string{"leader-elect": {"false"}}
+	}
+	return map[string][]string{
+		"leader-elect":                {"true"},
+		"leader-elect-lease-duration": {le.LeaseDuration},
+		"leader-elect-renew-deadline": {le.RenewDeadline},
+		"leader-elect-retry-period":   {le.RetryPeriod},
+	}
+}
+
 func (s *KubeControllerManager) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
 	errorChannel := make(chan error, 1)
@@ -127,7 +163,7 @@ func (s *KubeControllerManager) Run(ctx context.Context, ready chan<- struct{},
 	if err := assets.ApplyNamespaces([]string{
 		"core/namespace-openshift-kube-controller-manager.yaml",
 		"core/namespace-openshift-infra.yaml",
-	}, s.kubeadmConfig); err != nil {
+	}, s.namespaces, s.kubeadmConfig); err != nil {
 		klog.Fatalf("failed to apply openshift namespaces %v", err)
 	}
 
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func TestKubeControllerManagerDualStack(t *testing.T) {
+	var tests = []struct {
+		primary     string
+		clusterCIDR string
+		serviceCIDR string
+	}{
+		{primary: "", clusterCIDR: "10.42.0.0/16,fd01::/48", serviceCIDR: "10.43.0.0/16,fd02::/112"},
+		{primary: "IPv4", clusterCIDR: "10.42.0.0/16,fd01::/48", serviceCIDR: "10.43.0.0/16,fd02::/112"},
+		{primary: "IPv6", clusterCIDR: "fd01::/48,10.42.0.0/16", serviceCIDR: "fd02::/112,10.43.0.0/16"},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Cluster.IPFamilyPolicy = config.IPFamilyPolicyDualStack
+		cfg.Cluster.PrimaryIPFamily = tt.primary
+		// configured with IPv4 first, the primary family must be listed first
+		cfg.Cluster.ClusterCIDR = "10.42.0.0/16,fd01::/48"
+		cfg.Cluster.ServiceCIDR = "10.43.0.0/16,fd02::/112"
+
+		opts := NewKubeControllerManager(cfg).kubecmOptions
+		if got := opts.KubeCloudShared.ClusterCIDR; got != tt.clusterCIDR {
+			t.Errorf("expected cluster-cidr %q with primary family %q, got %q", tt.clusterCIDR, tt.primary, got)
+		}
+		if got := opts.NodeIPAMController.ServiceCIDR; got != tt.serviceCIDR {
+			t.Errorf("expected service-cluster-ip-range %q with primary family %q, got %q", tt.serviceCIDR, tt.primary, got)
+		}
+	}
+}
+
+func TestKubeControllerManagerClusterSigningDuration(t *testing.T) {
+	// the upstream default, capped by the lifetime of the signing CA
+	for duration, expected := range map[string]time.Duration{"": 365 * 24 * time.Hour, "72h": 72 * time.Hour} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.ControllerManager.ClusterSigningDuration = duration
+
+		opts := NewKubeControllerManager(cfg).kubecmOptions
+		if got := opts.CSRSigningController.ClusterSigningDuration.Duration; got != expected {
+			t.Errorf("expected cluster-signing-duration %s with %q, got %s", expected, duration, got)
+		}
+	}
+}
+
+func TestKubeControllerManagerTokenCleaner(t *testing.T) {
+	var tests = []struct {
+		tokenCleaner bool
+		extraArgs    map[string][]string
+		expected     []string
+	}{
+		{tokenCleaner: false, expected: []string{"*"}},
+		{tokenCleaner: true, expected: []string{"*", "tokencleaner"}},
+		// the token cleaner is added to the controllers of extraArgs
+		{tokenCleaner: true, extraArgs: map[string][]string{"controllers": {"*", "-ttl"}}, expected: []string{"*", "-ttl", "tokencleaner"}},
+		{tokenCleaner: true, extraArgs: map[string][]string{"controllers": {"*", "tokencleaner"}}, expected: []string{"*", "tokencleaner"}},
+		{tokenCleaner: false, extraArgs: map[string][]string{"controllers": {"*", "-ttl"}}, expected: []string{"*", "-ttl"}},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.APIServer.BootstrapTokenAuth = true
+		cfg.ControllerManager.TokenCleaner = tt.tokenCleaner
+		cfg.ControllerManager.ExtraArgs = tt.extraArgs
+
+		opts := NewKubeControllerManager(cfg).kubecmOptions
+		if got := opts.Generic.Controllers; !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("expected controllers %v with tokenCleaner=%v, got %v", tt.expected, tt.tokenCleaner, got)
+		}
+	}
+}
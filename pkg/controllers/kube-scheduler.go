@@ -25,6 +25,7 @@ import (
 
 	"github.com/openshift/microshift/pkg/config"
 	"github.com/openshift/microshift/pkg/util"
+	"github.com/spf13/pflag"
 
 	klog "k8s.io/klog/v2"
 	kubescheduler "k8s.io/kubernetes/cmd/kube-scheduler/app"
@@ -55,19 +56,54 @@ func (s *KubeScheduler) configure(cfg *config.MicroshiftConfig) {
 	}
 
 	s.options = schedulerOptions.NewOptions()
-	s.options.ConfigFile = microshiftDataDir + "/resources/kube-scheduler/config/config.yaml"
+
+	// the kubeconfig and leader election settings are managed through the config file
+	extraArgs, _ := util.FilterExtraArgs(s.Name(), []string{"config", "kubeconfig", "leader-elect"}, cfg.Scheduler.ExtraArgs)
+	fs := pflag.NewFlagSet(s.Name(), pflag.ContinueOnError)
+	for _, f := range s.options.Flags.FlagSets {
+		fs.AddFlagSet(f)
+	}
+	if err := fs.Parse(util.ArgsToFlags(extraArgs)); err != nil {
+		klog.Fatalf("%s failed to parse flags: %v", s.Name(), err)
+	}
+
+	s.options.ConfigFile = schedulerConfigPath(cfg)
 	s.kubeconfig = cfg.KubeConfigPath(config.KubeAdmin)
 }
 
+func schedulerConfigPath(cfg *config.MicroshiftConfig) string {
+	return filepath.Join(cfg.RoleDataDir(config.ControlPlaneRole), "resources", "kube-scheduler", "config", "config.yaml")
+}
+
 func (s *KubeScheduler) writeConfig(cfg *config.MicroshiftConfig) error {
+	leaderElection := `
+leaderElection:
+  leaderElect: false`
+	if le := cfg.Scheduler.LeaderElection; le.Enabled {
+		leaderElection = `
+leaderElection:
+  leaderElect: true
+  leaseDuration: ` + le.LeaseDuration + `
+  renewDeadline: ` + le.RenewDeadline + `
+  retryPeriod: ` + le.RetryPeriod
+	}
+	// pods keep their priority without preemption, they are only scheduled first
+	profiles := ""
+	if !cfg.Scheduler.Preemption {
+		profiles = `
+profiles:
+- schedulerName: default-scheduler
+  plugins:
+    postFilter:
+      disabled:
+      - name: DefaultPreemption`
+	}
 	data := []byte(`apiVersion: kubescheduler.config.k8s.io/v1beta3
 kind: KubeSchedulerConfiguration
 clientConnection:
-  kubeconfig: ` + cfg.KubeConfigPath(config.KubeScheduler) + `
-leaderElection:
-  leaderElect: false`)
+  kubeconfig: ` + cfg.KubeConfigPath(config.KubeScheduler) + leaderElection + profiles)
 
-	path := filepath.Join(microshiftDataDir, "resources", "kube-scheduler", "config", "config.yaml")
+	path := schedulerConfigPath(cfg)
 	os.MkdirAll(filepath.Dir(path), os.FileMode(0700))
 	return ioutil.WriteFile(path, data, 0644)
 }
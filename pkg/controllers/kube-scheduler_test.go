@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	schedulerconfig "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
+)
+
+func TestKubeSchedulerPreemption(t *testing.T) {
+	for _, preemption := range []bool{true, false} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Scheduler.Preemption = preemption
+
+		s := &KubeScheduler{}
+		if err := s.writeConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(schedulerConfigPath(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, _, err := scheme.Codecs.UniversalDecoder().Decode(data, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to decode the kube-scheduler config: %v", err)
+		}
+
+		disabled := false
+		for _, profile := range obj.(*schedulerconfig.KubeSchedulerConfiguration).Profiles {
+			for _, plugin := range profile.Plugins.PostFilter.Disabled {
+				disabled = disabled || plugin.Name == "DefaultPreemption"
+			}
+		}
+		if disabled == preemption {
+			t.Errorf("expected DefaultPreemption to be disabled %v with preemption %v", !preemption, preemption)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+const csrApprovalInterval = 5 * time.Second
+
+// allowedServingUsages are the key usages the kubelet requests for its serving certificate
+var allowedServingUsages = sets.NewString(
+	string(certificatesv1.UsageDigitalSignature),
+	string(certificatesv1.UsageKeyEncipherment),
+	string(certificatesv1.UsageServerAuth),
+)
+
+// KubeletServingCSRApprover approves the certificate signing requests of the local
+// kubelet for its serving certificate, which kube-controller-manager then signs. Unlike
+// client certificates, kube-controller-manager doesn't approve these by itself, as it
+// can't tell which addresses a node may serve on.
+type KubeletServingCSRApprover struct {
+	kubeconfig string
+	nodeName   string
+	// addresses the kubelet may request a serving certificate for
+	addresses sets.String
+}
+
+func NewKubeletServingCSRApprover(cfg *config.MicroshiftConfig) *KubeletServingCSRApprover {
+	addresses := sets.NewString(cfg.KubeletNodeName())
+	for _, address := range append([]string{cfg.NodeIP}, cfg.Node.ServingCertSANs...) {
+		// requested IP addresses are compared in their canonical form
+		if ip := net.ParseIP(address); ip != nil {
+			address = ip.String()
+		}
+		addresses.Insert(address)
+	}
+	return &KubeletServingCSRApprover{
+		kubeconfig: cfg.KubeConfigPath(config.KubeAdmin),
+		nodeName:   cfg.KubeletNodeName(),
+		addresses:  addresses,
+	}
+}
+
+func (s *KubeletServingCSRApprover) Name() string           { return "kubelet-serving-csr-approver" }
+func (s *KubeletServingCSRApprover) Dependencies() []string { return []string{"kube-apiserver"} }
+
+func (s *KubeletServingCSRApprover) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", s.kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(rest.AddUserAgent(restConfig, s.Name()))
+	if err != nil {
+		return err
+	}
+	close(ready)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.approvePending(ctx, client); err != nil {
+			klog.Warningf("%s failed to approve pending requests: %v", s.Name(), err)
+		}
+	}, csrApprovalInterval)
+	return ctx.Err()
+}
+
+// approvePending approves the pending requests of the local kubelet for a serving
+// certificate. Other requests are left for someone else to decide on.
+func (s *KubeletServingCSRApprover) approvePending(ctx context.Context, client kubernetes.Interface) error {
+	csrs, err := client.CertificatesV1().CertificateSigningRequests().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range csrs.Items {
+		csr := &csrs.Items[i]
+		if csr.Spec.SignerName != certificatesv1.KubeletServingSignerName || decided(csr) {
+			continue
+		}
+		if err := s.validate(csr); err != nil {
+			klog.V(2).Infof("%s not approving %s: %v", s.Name(), csr.Name, err)
+			continue
+		}
+
+		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+			Type:    certificatesv1.CertificateApproved,
+			Status:  corev1.ConditionTrue,
+			Reason:  "AutoApproved",
+			Message: "Auto approving kubelet serving certificate for " + s.nodeName,
+		})
+		if _, err := client.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to approve %s: %w", csr.Name, err)
+		}
+		klog.Infof("%s approved %s", s.Name(), csr.Name)
+	}
+	return nil
+}
+
+// validate checks that csr was created by the local kubelet and only requests serving
+// usages for addresses of the node.
+func (s *KubeletServingCSRApprover) validate(csr *certificatesv1.CertificateSigningRequest) error {
+	nodeUser := "system:node:" + s.nodeName
+	if csr.Spec.Username != nodeUser {
+		return fmt.Errorf("requested by %q instead of %q", csr.Spec.Username, nodeUser)
+	}
+	if !sets.NewString(csr.Spec.Groups...).Has("system:nodes") {
+		return fmt.Errorf("requester is not in the system:nodes group")
+	}
+	hasServerAuth := false
+	for _, usage := range csr.Spec.Usages {
+		if !allowedServingUsages.Has(string(usage)) {
+			return fmt.Errorf("unexpected usage %q", usage)
+		}
+		hasServerAuth = hasServerAuth || usage == certificatesv1.UsageServerAuth
+	}
+	if !hasServerAuth {
+		return fmt.Errorf("missing usage %q", certificatesv1.UsageServerAuth)
+	}
+
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return fmt.Errorf("request is not a PEM encoded certificate request")
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return err
+	}
+	if req.Subject.CommonName != nodeUser {
+		return fmt.Errorf("unexpected common name %q", req.Subject.CommonName)
+	}
+	if len(req.Subject.Organization) != 1 || req.Subject.Organization[0] != "system:nodes" {
+		return fmt.Errorf("unexpected organization %v", req.Subject.Organization)
+	}
+	if len(req.EmailAddresses) > 0 || len(req.URIs) > 0 {
+		return fmt.Errorf("only DNS names and IP addresses may be requested")
+	}
+	if len(req.DNSNames) == 0 && len(req.IPAddresses) == 0 {
+		return fmt.Errorf("no DNS names or IP addresses requested")
+	}
+	for _, name := range req.DNSNames {
+		if !s.addresses.Has(name) {
+			return fmt.Errorf("DNS name %q is not an address of the node", name)
+		}
+	}
+	for _, ip := range req.IPAddresses {
+		if !s.addresses.Has(ip.String()) {
+			return fmt.Errorf("IP address %q is not an address of the node", ip)
+		}
+	}
+	return nil
+}
+
+func decided(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, c := range csr.Status.Conditions {
+		if c.Type == certificatesv1.CertificateApproved || c.Type == certificatesv1.CertificateDenied {
+			return true
+		}
+	}
+	return false
+}
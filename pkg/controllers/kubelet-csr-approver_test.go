@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestCSR(t *testing.T, name, username, commonName string, dnsNames []string, ips []net.IP) *certificatesv1.CertificateSigningRequest {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: commonName, Organization: []string{"system:nodes"}},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}),
+			SignerName: certificatesv1.KubeletServingSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageKeyEncipherment, certificatesv1.UsageServerAuth},
+			Username:   username,
+			Groups:     []string{"system:nodes", "system:authenticated"},
+		},
+	}
+}
+
+func TestKubeletServingCSRApprover(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.Node.HostnameOverride = "edge-node-1"
+	cfg.NodeIP = "192.168.1.10"
+	cfg.Node.ServingCertSANs = []string{"edge-node-1.example.com", "fd00::10"}
+	s := NewKubeletServingCSRApprover(cfg)
+
+	node := "system:node:edge-node-1"
+	clientCSR := newTestCSR(t, "client", node, node, nil, nil)
+	clientCSR.Spec.SignerName = certificatesv1.KubeAPIServerClientKubeletSignerName
+	clientCSR.Spec.Usages = []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth}
+	badUsage := newTestCSR(t, "bad-usage", node, node, []string{"edge-node-1"}, nil)
+	badUsage.Spec.Usages = append(badUsage.Spec.Usages, certificatesv1.UsageClientAuth)
+
+	var tests = []struct {
+		csr      *certificatesv1.CertificateSigningRequest
+		approved bool
+	}{
+		{csr: newTestCSR(t, "valid", node, node, []string{"edge-node-1", "edge-node-1.example.com"}, []net.IP{net.ParseIP("192.168.1.10"), net.ParseIP("fd00:0::10")}), approved: true},
+		{csr: newTestCSR(t, "other-node", "system:node:other", "system:node:other", []string{"other"}, nil), approved: false},
+		{csr: newTestCSR(t, "wrong-cn", node, "system:node:other", []string{"edge-node-1"}, nil), approved: false},
+		{csr: newTestCSR(t, "unknown-name", node, node, []string{"evil.example.com"}, nil), approved: false},
+		{csr: newTestCSR(t, "unknown-ip", node, node, []string{"edge-node-1"}, []net.IP{net.ParseIP("10.0.0.1")}), approved: false},
+		{csr: newTestCSR(t, "no-addresses", node, node, nil, nil), approved: false},
+		{csr: badUsage, approved: false},
+		{csr: clientCSR, approved: false},
+	}
+
+	client := fake.NewSimpleClientset()
+	for _, tt := range tests {
+		if _, err := client.CertificatesV1().CertificateSigningRequests().Create(context.Background(), tt.csr, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.approvePending(context.Background(), client); err != nil {
+		t.Fatalf("failed to approve pending requests: %v", err)
+	}
+
+	for _, tt := range tests {
+		csr, err := client.CertificatesV1().CertificateSigningRequests().Get(context.Background(), tt.csr.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decided(csr) != tt.approved {
+			t.Errorf("%s: expected approved=%v, got conditions %v", tt.csr.Name, tt.approved, csr.Status.Conditions)
+		}
+	}
+
+	// decided requests are left alone
+	if err := s.approvePending(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	csr, err := client.CertificatesV1().CertificateSigningRequests().Get(context.Background(), "valid", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(csr.Status.Conditions) != 1 {
+		t.Errorf("expected a single approval, got conditions %v", csr.Status.Conditions)
+	}
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	schedulerv1beta3 "k8s.io/kube-scheduler/config/v1beta3"
+	"sigs.k8s.io/yaml"
+)
+
+func TestKubeControllerManagerLeaderElection(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.ControllerManager.LeaderElection.Enabled = enabled
+		cfg.ControllerManager.LeaderElection.LeaseDuration = "60s"
+		cfg.ControllerManager.LeaderElection.RenewDeadline = "40s"
+		cfg.ControllerManager.LeaderElection.RetryPeriod = "10s"
+
+		le := NewKubeControllerManager(cfg).kubecmOptions.Generic.LeaderElection
+		if le.LeaderElect != enabled {
+			t.Errorf("expected leader election enabled to be %v, got %v", enabled, le.LeaderElect)
+		}
+		if !enabled {
+			continue
+		}
+		if le.LeaseDuration.Duration != 60*time.Second || le.RenewDeadline.Duration != 40*time.Second || le.RetryPeriod.Duration != 10*time.Second {
+			t.Errorf("expected the configured lease durations, got %s/%s/%s", le.LeaseDuration.Duration, le.RenewDeadline.Duration, le.RetryPeriod.Duration)
+		}
+	}
+}
+
+func TestKubeControllerManagerLeaderElectionExtraArgs(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.ControllerManager.ExtraArgs = map[string][]string{"leader-elect": {"true"}}
+
+	// leader election is managed through the config
+	le := NewKubeControllerManager(cfg).kubecmOptions.Generic.LeaderElection
+	if le.LeaderElect {
+		t.Errorf("expected extra arguments not to enable leader election")
+	}
+}
+
+func TestKubeSchedulerLeaderElection(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Scheduler.LeaderElection.Enabled = enabled
+		cfg.Scheduler.LeaderElection.LeaseDuration = "60s"
+		cfg.Scheduler.LeaderElection.RenewDeadline = "40s"
+		cfg.Scheduler.LeaderElection.RetryPeriod = "10s"
+
+		s := NewKubeScheduler(cfg)
+		data, err := os.ReadFile(s.options.ConfigFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schedulerConfig schedulerv1beta3.KubeSchedulerConfiguration
+		if err := yaml.UnmarshalStrict(data, &schedulerConfig); err != nil {
+			t.Fatalf("failed to parse kube-scheduler config: %v", err)
+		}
+
+		le := schedulerConfig.LeaderElection
+		if le.LeaderElect == nil || *le.LeaderElect != enabled {
+			t.Errorf("expected leader election enabled to be %v, got %v", enabled, le.LeaderElect)
+		}
+		if !enabled {
+			continue
+		}
+		if le.LeaseDuration.Duration != 60*time.Second || le.RenewDeadline.Duration != 40*time.Second || le.RetryPeriod.Duration != 10*time.Second {
+			t.Errorf("expected the configured lease durations, got %s/%s/%s", le.LeaseDuration.Duration, le.RenewDeadline.Duration, le.RetryPeriod.Duration)
+		}
+	}
+}
@@ -36,6 +36,7 @@ import (
 type OCPRouteControllerManager struct {
 	kubeconfig string
 	config     *openshiftcontrolplanev1.OpenShiftControllerManagerConfig
+	namespaces config.NamespacesConfig
 }
 
 const (
@@ -56,11 +57,12 @@ func (s *OCPRouteControllerManager) Dependencies() []string {
 
 func (s *OCPRouteControllerManager) configure(cfg *config.MicroshiftConfig) {
 	s.kubeconfig = cfg.KubeConfigPath(config.KubeAdmin)
+	s.namespaces = cfg.Namespaces
 	s.config = s.writeConfig(cfg)
 }
 
 func (s *OCPRouteControllerManager) writeConfig(cfg *config.MicroshiftConfig) *openshiftcontrolplanev1.OpenShiftControllerManagerConfig {
-	servingCertDir := cryptomaterial.RouteControllerManagerServingCertDir(cryptomaterial.CertsDirectory(microshiftDataDir))
+	servingCertDir := cryptomaterial.RouteControllerManagerServingCertDir(cryptomaterial.CertsDirectory(cfg.DataDir))
 
 	c := &openshiftcontrolplanev1.OpenShiftControllerManagerConfig{
 		KubeClientConfig: configv1.KubeClientConfig{
@@ -77,7 +79,7 @@ func (s *OCPRouteControllerManager) writeConfig(cfg *config.MicroshiftConfig) *o
 					CertFile: cryptomaterial.ServingCertPath(servingCertDir),
 					KeyFile:  cryptomaterial.ServingKeyPath(servingCertDir),
 				},
-				ClientCA: cryptomaterial.TotalClientCABundlePath(cryptomaterial.CertsDirectory(microshiftDataDir)),
+				ClientCA: cryptomaterial.TotalClientCABundlePath(cryptomaterial.CertsDirectory(cfg.DataDir)),
 			},
 		},
 		Controllers: []string{
@@ -108,7 +110,7 @@ func (s *OCPRouteControllerManager) Run(ctx context.Context, ready chan<- struct
 
 	if err := assets.ApplyNamespaces([]string{
 		"core/0000_50_cluster-openshift-route-controller-manager_00_namespace.yaml",
-	}, s.kubeconfig); err != nil {
+	}, s.namespaces, s.kubeconfig); err != nil {
 		klog.Fatalf("failed to apply openshift namespaces %v", err)
 	}
 	clientConfig, err := helpers.GetKubeClientConfig(s.config.KubeClientConfig)
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/microshift/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+// PriorityClassManager applies the PriorityClasses of the configuration at boot.
+type PriorityClassManager struct {
+	kubeconfig string
+	classes    []config.PriorityClass
+}
+
+func NewPriorityClassManager(cfg *config.MicroshiftConfig) *PriorityClassManager {
+	return &PriorityClassManager{
+		kubeconfig: cfg.KubeConfigPath(config.KubeAdmin),
+		classes:    cfg.Scheduler.PriorityClasses,
+	}
+}
+
+func (s *PriorityClassManager) Name() string { return "priority-class-manager" }
+func (s *PriorityClassManager) Dependencies() []string {
+	return []string{"kube-apiserver"}
+}
+
+func (s *PriorityClassManager) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", s.kubeconfig)
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(rest.AddUserAgent(restConfig, s.Name()))
+	if err != nil {
+		return err
+	}
+	if err := applyPriorityClasses(ctx, client, s.classes); err != nil {
+		return err
+	}
+	close(ready)
+	return ctx.Err()
+}
+
+// applyPriorityClasses creates the classes or updates those that exist. The value
+// and preemption policy of a class can't be changed, so a class that differs in them
+// has to be deleted by the administrator first.
+func applyPriorityClasses(ctx context.Context, client kubernetes.Interface, classes []config.PriorityClass) error {
+	for _, pc := range classes {
+		required := newPriorityClass(pc)
+		existing, err := client.SchedulingV1().PriorityClasses().Get(ctx, required.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if _, err := client.SchedulingV1().PriorityClasses().Create(ctx, required, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("failed to create priority class %s: %v", required.Name, err)
+			}
+			klog.Infof("Created priority class %s", required.Name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get priority class %s: %v", required.Name, err)
+		}
+
+		// the apiserver defaults the policy of classes created without one
+		existingPolicy := corev1.PreemptLowerPriority
+		if existing.PreemptionPolicy != nil {
+			existingPolicy = *existing.PreemptionPolicy
+		}
+		if existing.Value != required.Value || existingPolicy != *required.PreemptionPolicy {
+			return fmt.Errorf("priority class %s exists with value %d and preemption policy %s, delete it to apply value %d and preemption policy %s",
+				required.Name, existing.Value, existingPolicy, required.Value, *required.PreemptionPolicy)
+		}
+		if existing.GlobalDefault == required.GlobalDefault && existing.Description == required.Description {
+			continue
+		}
+		existing = existing.DeepCopy()
+		existing.GlobalDefault = required.GlobalDefault
+		existing.Description = required.Description
+		if _, err := client.SchedulingV1().PriorityClasses().Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update priority class %s: %v", required.Name, err)
+		}
+		klog.Infof("Updated priority class %s", required.Name)
+	}
+	return nil
+}
+
+func newPriorityClass(pc config.PriorityClass) *schedulingv1.PriorityClass {
+	policy := corev1.PreemptLowerPriority
+	if pc.PreemptionPolicy != "" {
+		policy = corev1.PreemptionPolicy(pc.PreemptionPolicy)
+	}
+	return &schedulingv1.PriorityClass{
+		ObjectMeta:       metav1.ObjectMeta{Name: pc.Name},
+		Value:            pc.Value,
+		GlobalDefault:    pc.GlobalDefault,
+		Description:      pc.Description,
+		PreemptionPolicy: &policy,
+	}
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyPriorityClasses(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "workload-default"}, Value: 1000, Description: "outdated"},
+	)
+	classes := []config.PriorityClass{
+		{Name: "workload-default", Value: 1000, GlobalDefault: true, Description: "Default priority of workloads"},
+		{Name: "best-effort", Value: -10, PreemptionPolicy: config.PreemptionPolicyNever},
+	}
+
+	ctx := context.Background()
+	if err := applyPriorityClasses(ctx, client, classes); err != nil {
+		t.Fatalf("applyPriorityClasses() error = %v", err)
+	}
+
+	updated, err := client.SchedulingV1().PriorityClasses().Get(ctx, "workload-default", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.GlobalDefault || updated.Description != "Default priority of workloads" {
+		t.Errorf("expected the existing class to be updated, got %+v", updated)
+	}
+	created, err := client.SchedulingV1().PriorityClasses().Get(ctx, "best-effort", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the missing class to be created: %v", err)
+	}
+	if created.Value != -10 || created.PreemptionPolicy == nil || *created.PreemptionPolicy != corev1.PreemptNever {
+		t.Errorf("expected value -10 and preemption policy Never, got %+v", created)
+	}
+}
+
+func TestApplyPriorityClassesImmutable(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "workload-default"}, Value: 500},
+	)
+	classes := []config.PriorityClass{{Name: "workload-default", Value: 1000}}
+
+	err := applyPriorityClasses(context.Background(), client, classes)
+	if err == nil || !strings.Contains(err.Error(), "delete it") {
+		t.Errorf("expected an error about the immutable value, got %v", err)
+	}
+}
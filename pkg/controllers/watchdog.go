@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"github.com/openshift/microshift/pkg/util/cryptomaterial"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// APIServerWatchdog checks the liveness of the local apiserver and takes the configured
+// action once it stayed unhealthy for longer than the failure threshold. The apiserver
+// runs in-process and can't be restarted on its own, so recovering from a hung apiserver
+// means restarting MicroShift.
+type APIServerWatchdog struct {
+	masterURL      string
+	servingCAPath  string
+	clientCertPath string
+	clientKeyPath  string
+
+	interval  time.Duration
+	threshold time.Duration
+	action    string
+}
+
+func NewAPIServerWatchdog(cfg *config.MicroshiftConfig) *APIServerWatchdog {
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
+	// durations are validated with the config
+	interval, _ := time.ParseDuration(cfg.APIServer.Watchdog.Interval)
+	threshold, _ := time.ParseDuration(cfg.APIServer.Watchdog.FailureThreshold)
+	return &APIServerWatchdog{
+		masterURL:      cfg.Cluster.URL,
+		servingCAPath:  cryptomaterial.ServiceAccountTokenCABundlePath(certsDir),
+		clientCertPath: cryptomaterial.ClientCertPath(cryptomaterial.AdminKubeconfigClientCertDir(certsDir)),
+		clientKeyPath:  cryptomaterial.ClientKeyPath(cryptomaterial.AdminKubeconfigClientCertDir(certsDir)),
+		interval:       interval,
+		threshold:      threshold,
+		action:         cfg.APIServer.Watchdog.Action,
+	}
+}
+
+func (s *APIServerWatchdog) Name() string           { return "apiserver-watchdog" }
+func (s *APIServerWatchdog) Dependencies() []string { return []string{"kube-apiserver"} }
+
+func (s *APIServerWatchdog) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+
+	restClient, err := s.restClient()
+	if err != nil {
+		return err
+	}
+	close(ready)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var unhealthySince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		err := s.checkLivez(ctx, restClient)
+		if err == nil {
+			if !unhealthySince.IsZero() {
+				klog.Infof("%q: kube-apiserver recovered after %s", s.Name(), time.Since(unhealthySince).Round(time.Second))
+			}
+			unhealthySince = time.Time{}
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if unhealthySince.IsZero() {
+			unhealthySince = time.Now()
+		}
+		unhealthyFor := time.Since(unhealthySince)
+		klog.Warningf("%q: kube-apiserver unhealthy for %s: %v", s.Name(), unhealthyFor.Round(time.Second), err)
+		if unhealthyFor < s.threshold {
+			continue
+		}
+
+		switch s.action {
+		case config.WatchdogActionLog:
+			klog.Errorf("%q: kube-apiserver stayed unhealthy for longer than %s", s.Name(), s.threshold)
+			// report again once another threshold passed
+			unhealthySince = time.Now()
+		default:
+			return fmt.Errorf("kube-apiserver stayed unhealthy for longer than %s: %w", s.threshold, err)
+		}
+	}
+}
+
+func (s *APIServerWatchdog) restClient() (*rest.RESTClient, error) {
+	restConfig := &rest.Config{
+		Host: s.masterURL,
+		// a hung apiserver must not block the check
+		Timeout: s.interval,
+		// the checks are rare enough not to need client side rate limiting
+		QPS: -1,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAFile:   s.servingCAPath,
+			CertFile: s.clientCertPath,
+			KeyFile:  s.clientKeyPath,
+		},
+	}
+	if err := rest.SetKubernetesDefaults(restConfig); err != nil {
+		return nil, err
+	}
+	restConfig.NegotiatedSerializer = serializer.NewCodecFactory(runtime.NewScheme())
+	return rest.UnversionedRESTClientFor(restConfig)
+}
+
+func (s *APIServerWatchdog) checkLivez(ctx context.Context, restClient *rest.RESTClient) error {
+	var status int
+	if err := restClient.Get().AbsPath("/livez").Do(ctx).StatusCode(&status).Error(); err != nil {
+		return err
+	}
+	if status < 200 || status >= 400 {
+		return fmt.Errorf("received http status %d", status)
+	}
+	return nil
+}
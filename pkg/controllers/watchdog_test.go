@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// newFakeAPIServer serves /livez, failing once healthy is set to false.
+func newFakeAPIServer(t *testing.T, healthy *atomic.Value) (*httptest.Server, string) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/livez" {
+			http.NotFound(w, r)
+			return
+		}
+		if !healthy.Load().(bool) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return srv, caPath
+}
+
+func newTestWatchdog(url, caPath, action string) *APIServerWatchdog {
+	return &APIServerWatchdog{
+		masterURL:     url,
+		servingCAPath: caPath,
+		interval:      10 * time.Millisecond,
+		threshold:     100 * time.Millisecond,
+		action:        action,
+	}
+}
+
+func TestAPIServerWatchdogExit(t *testing.T) {
+	healthy := &atomic.Value{}
+	healthy.Store(true)
+	srv, caPath := newFakeAPIServer(t, healthy)
+	s := newTestWatchdog(srv.URL, caPath, config.WatchdogActionExit)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, ready, stopped) }()
+
+	select {
+	case <-ready:
+	case <-time.After(time.Second):
+		t.Fatal("watchdog did not become ready")
+	}
+
+	// a healthy apiserver is left alone
+	select {
+	case err := <-done:
+		t.Fatalf("expected the watchdog to keep running while the apiserver is healthy, returned %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	unhealthySince := time.Now()
+	healthy.Store(false)
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected the watchdog to fail once the apiserver stayed unhealthy")
+		}
+		if elapsed := time.Since(unhealthySince); elapsed < s.threshold {
+			t.Errorf("expected the watchdog to act after the threshold of %s, acted after %s", s.threshold, elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not act on the unhealthy apiserver")
+	}
+}
+
+func TestAPIServerWatchdogRecovery(t *testing.T) {
+	healthy := &atomic.Value{}
+	healthy.Store(true)
+	srv, caPath := newFakeAPIServer(t, healthy)
+	s := newTestWatchdog(srv.URL, caPath, config.WatchdogActionExit)
+	s.threshold = 300 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, make(chan struct{}), make(chan struct{})) }()
+
+	// flap for less than the threshold, repeatedly
+	for i := 0; i < 3; i++ {
+		healthy.Store(false)
+		time.Sleep(100 * time.Millisecond)
+		healthy.Store(true)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected the watchdog not to act when the apiserver recovers within the threshold, returned %v", err)
+	default:
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected the watchdog to stop with the context, got %v", err)
+	}
+}
+
+func TestAPIServerWatchdogLog(t *testing.T) {
+	healthy := &atomic.Value{}
+	healthy.Store(false)
+	srv, caPath := newFakeAPIServer(t, healthy)
+	s := newTestWatchdog(srv.URL, caPath, config.WatchdogActionLog)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*s.threshold)
+	defer cancel()
+	if err := s.Run(ctx, make(chan struct{}), make(chan struct{})); err != context.DeadlineExceeded {
+		t.Errorf("expected the watchdog to only log past the threshold, got %v", err)
+	}
+}
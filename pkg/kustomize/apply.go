@@ -2,38 +2,82 @@ package kustomize
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/openshift/microshift/pkg/config"
-	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextclientv1 "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/mergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/cli-runtime/pkg/genericclioptions"
-	cliflag "k8s.io/component-base/cli/flag"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
-	"k8s.io/kubectl/pkg/cmd/apply"
-	cmdutil "k8s.io/kubectl/pkg/cmd/util"
-	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/kustomize/api/krusty"
 )
 
 const (
-	retryInterval = 10 * time.Second
-	retryTimeout  = 1 * time.Minute
+	// the backoff between retries of applying a kustomization doubles up to this
+	maxApplyBackoff = 1 * time.Minute
+
+	crdCheckInterval = 1 * time.Second
+	crdTimeout       = 1 * time.Minute
+
+	fieldManager = "microshift"
 )
 
 var microshiftManifestsDir = config.GetManifestsDir()
 
 type Kustomizer struct {
-	paths      []string
-	kubeconfig string
+	paths        []string
+	workDir      string
+	kubeconfig   string
+	waitForReady []config.ResourceRef
+
+	applyRetries int
+	applyBackoff time.Duration
+	applyMode    string
+	force        bool
+	filter       ResourceFilter
 }
 
 func NewKustomizer(cfg *config.MicroshiftConfig) *Kustomizer {
+	// the backoff and the selection are validated with the config
+	applyBackoff, _ := time.ParseDuration(cfg.Manifests.ApplyBackoff)
+	filter, _ := NewResourceFilter(cfg.Manifests)
+	if cfg.Metrics.Enabled {
+		registerMetrics()
+	}
 	return &Kustomizer{
-		paths:      microshiftManifestsDir,
-		kubeconfig: cfg.KubeConfigPath(config.KubeAdmin),
+		paths:        microshiftManifestsDir,
+		workDir:      cfg.ManifestsWorkDir(),
+		kubeconfig:   cfg.KubeConfigPath(config.KubeAdmin),
+		waitForReady: cfg.Manifests.WaitForReady,
+		applyRetries: cfg.Manifests.ApplyRetries,
+		applyBackoff: applyBackoff,
+		applyMode:    cfg.Manifests.ApplyMode,
+		force:        cfg.Manifests.ForceConflicts,
+		filter:       filter,
 	}
 }
 
@@ -42,78 +86,318 @@ func (s *Kustomizer) Dependencies() []string { return []string{"kube-apiserver"}
 
 func (s *Kustomizer) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 	defer close(stopped)
-	defer close(ready)
 
 	for _, path := range s.paths {
-		s.ApplyKustomizationPath(path)
+		if err := s.ApplyKustomizationPath(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	if len(s.waitForReady) > 0 {
+		restConfig, err := clientcmd.BuildConfigFromFlags("", s.kubeconfig)
+		if err != nil {
+			return err
+		}
+		client := kubernetes.NewForConfigOrDie(rest.AddUserAgent(restConfig, "kustomizer"))
+
+		if err := s.waitForWorkloads(ctx, client, readyCheckInterval, readyTimeout); err != nil {
+			return err
+		}
 	}
 
+	close(ready)
 	return ctx.Err()
 }
 
-func (s *Kustomizer) ApplyKustomizationPath(path string) {
-	kustomization := filepath.Join(path, "kustomization.yaml")
-	if _, err := os.Stat(kustomization); !errors.Is(err, os.ErrNotExist) {
-		klog.Infof("Applying kustomization at %v ", kustomization)
-		if err := ApplyKustomizationWithRetries(path, s.kubeconfig); err != nil {
-			klog.Fatalf("Applying kustomization at %v failed: %s. Giving up.", kustomization, err)
-		} else {
-			klog.Infof("Kustomization at %v applied successfully.", kustomization)
+// waitForWorkloads waits for the workloads of manifests.waitForReady. The kustomizer
+// only becomes ready once they are, so that MicroShift doesn't report ready while
+// they aren't, and fails if they don't become ready in time.
+func (s *Kustomizer) waitForWorkloads(ctx context.Context, client kubernetes.Interface, interval, timeout time.Duration) error {
+	klog.Infof("Waiting for %v to become ready", s.waitForReady)
+	if err := waitForResourcesReady(ctx, client, s.waitForReady, interval, timeout); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-	} else {
+		return fmt.Errorf("manifests not ready: %w", err)
+	}
+	klog.Infof("%v are ready", s.waitForReady)
+	return nil
+}
+
+// ApplyKustomizationPath applies the kustomization in path, if there is one. Once the
+// retries are exhausted the error is returned, so that the service manager stops
+// MicroShift or, if the kustomizer is optional, marks it degraded.
+func (s *Kustomizer) ApplyKustomizationPath(ctx context.Context, path string) error {
+	kustomization := filepath.Join(path, "kustomization.yaml")
+	if _, err := os.Stat(kustomization); errors.Is(err, os.ErrNotExist) {
 		klog.Infof("No kustomization found at " + kustomization)
+		return nil
+	}
+	klog.Infof("Applying kustomization at %v ", kustomization)
+	err := applyWithRetries(ctx, s.applyRetries, s.applyBackoff, func(ctx context.Context) error {
+		return ApplyKustomization(ctx, path, s.workDir, s.kubeconfig, s.applyMode, s.force, s.filter)
+	})
+	if err != nil {
+		return fmt.Errorf("applying kustomization at %v failed, giving up: %w", kustomization, err)
 	}
+	klog.Infof("Kustomization at %v applied successfully.", kustomization)
+	return nil
 }
 
-func ApplyKustomizationWithRetries(kustomization string, kubeconfig string) error {
-	return wait.Poll(retryInterval, retryTimeout, func() (bool, error) {
-		if err := ApplyKustomization(kustomization, kubeconfig); err != nil {
-			klog.Infof("Applying kustomization failed: %s. Retrying in %s.", err, retryInterval)
-			return false, nil
+// applyWithRetries calls apply until it succeeds or failed retries more times,
+// waiting for backoff before the first retry and twice as long before each of the
+// following ones, e.g. while the apiserver isn't serving all APIs yet.
+func applyWithRetries(ctx context.Context, retries int, backoff time.Duration, apply func(context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := apply(ctx)
+		if err == nil {
+			return nil
 		}
-		return true, nil
-	})
+		if attempt >= retries {
+			return fmt.Errorf("failed after %d attempts: %w", attempt+1, err)
+		}
+		klog.Infof("Applying kustomization failed: %s. Retrying in %s.", err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxApplyBackoff {
+			backoff = maxApplyBackoff
+		}
+	}
+}
+
+// ApplyKustomization renders the kustomization and applies the resulting resources
+// using server-side or client-side apply depending on mode, ordered by kind and
+// concurrently within each phase. force resolves server-side apply conflicts in
+// favor of MicroShift. Only the resources matching filter are applied. Anything
+// kustomize writes while rendering goes to workDir instead of the kustomization's
+// directory.
+func ApplyKustomization(ctx context.Context, kustomization, workDir, kubeconfig, mode string, force bool, filter ResourceFilter) error {
+	objs, err := renderKustomization(kustomization, workDir)
+	if err != nil {
+		return err
+	}
+	objs = filter.filter(objs)
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return err
+	}
+	restConfig = rest.AddUserAgent(restConfig, "kustomizer")
+	a, err := newClusterApplier(restConfig, mode, force)
+	if err != nil {
+		return err
+	}
+	results, err := applyResources(ctx, a, objs, applyWorkers)
+	klog.Infof("Applied kustomization at %s: %s", kustomization, results)
+	recordApplyResults(results)
+	return err
+}
+
+func renderKustomization(kustomization, workDir string) ([]*unstructured.Unstructured, error) {
+	fs, err := newWorkDirFs(kustomization, workDir)
+	if err != nil {
+		return nil, err
+	}
+	resMap, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fs, kustomization)
+	if err != nil {
+		return nil, err
+	}
+	objs := []*unstructured.Unstructured{}
+	for _, r := range resMap.Resources() {
+		m, err := r.Map()
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: m})
+	}
+	return objs, nil
+}
+
+// clusterApplier applies resources through the dynamic client, mapping their kinds
+// using the discovery information of the cluster.
+type clusterApplier struct {
+	client dynamic.Interface
+	mapper meta.ResettableRESTMapper
+	crds   apiextclientv1.CustomResourceDefinitionsGetter
+	mode   string
+	force  bool
 }
 
-func ApplyKustomization(kustomization string, kubeconfig string) error {
-	cmds := &cobra.Command{
-		Use:   "kubectl",
-		Short: "kubectl",
+func newClusterApplier(restConfig *rest.Config, mode string, force bool) (*clusterApplier, error) {
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
 	}
-	persistFlags := cmds.PersistentFlags()
-	persistFlags.SetNormalizeFunc(cliflag.WarnWordSepNormalizeFunc)
-	persistFlags.SetNormalizeFunc(cliflag.WordSepNormalizeFunc)
+	crds, err := apiextclientv1.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &clusterApplier{
+		client: client,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		crds:   crds,
+		mode:   mode,
+		force:  force,
+	}, nil
+}
 
-	kubeConfigFlags := genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag()
-	kubeConfigFlags.KubeConfig = &kubeconfig
-	kubeConfigFlags.AddFlags(persistFlags)
+func (a *clusterApplier) apply(ctx context.Context, obj *unstructured.Unstructured) (applyResult, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return applyFailed, err
+	}
 
-	matchVersionKubeConfigFlags := cmdutil.NewMatchVersionFlags(kubeConfigFlags)
-	matchVersionKubeConfigFlags.AddFlags(persistFlags)
+	var ri dynamic.ResourceInterface = a.client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		ri = a.client.Resource(mapping.Resource).Namespace(namespace)
+	}
+	if a.mode == config.ApplyModeClient {
+		return clientSideApply(ctx, ri, obj)
+	}
+	return a.serverSideApply(ctx, ri, obj)
+}
 
-	f := cmdutil.NewFactory(matchVersionKubeConfigFlags)
-	ioStreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
-	groups := templates.CommandGroups{
-		{
-			Message: "Advanced Commands:",
-			Commands: []*cobra.Command{
-				apply.NewCmdApply("kubectl", f, ioStreams),
-			},
-		},
+// serverSideApply applies obj as the microshift field manager. Unless forced, applying
+// fails when other managers have set any of its fields since, as server-side apply
+// rejects the whole object, so that changes to the manifests aren't lost. Whether the object changed is told by its resource version, which server-side
+// apply doesn't bump for no-op applies.
+func (a *clusterApplier) serverSideApply(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured) (applyResult, error) {
+	current, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	exists := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return applyFailed, err
 	}
-	groups.Add(cmds)
+	applied, err := ri.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: a.force})
+	switch {
+	case err != nil && !a.force && apierrors.IsConflict(err):
+		return applyConflict, fmt.Errorf("other managers own conflicting fields, set manifests.forceConflicts to take them over: %w", err)
+	case err != nil:
+		return applyFailed, err
+	case !exists:
+		return applyCreated, nil
+	case applied.GetResourceVersion() == current.GetResourceVersion():
+		return applyUnchanged, nil
+	}
+	return applyUpdated, nil
+}
 
-	applyFlags := apply.NewApplyFlags(f, ioStreams)
-	applyFlags.DeleteFlags.FileNameFlags.Kustomize = &kustomization
-	applyFlags.AddFlags(cmds)
+// clientSideApply applies obj like "kubectl apply", keeping the applied configuration
+// in the last-applied-configuration annotation and patching the object with a
+// three-way merge of the last applied, the new and the current configuration, so
+// that fields removed from the manifests are removed while those set by others stay.
+// Like kubectl, built-in types are patched with a strategic merge, which merges lists
+// such as containers by their keys, and custom resources with a JSON merge, which
+// replaces lists as a whole.
+func clientSideApply(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured) (applyResult, error) {
+	applied, err := json.Marshal(obj)
+	if err != nil {
+		return applyFailed, err
+	}
+	modified := obj.DeepCopy()
+	annotations := modified.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[corev1.LastAppliedConfigAnnotation] = string(applied)
+	modified.SetAnnotations(annotations)
 
-	o, err := applyFlags.ToOptions(cmds, "kubectl", nil)
+	current, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := ri.Create(ctx, modified, metav1.CreateOptions{FieldManager: fieldManager}); err != nil {
+			return applyFailed, err
+		}
+		return applyCreated, nil
+	}
 	if err != nil {
-		return err
+		return applyFailed, err
+	}
+
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return applyFailed, err
 	}
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return applyFailed, err
+	}
+	original := []byte(current.GetAnnotations()[corev1.LastAppliedConfigAnnotation])
+	patchType, patch, err := threeWayMergePatch(obj.GroupVersionKind(), original, modifiedJSON, currentJSON)
+	if err != nil {
+		return applyFailed, err
+	}
+	if string(patch) == "{}" {
+		return applyUnchanged, nil
+	}
+	if _, err := ri.Patch(ctx, obj.GetName(), patchType, patch, metav1.PatchOptions{FieldManager: fieldManager}); err != nil {
+		return applyFailed, err
+	}
+	return applyUpdated, nil
+}
 
-	if err := o.Validate(); err != nil {
+// threeWayMergePatch returns the patch of the kind from current to modified, which
+// removes the fields of original missing in modified. Built-in kinds get a strategic
+// merge patch, kinds unknown to the scheme, i.e. custom resources, a JSON merge patch.
+func threeWayMergePatch(gvk schema.GroupVersionKind, original, modified, current []byte) (types.PatchType, []byte, error) {
+	preconditions := []mergepatch.PreconditionFunc{
+		mergepatch.RequireKeyUnchanged("apiVersion"),
+		mergepatch.RequireKeyUnchanged("kind"),
+		mergepatch.RequireMetadataKeyUnchanged("name"),
+	}
+	versioned, err := scheme.Scheme.New(gvk)
+	if runtime.IsNotRegisteredError(err) {
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current, preconditions...)
+		return types.MergePatchType, patch, err
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(versioned)
+	if err != nil {
+		return "", nil, err
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookupPatchMeta, true, preconditions...)
+	return types.StrategicMergePatchType, patch, err
+}
+
+func (a *clusterApplier) waitForCRDs(ctx context.Context, names []string) error {
+	err := wait.PollImmediateWithContext(ctx, crdCheckInterval, crdTimeout, func(ctx context.Context) (bool, error) {
+		for _, name := range names {
+			crd, err := a.crds.CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				klog.Infof("Checking CRD %s failed: %v", name, err)
+				return false, nil
+			}
+			if !crdEstablished(crd) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
 		return err
 	}
-	return o.Run()
+	// the kinds of the new CRDs are only mapped after rediscovering the cluster's APIs
+	a.mapper.Reset()
+	return nil
+}
+
+func crdEstablished(crd *apiextv1.CustomResourceDefinition) bool {
+	for _, condition := range crd.Status.Conditions {
+		if condition.Type == apiextv1.Established && condition.Status == apiextv1.ConditionTrue {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,415 @@
+package kustomize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/dynamic"
+)
+
+// failingApply fails the first failures calls, e.g. while the apiserver isn't ready
+type failingApply struct {
+	failures int
+	calls    int
+}
+
+func (a *failingApply) apply(ctx context.Context) error {
+	a.calls++
+	if a.calls <= a.failures {
+		return fmt.Errorf("the server is currently unable to handle the request")
+	}
+	return nil
+}
+
+func TestApplyWithRetriesRecovers(t *testing.T) {
+	a := &failingApply{failures: 2}
+	if err := applyWithRetries(context.Background(), 3, time.Millisecond, a.apply); err != nil {
+		t.Fatalf("applyWithRetries() error = %v", err)
+	}
+	if a.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", a.calls)
+	}
+}
+
+func TestApplyWithRetriesGivesUp(t *testing.T) {
+	a := &failingApply{failures: 10}
+	if err := applyWithRetries(context.Background(), 3, time.Millisecond, a.apply); err == nil {
+		t.Fatalf("expected an error after running out of retries")
+	}
+	if a.calls != 4 {
+		t.Errorf("expected the initial attempt and 3 retries, got %d attempts", a.calls)
+	}
+}
+
+func TestApplyWithRetriesNoRetries(t *testing.T) {
+	a := &failingApply{failures: 1}
+	if err := applyWithRetries(context.Background(), 0, time.Millisecond, a.apply); err == nil {
+		t.Fatalf("expected an error without retries")
+	}
+	if a.calls != 1 {
+		t.Errorf("expected a single attempt, got %d", a.calls)
+	}
+}
+
+func TestApplyWithRetriesBackoff(t *testing.T) {
+	a := &failingApply{failures: 3}
+	start := time.Now()
+	if err := applyWithRetries(context.Background(), 3, 20*time.Millisecond, a.apply); err != nil {
+		t.Fatalf("applyWithRetries() error = %v", err)
+	}
+	// 20ms, 40ms and 80ms
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("expected the backoff to double between retries, took %s", elapsed)
+	}
+}
+
+func TestApplyWithRetriesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a := &failingApply{failures: 10}
+	if err := applyWithRetries(ctx, 3, time.Hour, a.apply); err != context.Canceled {
+		t.Errorf("expected the retries to stop with the context, got %v", err)
+	}
+}
+
+// fakeDynamicClient serves a single resource, recording the requests made to it.
+// Only the methods used for applying are implemented.
+type fakeDynamicClient struct {
+	dynamic.Interface
+	resource *fakeResource
+}
+
+func (c *fakeDynamicClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return c.resource
+}
+
+type fakePatch struct {
+	patchType types.PatchType
+	data      map[string]interface{}
+	options   metav1.PatchOptions
+}
+
+type fakeResource struct {
+	dynamic.NamespaceableResourceInterface
+	current *unstructured.Unstructured
+	// conflict is returned by server-side apply unless forced
+	conflict bool
+	// appliedVersion is the resource version of the object server-side apply
+	// returns
+	appliedVersion string
+
+	applies []metav1.ApplyOptions
+	creates []metav1.CreateOptions
+	created *unstructured.Unstructured
+	patches []fakePatch
+}
+
+func (r *fakeResource) Namespace(string) dynamic.ResourceInterface { return r }
+
+func (r *fakeResource) Apply(ctx context.Context, name string, obj *unstructured.Unstructured, options metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.applies = append(r.applies, options)
+	if r.conflict && !options.Force {
+		return nil, apierrors.NewApplyConflict(nil, "conflict with \"kubectl-edit\"")
+	}
+	applied := obj.DeepCopy()
+	applied.SetResourceVersion(r.appliedVersion)
+	return applied, nil
+}
+
+func (r *fakeResource) Get(ctx context.Context, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if r.current == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return r.current.DeepCopy(), nil
+}
+
+func (r *fakeResource) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.creates = append(r.creates, options)
+	r.created = obj
+	return obj, nil
+}
+
+func (r *fakeResource) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	patch := fakePatch{patchType: pt, options: options}
+	if err := json.Unmarshal(data, &patch.data); err != nil {
+		return nil, err
+	}
+	r.patches = append(r.patches, patch)
+	return r.current, nil
+}
+
+// staticMapper maps the kinds of the test objects without discovering them
+type staticMapper struct {
+	meta.RESTMapper
+}
+
+func (staticMapper) Reset() {}
+
+func newTestApplier(resource *fakeResource, mode string, force bool) *clusterApplier {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace)
+	return &clusterApplier{
+		client: &fakeDynamicClient{resource: resource},
+		mapper: staticMapper{mapper},
+		mode:   mode,
+		force:  force,
+	}
+}
+
+func newTestConfigMap(data map[string]interface{}) *unstructured.Unstructured {
+	obj := newObject("v1", "ConfigMap", "config")
+	obj.SetNamespace("busybox")
+	if err := unstructured.SetNestedField(obj.Object, data, "data"); err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+func TestServerSideApply(t *testing.T) {
+	for _, force := range []bool{true, false} {
+		r := &fakeResource{}
+		a := newTestApplier(r, config.ApplyModeServer, force)
+		if result, err := a.apply(context.Background(), newTestConfigMap(map[string]interface{}{"key": "value"})); err != nil || result != applyCreated {
+			t.Fatalf("apply() = %v, %v, expected the object to be created", result, err)
+		}
+		expected := metav1.ApplyOptions{FieldManager: fieldManager, Force: force}
+		if len(r.applies) != 1 || !reflect.DeepEqual(r.applies[0], expected) {
+			t.Errorf("expected a single server-side apply with %+v, got %+v", expected, r.applies)
+		}
+		if len(r.patches) > 0 || len(r.creates) > 0 {
+			t.Errorf("expected no client-side requests, got patches %+v and creates %+v", r.patches, r.creates)
+		}
+	}
+}
+
+func TestServerSideApplyConflicts(t *testing.T) {
+	r := &fakeResource{conflict: true}
+	if result, err := newTestApplier(r, config.ApplyModeServer, false).apply(context.Background(), newTestConfigMap(nil)); err == nil || result != applyConflict {
+		t.Errorf("expected conflicts to fail the apply, got %v, %v", result, err)
+	}
+
+	r = &fakeResource{conflict: true}
+	if _, err := newTestApplier(r, config.ApplyModeServer, true).apply(context.Background(), newTestConfigMap(nil)); err != nil {
+		t.Errorf("expected forcing to resolve the conflicts, got %v", err)
+	}
+	if len(r.applies) != 1 || !r.applies[0].Force {
+		t.Errorf("expected a single forced apply, got %+v", r.applies)
+	}
+}
+
+func TestServerSideApplyResults(t *testing.T) {
+	existing := newTestConfigMap(map[string]interface{}{"key": "value"})
+	existing.SetResourceVersion("1")
+
+	var tests = []struct {
+		current        *unstructured.Unstructured
+		appliedVersion string
+		expected       applyResult
+	}{
+		{current: nil, appliedVersion: "1", expected: applyCreated},
+		{current: existing, appliedVersion: "1", expected: applyUnchanged},
+		{current: existing, appliedVersion: "2", expected: applyUpdated},
+	}
+	for _, tt := range tests {
+		r := &fakeResource{current: tt.current, appliedVersion: tt.appliedVersion}
+		result, err := newTestApplier(r, config.ApplyModeServer, true).apply(context.Background(), newTestConfigMap(map[string]interface{}{"key": "value"}))
+		if err != nil {
+			t.Fatalf("apply() error = %v", err)
+		}
+		if result != tt.expected {
+			t.Errorf("expected the apply to be %s, got %s", tt.expected, result)
+		}
+	}
+}
+
+func TestClientSideApplyCreates(t *testing.T) {
+	r := &fakeResource{}
+	obj := newTestConfigMap(map[string]interface{}{"key": "value"})
+	if result, err := newTestApplier(r, config.ApplyModeClient, true).apply(context.Background(), obj); err != nil || result != applyCreated {
+		t.Fatalf("apply() = %v, %v, expected the object to be created", result, err)
+	}
+	if len(r.creates) != 1 || r.creates[0].FieldManager != fieldManager {
+		t.Fatalf("expected a single create as %q, got %+v", fieldManager, r.creates)
+	}
+	applied, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.created.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; got != string(applied) {
+		t.Errorf("expected the applied configuration to be recorded, got %q", got)
+	}
+	if len(r.applies) > 0 {
+		t.Errorf("expected no server-side apply, got %+v", r.applies)
+	}
+}
+
+func TestClientSideApplyPatches(t *testing.T) {
+	lastApplied := newTestConfigMap(map[string]interface{}{"removed": "value", "changed": "old"})
+	lastAppliedJSON, err := json.Marshal(lastApplied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a user added a key of their own
+	current := newTestConfigMap(map[string]interface{}{"removed": "value", "changed": "old", "user": "value"})
+	current.SetAnnotations(map[string]string{corev1.LastAppliedConfigAnnotation: string(lastAppliedJSON)})
+
+	r := &fakeResource{current: current}
+	obj := newTestConfigMap(map[string]interface{}{"changed": "new"})
+	if result, err := newTestApplier(r, config.ApplyModeClient, true).apply(context.Background(), obj); err != nil || result != applyUpdated {
+		t.Fatalf("apply() = %v, %v, expected the object to be updated", result, err)
+	}
+	if len(r.patches) != 1 {
+		t.Fatalf("expected a single patch, got %+v", r.patches)
+	}
+	patch := r.patches[0]
+	if patch.patchType != types.StrategicMergePatchType || patch.options.FieldManager != fieldManager {
+		t.Errorf("expected a strategic merge patch of the built-in type as %q, got a %s patch as %q", fieldManager, patch.patchType, patch.options.FieldManager)
+	}
+	data, _, _ := unstructured.NestedMap(patch.data, "data")
+	expected := map[string]interface{}{"removed": nil, "changed": "new"}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected the patch to change %v and keep the user's key, got %v", expected, data)
+	}
+}
+
+func TestClientSideApplyUnchanged(t *testing.T) {
+	obj := newTestConfigMap(map[string]interface{}{"key": "value"})
+	applied, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := obj.DeepCopy()
+	current.SetAnnotations(map[string]string{corev1.LastAppliedConfigAnnotation: string(applied)})
+
+	r := &fakeResource{current: current}
+	if result, err := newTestApplier(r, config.ApplyModeClient, true).apply(context.Background(), obj); err != nil || result != applyUnchanged {
+		t.Fatalf("apply() = %v, %v, expected the object to be unchanged", result, err)
+	}
+	if len(r.patches) > 0 {
+		t.Errorf("expected an unchanged object not to be patched, got %+v", r.patches)
+	}
+}
+
+func newTestDeployment(containers ...string) *unstructured.Unstructured {
+	obj := newObject("apps/v1", "Deployment", "busybox")
+	obj.SetNamespace("busybox")
+	list := []interface{}{}
+	for _, container := range containers {
+		name, image, _ := strings.Cut(container, "=")
+		list = append(list, map[string]interface{}{"name": name, "image": image})
+	}
+	if err := unstructured.SetNestedSlice(obj.Object, list, "spec", "template", "spec", "containers"); err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+// withLastApplied returns current annotated with the configuration applied last
+func withLastApplied(t *testing.T, current, lastApplied *unstructured.Unstructured) *unstructured.Unstructured {
+	lastAppliedJSON, err := json.Marshal(lastApplied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current.SetAnnotations(map[string]string{corev1.LastAppliedConfigAnnotation: string(lastAppliedJSON)})
+	return current
+}
+
+func TestClientSideApplyKeepsListItemsOfOthers(t *testing.T) {
+	// a sidecar was injected into the deployment applied before
+	current := withLastApplied(t, newTestDeployment("app=app:v1", "sidecar=proxy:v1"), newTestDeployment("app=app:v1"))
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &fakeResource{current: current}
+	obj := newTestDeployment("app=app:v2")
+	if result, err := newTestApplier(r, config.ApplyModeClient, false).apply(context.Background(), obj); err != nil || result != applyUpdated {
+		t.Fatalf("apply() = %v, %v, expected the object to be updated", result, err)
+	}
+	if len(r.patches) != 1 || r.patches[0].patchType != types.StrategicMergePatchType {
+		t.Fatalf("expected a single strategic merge patch, got %+v", r.patches)
+	}
+	patchJSON, err := json.Marshal(r.patches[0].data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patchedJSON, err := strategicpatch.StrategicMergePatch(currentJSON, patchJSON, appsv1.Deployment{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched := &appsv1.Deployment{}
+	if err := json.Unmarshal(patchedJSON, patched); err != nil {
+		t.Fatal(err)
+	}
+	images := map[string]string{}
+	for _, container := range patched.Spec.Template.Spec.Containers {
+		images[container.Name] = container.Image
+	}
+	if expected := map[string]string{"app": "app:v2", "sidecar": "proxy:v1"}; !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected the containers %v after patching, got %v", expected, images)
+	}
+}
+
+func TestClientSideApplyCustomResource(t *testing.T) {
+	lastApplied := newObject("example.com/v1", "Widget", "widget")
+	lastApplied.SetNamespace("busybox")
+	obj := lastApplied.DeepCopy()
+	if err := unstructured.SetNestedField(obj.Object, "large", "spec", "size"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &fakeResource{current: withLastApplied(t, lastApplied.DeepCopy(), lastApplied)}
+	if result, err := newTestApplier(r, config.ApplyModeClient, false).apply(context.Background(), obj); err != nil || result != applyUpdated {
+		t.Fatalf("apply() = %v, %v, expected the object to be updated", result, err)
+	}
+	if len(r.patches) != 1 || r.patches[0].patchType != types.MergePatchType {
+		t.Fatalf("expected a single JSON merge patch of the custom resource, got %+v", r.patches)
+	}
+	if size, _, _ := unstructured.NestedString(r.patches[0].data, "spec", "size"); size != "large" {
+		t.Errorf("expected the patch to set the size, got %v", r.patches[0].data)
+	}
+}
+
+func TestKustomizerRunFails(t *testing.T) {
+	dir := t.TempDir()
+	// a resource that doesn't exist fails rendering, before the cluster is contacted
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n- missing.yaml\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := &Kustomizer{paths: []string{t.TempDir(), dir}, workDir: t.TempDir(), applyBackoff: time.Millisecond}
+
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	if err := s.Run(context.Background(), ready, stopped); err == nil {
+		t.Fatal("expected the failed kustomization to fail the kustomizer instead of exiting")
+	}
+	select {
+	case <-ready:
+		t.Error("expected the failed kustomizer not to become ready")
+	default:
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("expected the failed kustomizer to be stopped")
+	}
+}
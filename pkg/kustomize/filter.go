@@ -0,0 +1,67 @@
+package kustomize
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// ResourceFilter selects the rendered resources that are applied, e.g. to apply only
+// the resources for a site from a manifest set shared by all sites.
+type ResourceFilter struct {
+	selector labels.Selector
+	include  []string
+	exclude  []string
+}
+
+// NewResourceFilter returns the filter for the label selector and name patterns of
+// the configuration.
+func NewResourceFilter(cfg config.ManifestsConfig) (ResourceFilter, error) {
+	selector, err := labels.Parse(cfg.Selector)
+	if err != nil {
+		return ResourceFilter{}, fmt.Errorf("invalid manifests selector %q: %w", cfg.Selector, err)
+	}
+	return ResourceFilter{selector: selector, include: cfg.Include, exclude: cfg.Exclude}, nil
+}
+
+// matches returns whether obj has the selected labels and a name that is included
+// and not excluded.
+func (f ResourceFilter) matches(obj *unstructured.Unstructured) bool {
+	if f.selector != nil && !f.selector.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	if len(f.include) > 0 && !matchesAny(f.include, obj.GetName()) {
+		return false
+	}
+	return !matchesAny(f.exclude, obj.GetName())
+}
+
+// filter returns the resources matching f, logging the ones that are skipped.
+func (f ResourceFilter) filter(objs []*unstructured.Unstructured) []*unstructured.Unstructured {
+	selected := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if f.matches(obj) {
+			selected = append(selected, obj)
+			continue
+		}
+		klog.V(2).Infof("Skipping %s %s/%s, it doesn't match the manifests selection", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	}
+	if len(selected) < len(objs) {
+		klog.Infof("Applying %d of %d resources matching the manifests selection", len(selected), len(objs))
+	}
+	return selected
+}
+
+// the patterns are validated with the config
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
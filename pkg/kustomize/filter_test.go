@@ -0,0 +1,56 @@
+package kustomize
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newLabeledObject(kind, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := newObject("v1", kind, name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+func TestResourceFilter(t *testing.T) {
+	site := newLabeledObject("Namespace", "site-a", map[string]string{"site": "a"})
+	app := newLabeledObject("ConfigMap", "app-config", map[string]string{"site": "a", "tier": "edge"})
+	debug := newLabeledObject("ConfigMap", "app-debug", map[string]string{"site": "a"})
+	other := newLabeledObject("ConfigMap", "app-config", map[string]string{"site": "b"})
+	unlabeled := newLabeledObject("ConfigMap", "shared", nil)
+	objs := []*unstructured.Unstructured{site, app, debug, other, unlabeled}
+
+	var tests = []struct {
+		name     string
+		cfg      config.ManifestsConfig
+		expected []*unstructured.Unstructured
+	}{
+		{"no selection", config.ManifestsConfig{}, objs},
+		{"selector", config.ManifestsConfig{Selector: "site=a"}, []*unstructured.Unstructured{site, app, debug}},
+		{"selector without label", config.ManifestsConfig{Selector: "!site"}, []*unstructured.Unstructured{unlabeled}},
+		{"include", config.ManifestsConfig{Include: []string{"app-*"}}, []*unstructured.Unstructured{app, debug, other}},
+		{"exclude", config.ManifestsConfig{Exclude: []string{"*-debug", "shared"}}, []*unstructured.Unstructured{site, app, other}},
+		{"combined", config.ManifestsConfig{Selector: "site=a", Include: []string{"app-*"}, Exclude: []string{"*-debug"}}, []*unstructured.Unstructured{app}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewResourceFilter(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.filter(objs); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", objectNames(tt.expected), objectNames(got))
+			}
+		})
+	}
+}
+
+func objectNames(objs []*unstructured.Unstructured) []string {
+	names := []string{}
+	for _, obj := range objs {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+	}
+	return names
+}
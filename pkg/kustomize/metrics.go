@@ -0,0 +1,37 @@
+package kustomize
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// manifestApplyTotal counts the applied resources by result. It is served with the
+// metrics of kube-apiserver, which share the registry of the process.
+var manifestApplyTotal = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Namespace:      "microshift",
+		Subsystem:      "manifest",
+		Name:           "apply_total",
+		Help:           "Number of resources the kustomizer applied from the manifests, by result.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"result"},
+)
+
+var registerMetricsOnce sync.Once
+
+// registerMetrics registers the kustomizer's metrics. Until then recording them is a
+// no-op.
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(manifestApplyTotal)
+	})
+}
+
+func recordApplyResults(results applyResults) {
+	for result, n := range results {
+		manifestApplyTotal.WithLabelValues(string(result)).Add(float64(n))
+	}
+}
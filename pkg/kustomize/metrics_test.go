@@ -0,0 +1,77 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+// resultApplier returns the result for each resource by name, failing the ones
+// without a result.
+type resultApplier struct {
+	results map[string]applyResult
+}
+
+func (a *resultApplier) apply(ctx context.Context, obj *unstructured.Unstructured) (applyResult, error) {
+	result, ok := a.results[obj.GetName()]
+	if !ok {
+		return applyFailed, fmt.Errorf("the server rejected %s", obj.GetName())
+	}
+	if result == applyConflict {
+		return result, fmt.Errorf("%s has conflicts", obj.GetName())
+	}
+	return result, nil
+}
+
+func (a *resultApplier) waitForCRDs(ctx context.Context, names []string) error { return nil }
+
+func TestApplyResourcesResults(t *testing.T) {
+	a := &resultApplier{results: map[string]applyResult{
+		"created":   applyCreated,
+		"updated-1": applyUpdated,
+		"updated-2": applyUpdated,
+		"unchanged": applyUnchanged,
+		"conflict":  applyConflict,
+	}}
+	objs := []*unstructured.Unstructured{}
+	for _, name := range []string{"created", "updated-1", "updated-2", "unchanged", "conflict", "rejected"} {
+		objs = append(objs, newObject("v1", "ConfigMap", name))
+	}
+
+	results, err := applyResources(context.Background(), a, objs, applyWorkers)
+	if err == nil || !strings.Contains(err.Error(), "the server rejected rejected") || !strings.Contains(err.Error(), "conflict has conflicts") {
+		t.Errorf("expected the rejected and conflicting resources to fail applying, got %v", err)
+	}
+	expected := applyResults{applyCreated: 1, applyUpdated: 2, applyUnchanged: 1, applyConflict: 1, applyFailed: 1}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected results %v, got %v", expected, results)
+	}
+	if got := results.String(); got != "1 conflict, 1 created, 1 failed, 1 unchanged, 2 updated" {
+		t.Errorf("expected the results to be summarized, got %q", got)
+	}
+
+	registry := metrics.NewKubeRegistry()
+	registry.MustRegister(manifestApplyTotal)
+	manifestApplyTotal.Reset()
+	recordApplyResults(results)
+	recordApplyResults(applyResults{applyUnchanged: 6})
+
+	want := `
+# HELP microshift_manifest_apply_total [ALPHA] Number of resources the kustomizer applied from the manifests, by result.
+# TYPE microshift_manifest_apply_total counter
+microshift_manifest_apply_total{result="conflict"} 1
+microshift_manifest_apply_total{result="created"} 1
+microshift_manifest_apply_total{result="failed"} 1
+microshift_manifest_apply_total{result="unchanged"} 7
+microshift_manifest_apply_total{result="updated"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "microshift_manifest_apply_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,146 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const applyWorkers = 8
+
+// applyResult is what applying a resource did to the object in the cluster
+type applyResult string
+
+const (
+	applyCreated   applyResult = "created"
+	applyUpdated   applyResult = "updated"
+	applyUnchanged applyResult = "unchanged"
+	// applyConflict is returned, along with an error, for objects not applied as
+	// other managers own conflicting fields
+	applyConflict applyResult = "conflict"
+	applyFailed   applyResult = "failed"
+)
+
+// applyResults counts the applied resources by result
+type applyResults map[applyResult]int
+
+func (r applyResults) String() string {
+	counts := make([]string, 0, len(r))
+	for result, n := range r {
+		counts = append(counts, fmt.Sprintf("%d %s", n, result))
+	}
+	sort.Strings(counts)
+	if len(counts) == 0 {
+		return "no resources"
+	}
+	return strings.Join(counts, ", ")
+}
+
+// resourceApplier applies single resources to the cluster.
+type resourceApplier interface {
+	apply(ctx context.Context, obj *unstructured.Unstructured) (applyResult, error)
+	// waitForCRDs blocks until the named CRDs are established and their custom
+	// resources can be applied.
+	waitForCRDs(ctx context.Context, names []string) error
+}
+
+func isCRD(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition"
+}
+
+func isNamespace(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Namespace"
+}
+
+// applyPhases orders the resources into phases that need to be applied one after the
+// other: namespaces and CRDs go first, as the remaining resources may live in or be
+// instances of them.
+func applyPhases(objs []*unstructured.Unstructured) [][]*unstructured.Unstructured {
+	first, rest := []*unstructured.Unstructured{}, []*unstructured.Unstructured{}
+	for _, obj := range objs {
+		if isNamespace(obj) || isCRD(obj) {
+			first = append(first, obj)
+		} else {
+			rest = append(rest, obj)
+		}
+	}
+	phases := [][]*unstructured.Unstructured{}
+	for _, phase := range [][]*unstructured.Unstructured{first, rest} {
+		if len(phase) > 0 {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
+// applyResources applies the resources phase by phase, with at most workers of them
+// being applied concurrently within a phase. Before moving on from a phase containing
+// CRDs, it waits for them to be established. The results of the resources applied
+// are returned even if applying failed.
+func applyResources(ctx context.Context, a resourceApplier, objs []*unstructured.Unstructured, workers int) (applyResults, error) {
+	results := applyResults{}
+	for _, phase := range applyPhases(objs) {
+		if err := applyConcurrently(ctx, a, phase, workers, results); err != nil {
+			return results, err
+		}
+
+		crds := []string{}
+		for _, obj := range phase {
+			if isCRD(obj) {
+				crds = append(crds, obj.GetName())
+			}
+		}
+		if len(crds) > 0 {
+			if err := a.waitForCRDs(ctx, crds); err != nil {
+				return results, fmt.Errorf("waiting for CRDs %v to be established: %w", crds, err)
+			}
+		}
+	}
+	return results, nil
+}
+
+// applyConcurrently applies the resources, adding their results to results.
+func applyConcurrently(ctx context.Context, a resourceApplier, objs []*unstructured.Unstructured, workers int, results applyResults) error {
+	queue := make(chan *unstructured.Unstructured)
+	errs := make(chan error, len(objs))
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range queue {
+				result, err := a.apply(ctx, obj)
+				if err != nil && result != applyConflict {
+					result = applyFailed
+				}
+				if err != nil {
+					errs <- fmt.Errorf("applying %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+				}
+				lock.Lock()
+				results[result]++
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, obj := range objs {
+		queue <- obj
+	}
+	close(queue)
+	wg.Wait()
+	close(errs)
+
+	aggregate := []error{}
+	for err := range errs {
+		aggregate = append(aggregate, err)
+	}
+	return utilerrors.NewAggregate(aggregate)
+}
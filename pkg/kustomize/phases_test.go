@@ -0,0 +1,208 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newObject(apiVersion, kind, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	return obj
+}
+
+var (
+	testNamespace = newObject("v1", "Namespace", "busybox")
+	testCRD       = newObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "widgets.example.com")
+	testCR        = newObject("example.com/v1", "Widget", "widget")
+	testConfigMap = newObject("v1", "ConfigMap", "config")
+)
+
+type fakeApplier struct {
+	lock        sync.Mutex
+	events      []string
+	established bool
+	inFlight    int
+	maxInFlight int
+	// establish is closed to let waitForCRDs return
+	establish chan struct{}
+}
+
+func (a *fakeApplier) record(event string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.events = append(a.events, event)
+}
+
+func (a *fakeApplier) apply(ctx context.Context, obj *unstructured.Unstructured) (applyResult, error) {
+	a.lock.Lock()
+	a.inFlight++
+	if a.inFlight > a.maxInFlight {
+		a.maxInFlight = a.inFlight
+	}
+	established := a.established
+	a.lock.Unlock()
+	defer func() {
+		a.lock.Lock()
+		a.inFlight--
+		a.lock.Unlock()
+	}()
+
+	if obj.GetKind() == "Widget" && !established {
+		return applyFailed, fmt.Errorf("no matches for kind %q", obj.GetKind())
+	}
+	time.Sleep(10 * time.Millisecond)
+	a.record(obj.GetKind())
+	return applyCreated, nil
+}
+
+func (a *fakeApplier) waitForCRDs(ctx context.Context, names []string) error {
+	if a.establish != nil {
+		<-a.establish
+	}
+	a.lock.Lock()
+	a.established = true
+	a.lock.Unlock()
+	a.record(fmt.Sprintf("wait %v", names))
+	return nil
+}
+
+func TestApplyPhases(t *testing.T) {
+	phases := applyPhases([]*unstructured.Unstructured{testCR, testConfigMap, testCRD, testNamespace})
+	expected := [][]*unstructured.Unstructured{
+		{testCRD, testNamespace},
+		{testCR, testConfigMap},
+	}
+	if !reflect.DeepEqual(phases, expected) {
+		t.Errorf("expected phases %v, got %v", expected, phases)
+	}
+
+	if phases := applyPhases([]*unstructured.Unstructured{testConfigMap}); len(phases) != 1 {
+		t.Errorf("expected empty phases to be skipped, got %v", phases)
+	}
+}
+
+func TestApplyResourcesOrdering(t *testing.T) {
+	a := &fakeApplier{}
+	objs := []*unstructured.Unstructured{testCR, testConfigMap, testCRD}
+	if _, err := applyResources(context.Background(), a, objs, applyWorkers); err != nil {
+		t.Fatalf("applying resources failed: %v", err)
+	}
+
+	if len(a.events) != 4 {
+		t.Fatalf("expected 3 resources to be applied and one wait, got %v", a.events)
+	}
+	if a.events[0] != "CustomResourceDefinition" || a.events[1] != "wait [widgets.example.com]" {
+		t.Errorf("expected the CRD to be applied and established first, got %v", a.events)
+	}
+}
+
+func TestApplyResourcesWaitsForCRDs(t *testing.T) {
+	a := &fakeApplier{establish: make(chan struct{})}
+	done := make(chan error)
+	go func() {
+		_, err := applyResources(context.Background(), a, []*unstructured.Unstructured{testCR, testCRD}, applyWorkers)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected applying to wait for the CRD to be established, returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	a.lock.Lock()
+	events := append([]string{}, a.events...)
+	a.lock.Unlock()
+	if !reflect.DeepEqual(events, []string{"CustomResourceDefinition"}) {
+		t.Errorf("expected only the CRD to be applied before it is established, got %v", events)
+	}
+
+	close(a.establish)
+	if err := <-done; err != nil {
+		t.Fatalf("applying resources failed: %v", err)
+	}
+	if a.events[len(a.events)-1] != "Widget" {
+		t.Errorf("expected the custom resource to be applied once the CRD is established, got %v", a.events)
+	}
+}
+
+func TestApplyResourcesBoundedConcurrency(t *testing.T) {
+	objs := []*unstructured.Unstructured{}
+	for i := 0; i < 20; i++ {
+		objs = append(objs, newObject("v1", "ConfigMap", fmt.Sprintf("config-%d", i)))
+	}
+	a := &fakeApplier{}
+	if _, err := applyResources(context.Background(), a, objs, 3); err != nil {
+		t.Fatalf("applying resources failed: %v", err)
+	}
+	if len(a.events) != len(objs) {
+		t.Errorf("expected %d resources to be applied, got %d", len(objs), len(a.events))
+	}
+	if a.maxInFlight > 3 {
+		t.Errorf("expected at most 3 concurrent applies, got %d", a.maxInFlight)
+	}
+	if a.maxInFlight < 2 {
+		t.Errorf("expected resources to be applied concurrently, got %d at a time", a.maxInFlight)
+	}
+}
+
+func TestApplyResourcesError(t *testing.T) {
+	// without a CRD the custom resource fails to apply, which fails the phase
+	a := &fakeApplier{}
+	results, err := applyResources(context.Background(), a, []*unstructured.Unstructured{testCR, testConfigMap}, applyWorkers)
+	if err == nil {
+		t.Errorf("expected applying a custom resource without its CRD to fail")
+	}
+	if expected := (applyResults{applyCreated: 1, applyFailed: 1}); !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected the results of the failed phase %v, got %v", expected, results)
+	}
+}
+
+func TestRenderKustomization(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: busybox
+resources:
+- namespace.yaml
+- configmap.yaml
+`,
+		"namespace.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: busybox
+`,
+		"configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objs, err := renderKustomization(dir, t.TempDir())
+	if err != nil {
+		t.Fatalf("rendering kustomization failed: %v", err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(objs))
+	}
+	if !isNamespace(objs[0]) || objs[1].GetKind() != "ConfigMap" || objs[1].GetNamespace() != "busybox" {
+		t.Errorf("unexpected resources %v", objs)
+	}
+}
@@ -0,0 +1,84 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	readyCheckInterval = 5 * time.Second
+	readyTimeout       = 5 * time.Minute
+)
+
+// waitForResourcesReady polls the referenced workloads until all of them are ready,
+// or returns an error naming the ones that aren't once timeout expires.
+func waitForResourcesReady(ctx context.Context, client kubernetes.Interface, refs []config.ResourceRef, interval, timeout time.Duration) error {
+	pending := refs
+	err := wait.PollImmediateWithContext(ctx, interval, timeout, func(ctx context.Context) (bool, error) {
+		notReady := []config.ResourceRef{}
+		for _, ref := range pending {
+			ready, err := resourceReady(ctx, client, ref)
+			if err != nil {
+				klog.Infof("Checking readiness of %s failed: %v", ref, err)
+			}
+			if !ready {
+				notReady = append(notReady, ref)
+			}
+		}
+		pending = notReady
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for %v to become ready: %w", pending, err)
+	}
+	return nil
+}
+
+// resourceReady returns whether the workload has rolled out its latest generation.
+func resourceReady(ctx context.Context, client kubernetes.Interface, ref config.ResourceRef) (bool, error) {
+	switch ref.Kind {
+	case "Deployment":
+		d, err := client.AppsV1().Deployments(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if d.Status.ObservedGeneration < d.Generation {
+			return false, nil
+		}
+		for _, c := range d.Status.Conditions {
+			if c.Type == appsv1.DeploymentAvailable {
+				return c.Status == corev1.ConditionTrue, nil
+			}
+		}
+		return false, nil
+	case "DaemonSet":
+		ds, err := client.AppsV1().DaemonSets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return ds.Status.ObservedGeneration >= ds.Generation &&
+			ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled &&
+			ds.Status.NumberAvailable == ds.Status.DesiredNumberScheduled, nil
+	case "StatefulSet":
+		ss, err := client.AppsV1().StatefulSets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		replicas := int32(1)
+		if ss.Spec.Replicas != nil {
+			replicas = *ss.Spec.Replicas
+		}
+		return ss.Status.ObservedGeneration >= ss.Generation &&
+			ss.Status.ReadyReplicas == replicas, nil
+	}
+	return false, fmt.Errorf("unsupported kind %q", ref.Kind)
+}
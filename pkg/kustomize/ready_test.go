@@ -0,0 +1,83 @@
+package kustomize
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newDeployment(available corev1.ConditionStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "busybox", Name: "busybox-deployment", Generation: 1},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: available},
+			},
+		},
+	}
+}
+
+var busyboxRef = config.ResourceRef{Kind: "Deployment", Namespace: "busybox", Name: "busybox-deployment"}
+
+func TestWaitForResourcesReady(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(newDeployment(corev1.ConditionFalse))
+
+	// make the deployment available after a few polls
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if _, err := client.AppsV1().Deployments("busybox").UpdateStatus(ctx, newDeployment(corev1.ConditionTrue), metav1.UpdateOptions{}); err != nil {
+			t.Errorf("failed to update deployment: %v", err)
+		}
+	}()
+
+	if err := waitForResourcesReady(ctx, client, []config.ResourceRef{busyboxRef}, 10*time.Millisecond, 5*time.Second); err != nil {
+		t.Errorf("expected deployment to become ready: %v", err)
+	}
+}
+
+func TestWaitForResourcesReady_timeout(t *testing.T) {
+	var ttests = []struct {
+		name   string
+		client *fake.Clientset
+	}{
+		{name: "deployment not available", client: fake.NewSimpleClientset(newDeployment(corev1.ConditionFalse))},
+		{name: "deployment missing", client: fake.NewSimpleClientset()},
+	}
+
+	for _, tt := range ttests {
+		err := waitForResourcesReady(context.Background(), tt.client, []config.ResourceRef{busyboxRef}, 10*time.Millisecond, 50*time.Millisecond)
+		if err == nil {
+			t.Errorf("%s: expected a timeout error", tt.name)
+		}
+	}
+}
+
+func TestKustomizerWaitForWorkloads(t *testing.T) {
+	s := &Kustomizer{waitForReady: []config.ResourceRef{busyboxRef}}
+
+	client := fake.NewSimpleClientset(newDeployment(corev1.ConditionTrue))
+	if err := s.waitForWorkloads(context.Background(), client, 10*time.Millisecond, 50*time.Millisecond); err != nil {
+		t.Errorf("expected the available deployment to be ready: %v", err)
+	}
+
+	client = fake.NewSimpleClientset(newDeployment(corev1.ConditionFalse))
+	err := s.waitForWorkloads(context.Background(), client, 10*time.Millisecond, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "manifests not ready") {
+		t.Errorf("expected the kustomizer to fail while the deployment isn't available, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.waitForWorkloads(ctx, client, 10*time.Millisecond, time.Minute); err != context.Canceled {
+		t.Errorf("expected waiting to stop with the context, got %v", err)
+	}
+}
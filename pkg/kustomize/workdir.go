@@ -0,0 +1,128 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// workDirFs is the file system kustomizations are rendered on. The manifests
+// directories may be read-only, e.g. on ostree based systems, so writes into the
+// source directory are redirected into a mirror of it below the work directory,
+// which is read first.
+type workDirFs struct {
+	filesys.FileSystem
+	source string
+	mirror string
+}
+
+// newWorkDirFs returns a file system reading source from disk and writing below
+// workDir. Its state from earlier renderings of source is removed.
+func newWorkDirFs(source, workDir string) (*workDirFs, error) {
+	source, err := filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+	mirror := filepath.Join(workDir, source)
+	if err := os.RemoveAll(mirror); err != nil {
+		return nil, err
+	}
+	return &workDirFs{FileSystem: filesys.MakeFsOnDisk(), source: source, mirror: mirror}, nil
+}
+
+// redirect returns the path in the work directory for paths in the source directory
+func (fs *workDirFs) redirect(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path, false
+	}
+	rel, err := filepath.Rel(fs.source, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path, false
+	}
+	return filepath.Join(fs.mirror, rel), true
+}
+
+// read returns the path to read, the redirected one if it was written
+func (fs *workDirFs) read(path string) string {
+	if redirected, ok := fs.redirect(path); ok && fs.FileSystem.Exists(redirected) {
+		return redirected
+	}
+	return path
+}
+
+// write returns the path to write, making sure its parent exists in the work directory
+func (fs *workDirFs) write(path string) (string, error) {
+	redirected, ok := fs.redirect(path)
+	if !ok {
+		return path, nil
+	}
+	return redirected, os.MkdirAll(filepath.Dir(redirected), 0700)
+}
+
+func (fs *workDirFs) Create(path string) (filesys.File, error) {
+	path, err := fs.write(path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.FileSystem.Create(path)
+}
+
+func (fs *workDirFs) Mkdir(path string) error {
+	path, err := fs.write(path)
+	if err != nil {
+		return err
+	}
+	return fs.FileSystem.Mkdir(path)
+}
+
+func (fs *workDirFs) MkdirAll(path string) error {
+	path, err := fs.write(path)
+	if err != nil {
+		return err
+	}
+	return fs.FileSystem.MkdirAll(path)
+}
+
+// RemoveAll only removes what was written to the work directory
+func (fs *workDirFs) RemoveAll(path string) error {
+	path, err := fs.write(path)
+	if err != nil {
+		return err
+	}
+	return fs.FileSystem.RemoveAll(path)
+}
+
+func (fs *workDirFs) WriteFile(path string, data []byte) error {
+	path, err := fs.write(path)
+	if err != nil {
+		return err
+	}
+	return fs.FileSystem.WriteFile(path, data)
+}
+
+func (fs *workDirFs) Open(path string) (filesys.File, error) {
+	return fs.FileSystem.Open(fs.read(path))
+}
+
+func (fs *workDirFs) ReadFile(path string) ([]byte, error) {
+	return fs.FileSystem.ReadFile(fs.read(path))
+}
+
+func (fs *workDirFs) Exists(path string) bool {
+	return fs.FileSystem.Exists(fs.read(path))
+}
+
+func (fs *workDirFs) IsDir(path string) bool {
+	return fs.FileSystem.IsDir(fs.read(path))
+}
+
+func (fs *workDirFs) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	// directories only created in the work directory resolve to it
+	if redirected, ok := fs.redirect(path); ok && !fs.FileSystem.Exists(path) && fs.FileSystem.Exists(redirected) {
+		return fs.FileSystem.CleanedAbs(redirected)
+	}
+	return fs.FileSystem.CleanedAbs(path)
+}
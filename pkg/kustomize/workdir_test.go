@@ -0,0 +1,151 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// listTree returns the paths of all files and directories below dir
+func listTree(t *testing.T, dir string) []string {
+	paths := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestWorkDirFsRedirectsWrites(t *testing.T) {
+	source, workDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "kustomization.yaml"), []byte("resources: []\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := newWorkDirFs(source, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart := filepath.Join(source, "charts", "chart.yaml")
+	if err := fs.MkdirAll(filepath.Dir(chart)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFile(chart, []byte("name: chart\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := listTree(t, source); !reflect.DeepEqual(got, []string{".", "kustomization.yaml"}) {
+		t.Errorf("expected the source to be untouched, got %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, source, "charts", "chart.yaml")); err != nil {
+		t.Errorf("expected the write to go to the work directory: %v", err)
+	}
+	if !fs.Exists(chart) || !fs.IsDir(filepath.Dir(chart)) {
+		t.Errorf("expected the written file to be visible in the source path")
+	}
+	if contents, err := fs.ReadFile(chart); err != nil || string(contents) != "name: chart\n" {
+		t.Errorf("expected to read the written file, got %q, %v", contents, err)
+	}
+	if contents, err := fs.ReadFile(filepath.Join(source, "kustomization.yaml")); err != nil || string(contents) != "resources: []\n" {
+		t.Errorf("expected to read the source file, got %q, %v", contents, err)
+	}
+
+	if err := fs.RemoveAll(source); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(source, "kustomization.yaml")); err != nil {
+		t.Errorf("expected removing to leave the source untouched: %v", err)
+	}
+	if fs.Exists(chart) {
+		t.Errorf("expected the written file to be removed")
+	}
+}
+
+func TestWorkDirFsWritesOutsideSource(t *testing.T) {
+	source, workDir, other := t.TempDir(), t.TempDir(), t.TempDir()
+	fs, err := newWorkDirFs(source, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// siblings with the source's name as prefix aren't part of it
+	sibling := source + "-other"
+	defer os.RemoveAll(sibling)
+	for _, path := range []string{filepath.Join(other, "file"), filepath.Join(sibling, "file")} {
+		if err := fs.MkdirAll(filepath.Dir(path)); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile(path, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected writes outside of the source to be kept: %v", err)
+		}
+	}
+}
+
+func TestWorkDirFsClearsEarlierState(t *testing.T) {
+	source, workDir := t.TempDir(), t.TempDir()
+	fs, err := newWorkDirFs(source, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(source, "stale.yaml")
+	if err := fs.WriteFile(stale, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = newWorkDirFs(source, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.Exists(stale) {
+		t.Errorf("expected the state of an earlier rendering to be removed")
+	}
+}
+
+func TestRenderKustomizationReadOnlySource(t *testing.T) {
+	source, workDir := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		"kustomization.yaml": `resources:
+- configmap.yaml
+`,
+		"configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(contents), 0400); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(source, 0500); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(source, 0700)
+
+	objs, err := renderKustomization(source, workDir)
+	if err != nil {
+		t.Fatalf("rendering kustomization failed: %v", err)
+	}
+	if len(objs) != 1 || objs[0].GetKind() != "ConfigMap" {
+		t.Errorf("unexpected resources %v", objs)
+	}
+	if got := listTree(t, source); !reflect.DeepEqual(got, []string{".", "configmap.yaml", "kustomization.yaml"}) {
+		t.Errorf("expected the source to be untouched, got %v", got)
+	}
+}
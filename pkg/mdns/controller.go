@@ -16,7 +16,10 @@ type MicroShiftmDNSController struct {
 	sync.Mutex
 	NodeName   string
 	NodeIP     string
+	Hostname   string
 	KubeConfig string
+	// dependency is the service the route API is available after
+	dependency string
 	myIPs      []string
 	resolver   *server.Resolver
 	hostCount  map[string]int
@@ -24,17 +27,23 @@ type MicroShiftmDNSController struct {
 }
 
 func NewMicroShiftmDNSController(cfg *config.MicroshiftConfig) *MicroShiftmDNSController {
+	dependency := "openshift-default-scc-manager"
+	if !cfg.ComponentEnabled(config.ComponentOpenShiftSCC) {
+		dependency = "openshift-crd-manager"
+	}
 	return &MicroShiftmDNSController{
 		NodeIP:     cfg.NodeIP,
-		NodeName:   cfg.NodeName,
+		NodeName:   cfg.KubeletNodeName(),
+		Hostname:   cfg.MDNS.Hostname,
 		KubeConfig: cfg.KubeConfigPath(config.KubeAdmin),
+		dependency: dependency,
 		hostCount:  make(map[string]int),
 	}
 }
 
 func (s *MicroShiftmDNSController) Name() string { return "microshift-mdns-controller" }
 func (s *MicroShiftmDNSController) Dependencies() []string {
-	return []string{"openshift-default-scc-manager"}
+	return []string{s.dependency}
 }
 
 func (c *MicroShiftmDNSController) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
@@ -80,10 +89,10 @@ func (c *MicroShiftmDNSController) Run(ctx context.Context, ready chan<- struct{
 
 	c.myIPs = ips
 
-	if strings.HasSuffix(c.NodeName, server.DefaultmDNSTLD) {
+	if hostname := c.advertisedHostname(); strings.HasSuffix(hostname, server.DefaultmDNSTLD) {
 
-		klog.Infof("mDNS: Host FQDN %q will be announced via mDNS on IPs %q", c.NodeName, ips)
-		c.resolver.AddDomain(c.NodeName+".", ips)
+		klog.Infof("mDNS: Host FQDN %q will be announced via mDNS on IPs %q", hostname, ips)
+		c.resolver.AddDomain(hostname+".", ips)
 	}
 
 	close(ready)
@@ -95,6 +104,17 @@ func (c *MicroShiftmDNSController) Run(ctx context.Context, ready chan<- struct{
 	return ctx.Err()
 }
 
+// advertisedHostname returns the configured mDNS hostname, falling back to the node name.
+func (c *MicroShiftmDNSController) advertisedHostname() string {
+	if c.Hostname == "" {
+		return c.NodeName
+	}
+	if !strings.Contains(c.Hostname, ".") {
+		return c.Hostname + server.DefaultmDNSTLD
+	}
+	return c.Hostname
+}
+
 func ipInAddrs(ip string, addrs []net.Addr) bool {
 	for _, a := range addrs {
 		ipAddr, _, _ := net.ParseCIDR(a.String())
@@ -0,0 +1,37 @@
+package mdns
+
+import (
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+func Test_advertisedHostname(t *testing.T) {
+	var tests = []struct {
+		name     string
+		hostname string
+		want     string
+	}{
+		{name: "node name fallback", hostname: "", want: testNodeName},
+		{name: "custom .local name", hostname: "edge-gateway.local", want: "edge-gateway.local"},
+		{name: "single label", hostname: "edge-gateway", want: "edge-gateway.local"},
+	}
+
+	for _, tt := range tests {
+		c := newTestController()
+		c.Hostname = tt.hostname
+		if got := c.advertisedHostname(); got != tt.want {
+			t.Errorf("%s: advertisedHostname() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHostnameOverride(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.Node.HostnameOverride = "edge-node-1.local"
+
+	c := NewMicroShiftmDNSController(cfg)
+	if got := c.advertisedHostname(); got != "edge-node-1.local" {
+		t.Errorf("expected the hostname override to be announced, got %q", got)
+	}
+}
@@ -17,12 +17,22 @@ package node
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/microshift/pkg/config"
@@ -42,13 +52,48 @@ import (
 const (
 	// Kubelet component name
 	componentKubelet = "kubelet"
+	// the kubelet records the policy the CPUs were assigned with in its root directory
+	cpuManagerStateFile = "cpu_manager_state"
+	// how often the Node is checked for becoming Ready
+	nodeReadyPollInterval = 2 * time.Second
 )
 
-var microshiftDataDir = config.GetDataDir()
+// managedKubeletArgs are the kubelet arguments set by MicroShift that users can't override
+var managedKubeletArgs = []string{
+	"bootstrap-kubeconfig",
+	"kubeconfig",
+	"config",
+	"runtime-cgroups",
+	"node-ip",
+	"container-runtime",
+	"container-runtime-endpoint",
+	"node-labels",
+	"client-ca-file",
+	"tls-cert-file",
+	"tls-private-key-file",
+	"rotate-server-certificates",
+	"container-log-max-size",
+	"container-log-max-files",
+	"cpu-manager-policy",
+	"topology-manager-policy",
+	"cluster-dns",
+	"cluster-domain",
+	"hostname-override",
+}
 
 type KubeletServer struct {
 	kubeletflags *kubeletoptions.KubeletFlags
 	kubeconfig   *kubeletconfig.KubeletConfiguration
+	deps         []string
+
+	// sysctls are set before starting the kubelet, unless the node doesn't manage them
+	sysctls      map[string]string
+	sysctlWriter sysctlWriter
+
+	// waitForNodeReady delays readiness until the Node nodeName is Ready in the apiserver
+	waitForNodeReady bool
+	nodeName         string
+	kubeconfigPath   string
 }
 
 func NewKubeletServer(cfg *config.MicroshiftConfig) *KubeletServer {
@@ -58,37 +103,90 @@ func NewKubeletServer(cfg *config.MicroshiftConfig) *KubeletServer {
 }
 
 func (s *KubeletServer) Name() string           { return componentKubelet }
-func (s *KubeletServer) Dependencies() []string { return []string{"kube-apiserver"} }
+func (s *KubeletServer) Dependencies() []string { return s.deps }
 
 func (s *KubeletServer) configure(cfg *config.MicroshiftConfig) {
+	// the kubelet can only wait for the apiserver if it runs in the same process
+	s.deps = []string{}
+	if cfg.HasRole(config.ControlPlaneRole) {
+		s.deps = append(s.deps, "kube-apiserver")
+	}
 
 	if err := s.writeConfig(cfg); err != nil {
 		klog.Fatalf("Failed to write kubelet config", err)
 	}
 
+	kubeletConfig, err := loadConfigFile(kubeletConfigPath(cfg))
+
+	if err != nil {
+		klog.Fatalf("Failed to load Kubelet Configuration", err)
+	}
+
 	kubeletFlags := kubeletoptions.NewKubeletFlags()
+
+	extraArgs, _ := util.FilterExtraArgs(s.Name(), managedKubeletArgs, cfg.Node.ExtraArgs)
+	fs := pflag.NewFlagSet(s.Name(), pflag.ContinueOnError)
+	kubeletFlags.AddFlags(fs)
+	kubeletoptions.AddKubeletConfigFlags(fs, kubeletConfig)
+	if err := fs.Parse(util.ArgsToFlags(extraArgs)); err != nil {
+		klog.Fatalf("%s failed to parse flags: %v", s.Name(), err)
+	}
+
 	kubeletFlags.BootstrapKubeconfig = cfg.KubeConfigPath(config.Kubelet)
 	kubeletFlags.KubeConfig = cfg.KubeConfigPath(config.Kubelet)
 	kubeletFlags.RuntimeCgroups = "/system.slice/crio.service"
 	kubeletFlags.NodeIP = cfg.NodeIP
+	if cfg.Node.HostnameOverride != "" {
+		kubeletFlags.HostnameOverride = cfg.Node.HostnameOverride
+	}
 	kubeletFlags.ContainerRuntime = "remote"
-	kubeletFlags.RemoteRuntimeEndpoint = "unix:///var/run/crio/crio.sock"
+	kubeletFlags.RemoteRuntimeEndpoint = crioEndpoint
 	kubeletFlags.NodeLabels["node-role.kubernetes.io/control-plane"] = ""
 	kubeletFlags.NodeLabels["node-role.kubernetes.io/master"] = ""
 	kubeletFlags.NodeLabels["node-role.kubernetes.io/worker"] = ""
 
-	kubeletConfig, err := loadConfigFile(microshiftDataDir + "/resources/kubelet/config/config.yaml")
+	if cfg.Node.ManageSysctls {
+		s.sysctls = cfg.NodeSysctls()
+	}
 
-	if err != nil {
-		klog.Fatalf("Failed to load Kubelet Configuration", err)
+	statePath := filepath.Join(kubeletFlags.RootDirectory, cpuManagerStateFile)
+	if policy := cpuManagerStatePolicy(statePath); policy != "" && policy != cfg.Node.CPUManagerPolicy {
+		klog.Warningf("%s: the CPU manager policy changed from %s to %s, the kubelet won't start until %s is removed after draining the node's pods",
+			s.Name(), policy, cfg.Node.CPUManagerPolicy, statePath)
 	}
+	s.sysctlWriter = procSysctlWriter{dir: procSysDir}
+
+	s.waitForNodeReady = cfg.Node.WaitForNodeReady
+	s.nodeName = cfg.KubeletNodeName()
+	s.kubeconfigPath = cfg.KubeConfigPath(config.Kubelet)
 
 	s.kubeconfig = kubeletConfig
 	s.kubeletflags = kubeletFlags
 }
 
+// cpuManagerStatePolicy returns the CPU manager policy recorded in the kubelet's state,
+// or an empty string if there is none.
+func cpuManagerStatePolicy(path string) string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	state := struct {
+		PolicyName string `json:"policyName"`
+	}{}
+	if err := json.Unmarshal(contents, &state); err != nil {
+		klog.Warningf("failed to decode the CPU manager state %s: %v", path, err)
+		return ""
+	}
+	return state.PolicyName
+}
+
+func kubeletConfigPath(cfg *config.MicroshiftConfig) string {
+	return filepath.Join(cfg.RoleDataDir(config.NodeRole), "resources", "kubelet", "config", "config.yaml")
+}
+
 func (s *KubeletServer) writeConfig(cfg *config.MicroshiftConfig) error {
-	certsDir := cryptomaterial.CertsDirectory(microshiftDataDir)
+	certsDir := cryptomaterial.CertsDirectory(cfg.DataDir)
 	servingCertDir := cryptomaterial.KubeletServingCertDir(certsDir)
 
 	data := []byte(`
@@ -96,19 +194,22 @@ kind: KubeletConfiguration
 apiVersion: kubelet.config.k8s.io/v1beta1
 authentication:
   x509:
-    clientCAFile: ` + cryptomaterial.KubeletClientCAPath(cryptomaterial.CertsDirectory(microshiftDataDir)) + `
+    clientCAFile: ` + cryptomaterial.KubeletClientCAPath(cryptomaterial.CertsDirectory(cfg.DataDir)) + `
   anonymous:
     enabled: false
 tlsCertFile: ` + cryptomaterial.ServingCertPath(servingCertDir) + `
 tlsPrivateKeyFile: ` + cryptomaterial.ServingKeyPath(servingCertDir) + `
 cgroupDriver: "systemd"
 failSwapOn: false
-volumePluginDir: ` + microshiftDataDir + `/kubelet-plugins/volume/exec
+volumePluginDir: ` + cfg.RoleDataDir(config.NodeRole) + `/kubelet-plugins/volume/exec
 clusterDNS:
   - ` + cfg.Cluster.DNS + `
 clusterDomain: ` + cfg.Cluster.Domain + `
-containerLogMaxSize: 50Mi
-maxPods: 250
+containerLogMaxSize: ` + cfg.Node.ContainerLogMaxSize + `
+containerLogMaxFiles: ` + strconv.Itoa(cfg.Node.ContainerLogMaxFiles) + `
+maxPods: ` + strconv.Itoa(cfg.Node.MaxPods) + `
+cpuManagerPolicy: ` + cfg.Node.CPUManagerPolicy + `
+topologyManagerPolicy: ` + cfg.Node.TopologyManagerPolicy + `
 kubeAPIQPS: 50
 kubeAPIBurst: 100
 cgroupsPerQOS: true
@@ -120,8 +221,9 @@ featureGates:
   APIPriorityAndFairness: true
   PodSecurity: true
   DownwardAPIHugePages: true
-  RotateKubeletServerCertificate: false #TODO
-serverTLSBootstrap: false #TODO`)
+  RotateKubeletServerCertificate: ` + strconv.FormatBool(cfg.Node.RotateServerCertificates) + `
+  NodeSwap: ` + strconv.FormatBool(cfg.Node.SwapBehavior != config.SwapBehaviorOff) + `
+serverTLSBootstrap: ` + strconv.FormatBool(cfg.Node.RotateServerCertificates))
 
 	// Load real resolv.conf in case systemd-resolved is used
 	// https://github.com/coredns/coredns/blob/master/plugin/loop/README.md#troubleshooting-loops-in-kubernetes-clusters
@@ -129,7 +231,27 @@ serverTLSBootstrap: false #TODO`)
 		data = append(data, "\nresolvConf: /run/systemd/resolve/resolv.conf"...)
 	}
 
-	path := filepath.Join(microshiftDataDir, "resources", "kubelet", "config", "config.yaml")
+	// the kubelet runs on hosts with swap in any case, but workloads only use it
+	// with a swap behavior
+	if cfg.Node.SwapBehavior != config.SwapBehaviorOff {
+		data = append(data, "\nmemorySwap:\n  swapBehavior: "+cfg.Node.SwapBehavior...)
+	}
+
+	if cfg.Node.ShutdownGracePeriod != "" {
+		data = append(data, "\nshutdownGracePeriod: "+cfg.Node.ShutdownGracePeriod...)
+	}
+	if cfg.Node.ShutdownGracePeriodCriticalPods != "" {
+		data = append(data, "\nshutdownGracePeriodCriticalPods: "+cfg.Node.ShutdownGracePeriodCriticalPods...)
+	}
+
+	if cfg.Node.NodeStatusUpdateFrequency != "" {
+		data = append(data, "\nnodeStatusUpdateFrequency: "+cfg.Node.NodeStatusUpdateFrequency...)
+	}
+	if cfg.Node.NodeStatusReportFrequency != "" {
+		data = append(data, "\nnodeStatusReportFrequency: "+cfg.Node.NodeStatusReportFrequency...)
+	}
+
+	path := kubeletConfigPath(cfg)
 	os.MkdirAll(filepath.Dir(path), os.FileMode(0700))
 	return ioutil.WriteFile(path, data, 0644)
 }
@@ -137,12 +259,28 @@ serverTLSBootstrap: false #TODO`)
 func (s *KubeletServer) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
 
 	defer close(stopped)
+
+	// the network doesn't work without the required sysctls, but they may have been
+	// set by the host's configuration already
+	if err := applySysctls(s.sysctlWriter, s.sysctls); err != nil {
+		klog.Warningf("%s: %v, pods may not be reachable unless the host sets them", s.Name(), err)
+	}
+
 	// run readiness check
 	go func() {
 		healthcheckStatus := util.RetryInsecureHttpsGet("http://127.0.0.1:10248/healthz")
 		if healthcheckStatus != 200 {
 			klog.Fatalf("", fmt.Errorf("%s failed to start", s.Name()))
 		}
+		if s.waitForNodeReady {
+			client, err := s.nodeClient()
+			if err != nil {
+				klog.Fatalf("%s failed to create a client to check node %s: %v", s.Name(), s.nodeName, err)
+			}
+			if err := waitForNodeReady(ctx, client, s.nodeName, nodeReadyPollInterval); err != nil {
+				return
+			}
+		}
 		klog.Infof("%s is ready", s.Name())
 		close(ready)
 	}()
@@ -163,6 +301,33 @@ func (s *KubeletServer) Run(ctx context.Context, ready chan<- struct{}, stopped
 	return ctx.Err()
 }
 
+func (s *KubeletServer) nodeClient() (kubernetes.Interface, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", s.kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(rest.AddUserAgent(restConfig, s.Name()))
+}
+
+// waitForNodeReady polls the Node until its Ready condition is true or ctx is done.
+// The kubelet may not have registered the Node yet, so errors are retried.
+func waitForNodeReady(ctx context.Context, client kubernetes.Interface, name string, interval time.Duration) error {
+	klog.Infof("Waiting for node %s to be Ready", name)
+	return wait.PollImmediateUntilWithContext(ctx, interval, func(ctx context.Context) (bool, error) {
+		node, err := client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			klog.V(2).Infof("Failed to get node %s: %v", name, err)
+			return false, nil
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				return condition.Status == corev1.ConditionTrue, nil
+			}
+		}
+		return false, nil
+	})
+}
+
 func loadConfigFile(name string) (*kubeletconfig.KubeletConfiguration, error) {
 	const errFmt = "failed to load Kubelet config file %s, error %v"
 	// compute absolute path based on current working dir
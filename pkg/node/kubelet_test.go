@@ -0,0 +1,251 @@
+package node
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubeletShutdownGracePeriods(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.ShutdownGracePeriod = "30s"
+	cfg.Node.ShutdownGracePeriodCriticalPods = "10s"
+
+	s := NewKubeletServer(cfg)
+
+	if s.kubeconfig.ShutdownGracePeriod.Duration != 30*time.Second {
+		t.Errorf("expected shutdownGracePeriod 30s, got %v", s.kubeconfig.ShutdownGracePeriod.Duration)
+	}
+	if s.kubeconfig.ShutdownGracePeriodCriticalPods.Duration != 10*time.Second {
+		t.Errorf("expected shutdownGracePeriodCriticalPods 10s, got %v", s.kubeconfig.ShutdownGracePeriodCriticalPods.Duration)
+	}
+}
+
+func TestKubeletNodeStatusFrequencies(t *testing.T) {
+	var tests = []struct {
+		update, report         string
+		wantUpdate, wantReport time.Duration
+	}{
+		{update: "", report: "", wantUpdate: 10 * time.Second, wantReport: 5 * time.Minute},
+		{update: "1m", report: "30m", wantUpdate: time.Minute, wantReport: 30 * time.Minute},
+		// the kubelet reports as often as it updates if only the update frequency is set
+		{update: "1m", report: "", wantUpdate: time.Minute, wantReport: time.Minute},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Node.NodeStatusUpdateFrequency = tt.update
+		cfg.Node.NodeStatusReportFrequency = tt.report
+
+		s := NewKubeletServer(cfg)
+
+		if got := s.kubeconfig.NodeStatusUpdateFrequency.Duration; got != tt.wantUpdate {
+			t.Errorf("%q/%q: expected nodeStatusUpdateFrequency %v, got %v", tt.update, tt.report, tt.wantUpdate, got)
+		}
+		if got := s.kubeconfig.NodeStatusReportFrequency.Duration; got != tt.wantReport {
+			t.Errorf("%q/%q: expected nodeStatusReportFrequency %v, got %v", tt.update, tt.report, tt.wantReport, got)
+		}
+	}
+}
+
+func TestKubeletHostnameOverride(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.HostnameOverride = "edge-node-1"
+	cfg.Node.ExtraArgs = map[string][]string{"hostname-override": {"other-node"}}
+
+	s := NewKubeletServer(cfg)
+
+	if s.kubeletflags.HostnameOverride != "edge-node-1" {
+		t.Errorf("expected hostname override %q, got %q", "edge-node-1", s.kubeletflags.HostnameOverride)
+	}
+}
+
+func TestKubeletDependencies(t *testing.T) {
+	var tests = []struct {
+		roles    []string
+		expected []string
+	}{
+		{roles: []string{config.NodeRole}, expected: []string{}},
+		{roles: []string{config.ControlPlaneRole, config.NodeRole}, expected: []string{"kube-apiserver"}},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Roles = tt.roles
+
+		s := NewKubeletServer(cfg)
+		if !reflect.DeepEqual(s.Dependencies(), tt.expected) {
+			t.Errorf("expected the kubelet to depend on %v with roles %v, got %v", tt.expected, tt.roles, s.Dependencies())
+		}
+	}
+}
+
+func TestKubeletRoleDataDir(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.RoleDataDirs = true
+
+	s := NewKubeletServer(cfg)
+
+	nodeDir := filepath.Join(cfg.DataDir, config.NodeRole)
+	if _, err := os.Stat(filepath.Join(nodeDir, "resources", "kubelet", "config", "config.yaml")); err != nil {
+		t.Errorf("expected the kubelet config in the node's data directory: %v", err)
+	}
+	if expected := filepath.Join(nodeDir, "kubelet-plugins", "volume", "exec"); s.kubeconfig.VolumePluginDir != expected {
+		t.Errorf("expected volume plugin directory %q, got %q", expected, s.kubeconfig.VolumePluginDir)
+	}
+}
+
+func TestKubeletServerCertificateRotation(t *testing.T) {
+	for _, rotate := range []bool{false, true} {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Node.RotateServerCertificates = rotate
+		cfg.Node.ExtraArgs = map[string][]string{"rotate-server-certificates": {strconv.FormatBool(!rotate)}}
+
+		s := NewKubeletServer(cfg)
+
+		if s.kubeconfig.ServerTLSBootstrap != rotate {
+			t.Errorf("rotate=%v: expected serverTLSBootstrap %v, got %v", rotate, rotate, s.kubeconfig.ServerTLSBootstrap)
+		}
+		if s.kubeconfig.FeatureGates["RotateKubeletServerCertificate"] != rotate {
+			t.Errorf("rotate=%v: expected the RotateKubeletServerCertificate feature gate to be %v", rotate, rotate)
+		}
+	}
+}
+
+func TestKubeletSwapBehavior(t *testing.T) {
+	var tests = []struct {
+		swapBehavior string
+		nodeSwap     bool
+		expected     string
+	}{
+		{swapBehavior: config.SwapBehaviorOff, nodeSwap: false, expected: ""},
+		{swapBehavior: config.SwapBehaviorLimitedSwap, nodeSwap: true, expected: "LimitedSwap"},
+		{swapBehavior: config.SwapBehaviorUnlimitedSwap, nodeSwap: true, expected: "UnlimitedSwap"},
+	}
+	for _, tt := range tests {
+		cfg := config.NewMicroshiftConfig()
+		cfg.DataDir = t.TempDir()
+		cfg.Node.SwapBehavior = tt.swapBehavior
+
+		s := NewKubeletServer(cfg)
+
+		if s.kubeconfig.FailSwapOn {
+			t.Errorf("%s: expected failSwapOn to be disabled", tt.swapBehavior)
+		}
+		if s.kubeconfig.FeatureGates["NodeSwap"] != tt.nodeSwap {
+			t.Errorf("%s: expected the NodeSwap feature gate to be %v", tt.swapBehavior, tt.nodeSwap)
+		}
+		if s.kubeconfig.MemorySwap.SwapBehavior != tt.expected {
+			t.Errorf("%s: expected swapBehavior %q, got %q", tt.swapBehavior, tt.expected, s.kubeconfig.MemorySwap.SwapBehavior)
+		}
+	}
+}
+
+func TestKubeletContainerLogRotation(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.ContainerLogMaxSize = "10Mi"
+	cfg.Node.ContainerLogMaxFiles = 3
+	cfg.Node.ExtraArgs = map[string][]string{
+		"container-log-max-size":  {"1Gi"},
+		"container-log-max-files": {"20"},
+	}
+
+	s := NewKubeletServer(cfg)
+
+	if s.kubeconfig.ContainerLogMaxSize != "10Mi" {
+		t.Errorf("expected containerLogMaxSize 10Mi, got %q", s.kubeconfig.ContainerLogMaxSize)
+	}
+	if s.kubeconfig.ContainerLogMaxFiles != 3 {
+		t.Errorf("expected containerLogMaxFiles 3, got %d", s.kubeconfig.ContainerLogMaxFiles)
+	}
+}
+
+func TestKubeletResourceManagerPolicies(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.CPUManagerPolicy = config.CPUManagerPolicyStatic
+	cfg.Node.TopologyManagerPolicy = config.TopologyManagerPolicySingleNUMANode
+	cfg.Node.ExtraArgs = map[string][]string{
+		"reserved-cpus":           {"0"},
+		"cpu-manager-policy":      {"none"},
+		"topology-manager-policy": {"best-effort"},
+	}
+
+	s := NewKubeletServer(cfg)
+
+	if s.kubeconfig.CPUManagerPolicy != "static" {
+		t.Errorf("expected cpuManagerPolicy static, got %q", s.kubeconfig.CPUManagerPolicy)
+	}
+	if s.kubeconfig.TopologyManagerPolicy != "single-numa-node" {
+		t.Errorf("expected topologyManagerPolicy single-numa-node, got %q", s.kubeconfig.TopologyManagerPolicy)
+	}
+}
+
+func TestCPUManagerStatePolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, cpuManagerStateFile)
+	if policy := cpuManagerStatePolicy(path); policy != "" {
+		t.Errorf("expected no policy without a state, got %q", policy)
+	}
+	state := `{"policyName":"none","defaultCpuSet":"","checksum":1353318690}`
+	if err := os.WriteFile(path, []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if policy := cpuManagerStatePolicy(path); policy != "none" {
+		t.Errorf("expected policy none from the state, got %q", policy)
+	}
+}
+
+func TestWaitForNodeReady(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge-node-1"},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionFalse},
+		}},
+	}
+	client := fake.NewSimpleClientset(node)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- waitForNodeReady(ctx, client, "edge-node-1", 10*time.Millisecond) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected to wait for the node to be Ready, returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	node = node.DeepCopy()
+	node.Status.Conditions[0].Status = corev1.ConditionTrue
+	if _, err := client.CoreV1().Nodes().UpdateStatus(ctx, node, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("expected the node to become Ready, got %v", err)
+	}
+}
+
+func TestWaitForNodeReadyNotRegistered(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := waitForNodeReady(ctx, client, "edge-node-1", 10*time.Millisecond); err == nil {
+		t.Error("expected an error when the node never registers")
+	}
+}
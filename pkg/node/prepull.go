@@ -0,0 +1,139 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/openshift/microshift/pkg/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+	"k8s.io/klog/v2"
+	kubeletutil "k8s.io/kubernetes/pkg/kubelet/util"
+)
+
+const (
+	crioEndpoint          = "unix:///var/run/crio/crio.sock"
+	crioConnectionTimeout = 2 * time.Minute
+)
+
+// imagePuller is the subset of the CRI image service used to pre-pull images
+type imagePuller interface {
+	// PullImage pulls an image, returning its reference. It gives up once ctx is done.
+	PullImage(ctx context.Context, image string) (string, error)
+	Close() error
+}
+
+// criImagePuller pulls images with the CRI image service of the container runtime. It
+// calls the service directly rather than through the kubelet's remote image service,
+// so that pulls are canceled with the context of the caller.
+type criImagePuller struct {
+	conn   *grpc.ClientConn
+	client runtimeapi.ImageServiceClient
+}
+
+func newCRIImagePuller(endpoint string, connectionTimeout time.Duration) (imagePuller, error) {
+	addr, dialer, err := kubeletutil.GetAddressAndDialer(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(dialer),
+		grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	return &criImagePuller{conn: conn, client: runtimeapi.NewImageServiceClient(conn)}, nil
+}
+
+func (p *criImagePuller) PullImage(ctx context.Context, image string) (string, error) {
+	resp, err := p.client.PullImage(ctx, &runtimeapi.PullImageRequest{Image: &runtimeapi.ImageSpec{Image: image}})
+	if err != nil {
+		return "", err
+	}
+	if resp.ImageRef == "" {
+		return "", fmt.Errorf("imageRef of image %q is not set", image)
+	}
+	return resp.ImageRef, nil
+}
+
+func (p *criImagePuller) Close() error {
+	return p.conn.Close()
+}
+
+type ImagePrePuller struct {
+	images []string
+	wait   bool
+
+	newPuller func() (imagePuller, error)
+}
+
+func NewImagePrePuller(cfg *config.MicroshiftConfig) *ImagePrePuller {
+	return &ImagePrePuller{
+		images: cfg.Node.PrePullImages,
+		wait:   cfg.Node.WaitForPrePull,
+		newPuller: func() (imagePuller, error) {
+			return newCRIImagePuller(crioEndpoint, crioConnectionTimeout)
+		},
+	}
+}
+
+func (s *ImagePrePuller) Name() string           { return "image-prepuller" }
+func (s *ImagePrePuller) Dependencies() []string { return []string{componentKubelet} }
+
+func (s *ImagePrePuller) Run(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+	defer close(stopped)
+	if !s.wait {
+		close(ready)
+	}
+
+	if err := s.pullImages(ctx); err != nil {
+		return err
+	}
+
+	// failures to pull are not fatal, the kubelet pulls the images again when needed
+	if s.wait {
+		close(ready)
+	}
+	return ctx.Err()
+}
+
+// pullImages pulls each image in turn, logging progress and failures. It only returns
+// an error if the context is canceled.
+func (s *ImagePrePuller) pullImages(ctx context.Context) error {
+	puller, err := s.newPuller()
+	if err != nil {
+		klog.Errorf("%s failed to connect to the container runtime: %v", s.Name(), err)
+		return nil
+	}
+	defer puller.Close()
+
+	failed := 0
+	for i, image := range s.images {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		klog.Infof("Pulling image %s (%d/%d)", image, i+1, len(s.images))
+		ref, err := puller.PullImage(ctx, image)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			klog.Warningf("Failed to pull image %s: %v", image, err)
+			failed++
+			continue
+		}
+		klog.Infof("Pulled image %s as %s", image, ref)
+	}
+
+	if failed > 0 {
+		klog.Warningf("%s finished, %d of %d images failed to pull", s.Name(), failed, len(s.images))
+	} else {
+		klog.Infof("%s finished, all %d images pulled", s.Name(), len(s.images))
+	}
+	return nil
+}
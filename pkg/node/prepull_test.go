@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeImagePuller struct {
+	pulled []string
+	fail   map[string]bool
+	// block makes pulls wait for the context
+	block bool
+}
+
+func (f *fakeImagePuller) PullImage(ctx context.Context, image string) (string, error) {
+	f.pulled = append(f.pulled, image)
+	if f.block {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	if f.fail[image] {
+		return "", errors.New("pull failed")
+	}
+	return image, nil
+}
+
+func (f *fakeImagePuller) Close() error { return nil }
+
+func TestImagePrePuller(t *testing.T) {
+	images := []string{"registry.k8s.io/busybox", "quay.io/example/app:v1", "quay.io/example/db:v2"}
+	puller := &fakeImagePuller{fail: map[string]bool{"quay.io/example/app:v1": true}}
+	s := &ImagePrePuller{
+		images:    images,
+		wait:      true,
+		newPuller: func() (imagePuller, error) { return puller, nil },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	go s.Run(ctx, ready, stopped)
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for image-prepuller to become ready")
+	}
+	<-stopped
+
+	if !reflect.DeepEqual(puller.pulled, images) {
+		t.Errorf("expected images %v to be pulled, got %v", images, puller.pulled)
+	}
+}
+
+func TestImagePrePullerCanceled(t *testing.T) {
+	images := []string{"registry.k8s.io/busybox", "quay.io/example/app:v1"}
+	puller := &fakeImagePuller{block: true}
+	s := &ImagePrePuller{
+		images:    images,
+		wait:      true,
+		newPuller: func() (imagePuller, error) { return puller, nil },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, ready, stopped) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected image-prepuller to stop with the context, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the pull in progress to be canceled")
+	}
+	if !reflect.DeepEqual(puller.pulled, images[:1]) {
+		t.Errorf("expected only %v to be pulled, got %v", images[:1], puller.pulled)
+	}
+}
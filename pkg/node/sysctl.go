@@ -0,0 +1,73 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const procSysDir = "/proc/sys"
+
+// sysctlWriter reads and sets kernel parameters by their dotted names
+type sysctlWriter interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+}
+
+// procSysctlWriter sets kernel parameters through the files under /proc/sys
+type procSysctlWriter struct {
+	dir string
+}
+
+func (w procSysctlWriter) path(key string) string {
+	return filepath.Join(w.dir, strings.ReplaceAll(key, ".", "/"))
+}
+
+func (w procSysctlWriter) Get(key string) (string, error) {
+	value, err := os.ReadFile(w.path(key))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func (w procSysctlWriter) Set(key, value string) error {
+	return os.WriteFile(w.path(key), []byte(value), 0644)
+}
+
+// applySysctls sets the kernel parameters that don't have the expected values yet.
+// Parameters that can't be set are returned as errors, their values may still be
+// correct if the host's configuration set them.
+func applySysctls(w sysctlWriter, sysctls map[string]string) error {
+	keys := make([]string, 0, len(sysctls))
+	for key := range sysctls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		value := sysctls[key]
+		// values of multiple fields are read back separated by tabs, e.g. those of
+		// net.ipv4.ip_local_port_range
+		if current, err := w.Get(key); err == nil && normalizeSysctlValue(current) == normalizeSysctlValue(value) {
+			continue
+		}
+		if err := w.Set(key, value); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set %s=%s: %v", key, value, err))
+			continue
+		}
+		klog.Infof("%s set sysctl %s=%s", componentKubelet, key, value)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// normalizeSysctlValue separates the fields of a value by single spaces.
+func normalizeSysctlValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
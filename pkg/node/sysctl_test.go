@@ -0,0 +1,125 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/microshift/pkg/config"
+)
+
+// fakeSysctlWriter keeps the kernel parameters in memory and fails to set the
+// parameters in readOnly
+type fakeSysctlWriter struct {
+	values   map[string]string
+	readOnly map[string]bool
+	set      []string
+}
+
+func (w *fakeSysctlWriter) Get(key string) (string, error) {
+	value, ok := w.values[key]
+	if !ok {
+		return "", fmt.Errorf("unknown sysctl %s", key)
+	}
+	return value, nil
+}
+
+func (w *fakeSysctlWriter) Set(key, value string) error {
+	if w.readOnly[key] {
+		return fmt.Errorf("read-only sysctl %s", key)
+	}
+	w.values[key] = value
+	w.set = append(w.set, key)
+	return nil
+}
+
+func TestApplySysctls(t *testing.T) {
+	w := &fakeSysctlWriter{values: map[string]string{
+		"net.ipv4.ip_forward": "0",
+		"vm.max_map_count":    "262144",
+		// /proc separates the fields by tabs
+		"net.ipv4.ip_local_port_range": "32768\t60999",
+	}}
+	err := applySysctls(w, map[string]string{
+		"net.ipv4.ip_forward":                "1",
+		"net.bridge.bridge-nf-call-iptables": "1",
+		"vm.max_map_count":                   "262144",
+		"net.ipv4.ip_local_port_range":       "32768 60999",
+	})
+	if err != nil {
+		t.Fatalf("applySysctls() error = %v", err)
+	}
+
+	// the parameter that is already set isn't written again
+	if want := []string{"net.bridge.bridge-nf-call-iptables", "net.ipv4.ip_forward"}; !reflect.DeepEqual(w.set, want) {
+		t.Errorf("expected %v to be set, got %v", want, w.set)
+	}
+	if w.values["net.ipv4.ip_forward"] != "1" {
+		t.Errorf("expected net.ipv4.ip_forward to be set to 1, got %q", w.values["net.ipv4.ip_forward"])
+	}
+}
+
+func TestApplySysctlsFailure(t *testing.T) {
+	w := &fakeSysctlWriter{
+		values:   map[string]string{},
+		readOnly: map[string]bool{"net.ipv4.ip_forward": true},
+	}
+	err := applySysctls(w, map[string]string{
+		"net.ipv4.ip_forward": "1",
+		"vm.max_map_count":    "262144",
+	})
+	if err == nil {
+		t.Fatalf("expected an error for a sysctl that can't be set")
+	}
+	if w.values["vm.max_map_count"] != "262144" {
+		t.Errorf("expected the other sysctls to be set despite the failure")
+	}
+}
+
+func TestProcSysctlWriter(t *testing.T) {
+	w := procSysctlWriter{dir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(w.dir, "net", "ipv4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(w.dir, "net", "ipv4", "ip_forward"), []byte("0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applySysctls(w, map[string]string{"net.ipv4.ip_forward": "1"}); err != nil {
+		t.Fatalf("applySysctls() error = %v", err)
+	}
+	if value, err := w.Get("net.ipv4.ip_forward"); err != nil || value != "1" {
+		t.Errorf("expected net.ipv4.ip_forward to be 1, got %q, %v", value, err)
+	}
+}
+
+func TestKubeletSysctls(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.Sysctls = map[string]string{"vm.max_map_count": "262144"}
+
+	s := NewKubeletServer(cfg)
+	for _, key := range []string{"net.ipv4.ip_forward", "vm.max_map_count"} {
+		if _, ok := s.sysctls[key]; !ok {
+			t.Errorf("expected the kubelet to set %s, got %v", key, s.sysctls)
+		}
+	}
+}
+
+func TestKubeletNoSysctls(t *testing.T) {
+	cfg := config.NewMicroshiftConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Node.ManageSysctls = false
+	cfg.Node.Sysctls = map[string]string{"vm.max_map_count": "262144"}
+
+	s := NewKubeletServer(cfg)
+	w := &fakeSysctlWriter{values: map[string]string{}}
+	if err := applySysctls(w, s.sysctls); err != nil {
+		t.Fatalf("applySysctls() error = %v", err)
+	}
+	if len(w.set) != 0 {
+		t.Errorf("expected no sysctls to be set when opted out, got %v", w.set)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"syscall"
 
 	"github.com/openshift/microshift/pkg/util/sigchannel"
@@ -16,6 +17,15 @@ type ServiceManager struct {
 
 	services   []Service
 	serviceMap map[string]Service
+
+	errLock sync.Mutex
+	err     error
+
+	stateLock sync.Mutex
+	states    map[string]string
+
+	// optional services don't stop MicroShift when they fail
+	optional map[string]bool
 }
 
 func NewServiceManager() *ServiceManager {
@@ -25,6 +35,8 @@ func NewServiceManager() *ServiceManager {
 
 		services:   []Service{},
 		serviceMap: make(map[string]Service),
+		states:     make(map[string]string),
+		optional:   make(map[string]bool),
 	}
 }
 func (s *ServiceManager) Name() string           { return s.name }
@@ -48,6 +60,18 @@ func (m *ServiceManager) AddService(s Service) error {
 
 	m.services = append(m.services, s)
 	m.serviceMap[s.Name()] = s
+	m.setState(s.Name(), StatePending)
+	return nil
+}
+
+// SetOptional classifies an added service as optional or critical, services are critical
+// unless classified otherwise. MicroShift stops when a critical service fails, while a
+// failed optional service is marked degraded and its dependents aren't started.
+func (m *ServiceManager) SetOptional(name string, optional bool) error {
+	if _, exists := m.serviceMap[name]; !exists {
+		return fmt.Errorf("unknown service '%s'", name)
+	}
+	m.optional[name] = optional
 	return nil
 }
 
@@ -79,6 +103,18 @@ func (m *ServiceManager) Run(ctx context.Context, ready chan<- struct{}, stopped
 			return ctx.Err()
 		}
 
+		// Dependents of degraded services can't work either
+		if dependency := m.degradedDependency(service); dependency != "" {
+			klog.Errorf("not starting %s as its dependency %s is degraded", service.Name(), dependency)
+			m.setState(service.Name(), StateDegraded)
+			settled, serviceStopped := make(chan struct{}), make(chan struct{})
+			close(settled)
+			close(serviceStopped)
+			readyMap[service.Name()] = settled
+			stoppedMap[service.Name()] = serviceStopped
+			continue
+		}
+
 		// Start the service and store its ready and stopped channels
 		serviceReady, serviceStopped := m.asyncRun(ctx, service)
 		readyMap[service.Name()] = serviceReady
@@ -96,15 +132,92 @@ func (m *ServiceManager) Run(ctx context.Context, ready chan<- struct{}, stopped
 	return ctx.Err()
 }
 
+// Err returns the error of the first service that failed, if any.
+func (m *ServiceManager) Err() error {
+	m.errLock.Lock()
+	defer m.errLock.Unlock()
+	return m.err
+}
+
+func (m *ServiceManager) setErr(err error) {
+	m.errLock.Lock()
+	defer m.errLock.Unlock()
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+func (m *ServiceManager) degradedDependency(service Service) string {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	for _, dependency := range service.Dependencies() {
+		if m.states[dependency] == StateDegraded {
+			return dependency
+		}
+	}
+	return ""
+}
+
+// Status returns the state of each service, in the order the services were added.
+func (m *ServiceManager) Status() []ServiceStatus {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	statuses := make([]ServiceStatus, 0, len(m.services))
+	for _, service := range m.services {
+		statuses = append(statuses, ServiceStatus{
+			Name:         service.Name(),
+			Dependencies: service.Dependencies(),
+			State:        m.states[service.Name()],
+			Optional:     m.optional[service.Name()],
+		})
+	}
+	return statuses
+}
+
+// setState records the state of a service. States only move forward, so that a late
+// readiness signal doesn't mask that the service stopped.
+func (m *ServiceManager) setState(name, state string) {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	if current, ok := m.states[name]; !ok || stateOrder[state] > stateOrder[current] {
+		m.states[name] = state
+	}
+}
+
+// asyncRun starts the service and returns a channel that is closed once it is ready or,
+// if it is optional, failed, and a channel that is closed once it stopped.
 func (m *ServiceManager) asyncRun(ctx context.Context, service Service) (<-chan struct{}, <-chan struct{}) {
 	ready, stopped := make(chan struct{}), make(chan struct{})
+	settled := make(chan struct{})
+	var settle sync.Once
+	m.setState(service.Name(), StateStarting)
+	go func() {
+		select {
+		case <-ready:
+			m.setState(service.Name(), StateReady)
+			settle.Do(func() { close(settled) })
+		case <-stopped:
+		}
+	}()
+
+	fail := func(err error) {
+		if m.optional[service.Name()] {
+			klog.Errorf("optional %v, continuing degraded", err)
+			m.setState(service.Name(), StateDegraded)
+			settle.Do(func() { close(settled) })
+			return
+		}
+		klog.Errorf("%v, stopping MicroShift", err)
+		m.setErr(err)
+		m.setState(service.Name(), StateFailed)
+		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	}
+
 	klog.WithMicroshiftLoggerComponent(service.Name(), func() {
 		go func() {
 			defer func() {
 				if r := recover(); r != nil {
-					klog.Errorf("%s panicked: %s", service.Name(), r)
-					klog.Error("Stopping MicroShift")
-					syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+					fail(fmt.Errorf("service %s panicked: %s", service.Name(), r))
 					if !sigchannel.IsClosed(stopped) {
 						close(stopped)
 					}
@@ -113,14 +226,14 @@ func (m *ServiceManager) asyncRun(ctx context.Context, service Service) (<-chan
 
 			klog.Infof("Starting %s", service.Name())
 			if err := service.Run(ctx, ready, stopped); err != nil && !errors.Is(err, context.Canceled) {
-				klog.Errorf("service %s exited with error: %s, stopping MicroShift", service.Name(), err)
-				syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+				fail(fmt.Errorf("service %s exited with error: %w", service.Name(), err))
 			} else {
 				klog.Infof("%s completed", service.Name())
+				m.setState(service.Name(), StateStopped)
 			}
 		}()
 	})
-	return ready, stopped
+	return settled, stopped
 }
 
 func values(m map[string]<-chan struct{}) []<-chan struct{} {
@@ -229,3 +229,78 @@ func TestRunToServicePanic(t *testing.T) {
 		t.Errorf("stopped channel not closed after completing service manager")
 	}
 }
+
+func TestRunOptionalServiceFailure(t *testing.T) {
+	var waitForContext = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		close(ready)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	var failToStart = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		return errors.New("I'm failing to start")
+	}
+	var mustNotRun = func(ctx context.Context, ready chan<- struct{}, stopped chan<- struct{}) error {
+		defer close(stopped)
+		t.Errorf("dependent of a degraded service was started")
+		return nil
+	}
+
+	m := NewServiceManager()
+	m.AddService(NewGenericService("foo", nil, waitForContext))
+	m.AddService(NewGenericService("bar-optional", []string{"foo"}, failToStart))
+	m.AddService(NewGenericService("baz", []string{"bar-optional"}, mustNotRun))
+	m.AddService(NewGenericService("qux", []string{"foo"}, waitForContext))
+	if err := m.SetOptional("bar-optional", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetOptional("unknown", true); err == nil {
+		t.Errorf("expected an error classifying an unknown service")
+	}
+
+	// a failing critical service would stop the test with a SIGTERM
+	sigTerm := make(chan os.Signal, 1)
+	signal.Notify(sigTerm, syscall.SIGTERM)
+	defer signal.Stop(sigTerm)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready, stopped := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx, ready, stopped) }()
+
+	select {
+	case <-ready:
+	case <-sigTerm:
+		t.Fatal("optional service failure stopped MicroShift")
+	case <-time.After(5 * time.Second):
+		t.Fatal("service manager did not become ready with a failed optional service")
+	}
+
+	expected := map[string]string{
+		"foo":          StateReady,
+		"bar-optional": StateDegraded,
+		"baz":          StateDegraded,
+		"qux":          StateReady,
+	}
+	for _, status := range m.Status() {
+		if status.State != expected[status.Name] {
+			t.Errorf("expected %s to be %s, got %s", status.Name, expected[status.Name], status.State)
+		}
+		if status.Optional != (status.Name == "bar-optional") {
+			t.Errorf("unexpected classification of %s as optional: %v", status.Name, status.Optional)
+		}
+	}
+	if err := m.Err(); err != nil {
+		t.Errorf("expected no error from an optional service failure, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the service manager to stop with the context, got %v", err)
+	}
+	if !sigchannel.IsClosed(stopped) {
+		t.Errorf("stopped channel not closed after completing service manager")
+	}
+}
@@ -13,3 +13,32 @@ type Service interface {
 	Dependencies() []string
 	Runner
 }
+
+// States of a service as reported by ServiceManager.Status
+const (
+	StatePending  = "pending"
+	StateStarting = "starting"
+	StateReady    = "ready"
+	StateStopped  = "stopped"
+	StateFailed   = "failed"
+	// StateDegraded is the state of an optional service that failed, and of the
+	// services depending on it, which aren't started
+	StateDegraded = "degraded"
+)
+
+var stateOrder = map[string]int{
+	StatePending:  0,
+	StateStarting: 1,
+	StateReady:    2,
+	StateStopped:  3,
+	StateFailed:   3,
+	StateDegraded: 3,
+}
+
+// ServiceStatus is the state of a service and the services it depends on.
+type ServiceStatus struct {
+	Name         string   `json:"name"`
+	Dependencies []string `json:"dependencies"`
+	State        string   `json:"state"`
+	Optional     bool     `json:"optional,omitempty"`
+}
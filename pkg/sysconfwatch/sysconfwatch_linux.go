@@ -32,8 +32,10 @@ const sysConfigCheckInterval = time.Second * 5
 const sysConfigAllowedTimeDrift = time.Second * 10
 
 type SysConfWatchController struct {
-	NodeIP  string
-	timerFd int
+	NodeIP string
+	// NodeIPInterface is the interface the node IP was selected from, if any
+	NodeIPInterface string
+	timerFd         int
 }
 
 func NewSysConfWatchController(cfg *config.MicroshiftConfig) *SysConfWatchController {
@@ -55,8 +57,9 @@ func NewSysConfWatchController(cfg *config.MicroshiftConfig) *SysConfWatchContro
 	}
 
 	return &SysConfWatchController{
-		NodeIP:  cfg.NodeIP,
-		timerFd: fd,
+		NodeIP:          cfg.NodeIP,
+		NodeIPInterface: cfg.Node.NodeIPInterface,
+		timerFd:         fd,
 	}
 }
 
@@ -65,6 +68,14 @@ func (s *SysConfWatchController) Dependencies() []string {
 	return []string{}
 }
 
+// currentIP returns the address that would be selected as the node IP now
+func (c *SysConfWatchController) currentIP() (string, error) {
+	if c.NodeIPInterface != "" {
+		return util.GetInterfaceIP(c.NodeIPInterface, c.NodeIP)
+	}
+	return util.GetHostIP()
+}
+
 func getSysMonTimes() (int64, int64) {
 	var stm unix.Timespec
 	var mtm unix.Timespec
@@ -98,7 +109,7 @@ func (c *SysConfWatchController) Run(ctx context.Context, ready chan<- struct{},
 		select {
 		case <-ticker.C:
 			// Check the IP change
-			currentIP, _ := util.GetHostIP()
+			currentIP, _ := c.currentIP()
 			if c.NodeIP != currentIP {
 				klog.Warningf("IP address has changed from %q to %q, restarting MicroShift", c.NodeIP, currentIP)
 				os.Exit(0)
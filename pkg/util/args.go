@@ -0,0 +1,65 @@
+package util
+
+import (
+	"sort"
+
+	"k8s.io/klog/v2"
+)
+
+// FilterExtraArgs drops the user provided extra arguments of a component that collide
+// with an argument managed by MicroShift, logging a warning for each of them. It returns
+// the remaining extra arguments and the names of the dropped ones.
+func FilterExtraArgs(component string, managed []string, extra map[string][]string) (map[string][]string, []string) {
+	managedSet := make(map[string]struct{}, len(managed))
+	for _, name := range managed {
+		managedSet[name] = struct{}{}
+	}
+
+	filtered := make(map[string][]string, len(extra))
+	collisions := []string{}
+	for _, name := range sortedArgNames(extra) {
+		if _, ok := managedSet[name]; ok {
+			klog.Warningf("ignoring extra argument --%s for %s: the argument is managed by MicroShift", name, component)
+			collisions = append(collisions, name)
+			continue
+		}
+		filtered[name] = extra[name]
+	}
+	return filtered, collisions
+}
+
+// MergeArgs merges the user provided extra arguments of a component into the arguments
+// managed by MicroShift. Managed arguments take precedence over extra ones.
+func MergeArgs(component string, managed map[string][]string, extra map[string][]string) map[string][]string {
+	merged := make(map[string][]string, len(managed)+len(extra))
+	for name, values := range managed {
+		merged[name] = values
+	}
+
+	filtered, _ := FilterExtraArgs(component, sortedArgNames(managed), extra)
+	for name, values := range filtered {
+		merged[name] = values
+	}
+	return merged
+}
+
+// ArgsToFlags renders arguments as command line flags, sorted by name. Arguments with
+// multiple values are repeated once per value.
+func ArgsToFlags(args map[string][]string) []string {
+	flags := []string{}
+	for _, name := range sortedArgNames(args) {
+		for _, value := range args[name] {
+			flags = append(flags, "--"+name+"="+value)
+		}
+	}
+	return flags
+}
+
+func sortedArgNames(args map[string][]string) []string {
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
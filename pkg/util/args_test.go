@@ -0,0 +1,51 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeArgs(t *testing.T) {
+	managed := map[string][]string{
+		"kubeconfig":   {"/var/lib/microshift/resources/kubeconfig"},
+		"leader-elect": {"false"},
+	}
+	extra := map[string][]string{
+		"leader-elect":  {"true"},
+		"v":             {"4"},
+		"feature-gates": {"A=true", "B=false"},
+	}
+
+	merged := MergeArgs("kube-controller-manager", managed, extra)
+
+	assert.Equal(t, map[string][]string{
+		"kubeconfig":    {"/var/lib/microshift/resources/kubeconfig"},
+		"leader-elect":  {"false"},
+		"v":             {"4"},
+		"feature-gates": {"A=true", "B=false"},
+	}, merged, "managed arguments must take precedence over extra arguments")
+	assert.Equal(t, []string{"false"}, managed["leader-elect"], "managed arguments must not be modified")
+}
+
+func TestFilterExtraArgs_collisions(t *testing.T) {
+	extra := map[string][]string{
+		"node-ip":    {"10.0.0.1"},
+		"max-pods":   {"100"},
+		"kubeconfig": {"/tmp/kubeconfig"},
+	}
+
+	filtered, collisions := FilterExtraArgs("kubelet", []string{"kubeconfig", "node-ip"}, extra)
+
+	assert.Equal(t, map[string][]string{"max-pods": {"100"}}, filtered)
+	assert.Equal(t, []string{"kubeconfig", "node-ip"}, collisions)
+}
+
+func TestArgsToFlags(t *testing.T) {
+	flags := ArgsToFlags(map[string][]string{
+		"v":             {"2"},
+		"feature-gates": {"A=true", "B=false"},
+	})
+
+	assert.Equal(t, []string{"--feature-gates=A=true", "--feature-gates=B=false", "--v=2"}, flags)
+}
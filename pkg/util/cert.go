@@ -18,10 +18,12 @@ package util
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -84,3 +86,19 @@ func PublicKeyToPem(key *rsa.PublicKey) ([]byte, error) {
 	)
 	return keyinPem, nil
 }
+
+// LoadCertKeyPair reads a PEM encoded certificate and key, making sure they belong together.
+func LoadCertKeyPair(certFile, keyFile string) ([]byte, []byte, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate %s and key %s: %v", certFile, keyFile, err)
+	}
+	return certPEM, keyPEM, nil
+}
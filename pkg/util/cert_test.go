@@ -0,0 +1,33 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/cert"
+)
+
+func TestLoadCertKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey("router", nil, nil)
+	assert.NoError(t, err)
+	_, otherKeyPEM, err := cert.GenerateSelfSignedCertKey("other", nil, nil)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	otherKeyFile := filepath.Join(dir, "other.key")
+	assert.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	assert.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	assert.NoError(t, os.WriteFile(otherKeyFile, otherKeyPEM, 0600))
+
+	loadedCert, loadedKey, err := LoadCertKeyPair(certFile, keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, certPEM, loadedCert)
+	assert.Equal(t, keyPEM, loadedKey)
+
+	_, _, err = LoadCertKeyPair(certFile, otherKeyFile)
+	assert.Error(t, err, "expected mismatched key to be rejected")
+}
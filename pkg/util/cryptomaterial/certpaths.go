@@ -36,6 +36,10 @@ const (
 
 	IngressSignerCAValidityDays    = 365 * 2
 	IngressServingCertValidityDays = 365
+
+	// AggregatorClientUserName is the user the apiserver authenticates as to aggregated
+	// apiservers, which accept its identity headers only from this user
+	AggregatorClientUserName = "system:openshift-aggregator"
 )
 
 func CertsDirectory(dataPath string) string { return filepath.Join(dataPath, "certs") }
@@ -179,3 +183,13 @@ func KubeletClientCAPath(certsDir string) string {
 func ServiceAccountTokenCABundlePath(certsDir string) string {
 	return filepath.Join(certsDir, "ca-bundle", "service-account-token-ca.crt")
 }
+
+// AdditionalTrustBundleDir returns the directory holding the user provided CA bundle
+// that components trust in addition to the system trust store
+func AdditionalTrustBundleDir(certsDir string) string {
+	return filepath.Join(certsDir, "additional-trust-bundle")
+}
+
+func AdditionalTrustBundlePath(certsDir string) string {
+	return CABundlePath(AdditionalTrustBundleDir(certsDir))
+}
@@ -399,6 +399,16 @@ func (s *CertificateSigner) GetCertKey(subjectName string) ([]byte, []byte, erro
 	return certConfig.tlsConfig.GetPEMBytes()
 }
 
+// GetCertDir returns the directory the named certificate and its key are stored in
+func (s *CertificateSigner) GetCertDir(subjectName string) (string, error) {
+	certConfig, exists := s.signedCertificates[subjectName]
+	if !exists {
+		return "", fmt.Errorf("no certificate with name %q was found", subjectName)
+	}
+
+	return certConfig.certDir, nil
+}
+
 func (s *CertificateSigner) GetSubCANames() []string {
 	return certificateSignersMapKeysOrdered(s.subCAs)
 }
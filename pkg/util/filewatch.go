@@ -0,0 +1,72 @@
+package util
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// WatchFiles calls onChange whenever any of the files changes. Changes are debounced:
+// onChange is only called once no further change happened for the debounce period, so
+// that writing a certificate and its key results in a single call. The parent
+// directories are watched so that files replaced by a rename or a symlink swap, as done
+// for Kubernetes secret volumes, are picked up. WatchFiles blocks until ctx is done.
+func WatchFiles(ctx context.Context, paths []string, debounce time.Duration, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	watched := map[string]struct{}{}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		watched[path] = struct{}{}
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isRelevantEvent(event, watched) {
+				continue
+			}
+			klog.V(2).Infof("%s changed (%s)", event.Name, event.Op)
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			klog.Warningf("watching %v: %v", paths, err)
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
+
+// isRelevantEvent returns whether the event is about one of the watched files, or about
+// the hidden ..data style symlinks Kubernetes uses to atomically update volumes.
+func isRelevantEvent(event fsnotify.Event, watched map[string]struct{}) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if _, ok := watched[filepath.Clean(event.Name)]; ok {
+		return true
+	}
+	return strings.HasPrefix(filepath.Base(event.Name), "..")
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	for _, f := range []string{certFile, keyFile} {
+		assert.NoError(t, os.WriteFile(f, []byte("initial"), 0600))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int32
+	changed := make(chan struct{}, 10)
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		err := WatchFiles(ctx, []string{certFile, keyFile}, 200*time.Millisecond, func() {
+			atomic.AddInt32(&calls, 1)
+			changed <- struct{}{}
+		})
+		assert.NoError(t, err)
+	}()
+	// give the watcher time to start
+	time.Sleep(100 * time.Millisecond)
+
+	// updating the certificate and key is a single logical update
+	writeUpdate := func(contents string) {
+		assert.NoError(t, os.WriteFile(certFile, []byte(contents), 0600))
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, os.WriteFile(keyFile, []byte(contents), 0600))
+	}
+
+	// unrelated files in the directory are ignored
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0600))
+
+	writeUpdate("first")
+	waitForChange(t, changed)
+	writeUpdate("second")
+	waitForChange(t, changed)
+
+	// wait for any spurious call after the debounce period
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "expected one call per update")
+
+	cancel()
+	<-watchDone
+}
+
+func waitForChange(t *testing.T, changed <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change notification")
+	}
+}
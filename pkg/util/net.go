@@ -21,6 +21,7 @@ import (
 	tcpnet "net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 )
@@ -53,24 +55,87 @@ func GetHostIP() (string, error) {
 	return gatewayIP, nil
 }
 
-func getOVNGatewayIP() (string, error) {
-	iface, err := tcpnet.InterfaceByName(OVNGatewayInterface)
+// interfaceAddrs returns the addresses of the named network interface. Tests replace
+// it to not depend on the host's interfaces.
+var interfaceAddrs = func(name string) ([]tcpnet.Addr, error) {
+	iface, err := tcpnet.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return iface.Addrs()
+}
+
+// isOVSPort returns whether the named network interface was added to an Open vSwitch
+// bridge, whose ports have the ovs-system datapath as their master. Tests replace it
+// to not depend on the host's interfaces.
+var isOVSPort = func(name string) bool {
+	master, err := os.Readlink(filepath.Join("/sys/class/net", name, "master"))
+	return err == nil && filepath.Base(master) == "ovs-system"
+}
+
+// GetInterfaceIP returns the address of the named interface to use as the node IP.
+// The preferred address is returned if the interface has it, so that it can choose
+// between several addresses, otherwise the address is selected by selectHostIP.
+// configure-ovs.sh moves the addresses of the default route's interface to the OVN
+// gateway bridge, so they are looked up on the bridge once the interface became one
+// of its ports.
+func GetInterfaceIP(name, preferred string) (string, error) {
+	addrs, err := interfaceAddrs(name)
 	if err != nil {
 		return "", err
 	}
-	addrs, err := iface.Addrs()
+	if name != OVNGatewayInterface && selectHostIP(addrs) == nil && isOVSPort(name) {
+		klog.V(2).Infof("interface %s is a port of Open vSwitch without an address, using the address of %s", name, OVNGatewayInterface)
+		ip, err := GetInterfaceIP(OVNGatewayInterface, preferred)
+		if err != nil {
+			return "", fmt.Errorf("interface %s is a port of Open vSwitch without an address, and %s has none either: %w", name, OVNGatewayInterface, err)
+		}
+		return ip, nil
+	}
+	if ip := tcpnet.ParseIP(preferred); ip != nil && ip.IsGlobalUnicast() {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*tcpnet.IPNet); ok && ipNet.IP.Equal(ip) {
+				return ip.String(), nil
+			}
+		}
+	}
+	ip := selectHostIP(addrs)
+	if ip == nil {
+		return "", fmt.Errorf("interface %s has no address usable as the node IP", name)
+	}
+	return ip.String(), nil
+}
+
+func getOVNGatewayIP() (string, error) {
+	addrs, err := interfaceAddrs(OVNGatewayInterface)
 	if err != nil {
 		return "", err
 	}
+	ip := selectHostIP(addrs)
+	if ip == nil {
+		return "", fmt.Errorf("failed to get ovn gateway IP address")
+	}
+	return ip.String(), nil
+}
+
+// selectHostIP returns the first global unicast IPv4 address, or if there is none,
+// the first global unicast IPv6 address. Link-local addresses are never selected as
+// they are only valid together with a zone and can't be used as the node IP.
+func selectHostIP(addrs []tcpnet.Addr) tcpnet.IP {
+	var ipv6 tcpnet.IP
 	for _, addr := range addrs {
-		ip := addr.(*tcpnet.IPNet).IP
-		if ip.To4() != nil {
-			return ip.To4().String(), nil
-		} else {
-			return ip.String(), nil
+		ipNet, ok := addr.(*tcpnet.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil {
+			return ip
+		}
+		if ipv6 == nil {
+			ipv6 = ipNet.IP
 		}
 	}
-	return "", fmt.Errorf("failed to get ovn gateway IP address")
+	return ipv6
 }
 
 func RetryInsecureHttpsGet(url string) int {
@@ -120,22 +185,31 @@ func CreateLocalhostListenerOnPort(port int) (tcpnet.Listener, error) {
 	return ln, nil
 }
 
+// AddToNoProxyEnv adds the entries to NO_PROXY, merging in the entries of no_proxy,
+// which is unset. Malformed entries of the environment are kept, NO_PROXY is updated
+// anyway, but they are reported in the returned error.
 func AddToNoProxyEnv(additionalEntries ...string) error {
 	entries := map[string]struct{}{}
 
 	// put both the NO_PROXY and no_proxy elements in a map to avoid duplicates
-	addNoProxyEnvVarEntries(entries, "NO_PROXY")
-	addNoProxyEnvVarEntries(entries, "no_proxy")
+	malformed := addNoProxyEnvVarEntries(entries, "NO_PROXY")
+	malformed = append(malformed, addNoProxyEnvVarEntries(entries, "no_proxy")...)
 
 	for _, entry := range additionalEntries {
-		entries[entry] = struct{}{}
+		entries[normalizeNoProxyEntry(entry)] = struct{}{}
 	}
 
 	noProxyEnv := strings.Join(mapKeys(entries), ",")
 
 	// unset the lower-case one, and keep only upper-case
 	os.Unsetenv("no_proxy")
-	return errors.Wrap(os.Setenv("NO_PROXY", noProxyEnv), "error updating NO_PROXY")
+	if err := os.Setenv("NO_PROXY", noProxyEnv); err != nil {
+		return errors.Wrap(err, "error updating NO_PROXY")
+	}
+	if len(malformed) > 0 {
+		return fmt.Errorf("malformed proxy exclusions %s, each entry must be a host or domain name, an IP address or a CIDR, optionally with a port", strings.Join(malformed, ", "))
+	}
+	return nil
 }
 
 func mapKeys(entries map[string]struct{}) []string {
@@ -149,12 +223,60 @@ func mapKeys(entries map[string]struct{}) []string {
 	return keys
 }
 
-func addNoProxyEnvVarEntries(entries map[string]struct{}, envVar string) {
+// addNoProxyEnvVarEntries adds the entries of the environment variable and returns
+// the malformed ones, as they appear in the variable.
+func addNoProxyEnvVarEntries(entries map[string]struct{}, envVar string) []string {
+	malformed := []string{}
 	noProxy := os.Getenv(envVar)
 
 	if noProxy != "" {
 		for _, entry := range strings.Split(noProxy, ",") {
-			entries[strings.Trim(entry, " ")] = struct{}{}
+			// e.g. trailing commas
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			normalized := normalizeNoProxyEntry(entry)
+			if !validNoProxyEntry(normalized) {
+				malformed = append(malformed, fmt.Sprintf("%q in %s", entry, envVar))
+			}
+			entries[normalized] = struct{}{}
 		}
 	}
+	return malformed
+}
+
+// validNoProxyEntry returns whether Go and curl understand the entry, e.g. *,
+// .example.com, example.com:8080, 192.168.1.10 or 10.0.0.0/8.
+func validNoProxyEntry(entry string) bool {
+	if entry == "*" || tcpnet.ParseIP(entry) != nil {
+		return true
+	}
+	if strings.Contains(entry, "/") {
+		_, _, err := tcpnet.ParseCIDR(entry)
+		return err == nil
+	}
+	host := entry
+	if h, _, err := tcpnet.SplitHostPort(entry); err == nil {
+		host = h
+	}
+	if tcpnet.ParseIP(host) != nil {
+		return true
+	}
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "*"), ".")
+	return len(validation.IsDNS1123Subdomain(strings.ToLower(host))) == 0
+}
+
+// normalizeNoProxyEntry returns IP addresses and CIDRs in their canonical form, so that
+// differently formatted IPv6 entries are deduplicated. Brackets are removed from IPv6
+// addresses, Go and curl only match bare addresses in NO_PROXY. Other entries, e.g.
+// domains, are returned trimmed but otherwise unmodified.
+func normalizeNoProxyEntry(entry string) string {
+	entry = strings.TrimSpace(entry)
+	if ip := tcpnet.ParseIP(strings.TrimSuffix(strings.TrimPrefix(entry, "["), "]")); ip != nil {
+		return ip.String()
+	}
+	if _, ipNet, err := tcpnet.ParseCIDR(entry); err == nil {
+		return ipNet.String()
+	}
+	return entry
 }
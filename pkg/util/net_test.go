@@ -1,6 +1,8 @@
 package util
 
 import (
+	"fmt"
+	tcpnet "net"
 	"os"
 	"testing"
 
@@ -50,3 +52,121 @@ func TestAddToNoProxyEnv_with_both(t *testing.T) {
 	assert.Equal(t, "", os.Getenv("no_proxy"), "no_proxy expected to be empty")
 	clearNoProxy()
 }
+
+func TestAddToNoProxyEnv_ipv6(t *testing.T) {
+	os.Setenv("NO_PROXY", "[fd00::1]")
+	os.Setenv("no_proxy", "")
+	AddToNoProxyEnv("fd00:0:0:0:0:0:0:1", "[fd00::10]", "fd01::5/64", ".svc")
+
+	assert.Equal(t, ".svc,fd00::1,fd00::10,fd01::/64", os.Getenv("NO_PROXY"), "NO_PROXY has unexpected value")
+	clearNoProxy()
+}
+
+func TestAddToNoProxyEnv_malformed(t *testing.T) {
+	os.Setenv("NO_PROXY", "my.host.local,10.0.0.0/33,,")
+	os.Setenv("no_proxy", "bad host")
+	err := AddToNoProxyEnv(".svc")
+
+	if assert.Error(t, err, "expected malformed entries to be reported") {
+		assert.Contains(t, err.Error(), `"10.0.0.0/33" in NO_PROXY`)
+		assert.Contains(t, err.Error(), `"bad host" in no_proxy`)
+		assert.NotContains(t, err.Error(), "my.host.local")
+	}
+	// NO_PROXY is updated anyway
+	assert.Equal(t, ".svc,10.0.0.0/33,bad host,my.host.local", os.Getenv("NO_PROXY"), "NO_PROXY has unexpected value")
+	clearNoProxy()
+}
+
+func TestValidNoProxyEntry(t *testing.T) {
+	for _, entry := range []string{"*", "localhost", ".svc", "*.example.com", "example.com:8080", "192.168.1.10", "[fd00::1]:443", "10.0.0.0/8", "fd01::/64"} {
+		assert.True(t, validNoProxyEntry(entry), "expected %q to be valid", entry)
+	}
+	for _, entry := range []string{"bad host", "10.0.0.0/33", "example.com/path", "under_score.com", "-example.com"} {
+		assert.False(t, validNoProxyEntry(entry), "expected %q to be malformed", entry)
+	}
+}
+
+func ipNet(t *testing.T, s string) tcpnet.Addr {
+	ip, n, err := tcpnet.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.IP = ip
+	return n
+}
+
+func TestSelectHostIP(t *testing.T) {
+	var ttests = []struct {
+		name  string
+		addrs []tcpnet.Addr
+		want  string
+	}{
+		{
+			name:  "IPv4 preferred",
+			addrs: []tcpnet.Addr{ipNet(t, "2001:db8::10/64"), ipNet(t, "192.168.1.10/24")},
+			want:  "192.168.1.10",
+		},
+		{
+			name:  "IPv6 only skips link-local",
+			addrs: []tcpnet.Addr{ipNet(t, "fe80::1/64"), ipNet(t, "2001:db8::10/64")},
+			want:  "2001:db8::10",
+		},
+		{
+			name:  "only link-local and loopback",
+			addrs: []tcpnet.Addr{ipNet(t, "fe80::1/64"), ipNet(t, "127.0.0.1/8"), ipNet(t, "169.254.1.1/16")},
+			want:  "<nil>",
+		},
+	}
+
+	for _, tt := range ttests {
+		assert.Equal(t, tt.want, selectHostIP(tt.addrs).String(), tt.name)
+	}
+}
+
+func TestGetInterfaceIP(t *testing.T) {
+	interfaces := map[string][]tcpnet.Addr{
+		"mgmt0": {ipNet(t, "fe80::1/64"), ipNet(t, "192.168.1.10/24"), ipNet(t, "192.168.1.11/24")},
+		"data0": {ipNet(t, "fe80::2/64"), ipNet(t, "2001:db8::10/64")},
+		"lo":    {ipNet(t, "127.0.0.1/8"), ipNet(t, "::1/128")},
+		// configure-ovs.sh moved the address of eth0 to br-ex
+		"eth0":  {ipNet(t, "fe80::3/64")},
+		"br-ex": {ipNet(t, "fe80::3/64"), ipNet(t, "10.0.0.5/24")},
+	}
+	defer func(f func(string) bool) { isOVSPort = f }(isOVSPort)
+	isOVSPort = func(name string) bool { return name == "eth0" }
+	defer func(f func(string) ([]tcpnet.Addr, error)) { interfaceAddrs = f }(interfaceAddrs)
+	interfaceAddrs = func(name string) ([]tcpnet.Addr, error) {
+		addrs, ok := interfaces[name]
+		if !ok {
+			return nil, fmt.Errorf("route ip+net: no such network interface")
+		}
+		return addrs, nil
+	}
+
+	var ttests = []struct {
+		name      string
+		iface     string
+		preferred string
+		want      string
+		wantErr   bool
+	}{
+		{name: "first address", iface: "mgmt0", want: "192.168.1.10"},
+		{name: "preferred address of the interface", iface: "mgmt0", preferred: "192.168.1.11", want: "192.168.1.11"},
+		{name: "preferred address of another interface", iface: "mgmt0", preferred: "2001:db8::10", want: "192.168.1.10"},
+		{name: "skips link-local", iface: "data0", preferred: "fe80::2", want: "2001:db8::10"},
+		{name: "no usable address", iface: "lo", wantErr: true},
+		{name: "missing interface", iface: "eth9", wantErr: true},
+		{name: "port of the OVN gateway bridge", iface: "eth0", want: "10.0.0.5"},
+		{name: "preferred address on the OVN gateway bridge", iface: "eth0", preferred: "10.0.0.5", want: "10.0.0.5"},
+	}
+
+	for _, tt := range ttests {
+		got, err := GetInterfaceIP(tt.iface, tt.preferred)
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
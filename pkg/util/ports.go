@@ -0,0 +1,131 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// tcpListenState is the socket state of listening sockets in /proc/net/tcp
+	tcpListenState = "0A"
+)
+
+// PortInUseError is returned when a port required by a MicroShift component is
+// already bound by another process.
+type PortInUseError struct {
+	Component string
+	Port      int
+	// PID and Command identify the process holding the port, if they could be discovered
+	PID     int
+	Command string
+}
+
+func (e *PortInUseError) Error() string {
+	msg := fmt.Sprintf("port %d required by %s is already in use", e.Port, e.Component)
+	if e.PID > 0 {
+		msg += fmt.Sprintf(" by process %q (pid %d)", e.Command, e.PID)
+	}
+	return msg
+}
+
+// IsPortFree returns whether the TCP port can be bound on all interfaces.
+func IsPortFree(port int) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// WaitForPortFree waits up to timeout for the port to become bindable. If it is still
+// in use after that, a PortInUseError naming the component and, if discoverable, the
+// process holding the port is returned.
+func WaitForPortFree(component string, port int, timeout time.Duration) error {
+	if IsPortFree(port) {
+		return nil
+	}
+	if timeout > 0 {
+		err := wait.PollImmediate(500*time.Millisecond, timeout, func() (bool, error) {
+			return IsPortFree(port), nil
+		})
+		if err == nil {
+			return nil
+		}
+	}
+
+	pid, command := findListeningProcess(port)
+	return &PortInUseError{Component: component, Port: port, PID: pid, Command: command}
+}
+
+// findListeningProcess looks up the process listening on the TCP port via procfs.
+// It returns a zero pid if the process could not be determined.
+func findListeningProcess(port int) (int, string) {
+	inodes := map[string]struct{}{}
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, inode := range listeningSocketInodes(table, port) {
+			inodes[inode] = struct{}{}
+		}
+	}
+	if len(inodes) == 0 {
+		return 0, ""
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		if _, ok := inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")]; !ok {
+			continue
+		}
+		pidDir := filepath.Dir(filepath.Dir(fd))
+		pid, err := strconv.Atoi(filepath.Base(pidDir))
+		if err != nil {
+			continue
+		}
+		comm, _ := os.ReadFile(filepath.Join(pidDir, "comm"))
+		return pid, strings.TrimSpace(string(comm))
+	}
+	return 0, ""
+}
+
+// listeningSocketInodes returns the inodes of listening sockets bound to the port in
+// a /proc/net/tcp formatted table.
+func listeningSocketInodes(table string, port int) []string {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	inodes := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListenState {
+			continue
+		}
+		localAddress := fields[1]
+		i := strings.LastIndex(localAddress, ":")
+		if i < 0 {
+			continue
+		}
+		localPort, err := strconv.ParseInt(localAddress[i+1:], 16, 32)
+		if err != nil || int(localPort) != port {
+			continue
+		}
+		inodes = append(inodes, fields[9])
+	}
+	return inodes
+}
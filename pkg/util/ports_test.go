@@ -0,0 +1,56 @@
+package util
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listenOnFreePort(t *testing.T) (net.Listener, int) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	return ln, ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestWaitForPortFree_free(t *testing.T) {
+	ln, port := listenOnFreePort(t)
+	ln.Close()
+
+	assert.True(t, IsPortFree(port), "port %d expected to be free", port)
+	assert.NoError(t, WaitForPortFree("etcd", port, 0))
+}
+
+func TestWaitForPortFree_occupied(t *testing.T) {
+	ln, port := listenOnFreePort(t)
+	defer ln.Close()
+
+	assert.False(t, IsPortFree(port), "port %d expected to be in use", port)
+
+	err := WaitForPortFree("etcd", port, time.Second)
+	var portErr *PortInUseError
+	if !errors.As(err, &portErr) {
+		t.Fatalf("expected PortInUseError, got %v", err)
+	}
+	assert.Equal(t, "etcd", portErr.Component)
+	assert.Equal(t, port, portErr.Port)
+	if _, err := os.Stat("/proc/net/tcp"); err == nil {
+		assert.Equal(t, os.Getpid(), portErr.PID, "expected the test process to be identified as the listener")
+	}
+	assert.Contains(t, err.Error(), "is already in use")
+}
+
+func TestWaitForPortFree_released(t *testing.T) {
+	ln, port := listenOnFreePort(t)
+	go func() {
+		time.Sleep(time.Second)
+		ln.Close()
+	}()
+
+	assert.NoError(t, WaitForPortFree("etcd", port, 10*time.Second))
+}